@@ -15,6 +15,7 @@ import (
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/forwarder/interceptor"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tlsutil"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tracing"
 )
 
 const (
@@ -81,6 +82,15 @@ func (cfg *Config) Setup() (cmd.Starter, error) {
 		cfg.tlsConfig = &tlsConfig
 	}
 
+	// Tracing is enabled when cloud-api-adaptor exports traces
+	if cfg.daemonConfig.Tracing.Enabled() {
+		tracingService, err := tracing.NewService(cfg.daemonConfig.Tracing, programName)
+		if err != nil {
+			return nil, fmt.Errorf("setting up tracing: %w", err)
+		}
+		services = append(services, tracingService)
+	}
+
 	interceptor := interceptor.NewInterceptor(cfg.kataAgentSocketPath, cfg.podNamespace)
 
 	podNode := podnetwork.NewPodNode(cfg.podNamespace, cfg.HostInterface, cfg.daemonConfig.PodNetwork)
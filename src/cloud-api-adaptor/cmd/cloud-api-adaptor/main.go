@@ -9,19 +9,30 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/cmd"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/cloud"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/hostpolicy"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/k8sops"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/proxy"
 	daemon "github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/forwarder"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/initdata"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler/geneve"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler/routed"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler/vxlan"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler/wireguard"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/attestation"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/audit"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tlsconfig"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tlsutil"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tracing"
 	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+	putil "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util"
 
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/probe"
@@ -34,6 +45,8 @@ const (
 type daemonConfig struct {
 	serverConfig  cloud.ServerConfig
 	networkConfig tunneler.NetworkConfig
+	tracingConfig tracing.Config
+	probeHost     string
 }
 
 func formatTLSWarnings(tlsConfig *tlsutil.TLSConfig, disableTLS bool, tlsCipherSuites string) []string {
@@ -101,9 +114,21 @@ func (cfg *daemonConfig) Setup() (cmd.Starter, error) {
 	}
 
 	var (
-		disableTLS      bool
-		tlsConfig       tlsutil.TLSConfig
-		tlsCipherSuites string
+		disableTLS        bool
+		tlsConfig         tlsutil.TLSConfig
+		tlsCipherSuites   string
+		caSecret          string
+		caDir             string
+		dialerConfig      putil.DialerConfig
+		verifierSpec      string
+		auditSink         string
+		auditLevels       string
+		auditKeyFile      string
+		hostPolicyFile    string
+		configFile        string
+		printConfig       bool
+		printConfigSchema bool
+		reg               *provider.FlagRegistrar
 	)
 
 	cmd.Parse(programName, os.Args[1:], func(flags *flag.FlagSet) {
@@ -114,9 +139,11 @@ func (cfg *daemonConfig) Setup() (cmd.Starter, error) {
 			flags.PrintDefaults()
 		}
 
-		reg := provider.NewFlagRegistrar(flags)
+		reg = provider.NewFlagRegistrar(flags)
+		reg.StringWithEnv(&configFile, "config", "", "CONFIG_FILE", "YAML configuration file. Flags and environment variables override its settings", provider.CommandLineOnly())
 
 		// Common flags with environment variable support
+		reg.SetSection("server")
 		reg.StringWithEnv(&cfg.serverConfig.SocketPath, "socket", adaptor.DefaultSocketPath, "REMOTE_HYPERVISOR_ENDPOINT", "Unix domain socket path of remote hypervisor service")
 		reg.StringWithEnv(&cfg.serverConfig.PodsDir, "pods-dir", adaptor.DefaultPodsDir, "PODS_DIR", "base directory for pod directories")
 		reg.StringWithEnv(&cfg.serverConfig.PauseImage, "pause-image", "", "PAUSE_IMAGE", "pause image to be used for the pods")
@@ -127,24 +154,81 @@ func (cfg *daemonConfig) Setup() (cmd.Starter, error) {
 		reg.BoolWithEnv(&tlsConfig.SkipVerify, "tls-skip-verify", false, "TLS_SKIP_VERIFY", "Skip TLS certificate verification - use it only for testing")
 		reg.StringWithEnv(&tlsConfig.MinTLSVersion, "tls-min-version", "", "TLS_MIN_VERSION", "Minimum TLS version for peer pod connections (VersionTLS12 or VersionTLS13)")
 		reg.StringWithEnv(&tlsCipherSuites, "tls-cipher-suites", "", "TLS_CIPHER_SUITES", "Comma-separated IANA TLS cipher suite names for peer pod connections (not applicable for VersionTLS13)")
+		reg.StringWithEnv(&caSecret, "ca-secret", "", "CA_SECRET", "Kubernetes Secret (<namespace>/<name>) to load the auto-generated CA and client certificates from, created if missing")
+		reg.StringWithEnv(&caDir, "ca-dir", "", "CA_DIR", "Directory to load the auto-generated CA and client certificates from, created if missing")
 		reg.DurationWithEnv(&cfg.serverConfig.ProxyTimeout, "proxy-timeout", proxy.DefaultProxyTimeout, "PROXY_TIMEOUT", "Maximum timeout in minutes for establishing agent proxy connection")
-		reg.StringWithEnv(&cfg.networkConfig.TunnelType, "tunnel-type", podnetwork.DefaultTunnelType, "TUNNEL_TYPE", "Tunnel provider")
-		reg.IntWithEnv(&cfg.networkConfig.VXLAN.Port, "vxlan-port", vxlan.DefaultVXLANPort, "VXLAN_PORT", "VXLAN UDP port number (VXLAN tunnel mode only")
-		reg.StringWithEnv(&cfg.serverConfig.Initdata, "initdata", "", "INITDATA", "Default initdata for all Pods")
+		reg.StringWithEnv(&cfg.serverConfig.ReverseConnectAddr, "reverse-connect-addr", "", "REVERSE_CONNECT_ADDR", "host:port of the rendezvous listener of this node that pod VMs dial to in reverse connect mode. The node IP in NODE_IP is used if the host is empty, as in :15151. Reverse connect mode is disabled if empty")
+		reg.StringWithEnv(&cfg.serverConfig.RendezvousListenAddr, "rendezvous-listen-addr", proxy.DefaultRendezvousListenAddr, "RENDEZVOUS_LISTEN_ADDR", "Listen address of the rendezvous listener for reverse connect mode")
+		reg.StringWithEnv(&dialerConfig.Chain, "agent-dialer", "", "AGENT_DIALER", "Comma separated chain of socks5://, http:// (HTTP CONNECT) or ssh:// (jump host) proxy URLs to dial pod VMs through. Pod VMs are dialed directly if empty", provider.Secret())
+		reg.StringWithEnv(&dialerConfig.SSHPrivateKeyPath, "agent-dialer-ssh-key", "", "AGENT_DIALER_SSH_KEY_PATH", "SSH private key file for ssh:// hops of the agent dialer")
+		reg.StringWithEnv(&dialerConfig.SSHHostKeyAllowlistDir, "agent-dialer-ssh-host-key-allowlist-dir", "", "AGENT_DIALER_SSH_HOST_KEY_ALLOWLIST_DIR", "Directory containing allowed SSH host key files of the jump hosts of the agent dialer (required for ssh:// hops)")
+		reg.StringWithEnv(&verifierSpec, "attestation-verifier", "", "ATTESTATION_VERIFIER", "URL of the verifier of TEE evidence in the certificates of pod VMs, or \"sample\" for testing. Attested TLS is disabled if empty")
+		reg.StringWithEnv(&auditSink, "audit-sink", "", "AUDIT_SINK", "Where to record agent requests forwarded to pod VMs: file (audit.jsonl in the pods directory) or a webhook URL. Auditing is disabled if empty")
+		reg.StringWithEnv(&auditLevels, "audit-levels", "", "AUDIT_LEVELS", "Comma separated audit levels (none, metadata or request) of agent requests as <method>=<level>, where * is any other method (default *=metadata)")
+		reg.StringWithEnv(&auditKeyFile, "audit-key-file", "", "AUDIT_KEY_FILE", "File with the secret HMAC key (at least 32 bytes) that chains audit records, kept outside the audit log. Required if AUDIT_SINK is set")
+		reg.StringWithEnv(&hostPolicyFile, "host-policy", "", "HOST_POLICY_FILE", "YAML file of rules that allow or deny agent requests to pod VMs on the worker node. All requests are forwarded if empty")
+		reg.StringWithEnv(&cfg.serverConfig.Initdata, "initdata", "", "INITDATA", "Default initdata for all Pods", provider.Secret())
+		reg.StringWithEnv(&cfg.serverConfig.DefaultPolicyConfigMap, "default-policy-configmap", "", "DEFAULT_POLICY_CONFIGMAP", "ConfigMap (<namespace>/<name>) of default kata agent policies, which are added to the initdata of pods without a policy. Disabled if empty")
 		reg.BoolWithEnv(&cfg.serverConfig.EnableCloudConfigVerify, "cloud-config-verify", false, "CLOUD_CONFIG_VERIFY", "Enable cloud config verify - should use it for production")
 		reg.IntWithEnv(&cfg.serverConfig.PeerPodsLimitPerNode, "peerpods-limit-per-node", 10, "PEERPODS_LIMIT_PER_NODE", "peer pods limit per node (default=10)")
 		reg.BoolWithEnv(&cfg.serverConfig.EnableScratchSpace, "enable-scratch-space", false, "ENABLE_SCRATCH_SPACE", "Enable encrypted scratch space for pod VMs")
-		reg.BoolWithEnv(&cfg.networkConfig.ExternalNetViaPodVM, "ext-network-via-podvm", false, "EXTERNAL_NETWORK_VIA_PODVM", "[EXPERIMENTAL] Enable external networking via pod VM")
-		reg.CustomTypeWithEnv(&cfg.networkConfig.PodSubnetCIDRs, "pod-subnet-cidrs", "", "POD_SUBNET_CIDRS", "[EXPERIMENTAL] Comma separated CIDRs for local pod subnets")
+		reg.CustomTypeWithEnv(&cfg.serverConfig.WarmPool, "warm-pool", "", "WARM_POOL", "[EXPERIMENTAL] Comma separated numbers of pre-booted pod VMs to keep per instance type and image, as [<instance type>[:<image>]=]<size>")
+		reg.StringWithEnv(&cfg.tracingConfig.Exporter, "tracing-exporter", "", "TRACING_EXPORTER", "Exporter of OpenTelemetry traces (otlp or stdout). Tracing is disabled if empty")
+		reg.StringWithEnv(&cfg.tracingConfig.Endpoint, "tracing-endpoint", "", "TRACING_ENDPOINT", "host:port of the OTLP gRPC trace collector. It must be reachable from pod VMs as well")
+		reg.BoolWithEnv(&cfg.tracingConfig.Insecure, "tracing-insecure", false, "TRACING_INSECURE", "Connect to the OTLP trace collector without TLS")
+		reg.StringWithEnv(&cfg.probeHost, "probe-host", probe.DefaultProbeHost, "PROBE_HOST", "Listen address of the probe and metrics server. Set it to 0.0.0.0 to scrape metrics from outside the worker node")
 
 		// Flags without environment variable support
-		flags.BoolVar(&disableTLS, "disable-tls", false, "Disable TLS encryption - use it only for testing")
-		flags.StringVar(&cfg.networkConfig.HostInterface, "host-interface", "", "Host Interface")
-		flags.IntVar(&cfg.networkConfig.VXLAN.MinID, "vxlan-min-id", vxlan.DefaultVXLANMinID, "Minimum VXLAN ID (VXLAN tunnel mode only")
+		reg.BoolWithEnv(&disableTLS, "disable-tls", false, "", "Disable TLS encryption - use it only for testing")
+		flags.BoolVar(&printConfig, "print-config", false, "Print the effective configuration with secrets masked, check required options and exit")
+		flags.BoolVar(&printConfigSchema, "print-config-schema", false, "Print the JSON Schema of the configuration file and exit")
+
+		reg.SetSection("network")
+		reg.StringWithEnv(&cfg.networkConfig.TunnelType, "tunnel-type", podnetwork.DefaultTunnelType, "TUNNEL_TYPE", "Tunnel provider (vxlan, geneve, wireguard or routed)")
+		reg.IntWithEnv(&cfg.networkConfig.VXLAN.Port, "vxlan-port", vxlan.DefaultVXLANPort, "VXLAN_PORT", "VXLAN UDP port number (VXLAN tunnel mode only")
+		reg.IntWithEnv(&cfg.networkConfig.Geneve.Port, "geneve-port", geneve.DefaultGenevePort, "GENEVE_PORT", "Geneve UDP port number (Geneve tunnel mode only)")
+		reg.IntWithEnv(&cfg.networkConfig.WireGuard.Port, "wireguard-port", wireguard.DefaultWireGuardPort, "WIREGUARD_PORT", "Base WireGuard UDP port number. The pod index is added to it (WireGuard tunnel mode only)")
+		reg.BoolWithEnv(&cfg.networkConfig.ExternalNetViaPodVM, "ext-network-via-podvm", false, "EXTERNAL_NETWORK_VIA_PODVM", "[EXPERIMENTAL] Enable external networking via pod VM")
+		reg.CustomTypeWithEnv(&cfg.networkConfig.PodSubnetCIDRs, "pod-subnet-cidrs", "", "POD_SUBNET_CIDRS", "[EXPERIMENTAL] Comma separated CIDRs for local pod subnets")
+		reg.StringWithEnv(&cfg.networkConfig.HostInterface, "host-interface", "", "", "Host Interface")
+		reg.IntWithEnv(&cfg.networkConfig.VXLAN.MinID, "vxlan-min-id", vxlan.DefaultVXLANMinID, "", "Minimum VXLAN ID (VXLAN tunnel mode only")
+		reg.IntWithEnv(&cfg.networkConfig.Geneve.MinID, "geneve-min-id", geneve.DefaultGeneveMinID, "", "Minimum Geneve VNI (Geneve tunnel mode only)")
+		reg.CustomTypeWithEnv(&cfg.networkConfig.Geneve.Options, "geneve-options", "", "GENEVE_OPTIONS", "Comma separated TLV options of Geneve packets, as <class>:<type>:<data> in hex. Each pod uses the Geneve port plus its index if set (Geneve tunnel mode only)")
+		reg.StringWithEnv(&cfg.networkConfig.FirewallBackend, "firewall-backend", netops.FirewallBackendAuto, "FIREWALL_BACKEND", "Firewall backend for tunnel host rules (auto, iptables or nftables)")
 
 		cloud.ParseCmd(flags)
 	})
 
+	if printConfigSchema {
+		schema, err := reg.Schema()
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println(string(schema))
+		cmd.Exit(0)
+	}
+
+	if configFile != "" {
+		if err := reg.LoadConfigFile(configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if printConfig {
+		if err := reg.Dump(os.Stdout); err != nil {
+			return nil, err
+		}
+		if err := reg.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", programName, err)
+			cmd.Exit(1)
+		}
+		cmd.Exit(0)
+	}
+
+	if err := reg.Validate(); err != nil {
+		return nil, err
+	}
+
 	cmd.ShowVersion(programName)
 
 	fmt.Printf("%s: starting Cloud API Adaptor daemon for %q\n", programName, cloudName)
@@ -159,6 +243,84 @@ func (cfg *daemonConfig) Setup() (cmd.Starter, error) {
 		tlsConfigPtr = &tlsConfig
 	}
 
+	if caSecret != "" && caDir != "" {
+		return nil, fmt.Errorf("--ca-secret and --ca-dir are mutually exclusive")
+	}
+
+	if !disableTLS && caSecret != "" {
+		store, err := k8sops.NewSecretCredentialStore(caSecret)
+		if err != nil {
+			return nil, fmt.Errorf("setting up credential store: %w", err)
+		}
+		cfg.serverConfig.CredentialStore = store
+	} else if !disableTLS && caDir != "" {
+		cfg.serverConfig.CredentialStore = tlsutil.NewFileCredentialStore(caDir)
+	}
+
+	if cfg.serverConfig.ReverseConnectAddr != "" {
+		if disableTLS || tlsConfig.HasCA() {
+			return nil, fmt.Errorf("reverse connect mode requires TLS with certificates generated by cloud-api-adaptor")
+		}
+		addr, err := proxy.ResolveReverseConnectAddr(cfg.serverConfig.ReverseConnectAddr, os.Getenv("NODE_IP"))
+		if err != nil {
+			return nil, err
+		}
+		cfg.serverConfig.ReverseConnectAddr = addr
+	}
+
+	if dialerConfig.Chain != "" {
+		if cfg.serverConfig.ReverseConnectAddr != "" {
+			return nil, fmt.Errorf("--agent-dialer cannot be used in reverse connect mode")
+		}
+		dialerConfig.Timeout = cfg.serverConfig.ProxyTimeout
+		dialer, err := putil.NewChainDialer(&dialerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("setting up agent dialer: %w", err)
+		}
+		cfg.serverConfig.AgentDialer = dialer
+	}
+
+	if verifierSpec != "" {
+		if disableTLS {
+			return nil, fmt.Errorf("attested TLS requires TLS")
+		}
+		if cfg.serverConfig.ReverseConnectAddr != "" {
+			return nil, fmt.Errorf("attested TLS cannot be used in reverse connect mode")
+		}
+		verifier, err := attestation.NewVerifier(verifierSpec)
+		if err != nil {
+			return nil, fmt.Errorf("setting up attestation verifier: %w", err)
+		}
+		cfg.serverConfig.AttestationVerifier = verifier
+	}
+
+	if auditSink != "" {
+		levels, err := audit.ParseLevels(auditLevels)
+		if err != nil {
+			return nil, fmt.Errorf("invalid audit levels: %w", err)
+		}
+		if auditKeyFile == "" {
+			return nil, fmt.Errorf("AUDIT_KEY_FILE must be set to record agent requests")
+		}
+		key, err := audit.LoadKey(auditKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		auditor, err := audit.New(auditSink, cfg.serverConfig.PodsDir, key, levels)
+		if err != nil {
+			return nil, fmt.Errorf("setting up audit sink: %w", err)
+		}
+		cfg.serverConfig.Auditor = auditor
+	}
+
+	if hostPolicyFile != "" {
+		policy, err := hostpolicy.Load(hostPolicyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.serverConfig.HostPolicy = policy
+	}
+
 	for _, w := range formatTLSWarnings(tlsConfigPtr, disableTLS, tlsCipherSuites) {
 		fmt.Printf("%s: WARNING: %s\n", programName, w)
 	}
@@ -169,21 +331,38 @@ func (cfg *daemonConfig) Setup() (cmd.Starter, error) {
 		}
 	}
 
+	var services []cmd.Service
+
+	if cfg.tracingConfig.Enabled() {
+		tracingService, err := tracing.NewService(&cfg.tracingConfig, programName)
+		if err != nil {
+			return nil, fmt.Errorf("setting up tracing: %w", err)
+		}
+		cfg.serverConfig.Tracing = &cfg.tracingConfig
+		services = append(services, tracingService)
+	}
+
 	// DEPRECATED: LoadEnv() is now a no-op for all providers.
 	// Environment variables are loaded during ParseCmd() via FlagRegistrar.
 	// This call will be removed in a future release.
 	cloud.LoadEnv()
 
+	cfg.networkConfig.PodIndexFile = filepath.Join(cfg.serverConfig.PodsDir, podnetwork.PodIndexFile)
+
 	workerNode, err := podnetwork.NewWorkerNode(&cfg.networkConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	provider, err := cloud.NewProvider()
+	cloudProvider, err := cloud.NewProvider()
 	if err != nil {
 		return nil, err
 	}
 
+	if _, ok := cloudProvider.(provider.PodIPAssigner); !ok && cfg.networkConfig.TunnelType == routed.TunnelType {
+		return nil, fmt.Errorf("%s tunnel type is not supported by cloud provider %s", routed.TunnelType, cloudName)
+	}
+
 	if cfg.serverConfig.Initdata != "" {
 		idReader := strings.NewReader(cfg.serverConfig.Initdata)
 		_, err = initdata.Parse(idReader)
@@ -192,9 +371,15 @@ func (cfg *daemonConfig) Setup() (cmd.Starter, error) {
 		}
 	}
 
-	server := adaptor.NewServer(provider, &cfg.serverConfig, workerNode)
+	cfg.serverConfig.CloudProvider = cloudName
+
+	server, err := adaptor.NewServer(cloudProvider, &cfg.serverConfig, workerNode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server: %w", err)
+	}
+	services = append(services, server)
 
-	return cmd.NewStarter(server), nil
+	return cmd.NewStarter(services...), nil
 }
 
 var config = &daemonConfig{}
@@ -209,7 +394,7 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go probe.Start(ctx, config.serverConfig.SocketPath)
+	go probe.Start(ctx, config.serverConfig.SocketPath, config.probeHost)
 
 	if err := starter.Start(ctx); err != nil {
 		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], err)
@@ -10,22 +10,34 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/netip"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/containerd/containerd/pkg/cri/annotations"
 	pb "github.com/kata-containers/kata-containers/src/runtime/protocols/hypervisor"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	v1 "k8s.io/api/core/v1"
 
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/hostpolicy"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/k8sops"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/metrics"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/proxy"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/forwarder"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/paths"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler/routed"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/attestation"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/audit"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tlsutil"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tracing"
 	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
 	putil "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util/cloudinit"
@@ -36,21 +48,44 @@ const (
 )
 
 type ServerConfig struct {
+	CloudProvider           string
 	TLSConfig               *tlsutil.TLSConfig
+	CredentialStore         tlsutil.CredentialStore
 	SocketPath              string
 	PauseImage              string
 	PodsDir                 string
 	ForwarderPort           string
 	ProxyTimeout            time.Duration
+	ReverseConnectAddr      string
+	RendezvousListenAddr    string
+	AgentDialer             putil.ContextDialer
+	AttestationVerifier     attestation.Verifier
+	Auditor                 *audit.Auditor
+	HostPolicy              hostpolicy.Policy
 	Initdata                string
+	DefaultPolicyConfigMap  string
 	EnableCloudConfigVerify bool
 	PeerPodsLimitPerNode    int
 	RootVolumeSize          int
 	EnableScratchSpace      bool
+	WarmPool                WarmPoolSpecs
+	Tracing                 *tracing.Config
+}
+
+// Redact returns a copy of the server config without secrets, for logging
+func (c ServerConfig) Redact() *ServerConfig {
+	return putil.RedactStruct(&c, "TLSConfig.KeyData", "Initdata").(*ServerConfig)
 }
 
 var logger = log.New(log.Writer(), "[adaptor/cloud] ", log.LstdFlags|log.Lmsgprefix)
 
+// Creation of an instance is retried when the cloud is temporarily out of capacity.
+// Other errors are returned immediately, since the kata shim waits for StartVM.
+var (
+	createInstanceRetries       = 2
+	createInstanceRetryInterval = 15 * time.Second
+)
+
 func (s *cloudService) addSandbox(sid sandboxID, sandbox *sandbox) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -60,6 +95,7 @@ func (s *cloudService) addSandbox(sid sandboxID, sandbox *sandbox) error {
 	}
 
 	s.sandboxes[sid] = sandbox
+	s.updateSandboxMetrics()
 
 	return nil
 }
@@ -84,6 +120,7 @@ func (s *cloudService) removeSandbox(id sandboxID) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	delete(s.sandboxes, sid)
+	s.updateSandboxMetrics()
 	return nil
 }
 
@@ -92,22 +129,188 @@ func NewService(provider provider.Provider, proxyFactory proxy.Factory, workerNo
 	var err error
 
 	s := &cloudService{
-		provider:     provider,
+		provider:     newInstrumentedProvider(provider, serverConfig.CloudProvider),
 		proxyFactory: proxyFactory,
 		sandboxes:    map[sandboxID]*sandbox{},
 		serverConfig: serverConfig,
 		workerNode:   workerNode,
+		store:        newSandboxStore(serverConfig.PodsDir),
 	}
 	s.cond = sync.NewCond(&s.mutex)
 	s.ppService, err = k8sops.NewPeerPodService()
 	if err != nil {
 		logger.Printf("failed to create PeerPodService, runtime failure may result in dangling resources %s", err)
 	}
+	if events, err := k8sops.NewPodEventRecorder(); err != nil {
+		logger.Printf("failed to create PodEventRecorder, events are not recorded on pods: %v", err)
+	} else {
+		s.events = events
+	}
+	if (serverConfig.HostPolicy != nil && serverConfig.HostPolicy.UsesLabels()) || serverConfig.DefaultPolicyConfigMap != "" {
+		if labels, err := k8sops.NewLabelLookup(); err != nil {
+			logger.Printf("failed to create LabelLookup, policies that refer to labels cannot be applied to pods: %v", err)
+		} else {
+			s.labels = labels
+		}
+	}
+	if serverConfig.DefaultPolicyConfigMap != "" {
+		if reader, err := k8sops.NewConfigMapReader(serverConfig.DefaultPolicyConfigMap); err != nil {
+			logger.Printf("failed to create ConfigMapReader, pods without a policy fail to start: %v", err)
+		} else {
+			s.defaultPolicies = reader
+		}
+	}
+
+	s.restoreSandboxes()
+
+	if len(serverConfig.WarmPool) > 0 {
+		if err := s.startWarmPool(); err != nil {
+			logger.Printf("warm pool is disabled: %v", err)
+		}
+	}
 
 	return s
 }
 
+func (s *cloudService) startWarmPool() error {
+	tlsConfig := s.serverConfig.TLSConfig
+	if tlsConfig == nil {
+		return errors.New("cloud configs can only be delivered to warm pool VMs over TLS")
+	}
+
+	// Warm pool VMs are not associated with pods yet. An agent proxy is created just to access the credentials.
+	agentProxy := s.proxyFactory.New(putil.WarmPoolPodName, "", nil)
+	caService, clientCA := agentProxy.CAService(), agentProxy.ClientCA()
+	if caService == nil || clientCA == nil {
+		return errors.New("warm pool VMs require TLS certificates generated by cloud-api-adaptor")
+	}
+	if s.serverConfig.ReverseConnectAddr != "" {
+		return errors.New("warm pool VMs cannot be provisioned in reverse connect mode")
+	}
+	if s.serverConfig.AttestationVerifier != nil {
+		return errors.New("warm pool VMs cannot be provisioned in attested TLS mode")
+	}
+
+	provisioner := &httpsProvisioner{
+		tlsConfig:     tlsConfig,
+		forwarderPort: s.serverConfig.ForwarderPort,
+		dialer:        s.serverConfig.AgentDialer,
+	}
+	bootstrap := newWarmPoolBootstrap(caService, clientCA, tlsConfig, s.serverConfig.ForwarderPort)
+	statePath := filepath.Join(s.serverConfig.PodsDir, WarmPoolStateFile)
+
+	s.warmPool = newWarmPool(s.serverConfig.WarmPool, s.provider, provisioner, bootstrap, statePath)
+	if k8sops.IsKubernetesEnvironment() {
+		s.warmPool.publish = func(ctx context.Context, instanceIDs []string) error {
+			return k8sops.AnnotateNode(ctx, putil.WarmPoolAnnotation, strings.Join(instanceIDs, ","))
+		}
+	}
+	s.warmPool.Start()
+
+	logger.Printf("started warm pool: %s", s.serverConfig.WarmPool.String())
+
+	return nil
+}
+
+// restoreSandboxes re-adopts pod VMs recorded by a previous cloud-api-adaptor process,
+// and restarts their agent proxies without recreating the VMs
+func (s *cloudService) restoreSandboxes() {
+	states, err := s.store.List()
+	if err != nil {
+		logger.Printf("failed to load sandbox states: %v", err)
+	}
+
+	for _, state := range states {
+		if state.InstanceID == "" || len(state.IPs) == 0 {
+			logger.Printf("sandbox %s has no running instance, discarding its state", state.ID)
+			if err := s.store.Delete(state.ID); err != nil {
+				logger.Printf("removing state of sandbox %s: %v", state.ID, err)
+			}
+			continue
+		}
+
+		// The pod network namespace disappears when the pod is deleted while cloud-api-adaptor is down.
+		// The PeerPod object owned by the deleted pod takes care of deleting the instance in that case.
+		if _, err := os.Stat(state.NetNSPath); err != nil {
+			logger.Printf("netns %s of sandbox %s is not available (%v), discarding its state", state.NetNSPath, state.ID, err)
+			if err := s.store.Delete(state.ID); err != nil {
+				logger.Printf("removing state of sandbox %s: %v", state.ID, err)
+			}
+			continue
+		}
+
+		if state.PodNetwork != nil {
+			state.PodNetwork.WireGuardWorkerNodeKey = state.WireGuardWorkerNodeKey
+		}
+
+		socketPath := filepath.Join(s.serverConfig.PodsDir, string(state.ID), proxy.SocketName)
+
+		sandbox := &sandbox{
+			id:           state.ID,
+			podName:      state.PodName,
+			podNamespace: state.PodNamespace,
+			netNSPath:    state.NetNSPath,
+			serverName:   state.ServerName,
+			agentProxy:   s.proxyFactory.New(state.ServerName, socketPath, s.requestFilter(state.PodNamespace, state.PodName, nil)),
+			podNetwork:   state.PodNetwork,
+			instanceID:   state.InstanceID,
+			instanceName: state.InstanceName,
+			instanceIPs:  state.IPs,
+		}
+
+		if err := s.addSandbox(state.ID, sandbox); err != nil {
+			logger.Printf("restoring sandbox %s: %v", state.ID, err)
+			continue
+		}
+
+		serverURL := s.forwarderURL(state.IPs[0])
+
+		go func() {
+			if err := sandbox.agentProxy.Start(context.Background(), serverURL); err != nil {
+				logger.Printf("error running agent proxy of restored sandbox %s: %v", sandbox.id, err)
+			}
+		}()
+
+		logger.Printf("restored sandbox %s for pod %s in namespace %s (instance: %s)", state.ID, state.PodName, state.PodNamespace, state.InstanceID)
+	}
+}
+
+func (s *cloudService) saveSandbox(sandbox *sandbox) error {
+	s.mutex.Lock()
+	state := &sandboxState{
+		ID:           sandbox.id,
+		PodName:      sandbox.podName,
+		PodNamespace: sandbox.podNamespace,
+		NetNSPath:    sandbox.netNSPath,
+		ServerName:   sandbox.serverName,
+		PodNetwork:   sandbox.podNetwork,
+		InstanceID:   sandbox.instanceID,
+		InstanceName: sandbox.instanceName,
+		IPs:          sandbox.instanceIPs,
+	}
+	if sandbox.podNetwork != nil {
+		state.WireGuardWorkerNodeKey = sandbox.podNetwork.WireGuardWorkerNodeKey
+	}
+	s.mutex.Unlock()
+
+	return s.store.Save(state)
+}
+
+func (s *cloudService) forwarderURL(instanceIP netip.Addr) *url.URL {
+	return &url.URL{
+		Scheme: "http",
+		Host:   net.JoinHostPort(instanceIP.String(), s.serverConfig.ForwarderPort),
+		Path:   forwarder.AgentURLPath,
+	}
+}
+
 func (s *cloudService) Teardown() error {
+	if s.warmPool != nil {
+		s.warmPool.Drain()
+	}
+	if s.events != nil {
+		s.events.Shutdown()
+	}
 	return s.provider.Teardown()
 }
 
@@ -115,7 +318,7 @@ func (s *cloudService) ConfigVerifier() error {
 	return s.provider.ConfigVerifier()
 }
 
-func (s *cloudService) setInstance(sid sandboxID, instanceID, instanceName string) error {
+func (s *cloudService) setInstance(sid sandboxID, instanceID, instanceName string, instanceIPs []netip.Addr) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -126,6 +329,7 @@ func (s *cloudService) setInstance(sid sandboxID, instanceID, instanceName strin
 
 	sandbox.instanceID = instanceID
 	sandbox.instanceName = instanceName
+	sandbox.instanceIPs = instanceIPs
 
 	s.cond.Broadcast()
 
@@ -162,10 +366,12 @@ func (s *cloudService) Version(ctx context.Context, req *pb.VersionRequest) (*pb
 }
 
 func (s *cloudService) CreateVM(ctx context.Context, req *pb.CreateVMRequest) (res *pb.CreateVMResponse, err error) {
+	ctx, span := tracing.Start(ctx, "CreateVM", attribute.String("sandbox.id", req.Id))
 	defer func() {
 		if err != nil {
 			logger.Print(err)
 		}
+		tracing.End(span, err)
 	}()
 
 	sid := sandboxID(req.Id)
@@ -184,6 +390,16 @@ func (s *cloudService) CreateVM(ctx context.Context, req *pb.CreateVMRequest) (r
 		return nil, fmt.Errorf("namespace name %s is missing in annotations", annotations.SandboxNamespace)
 	}
 
+	span.SetAttributes(attribute.String("k8s.pod.name", pod), attribute.String("k8s.namespace.name", namespace))
+
+	var podRef *v1.ObjectReference
+	if s.events != nil {
+		var refErr error
+		if podRef, refErr = s.events.PodReference(ctx, namespace, pod); refErr != nil {
+			logger.Printf("events are not recorded on pod %s/%s: %v", namespace, pod, refErr)
+		}
+	}
+
 	// Get Pod VM instance type from annotations
 	instanceType := util.GetInstanceTypeFromAnnotation(req.Annotations)
 
@@ -225,18 +441,20 @@ func (s *cloudService) CreateVM(ctx context.Context, req *pb.CreateVMRequest) (r
 	serverName := putil.GenerateInstanceName(pod, string(sid), 63)
 
 	podDir := filepath.Join(s.serverConfig.PodsDir, string(sid))
-	if err := os.MkdirAll(podDir, os.ModePerm); err != nil {
+	if err := os.MkdirAll(podDir, 0o700); err != nil {
 		return nil, fmt.Errorf("creating a pod directory: %s, %w", podDir, err)
 	}
 	socketPath := filepath.Join(podDir, proxy.SocketName)
 
-	agentProxy := s.proxyFactory.New(serverName, socketPath)
+	agentProxy := s.proxyFactory.New(serverName, socketPath, s.requestFilter(namespace, pod, podRef))
 
 	daemonConfig := forwarder.Config{
 		PodNamespace: namespace,
 		PodName:      pod,
 		PodNetwork:   podNetworkConfig,
 		TLSClientCA:  string(agentProxy.ClientCA()),
+
+		ReverseConnectAddr: s.serverConfig.ReverseConnectAddr,
 	}
 
 	if s.serverConfig.TLSConfig != nil {
@@ -246,7 +464,15 @@ func (s *cloudService) CreateVM(ctx context.Context, req *pb.CreateVMRequest) (r
 		daemonConfig.CipherSuites = s.serverConfig.TLSConfig.CipherSuites
 	}
 
-	if caService := agentProxy.CAService(); caService != nil {
+	if s.serverConfig.Tracing.Enabled() {
+		daemonConfig.Tracing = s.serverConfig.Tracing
+	}
+
+	if verifier := s.serverConfig.AttestationVerifier; verifier != nil {
+		// The pod VM generates its own server certificate with evidence of its TEE
+		daemonConfig.TLSAttester = verifier.Attester()
+		daemonConfig.TLSServerName = serverName
+	} else if caService := agentProxy.CAService(); caService != nil {
 		certPEM, keyPEM, err := caService.Issue(serverName)
 		if err != nil {
 			return nil, fmt.Errorf("creating TLS certificate for communication between worker node and peer pod VM")
@@ -261,9 +487,13 @@ func (s *cloudService) CreateVM(ctx context.Context, req *pb.CreateVMRequest) (r
 		return nil, fmt.Errorf("generating JSON data: %w", err)
 	}
 
-	// Store apf.json in worker node for debugging
+	// Store apf.json without secrets in worker node for debugging
+	debugJSON, err := json.MarshalIndent(daemonConfig.Redact(), "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("generating JSON data: %w", err)
+	}
 	apfJSONPath := filepath.Join(podDir, "apf.json")
-	if err := os.WriteFile(apfJSONPath, apfJSON, 0o666); err != nil {
+	if err := os.WriteFile(apfJSONPath, debugJSON, 0o600); err != nil {
 		return nil, fmt.Errorf("storing %s: %w", apfJSONPath, err)
 	}
 	logger.Printf("stored %s", apfJSONPath)
@@ -306,6 +536,11 @@ func (s *cloudService) CreateVM(ctx context.Context, req *pb.CreateVMRequest) (r
 		initdataEnc = s.serverConfig.Initdata
 	}
 
+	initdataEnc, err = s.mergeDefaultPolicy(ctx, namespace, pod, initdataEnc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add default policy to initdata: %w", err)
+	}
+
 	if initdataEnc != "" {
 		cloudConfig.WriteFiles = append(cloudConfig.WriteFiles, cloudinit.WriteFile{
 			Path:    paths.InitDataPath,
@@ -326,10 +561,13 @@ func (s *cloudService) CreateVM(ctx context.Context, req *pb.CreateVMRequest) (r
 		podName:      pod,
 		podNamespace: namespace,
 		netNSPath:    netNSPath,
+		serverName:   serverName,
 		agentProxy:   agentProxy,
 		podNetwork:   podNetworkConfig,
 		cloudConfig:  cloudConfig,
 		spec:         vmSpec,
+		spanContext:  span.SpanContext(),
+		podRef:       podRef,
 	}
 
 	if err := s.addSandbox(sid, sandbox); err != nil {
@@ -342,7 +580,9 @@ func (s *cloudService) CreateVM(ctx context.Context, req *pb.CreateVMRequest) (r
 }
 
 func (s *cloudService) StartVM(ctx context.Context, req *pb.StartVMRequest) (res *pb.StartVMResponse, err error) {
+	start := time.Now()
 	defer func() {
+		metrics.ObserveDuration(metrics.StartVMDuration, start, err)
 		if err != nil {
 			logger.Printf("error starting instance: %v", err)
 		}
@@ -355,7 +595,12 @@ func (s *cloudService) StartVM(ctx context.Context, req *pb.StartVMRequest) (res
 		return nil, fmt.Errorf("getting sandbox: %w", err)
 	}
 
-	instance, err := s.provider.CreateInstance(ctx, sandbox.podName, string(sid), sandbox.cloudConfig, sandbox.spec)
+	ctx, span := tracing.Start(trace.ContextWithSpanContext(ctx, sandbox.spanContext), "StartVM")
+	defer func() { tracing.End(span, err) }()
+
+	s.normalEvent(sandbox, EventCreatingInstance, "Creating pod VM instance %s", sandbox.serverName)
+
+	instance, err := s.createInstance(ctx, sandbox)
 
 	// Cleanup instance if it was created but an error occurred (either during creation or later)
 	defer func() {
@@ -365,52 +610,98 @@ func (s *cloudService) StartVM(ctx context.Context, req *pb.StartVMRequest) (res
 			defer cancel()
 			if delErr := s.provider.DeleteInstance(cleanupCtx, instance.ID); delErr != nil {
 				logger.Printf("failed to cleanup instance %s: %v", instance.ID, delErr)
-			} else if s.ppService != nil {
-				if relErr := s.ppService.ReleasePeerPod(sandbox.podName, sandbox.podNamespace, instance.ID); relErr != nil {
-					logger.Printf("failed to release PeerPod during cleanup: %v", relErr)
+			} else {
+				if s.warmPool != nil {
+					s.warmPool.Release(instance.ID)
+				}
+				if s.ppService != nil {
+					if relErr := s.ppService.ReleasePeerPod(sandbox.podName, sandbox.podNamespace, instance.ID); relErr != nil {
+						logger.Printf("failed to release PeerPod during cleanup: %v", relErr)
+					}
 				}
 			}
+			if delErr := s.store.Delete(sid); delErr != nil {
+				logger.Printf("failed to remove state of sandbox %s during cleanup: %v", sid, delErr)
+			}
 		}
 	}()
 
 	if err != nil {
+		s.warningEvent(sandbox, EventFailedCreateInstance, "Failed to create pod VM instance: %v", err)
 		return nil, fmt.Errorf("creating an instance : %w", err)
 	}
 
+	if instance.Type != "" {
+		s.normalEvent(sandbox, EventInstanceTypeSelected, "Selected instance type %s", instance.Type)
+	}
+	s.normalEvent(sandbox, EventInstanceCreated, "Created pod VM instance %s (ID: %s)", instance.Name, instance.ID)
+
+	owned := true
 	if s.ppService != nil {
 		if ownErr := s.ppService.OwnPeerPod(sandbox.podName, sandbox.podNamespace, instance.ID); ownErr != nil {
 			logger.Printf("failed to create PeerPod: %v", ownErr)
+			owned = false
 		}
 	}
+	// A claimed warm pool instance is protected from the orphan collector until its PeerPod is created
+	if owned && s.warmPool != nil {
+		s.warmPool.Release(instance.ID)
+	}
 
-	if err = s.setInstance(sid, instance.ID, instance.Name); err != nil {
+	if err = s.setInstance(sid, instance.ID, instance.Name, instance.IPs); err != nil {
 		return nil, fmt.Errorf("setting instance: %w", err)
 	}
 
 	logger.Printf("created an instance %s for sandbox %s", instance.Name, sid)
 
 	if len(instance.IPs) == 0 {
+		s.warningEvent(sandbox, EventFailedInstanceIP, "No IP address is assigned to pod VM instance %s", instance.ID)
 		return nil, fmt.Errorf("instance IP is not available")
 	}
 
-	instanceIP := instance.IPs[0].String()
-	forwarderPort := s.serverConfig.ForwarderPort
+	s.normalEvent(sandbox, EventInstanceIPAssigned, "Pod VM instance %s has IP addresses %v", instance.ID, instance.IPs)
 
-	if err := s.workerNode.Setup(sandbox.netNSPath, instance.IPs, sandbox.podNetwork); err != nil {
+	if podIPs := routedPodIPs(sandbox.podNetwork); len(podIPs) > 0 {
+		assigner, ok := s.provider.(provider.PodIPAssigner)
+		if !ok {
+			err = fmt.Errorf("cloud provider cannot assign pod IP addresses: %w", errors.ErrUnsupported)
+		} else {
+			err = assigner.AssignPodIPs(ctx, instance.ID, podIPs)
+		}
+		if err != nil {
+			s.warningEvent(sandbox, EventFailedAssignPodIPs, "Failed to assign pod IP addresses %v to pod VM instance %s: %v", podIPs, instance.ID, err)
+			return nil, fmt.Errorf("assigning pod IP addresses %v to instance %s: %w", podIPs, instance.ID, err)
+		}
+		s.normalEvent(sandbox, EventPodIPsAssigned, "Assigned pod IP addresses %v to pod VM instance %s", podIPs, instance.ID)
+	}
+
+	setupStart := time.Now()
+	_, setupSpan := tracing.Start(ctx, "WorkerNode.Setup")
+	err = s.workerNode.Setup(sandbox.netNSPath, instance.IPs, sandbox.podNetwork)
+	tracing.End(setupSpan, err)
+	metrics.ObserveDuration(metrics.PodNetworkSetupDuration, setupStart, err)
+	if err != nil {
+		s.warningEvent(sandbox, EventFailedPodNetworkSetup, "Failed to set up pod network tunnel: %v", err)
 		return nil, fmt.Errorf("setting up pod network tunnel on netns %s: %w", sandbox.netNSPath, err)
 	}
 
-	serverURL := &url.URL{
-		Scheme: "http",
-		Host:   net.JoinHostPort(instanceIP, forwarderPort),
-		Path:   forwarder.AgentURLPath,
+	s.normalEvent(sandbox, EventPodNetworkReady, "Set up %s pod network tunnel to pod VM instance %s", sandbox.podNetwork.TunnelType, instance.ID)
+
+	// Record the sandbox so that a restarted cloud-api-adaptor can re-adopt the instance
+	if saveErr := s.saveSandbox(sandbox); saveErr != nil {
+		logger.Printf("failed to store state of sandbox %s, it will not survive a restart: %v", sid, saveErr)
 	}
 
+	serverURL := s.forwarderURL(instance.IPs[0])
+
+	// Forwarded agent requests are traced as part of the trace of the pod
+	proxyCtx := trace.ContextWithSpanContext(context.Background(), sandbox.spanContext)
+
 	errCh := make(chan error)
 	go func() {
 		defer close(errCh)
 
-		if err := sandbox.agentProxy.Start(context.Background(), serverURL); err != nil {
+		if err := sandbox.agentProxy.Start(proxyCtx, serverURL); err != nil {
 			logger.Printf("error running agent proxy: %v", err)
 			errCh <- err
 		}
@@ -423,17 +714,63 @@ func (s *cloudService) StartVM(ctx context.Context, req *pb.StartVMRequest) (res
 		if shutdownErr := sandbox.agentProxy.Shutdown(); shutdownErr != nil {
 			logger.Printf("stopping agent proxy: %v", shutdownErr)
 		}
+		s.warningEvent(sandbox, EventFailedAgentProxyConnect, "Start of pod VM instance %s was interrupted: %v", instance.ID, ctx.Err())
 		return nil, ctx.Err()
 	case err = <-errCh:
+		s.warningEvent(sandbox, EventFailedAgentProxyConnect, "Failed to connect to pod VM instance %s: %v", instance.ID, err)
 		return nil, err
 	case <-sandbox.agentProxy.Ready():
 	}
 
 	logger.Print("agent proxy is ready")
+	s.normalEvent(sandbox, EventAgentProxyConnected, "Connected to agent-protocol-forwarder on pod VM instance %s at %s", instance.ID, serverURL.Host)
 
 	return &pb.StartVMResponse{}, nil
 }
 
+// routedPodIPs returns the pod IP addresses that the cloud network routes to the pod VM instance.
+// It returns nil for tunnel types other than routed.
+func routedPodIPs(config *tunneler.Config) []netip.Addr {
+	if config == nil || config.TunnelType != routed.TunnelType {
+		return nil
+	}
+	var ips []netip.Addr
+	for _, prefix := range config.PodAddrs() {
+		ips = append(ips, prefix.Addr())
+	}
+	return ips
+}
+
+// createInstance claims an instance from the warm pool if available, or creates a new instance otherwise
+func (s *cloudService) createInstance(ctx context.Context, sandbox *sandbox) (*provider.Instance, error) {
+	if s.warmPool != nil {
+		if w := s.warmPool.Claim(sandbox.spec); w != nil {
+			err := s.warmPool.Provision(ctx, w, sandbox.cloudConfig)
+			if err == nil {
+				logger.Printf("claimed warm pool instance %s for sandbox %s", w.instance.Name, sandbox.id)
+				return w.instance, nil
+			}
+			logger.Printf("failed to provision warm pool instance %s, creating a new instance: %v", w.instance.Name, err)
+			s.warmPool.Discard(w)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		instance, err := s.provider.CreateInstance(ctx, sandbox.podName, string(sandbox.id), sandbox.cloudConfig, sandbox.spec)
+		if err == nil || instance != nil || !provider.IsRetryable(err) || attempt > createInstanceRetries {
+			return instance, err
+		}
+
+		logger.Printf("creating an instance for sandbox %s failed, retrying in %s: %v", sandbox.id, createInstanceRetryInterval, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-time.After(createInstanceRetryInterval):
+		}
+	}
+}
+
 func (s *cloudService) StopVM(ctx context.Context, req *pb.StopVMRequest) (*pb.StopVMResponse, error) {
 	sid := sandboxID(req.Id)
 
@@ -444,15 +781,35 @@ func (s *cloudService) StopVM(ctx context.Context, req *pb.StopVMRequest) (*pb.S
 		return nil, err
 	}
 
+	ctx, span := tracing.Start(trace.ContextWithSpanContext(ctx, sandbox.spanContext), "StopVM")
+	defer span.End()
+
 	if err := sandbox.agentProxy.Shutdown(); err != nil {
 		logger.Printf("stopping agent proxy: %v", err)
 	}
 
+	// Pod IP addresses are released before the instance is deleted, so that the cloud network
+	// stops routing them to the instance while it is shutting down
+	if podIPs := routedPodIPs(sandbox.podNetwork); len(podIPs) > 0 {
+		if assigner, ok := s.provider.(provider.PodIPAssigner); ok {
+			if err := assigner.UnassignPodIPs(ctx, sandbox.instanceID, podIPs); err != nil {
+				logger.Printf("Error unassigning pod IP addresses %v from an instance %s: %v", podIPs, sandbox.instanceID, err)
+			}
+		}
+	}
+
 	if err := s.provider.DeleteInstance(ctx, sandbox.instanceID); err != nil {
 		logger.Printf("Error deleting an instance %s: %v", sandbox.instanceID, err)
-	} else if s.ppService != nil {
-		if err := s.ppService.ReleasePeerPod(sandbox.podName, sandbox.podNamespace, sandbox.instanceID); err != nil {
-			logger.Printf("failed to release PeerPod %v", err)
+		s.warningEvent(sandbox, EventFailedDeleteInstance, "Failed to delete pod VM instance %s: %v", sandbox.instanceID, err)
+	} else {
+		s.normalEvent(sandbox, EventInstanceDeleted, "Deleted pod VM instance %s", sandbox.instanceID)
+		if s.warmPool != nil {
+			s.warmPool.Release(sandbox.instanceID)
+		}
+		if s.ppService != nil {
+			if err := s.ppService.ReleasePeerPod(sandbox.podName, sandbox.podNamespace, sandbox.instanceID); err != nil {
+				logger.Printf("failed to release PeerPod %v", err)
+			}
 		}
 	}
 
@@ -460,6 +817,10 @@ func (s *cloudService) StopVM(ctx context.Context, req *pb.StopVMRequest) (*pb.S
 		logger.Printf("tearing down netns %s: %v", sandbox.netNSPath, err)
 	}
 
+	if err := s.store.Delete(sid); err != nil {
+		logger.Printf("removing state of sandbox %s: %v", sid, err)
+	}
+
 	if err = s.removeSandbox(sid); err != nil {
 		logger.Printf("removing sandbox %s: %v", sid, err)
 	}
@@ -5,13 +5,16 @@ package cloud
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/netip"
 	"net/url"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	cri "github.com/containerd/containerd/pkg/cri/annotations"
 	pb "github.com/kata-containers/kata-containers/src/runtime/protocols/hypervisor"
@@ -22,6 +25,7 @@ import (
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/forwarder"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler/routed"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tlsutil"
 	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util/cloudinit"
@@ -88,7 +92,7 @@ type mockProxyFactory struct {
 	podsDir string
 }
 
-func (f *mockProxyFactory) New(serverName, socketPath string) proxy.AgentProxy {
+func (f *mockProxyFactory) New(serverName, socketPath string, filter proxy.RequestFilter) proxy.AgentProxy {
 	return &mockProxy{
 		socketPath: socketPath,
 		readyCh:    make(chan struct{}),
@@ -114,6 +118,18 @@ func (n *mockWorkerNode) Teardown(nsPath string, config *tunneler.Config) error
 	return nil
 }
 
+// wireGuardWorkerNode sets the key of the worker node end of a WireGuard tunnel
+type wireGuardWorkerNode struct {
+	mockWorkerNode
+}
+
+func (n *wireGuardWorkerNode) Setup(nsPath string, podNodeIPs []netip.Addr, config *tunneler.Config) error {
+	config.WireGuardWorkerNodeKey = []byte(testWireGuardKey)
+	return nil
+}
+
+const testWireGuardKey = "0123456789abcdef0123456789abcdef"
+
 func TestCloudService(t *testing.T) {
 
 	ctx := context.Background()
@@ -195,6 +211,14 @@ func TestCreateVMTLSProfilePropagation(t *testing.T) {
 		data, err := os.ReadFile(apfPath)
 		require.NoError(t, err)
 
+		// Per-pod artifacts are accessible only by the owner
+		info, err := os.Stat(apfPath)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		info, err = os.Stat(filepath.Dir(apfPath))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+
 		var daemonCfg forwarder.Config
 		require.NoError(t, json.Unmarshal(data, &daemonCfg))
 
@@ -233,3 +257,222 @@ func TestCreateVMTLSProfilePropagation(t *testing.T) {
 		assert.Empty(t, daemonCfg.CipherSuites)
 	})
 }
+
+func TestServerConfigRedact(t *testing.T) {
+	cfg := &ServerConfig{
+		PodsDir:   "/run/peerpods/pods",
+		Initdata:  "H4sIAAAAAAAA/initdata",
+		TLSConfig: &tlsutil.TLSConfig{CertFile: "/etc/certificates/client.crt", KeyData: []byte("client key")},
+	}
+
+	line := fmt.Sprintf("%#v %#v", cfg.Redact(), cfg.Redact().TLSConfig)
+	assert.Contains(t, line, "/run/peerpods/pods")
+	assert.Contains(t, line, "/etc/certificates/client.crt")
+	assert.NotContains(t, line, "H4sIAAAAAAAA")
+	assert.NotContains(t, line, "client key")
+
+	// The original config is not modified
+	assert.Equal(t, "H4sIAAAAAAAA/initdata", cfg.Initdata)
+	assert.Equal(t, []byte("client key"), cfg.TLSConfig.KeyData)
+}
+
+func TestCloudServiceRestore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// The network namespace of a running pod must exist for its sandbox to be restored
+	netNSPath := filepath.Join(t.TempDir(), "netns")
+	require.NoError(t, os.WriteFile(netNSPath, nil, 0o600))
+
+	cfg := &ServerConfig{
+		PodsDir:       dir,
+		ForwarderPort: forwarder.DefaultListenPort,
+	}
+
+	s1 := NewService(&mockProvider{}, &mockProxyFactory{podsDir: dir}, &wireGuardWorkerNode{}, cfg)
+
+	sandboxID := "123"
+	sandboxNS := "default"
+	sandboxName := "mypod"
+
+	_, err := s1.CreateVM(ctx, &pb.CreateVMRequest{
+		Id: sandboxID,
+		Annotations: map[string]string{
+			cri.SandboxNamespace: sandboxNS,
+			cri.SandboxName:      sandboxName,
+		},
+		NetworkNamespacePath: netNSPath,
+	})
+	require.NoError(t, err)
+
+	_, err = s1.StartVM(ctx, &pb.StartVMRequest{Id: sandboxID})
+	require.NoError(t, err)
+
+	statePath := filepath.Join(dir, sandboxID, SandboxStateFile)
+	require.FileExists(t, statePath)
+
+	instanceID, err := s1.GetInstanceID(ctx, sandboxNS, sandboxName, false)
+	require.NoError(t, err)
+
+	// A sandbox whose pod network namespace is gone is discarded
+	staleDir := filepath.Join(dir, "stale")
+	require.NoError(t, os.MkdirAll(staleDir, os.ModePerm))
+	stale := &sandboxState{
+		ID:           "stale",
+		PodName:      "stalepod",
+		PodNamespace: sandboxNS,
+		NetNSPath:    filepath.Join(t.TempDir(), "missing"),
+		InstanceID:   "stale-instance",
+		IPs:          []netip.Addr{netip.MustParseAddr("127.0.0.1")},
+	}
+	require.NoError(t, newSandboxStore(dir).Save(stale))
+
+	// Simulate a restart of cloud-api-adaptor
+	s2 := NewService(&mockProvider{}, &mockProxyFactory{podsDir: dir}, &mockWorkerNode{}, cfg)
+
+	restoredID, err := s2.GetInstanceID(ctx, sandboxNS, sandboxName, false)
+	assert.NoError(t, err)
+	assert.Equal(t, instanceID, restoredID)
+
+	// The WireGuard key of the worker node is restored, but it is never part of the pod network config of the pod VM
+	restored := s2.(*cloudService).sandboxes["123"]
+	require.NotNil(t, restored)
+	assert.Equal(t, []byte(testWireGuardKey), restored.podNetwork.WireGuardWorkerNodeKey)
+	podNetworkJSON, err := json.Marshal(restored.podNetwork)
+	require.NoError(t, err)
+	assert.NotContains(t, string(podNetworkJSON), base64.StdEncoding.EncodeToString([]byte(testWireGuardKey)))
+
+	staleID, err := s2.GetInstanceID(ctx, sandboxNS, "stalepod", false)
+	assert.NoError(t, err)
+	assert.Empty(t, staleID)
+	assert.NoFileExists(t, filepath.Join(staleDir, SandboxStateFile))
+
+	_, err = s2.StopVM(ctx, &pb.StopVMRequest{Id: sandboxID})
+	assert.NoError(t, err)
+	assert.NoFileExists(t, statePath)
+}
+
+// podIPAssignerProvider records the pod IP addresses assigned to each instance
+type podIPAssignerProvider struct {
+	mockProvider
+	assigned map[string][]netip.Addr
+}
+
+func (p *podIPAssignerProvider) AssignPodIPs(ctx context.Context, instanceID string, ips []netip.Addr) error {
+	p.assigned[instanceID] = ips
+	return nil
+}
+
+func (p *podIPAssignerProvider) UnassignPodIPs(ctx context.Context, instanceID string, ips []netip.Addr) error {
+	delete(p.assigned, instanceID)
+	return nil
+}
+
+type routedWorkerNode struct {
+	mockWorkerNode
+}
+
+func (n *routedWorkerNode) Inspect(nsPath string) (*tunneler.Config, error) {
+	podIP := netip.MustParsePrefix("10.128.0.2/32")
+	return &tunneler.Config{
+		TunnelType: routed.TunnelType,
+		PodIP:      podIP,
+		PodIPs:     []netip.Prefix{podIP},
+	}, nil
+}
+
+func TestCloudServiceRoutedPodIPs(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	cfg := &ServerConfig{
+		PodsDir:       dir,
+		ForwarderPort: forwarder.DefaultListenPort,
+	}
+
+	req := &pb.CreateVMRequest{
+		Id: "123",
+		Annotations: map[string]string{
+			cri.SandboxNamespace: "default",
+			cri.SandboxName:      "mypod",
+		},
+	}
+
+	p := &podIPAssignerProvider{assigned: map[string][]netip.Addr{}}
+	s := NewService(p, &mockProxyFactory{podsDir: dir}, &routedWorkerNode{}, cfg)
+
+	_, err := s.CreateVM(ctx, req)
+	require.NoError(t, err)
+
+	_, err = s.StartVM(ctx, &pb.StartVMRequest{Id: req.Id})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]netip.Addr{"mypod-123": {netip.MustParseAddr("10.128.0.2")}}, p.assigned)
+
+	_, err = s.StopVM(ctx, &pb.StopVMRequest{Id: req.Id})
+	require.NoError(t, err)
+	assert.Empty(t, p.assigned)
+
+	// Providers that cannot assign pod IP addresses fail to start the pod VM
+	s = NewService(&mockProvider{}, &mockProxyFactory{podsDir: dir}, &routedWorkerNode{}, cfg)
+
+	_, err = s.CreateVM(ctx, req)
+	require.NoError(t, err)
+
+	_, err = s.StartVM(ctx, &pb.StartVMRequest{Id: req.Id})
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+type failingProvider struct {
+	mockProvider
+	errs  []error
+	calls int
+}
+
+func (p *failingProvider) CreateInstance(ctx context.Context, podName, sandboxID string, cloudConfig cloudinit.CloudConfigGenerator, spec provider.InstanceTypeSpec) (*provider.Instance, error) {
+	p.calls++
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return nil, err
+	}
+	return p.mockProvider.CreateInstance(ctx, podName, sandboxID, cloudConfig, spec)
+}
+
+func TestCreateInstanceRetry(t *testing.T) {
+	interval := createInstanceRetryInterval
+	createInstanceRetryInterval = time.Millisecond
+	defer func() { createInstanceRetryInterval = interval }()
+
+	capacityErr := provider.WrapError(provider.ErrInsufficientCapacity, errors.New("ZonalAllocationFailed"))
+	authErr := provider.WrapError(provider.ErrAuth, errors.New("AuthorizationFailed"))
+	exhaustedErr := provider.WrapError(provider.ErrFallbackExhausted, capacityErr)
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   error
+		wantCalls int
+	}{
+		{"capacity is retried", []error{capacityErr, capacityErr}, nil, 3},
+		{"retries are limited", []error{capacityErr, capacityErr, capacityErr}, provider.ErrInsufficientCapacity, 3},
+		{"auth fails fast", []error{authErr}, provider.ErrAuth, 1},
+		{"exhausted fallback is not retried", []error{exhaustedErr}, provider.ErrFallbackExhausted, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &failingProvider{errs: tt.errs}
+			s := &cloudService{provider: p}
+			sandbox := &sandbox{id: "123", podName: "mypod"}
+
+			instance, err := s.createInstance(context.Background(), sandbox)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, instance)
+			}
+			assert.Equal(t, tt.wantCalls, p.calls)
+		})
+	}
+}
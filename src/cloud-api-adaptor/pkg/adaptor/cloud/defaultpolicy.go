@@ -0,0 +1,71 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/defaultpolicy"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/initdata"
+)
+
+// configMapReader reads the data of a ConfigMap. It is implemented by k8sops.ConfigMapReader.
+type configMapReader interface {
+	Data(ctx context.Context) (map[string]string, error)
+}
+
+// mergeDefaultPolicy adds the default kata agent policy selected for a pod to its initdata, when the initdata
+// carries no policy. The ConfigMap of default policies is read for each pod, so that changes apply to new pods
+// without a restart. It is not read for pods that carry their own policy, which start even if it is unavailable.
+func (s *cloudService) mergeDefaultPolicy(ctx context.Context, namespace, pod, initdataEnc string) (string, error) {
+	if s.serverConfig.DefaultPolicyConfigMap == "" {
+		return initdataEnc, nil
+	}
+
+	hasPolicy, err := initdata.HasPolicy(initdataEnc)
+	if err != nil {
+		return "", fmt.Errorf("parsing initdata: %w", err)
+	}
+	if hasPolicy {
+		return initdataEnc, nil
+	}
+	if s.defaultPolicies == nil {
+		return "", errors.New("ConfigMap of default policies is not available")
+	}
+
+	data, err := s.defaultPolicies.Data(ctx)
+	if err != nil {
+		return "", err
+	}
+	set, err := defaultpolicy.Parse(data)
+	if err != nil {
+		return "", fmt.Errorf("parsing ConfigMap %s: %w", s.serverConfig.DefaultPolicyConfigMap, err)
+	}
+
+	target := &defaultpolicy.Pod{Namespace: namespace}
+	if set.UsesLabels() {
+		if s.labels == nil {
+			return "", errors.New("labels of pods are not available")
+		}
+		if target.PodLabels, target.NamespaceLabels, err = s.labels.PodLabels(ctx, namespace, pod); err != nil {
+			return "", err
+		}
+	}
+
+	name, policy, ok := set.Select(target)
+	if !ok {
+		return initdataEnc, nil
+	}
+
+	merged, added, err := initdata.WithDefaultPolicy(initdataEnc, policy)
+	if err != nil {
+		return "", fmt.Errorf("parsing initdata: %w", err)
+	}
+	if added {
+		logger.Printf("added default policy of selector %s to initdata of pod %s/%s", name, namespace, pod)
+	}
+	return merged, nil
+}
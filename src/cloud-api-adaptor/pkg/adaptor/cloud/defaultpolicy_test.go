@@ -0,0 +1,83 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/defaultpolicy"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/initdata"
+)
+
+type mockConfigMapReader struct {
+	data map[string]string
+	err  error
+}
+
+func (r *mockConfigMapReader) Data(ctx context.Context) (map[string]string, error) {
+	return r.data, r.err
+}
+
+func TestMergeDefaultPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	reader := &mockConfigMapReader{data: map[string]string{
+		defaultpolicy.SelectorsKey: "- namespaceLabels: {env: production}\n  policy: strict.rego\n",
+		"strict.rego":              "package agent_policy\n",
+	}}
+	labels := &mockLabelLookup{namespaceLabels: map[string]string{"env": "production"}}
+	s := &cloudService{
+		serverConfig:    &ServerConfig{DefaultPolicyConfigMap: "confidential-containers-system/default-policies"},
+		labels:          labels,
+		defaultPolicies: reader,
+	}
+
+	// The policy is added to empty initdata
+	merged, err := s.mergeDefaultPolicy(ctx, "shop", "web", "")
+	require.NoError(t, err)
+	id, err := initdata.Parse(strings.NewReader(merged))
+	require.NoError(t, err)
+	assert.Equal(t, "package agent_policy\n", id.Body.Data[initdata.PolicyKey])
+	assert.Equal(t, 1, labels.lookups)
+
+	// Initdata with a policy is unchanged
+	own, _, err := initdata.WithDefaultPolicy("", "package own\n")
+	require.NoError(t, err)
+	merged, err = s.mergeDefaultPolicy(ctx, "shop", "web", own)
+	require.NoError(t, err)
+	assert.Equal(t, own, merged)
+
+	// Pods that match no selector are unchanged
+	labels.namespaceLabels = nil
+	merged, err = s.mergeDefaultPolicy(ctx, "shop", "web", "")
+	require.NoError(t, err)
+	assert.Empty(t, merged)
+
+	// Pods fail to start when the ConfigMap cannot be read
+	reader.err = errors.New("not found")
+	_, err = s.mergeDefaultPolicy(ctx, "shop", "web", "")
+	assert.Error(t, err)
+
+	// unless they carry their own policy, for which the ConfigMap is not read
+	merged, err = s.mergeDefaultPolicy(ctx, "shop", "web", own)
+	require.NoError(t, err)
+	assert.Equal(t, own, merged)
+
+	s.defaultPolicies = nil
+	merged, err = s.mergeDefaultPolicy(ctx, "shop", "web", own)
+	require.NoError(t, err)
+	assert.Equal(t, own, merged)
+
+	// Nothing is merged when no ConfigMap is configured
+	s.serverConfig.DefaultPolicyConfigMap = ""
+	merged, err = s.mergeDefaultPolicy(ctx, "shop", "web", "")
+	require.NoError(t, err)
+	assert.Empty(t, merged)
+}
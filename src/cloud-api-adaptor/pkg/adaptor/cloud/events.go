@@ -0,0 +1,51 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package cloud
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Reasons of the events recorded on pods for each phase of the pod VM life cycle
+const (
+	EventCreatingInstance        = "CreatingInstance"
+	EventInstanceTypeSelected    = "InstanceTypeSelected"
+	EventInstanceCreated         = "InstanceCreated"
+	EventInstanceIPAssigned      = "InstanceIPAssigned"
+	EventPodIPsAssigned          = "PodIPsAssigned"
+	EventPodNetworkReady         = "PodNetworkReady"
+	EventAgentProxyConnected     = "AgentProxyConnected"
+	EventInstanceDeleted         = "InstanceDeleted"
+	EventFailedCreateInstance    = "FailedCreateInstance"
+	EventFailedInstanceIP        = "FailedInstanceIP"
+	EventFailedAssignPodIPs      = "FailedAssignPodIPs"
+	EventFailedPodNetworkSetup   = "FailedPodNetworkSetup"
+	EventFailedAgentProxyConnect = "FailedAgentProxyConnect"
+	EventFailedDeleteInstance    = "FailedDeleteInstance"
+	EventAgentRequestDenied      = "AgentRequestDenied"
+)
+
+// podEventRecorder records Kubernetes Events on pods. It is implemented by k8sops.PodEventRecorder.
+type podEventRecorder interface {
+	PodReference(ctx context.Context, namespace, name string) (*v1.ObjectReference, error)
+	Eventf(pod *v1.ObjectReference, eventType, reason, messageFmt string, args ...interface{})
+	Shutdown()
+}
+
+func (s *cloudService) normalEvent(sandbox *sandbox, reason, messageFmt string, args ...interface{}) {
+	s.recordEvent(sandbox, v1.EventTypeNormal, reason, messageFmt, args...)
+}
+
+func (s *cloudService) warningEvent(sandbox *sandbox, reason, messageFmt string, args ...interface{}) {
+	s.recordEvent(sandbox, v1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+func (s *cloudService) recordEvent(sandbox *sandbox, eventType, reason, messageFmt string, args ...interface{}) {
+	if s.events == nil || sandbox.podRef == nil {
+		return
+	}
+	s.events.Eventf(sandbox.podRef, eventType, reason, messageFmt, args...)
+}
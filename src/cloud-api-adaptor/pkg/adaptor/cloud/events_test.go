@@ -0,0 +1,74 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package cloud
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	cri "github.com/containerd/containerd/pkg/cri/annotations"
+	pb "github.com/kata-containers/kata-containers/src/runtime/protocols/hypervisor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/forwarder"
+)
+
+type mockEventRecorder struct {
+	mutex   sync.Mutex
+	reasons []string
+}
+
+func (r *mockEventRecorder) PodReference(ctx context.Context, namespace, name string) (*v1.ObjectReference, error) {
+	return &v1.ObjectReference{Kind: "Pod", Namespace: namespace, Name: name}, nil
+}
+
+func (r *mockEventRecorder) Eventf(pod *v1.ObjectReference, eventType, reason, messageFmt string, args ...interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.reasons = append(r.reasons, reason)
+}
+
+func (r *mockEventRecorder) Shutdown() {}
+
+func TestCloudServiceEvents(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	cfg := &ServerConfig{
+		PodsDir:       dir,
+		ForwarderPort: forwarder.DefaultListenPort,
+	}
+
+	events := &mockEventRecorder{}
+	s := NewService(&mockProvider{}, &mockProxyFactory{podsDir: dir}, &mockWorkerNode{}, cfg).(*cloudService)
+	s.events = events
+
+	sandboxID := "123"
+	_, err := s.CreateVM(ctx, &pb.CreateVMRequest{
+		Id: sandboxID,
+		Annotations: map[string]string{
+			cri.SandboxNamespace: "default",
+			cri.SandboxName:      "mypod",
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = s.StartVM(ctx, &pb.StartVMRequest{Id: sandboxID})
+	require.NoError(t, err)
+
+	_, err = s.StopVM(ctx, &pb.StopVMRequest{Id: sandboxID})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		EventCreatingInstance,
+		EventInstanceCreated,
+		EventInstanceIPAssigned,
+		EventPodNetworkReady,
+		EventAgentProxyConnected,
+		EventInstanceDeleted,
+	}, events.reasons)
+}
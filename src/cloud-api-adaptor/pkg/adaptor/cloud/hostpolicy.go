@@ -0,0 +1,83 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/hostpolicy"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/proxy"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/agentproto"
+)
+
+// podLabelLookup looks up labels of pods and their namespaces. It is implemented by k8sops.LabelLookup.
+type podLabelLookup interface {
+	PodLabels(ctx context.Context, namespace, name string) (podLabels, namespaceLabels map[string]string, err error)
+}
+
+// requestFilter returns a filter that rejects the agent requests of a pod that the host policy denies,
+// or nil if there is no host policy. Denials are recorded as events on the pod.
+func (s *cloudService) requestFilter(namespace, pod string, podRef *v1.ObjectReference) proxy.RequestFilter {
+	policy := s.serverConfig.HostPolicy
+	if policy == nil {
+		return nil
+	}
+
+	// Labels are looked up when the first request is evaluated, and cached for the lifetime of the pod
+	var (
+		mutex           sync.Mutex
+		labelsLoaded    bool
+		podLabels       map[string]string
+		namespaceLabels map[string]string
+	)
+	loadLabels := func(ctx context.Context) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if labelsLoaded || !policy.UsesLabels() {
+			return nil
+		}
+		if s.labels == nil {
+			return errors.New("labels of pods are not available")
+		}
+		var err error
+		if podLabels, namespaceLabels, err = s.labels.PodLabels(ctx, namespace, pod); err != nil {
+			return err
+		}
+		labelsLoaded = true
+		return nil
+	}
+
+	return func(ctx context.Context, method string, req any) error {
+		if err := loadLabels(ctx); err != nil {
+			return status.Errorf(codes.Unavailable, "host policy cannot be evaluated for pod %s/%s: %v", namespace, pod, err)
+		}
+
+		info := agentproto.DescribeRequest(req)
+		decision := policy.Evaluate(&hostpolicy.Request{
+			Method:          method,
+			Namespace:       namespace,
+			Pod:             pod,
+			PodLabels:       podLabels,
+			NamespaceLabels: namespaceLabels,
+			ContainerID:     info.ContainerID,
+			Command:         info.Command,
+			Path:            info.Path,
+		})
+		if decision.Allowed {
+			return nil
+		}
+
+		if s.events != nil && podRef != nil {
+			s.events.Eventf(podRef, v1.EventTypeWarning, EventAgentRequestDenied, "%s was denied by host policy (%s)", method, decision.Reason)
+		}
+		return status.Errorf(codes.PermissionDenied, "%s is denied by host policy (%s)", method, decision.Reason)
+	}
+}
@@ -0,0 +1,88 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	agent "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/agent/protocols/grpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/hostpolicy"
+)
+
+type mockLabelLookup struct {
+	namespaceLabels map[string]string
+	err             error
+	lookups         int
+}
+
+func (l *mockLabelLookup) PodLabels(ctx context.Context, namespace, name string) (map[string]string, map[string]string, error) {
+	l.lookups++
+	return nil, l.namespaceLabels, l.err
+}
+
+const testHostPolicy = `
+version: v1
+rules:
+- name: no-exec-in-production
+  action: deny
+  methods: [ExecProcess]
+  namespaceLabels:
+    env: production
+- name: protect-etc
+  action: deny
+  methods: [CopyFile]
+  paths: ["/etc/**"]
+`
+
+func TestRequestFilter(t *testing.T) {
+	ctx := context.Background()
+
+	policy, err := hostpolicy.Parse([]byte(testHostPolicy))
+	require.NoError(t, err)
+
+	events := &mockEventRecorder{}
+	labels := &mockLabelLookup{namespaceLabels: map[string]string{"env": "production"}}
+	s := &cloudService{
+		serverConfig: &ServerConfig{HostPolicy: policy},
+		events:       events,
+		labels:       labels,
+	}
+	podRef := &v1.ObjectReference{Kind: "Pod", Namespace: "shop", Name: "web"}
+
+	filter := s.requestFilter("shop", "web", podRef)
+	require.NotNil(t, filter)
+
+	err = filter(ctx, "ExecProcess", &agent.ExecProcessRequest{ContainerId: "c1", Process: &agent.Process{Args: []string{"sh"}}})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.ErrorContains(t, err, "no-exec-in-production")
+
+	err = filter(ctx, "CopyFile", &agent.CopyFileRequest{Path: "/etc/shadow"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	assert.NoError(t, filter(ctx, "CopyFile", &agent.CopyFileRequest{Path: "/run/data"}))
+	assert.NoError(t, filter(ctx, "CreateContainer", &agent.CreateContainerRequest{ContainerId: "c1"}))
+
+	// Labels are looked up once, and denials are recorded as events
+	assert.Equal(t, 1, labels.lookups)
+	assert.Equal(t, []string{EventAgentRequestDenied, EventAgentRequestDenied}, events.reasons)
+
+	// Requests are rejected while labels are not available
+	labels = &mockLabelLookup{err: errors.New("API server is down")}
+	s.labels = labels
+	filter = s.requestFilter("shop", "web", podRef)
+	err = filter(ctx, "CreateContainer", &agent.CreateContainerRequest{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	// There is no filter without a host policy
+	s.serverConfig.HostPolicy = nil
+	assert.Nil(t, s.requestFilter("shop", "web", podRef))
+}
@@ -0,0 +1,89 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/metrics"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tracing"
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util/cloudinit"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// instrumentedProvider records the duration and the failures of cloud provider requests, and traces them
+type instrumentedProvider struct {
+	provider.Provider
+	name string
+}
+
+func newInstrumentedProvider(p provider.Provider, name string) *instrumentedProvider {
+	return &instrumentedProvider{Provider: p, name: name}
+}
+
+func (p *instrumentedProvider) CreateInstance(ctx context.Context, podName, sandboxID string, cloudConfig cloudinit.CloudConfigGenerator, spec provider.InstanceTypeSpec) (*provider.Instance, error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "CreateInstance", attribute.String("cloud.provider", p.name), attribute.String("instance.type", spec.InstanceType))
+	instance, err := p.Provider.CreateInstance(ctx, podName, sandboxID, cloudConfig, spec)
+	if instance != nil {
+		span.SetAttributes(attribute.String("instance.id", instance.ID))
+	}
+	tracing.End(span, err)
+	metrics.ObserveProviderRequest(p.name, metrics.OpCreateInstance, start, err)
+	return instance, err
+}
+
+func (p *instrumentedProvider) DeleteInstance(ctx context.Context, instanceID string) error {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "DeleteInstance", attribute.String("cloud.provider", p.name), attribute.String("instance.id", instanceID))
+	err := p.Provider.DeleteInstance(ctx, instanceID)
+	tracing.End(span, err)
+	metrics.ObserveProviderRequest(p.name, metrics.OpDeleteInstance, start, err)
+	return err
+}
+
+// AssignPodIPs fails with errors.ErrUnsupported if the cloud provider does not implement provider.PodIPAssigner
+func (p *instrumentedProvider) AssignPodIPs(ctx context.Context, instanceID string, ips []netip.Addr) error {
+	assigner, ok := p.Provider.(provider.PodIPAssigner)
+	if !ok {
+		return fmt.Errorf("cloud provider %s cannot assign pod IP addresses: %w", p.name, errors.ErrUnsupported)
+	}
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "AssignPodIPs", attribute.String("cloud.provider", p.name), attribute.String("instance.id", instanceID))
+	err := assigner.AssignPodIPs(ctx, instanceID, ips)
+	tracing.End(span, err)
+	metrics.ObserveProviderRequest(p.name, metrics.OpAssignPodIPs, start, err)
+	return err
+}
+
+// UnassignPodIPs does nothing if the cloud provider does not implement provider.PodIPAssigner
+func (p *instrumentedProvider) UnassignPodIPs(ctx context.Context, instanceID string, ips []netip.Addr) error {
+	assigner, ok := p.Provider.(provider.PodIPAssigner)
+	if !ok {
+		return nil
+	}
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "UnassignPodIPs", attribute.String("cloud.provider", p.name), attribute.String("instance.id", instanceID))
+	err := assigner.UnassignPodIPs(ctx, instanceID, ips)
+	tracing.End(span, err)
+	metrics.ObserveProviderRequest(p.name, metrics.OpUnassignPodIPs, start, err)
+	return err
+}
+
+// updateSandboxMetrics updates the gauges derived from the sandbox map. The caller must hold s.mutex.
+func (s *cloudService) updateSandboxMetrics() {
+	var vxlanIDs int
+	for _, sandbox := range s.sandboxes {
+		if sandbox.podNetwork != nil && sandbox.podNetwork.VXLANID != 0 {
+			vxlanIDs++
+		}
+	}
+	metrics.ActiveSandboxes.Set(float64(len(s.sandboxes)))
+	metrics.AllocatedVXLANIDs.Set(float64(vxlanIDs))
+}
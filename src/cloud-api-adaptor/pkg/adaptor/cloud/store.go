@@ -0,0 +1,146 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package cloud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+)
+
+// SandboxStateFile is the name of the file in each pod directory that records
+// the state needed to re-adopt a running pod VM after cloud-api-adaptor restarts.
+const SandboxStateFile = "sandbox.json"
+
+type sandboxState struct {
+	ID           sandboxID        `json:"id"`
+	PodName      string           `json:"pod-name"`
+	PodNamespace string           `json:"pod-namespace"`
+	NetNSPath    string           `json:"netns-path"`
+	ServerName   string           `json:"server-name"`
+	PodNetwork   *tunneler.Config `json:"pod-network"`
+	InstanceID   string           `json:"instance-id"`
+	InstanceName string           `json:"instance-name"`
+	IPs          []netip.Addr     `json:"ips"`
+
+	// WireGuardWorkerNodeKey is the private key of the worker node end of a WireGuard tunnel.
+	// It is not part of PodNetwork, which is sent to the pod VM.
+	WireGuardWorkerNodeKey []byte `json:"wireguard-worker-node-key,omitempty"`
+}
+
+// sandboxStore persists sandbox state as one JSON file per pod directory under podsDir
+type sandboxStore struct {
+	podsDir string
+}
+
+func newSandboxStore(podsDir string) *sandboxStore {
+	return &sandboxStore{podsDir: podsDir}
+}
+
+func (s *sandboxStore) path(sid sandboxID) string {
+	return filepath.Join(s.podsDir, string(sid), SandboxStateFile)
+}
+
+// Save writes the state of a sandbox atomically, so that a crash during the write
+// never leaves a truncated file behind
+func (s *sandboxStore) Save(state *sandboxState) error {
+	if state.ID == "" {
+		return errors.New("empty sandbox id")
+	}
+
+	data, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return fmt.Errorf("encoding state of sandbox %s: %w", state.ID, err)
+	}
+
+	path := s.path(state.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("creating a pod directory: %s, %w", filepath.Dir(path), err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), SandboxStateFile+".*")
+	if err != nil {
+		return fmt.Errorf("creating a temporary file for %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("syncing %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", tmp.Name(), err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("storing %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Delete removes the state of a sandbox. A missing state file is not an error.
+func (s *sandboxStore) Delete(sid sandboxID) error {
+	if sid == "" {
+		return errors.New("empty sandbox id")
+	}
+	if err := os.Remove(s.path(sid)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List returns the states of all sandboxes found under podsDir. Unreadable or
+// corrupted state files are reported in the returned error and skipped.
+func (s *sandboxStore) List() ([]*sandboxState, error) {
+	entries, err := os.ReadDir(s.podsDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading %s: %w", s.podsDir, err)
+	}
+
+	var states []*sandboxState
+	var errs []error
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		path := s.path(sandboxID(entry.Name()))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, fmt.Errorf("reading %s: %w", path, err))
+			}
+			continue
+		}
+
+		var state sandboxState
+		if err := json.Unmarshal(data, &state); err != nil {
+			errs = append(errs, fmt.Errorf("decoding %s: %w", path, err))
+			continue
+		}
+
+		if state.ID != sandboxID(entry.Name()) {
+			errs = append(errs, fmt.Errorf("%s: sandbox id %q does not match the pod directory", path, state.ID))
+			continue
+		}
+
+		states = append(states, &state)
+	}
+
+	return states, errors.Join(errs...)
+}
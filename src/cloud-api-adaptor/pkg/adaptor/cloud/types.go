@@ -5,6 +5,7 @@ package cloud
 
 import (
 	"context"
+	"net/netip"
 	"sync"
 
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/k8sops"
@@ -14,6 +15,8 @@ import (
 	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util/cloudinit"
 	pb "github.com/kata-containers/kata-containers/src/runtime/protocols/hypervisor"
+	"go.opentelemetry.io/otel/trace"
+	v1 "k8s.io/api/core/v1"
 )
 
 type Service interface {
@@ -32,6 +35,12 @@ type cloudService struct {
 	mutex        sync.Mutex
 	ppService    *k8sops.PeerPodService
 	serverConfig *ServerConfig
+	store        *sandboxStore
+	warmPool     *warmPool
+	events       podEventRecorder
+	labels       podLabelLookup
+	// defaultPolicies reads the ConfigMap of default kata agent policies
+	defaultPolicies configMapReader
 }
 
 type sandboxID string
@@ -45,6 +54,12 @@ type sandbox struct {
 	podNamespace string
 	instanceName string
 	instanceID   string
+	instanceIPs  []netip.Addr
+	serverName   string
 	netNSPath    string
 	spec         provider.InstanceTypeSpec
+	// spanContext is the span of CreateVM, which is the root of the trace of the pod
+	spanContext trace.SpanContext
+	// podRef is the pod that events are recorded on
+	podRef *v1.ObjectReference
 }
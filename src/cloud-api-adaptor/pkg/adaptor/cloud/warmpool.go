@@ -0,0 +1,650 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package cloud
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	retry "github.com/avast/retry-go/v4"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/paths"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/userdata"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tlsutil"
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+	putil "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util/cloudinit"
+)
+
+// A warm pool keeps pod VMs booted before pods are created, so that StartVM does not wait for
+// an instance to be created and booted. Warm pool VMs boot with a provision config only, and
+// process-user-data in the VM waits for the per-pod cloud config, which is delivered by
+// StartVM over a mutually authenticated TLS connection when a pod claims the VM.
+
+const (
+	// WarmPoolStateFile is the name of the file under PodsDir that records the instances of the warm pool.
+	// Instances left behind by a previous cloud-api-adaptor process are deleted at start up.
+	WarmPoolStateFile = "warm-pool.json"
+
+	warmPoolRefillInterval    = 30 * time.Second
+	warmInstanceBootTimeout   = 15 * time.Minute
+	warmInstanceDeleteTimeout = 10 * time.Minute
+)
+
+// WarmPoolSpec is the number of idle pod VMs to keep for an instance type and an image.
+// Empty InstanceType and Image stand for the defaults of the cloud provider.
+type WarmPoolSpec struct {
+	InstanceType string
+	Image        string
+	Size         int
+}
+
+// WarmPoolSpecs is a comma separated list of warm pool specs in the form of [<instance type>[:<image>]=]<size>
+type WarmPoolSpecs []WarmPoolSpec
+
+func (s *WarmPoolSpecs) String() string {
+	var specs []string
+	for _, spec := range *s {
+		key := spec.InstanceType
+		if spec.Image != "" {
+			key += ":" + spec.Image
+		}
+		if key == "" {
+			specs = append(specs, strconv.Itoa(spec.Size))
+		} else {
+			specs = append(specs, fmt.Sprintf("%s=%d", key, spec.Size))
+		}
+	}
+	return strings.Join(specs, ",")
+}
+
+func (s *WarmPoolSpecs) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var spec WarmPoolSpec
+
+		key, size, found := strings.Cut(part, "=")
+		if !found {
+			key, size = "", part
+		}
+		spec.InstanceType, spec.Image, _ = strings.Cut(key, ":")
+
+		n, err := strconv.Atoi(size)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid warm pool size in %q", part)
+		}
+		spec.Size = n
+
+		*s = append(*s, spec)
+	}
+	return nil
+}
+
+type warmPoolKey struct {
+	instanceType string
+	image        string
+}
+
+// warmPoolKeyOf returns the warm pool key for a pod VM spec. Pod VMs with resource requirements
+// other than an instance type and an image are never served from the warm pool.
+func warmPoolKeyOf(spec provider.InstanceTypeSpec) (warmPoolKey, bool) {
+	if spec.VCPUs != 0 || spec.Memory != 0 || spec.GPUs != 0 || spec.MultiNic || len(spec.Volumes) > 0 {
+		return warmPoolKey{}, false
+	}
+	return warmPoolKey{instanceType: spec.InstanceType, image: spec.Image}, true
+}
+
+type warmInstance struct {
+	instance   *provider.Instance
+	serverName string
+	key        warmPoolKey
+}
+
+type warmInstanceState struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// warmProvisioner talks to process-user-data running in warm pool VMs
+type warmProvisioner interface {
+	// Ping returns nil when a warm pool VM is ready to receive a cloud config
+	Ping(ctx context.Context, w *warmInstance) error
+	// Provision delivers a per-pod cloud config to a warm pool VM
+	Provision(ctx context.Context, w *warmInstance, cloudConfig cloudinit.CloudConfigGenerator) error
+}
+
+type warmPool struct {
+	provider    provider.Provider
+	provisioner warmProvisioner
+	// bootstrap returns the user data of a warm pool VM
+	bootstrap   func(serverName string) (cloudinit.CloudConfigGenerator, error)
+	sizes       map[warmPoolKey]int
+	statePath   string
+	bootTimeout time.Duration
+	// publish records the IDs of the instances that are not claimed, or claimed but not tracked by a PeerPod yet,
+	// outside of the worker node, so that the orphan collector of peerpod-ctrl can tell them apart from claimed
+	// instances whose PeerPod is lost
+	publish func(ctx context.Context, instanceIDs []string) error
+
+	mutex     sync.Mutex
+	idle      map[warmPoolKey][]*warmInstance
+	filling   map[warmPoolKey]int
+	instances map[string]*warmInstance
+	// claimed is the IDs of claimed instances that are still published until Release is called
+	claimed map[string]bool
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	refillCh  chan struct{}
+	publishCh chan struct{}
+	wg        sync.WaitGroup
+}
+
+func newWarmPool(specs WarmPoolSpecs, provider provider.Provider, provisioner warmProvisioner, bootstrap func(string) (cloudinit.CloudConfigGenerator, error), statePath string) *warmPool {
+	sizes := make(map[warmPoolKey]int)
+	for _, spec := range specs {
+		sizes[warmPoolKey{instanceType: spec.InstanceType, image: spec.Image}] += spec.Size
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &warmPool{
+		provider:    provider,
+		provisioner: provisioner,
+		bootstrap:   bootstrap,
+		sizes:       sizes,
+		statePath:   statePath,
+		bootTimeout: warmInstanceBootTimeout,
+		idle:        make(map[warmPoolKey][]*warmInstance),
+		filling:     make(map[warmPoolKey]int),
+		instances:   make(map[string]*warmInstance),
+		claimed:     make(map[string]bool),
+		ctx:         ctx,
+		cancel:      cancel,
+		refillCh:    make(chan struct{}, 1),
+		publishCh:   make(chan struct{}, 1),
+	}
+}
+
+// Start deletes instances left behind by a previous process, and fills the pool in the background
+func (p *warmPool) Start() {
+	if p.publish != nil {
+		p.publishCh <- struct{}{}
+		p.wg.Add(1)
+		go p.runPublisher()
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		p.deleteStaleInstances()
+
+		ticker := time.NewTicker(warmPoolRefillInterval)
+		defer ticker.Stop()
+
+		for {
+			p.refill()
+
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-ticker.C:
+			case <-p.refillCh:
+			}
+		}
+	}()
+}
+
+// Drain stops refilling the pool and deletes all idle and booting instances
+func (p *warmPool) Drain() {
+	p.cancel()
+	p.wg.Wait()
+
+	p.mutex.Lock()
+	instances := p.instances
+	p.instances = make(map[string]*warmInstance)
+	p.idle = make(map[warmPoolKey][]*warmInstance)
+	claimed := p.publishedIDs()
+	p.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), warmInstanceDeleteTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var failed []warmInstanceState
+	var mutex sync.Mutex
+
+	for _, w := range instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.provider.DeleteInstance(ctx, w.instance.ID); err != nil {
+				logger.Printf("failed to delete warm pool instance %s (ID: %s): %v", w.instance.Name, w.instance.ID, err)
+				mutex.Lock()
+				failed = append(failed, warmInstanceState{ID: w.instance.ID, Name: w.instance.Name})
+				mutex.Unlock()
+				return
+			}
+			logger.Printf("deleted warm pool instance %s (ID: %s)", w.instance.Name, w.instance.ID)
+		}()
+	}
+	wg.Wait()
+
+	// Claimed instances are not deleted, so they remain published until their PeerPods are confirmed
+	if p.publish != nil {
+		if err := p.publish(ctx, claimed); err != nil {
+			logger.Printf("failed to publish warm pool instances: %v", err)
+		}
+	}
+
+	// Instances that failed to be deleted are retried at the next start up
+	if err := p.writeState(failed); err != nil {
+		logger.Printf("failed to store warm pool state: %v", err)
+	}
+}
+
+// Claim removes an idle instance that matches a pod VM spec from the pool. It returns nil if no instance is available.
+func (p *warmPool) Claim(spec provider.InstanceTypeSpec) *warmInstance {
+	key, ok := warmPoolKeyOf(spec)
+	if !ok {
+		return nil
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	idle := p.idle[key]
+	if len(idle) == 0 {
+		return nil
+	}
+
+	w := idle[0]
+	p.idle[key] = idle[1:]
+	delete(p.instances, w.instance.ID)
+	p.claimed[w.instance.ID] = true
+	p.saveState()
+
+	select {
+	case p.refillCh <- struct{}{}:
+	default:
+	}
+
+	return w
+}
+
+// Provision delivers a per-pod cloud config to a claimed instance
+func (p *warmPool) Provision(ctx context.Context, w *warmInstance, cloudConfig cloudinit.CloudConfigGenerator) error {
+	return p.provisioner.Provision(ctx, w, cloudConfig)
+}
+
+// Release stops publishing a claimed instance. It is called once the instance is tracked by a PeerPod,
+// or once the instance is deleted. Until then, the orphan collector does not delete the instance, although
+// its name does not match the pod.
+func (p *warmPool) Release(instanceID string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.claimed[instanceID] {
+		return
+	}
+	delete(p.claimed, instanceID)
+
+	select {
+	case p.publishCh <- struct{}{}:
+	default:
+	}
+}
+
+// Discard deletes a claimed instance that cannot be used
+func (p *warmPool) Discard(w *warmInstance) {
+	p.Release(w.instance.ID)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), warmInstanceDeleteTimeout)
+		defer cancel()
+		if err := p.provider.DeleteInstance(ctx, w.instance.ID); err != nil {
+			logger.Printf("failed to delete warm pool instance %s (ID: %s): %v", w.instance.Name, w.instance.ID, err)
+		}
+	}()
+}
+
+func (p *warmPool) refill() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for key, size := range p.sizes {
+		for n := size - len(p.idle[key]) - p.filling[key]; n > 0; n-- {
+			if p.ctx.Err() != nil {
+				return
+			}
+			p.filling[key]++
+			p.wg.Add(1)
+			go p.fill(key)
+		}
+	}
+}
+
+func (p *warmPool) fill(key warmPoolKey) {
+	defer p.wg.Done()
+	defer func() {
+		p.mutex.Lock()
+		p.filling[key]--
+		p.mutex.Unlock()
+	}()
+
+	id, err := randomID()
+	if err != nil {
+		logger.Printf("failed to generate a warm pool instance ID: %v", err)
+		return
+	}
+
+	serverName := putil.GenerateInstanceName(putil.WarmPoolPodName, id, 63)
+
+	cloudConfig, err := p.bootstrap(serverName)
+	if err != nil {
+		logger.Printf("failed to generate user data of warm pool instance %s: %v", serverName, err)
+		return
+	}
+
+	spec := provider.InstanceTypeSpec{
+		InstanceType: key.instanceType,
+		Image:        key.image,
+	}
+
+	instance, err := p.provider.CreateInstance(p.ctx, putil.WarmPoolPodName, id, cloudConfig, spec)
+	if err != nil {
+		logger.Printf("failed to create warm pool instance %s: %v", serverName, err)
+		if errors.Is(err, provider.ErrInvalidSpec) {
+			// Instances of this spec will never be created, so the pool stops trying
+			logger.Printf("warm pool instances of type %q and image %q are disabled", key.instanceType, key.image)
+			p.mutex.Lock()
+			p.sizes[key] = 0
+			p.mutex.Unlock()
+		}
+		return
+	}
+
+	w := &warmInstance{
+		instance:   instance,
+		serverName: serverName,
+		key:        key,
+	}
+
+	p.mutex.Lock()
+	p.instances[instance.ID] = w
+	p.saveState()
+	p.mutex.Unlock()
+
+	logger.Printf("created warm pool instance %s (ID: %s)", instance.Name, instance.ID)
+
+	if err := p.waitReady(w); err != nil {
+		if p.ctx.Err() != nil {
+			// Drain deletes the instance
+			return
+		}
+
+		logger.Printf("warm pool instance %s (ID: %s) is not ready: %v", instance.Name, instance.ID, err)
+
+		p.mutex.Lock()
+		delete(p.instances, instance.ID)
+		p.saveState()
+		p.mutex.Unlock()
+
+		p.Discard(w)
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.ctx.Err() == nil {
+		p.idle[key] = append(p.idle[key], w)
+		logger.Printf("warm pool instance %s (ID: %s) is ready", instance.Name, instance.ID)
+	}
+}
+
+func (p *warmPool) waitReady(w *warmInstance) error {
+	if len(w.instance.IPs) == 0 {
+		return errors.New("instance IP is not available")
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, p.bootTimeout)
+	defer cancel()
+
+	return retry.Do(
+		func() error {
+			return p.provisioner.Ping(ctx, w)
+		},
+		retry.Attempts(0),
+		retry.Context(ctx),
+		retry.MaxDelay(10*time.Second),
+		retry.LastErrorOnly(true),
+	)
+}
+
+// deleteStaleInstances deletes instances recorded by a previous cloud-api-adaptor process
+func (p *warmPool) deleteStaleInstances() {
+	data, err := os.ReadFile(p.statePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Printf("failed to read %s: %v", p.statePath, err)
+		}
+		return
+	}
+
+	var states []warmInstanceState
+	if err := json.Unmarshal(data, &states); err != nil {
+		logger.Printf("failed to decode %s: %v", p.statePath, err)
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, warmInstanceDeleteTimeout)
+	defer cancel()
+
+	var failed []warmInstanceState
+	for _, state := range states {
+		logger.Printf("deleting stale warm pool instance %s (ID: %s)", state.Name, state.ID)
+		if err := p.provider.DeleteInstance(ctx, state.ID); err != nil {
+			logger.Printf("failed to delete stale warm pool instance %s: %v", state.ID, err)
+			failed = append(failed, state)
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, w := range p.instances {
+		failed = append(failed, warmInstanceState{ID: w.instance.ID, Name: w.instance.Name})
+	}
+	if err := p.writeState(failed); err != nil {
+		logger.Printf("failed to store warm pool state: %v", err)
+	}
+}
+
+// saveState records all instances of the pool and has them published. The caller must hold the mutex.
+func (p *warmPool) saveState() {
+	var states []warmInstanceState
+	for _, w := range p.instances {
+		states = append(states, warmInstanceState{ID: w.instance.ID, Name: w.instance.Name})
+	}
+	if err := p.writeState(states); err != nil {
+		logger.Printf("failed to store warm pool state: %v", err)
+	}
+
+	select {
+	case p.publishCh <- struct{}{}:
+	default:
+	}
+}
+
+// runPublisher publishes the instances of the pool when they change. Failed attempts are retried.
+func (p *warmPool) runPublisher() {
+	defer p.wg.Done()
+
+	var retryCh <-chan time.Time
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.publishCh:
+		case <-retryCh:
+		}
+
+		p.mutex.Lock()
+		ids := p.publishedIDs()
+		p.mutex.Unlock()
+
+		retryCh = nil
+		if err := p.publish(p.ctx, ids); err != nil {
+			logger.Printf("failed to publish warm pool instances: %v", err)
+			retryCh = time.After(warmPoolRefillInterval)
+		}
+	}
+}
+
+// publishedIDs returns the sorted IDs of the instances and the claimed instances. The caller must hold the mutex.
+func (p *warmPool) publishedIDs() []string {
+	var ids []string
+	for id := range p.instances {
+		ids = append(ids, id)
+	}
+	for id := range p.claimed {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func (p *warmPool) writeState(states []warmInstanceState) error {
+	if len(states) == 0 {
+		if err := os.Remove(p.statePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(states)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.statePath, data, 0o600)
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// newWarmPoolBootstrap returns a function that generates the user data of warm pool VMs.
+// The user data contains a provision config with a server certificate for the instance,
+// so that only cloud-api-adaptor can deliver a cloud config to the instance.
+func newWarmPoolBootstrap(caService tlsutil.CAService, clientCA []byte, tlsConfig *tlsutil.TLSConfig, forwarderPort string) func(string) (cloudinit.CloudConfigGenerator, error) {
+	return func(serverName string) (cloudinit.CloudConfigGenerator, error) {
+		certPEM, keyPEM, err := caService.Issue(serverName)
+		if err != nil {
+			return nil, fmt.Errorf("creating TLS certificate for %s: %w", serverName, err)
+		}
+
+		pc := userdata.ProvisionConfig{
+			ListenAddr:    net.JoinHostPort("0.0.0.0", forwarderPort),
+			TLSServerCert: string(certPEM),
+			TLSServerKey:  string(keyPEM),
+			TLSClientCA:   string(clientCA),
+			MinTLSVersion: tlsConfig.MinTLSVersion,
+			CipherSuites:  tlsConfig.CipherSuites,
+		}
+
+		data, err := json.MarshalIndent(pc, "", "    ")
+		if err != nil {
+			return nil, fmt.Errorf("generating JSON data: %w", err)
+		}
+
+		return &cloudinit.CloudConfig{
+			WriteFiles: []cloudinit.WriteFile{
+				{
+					Path:    paths.ProvisionCfgPath,
+					Content: string(data),
+				},
+			},
+		}, nil
+	}
+}
+
+// httpsProvisioner delivers cloud configs to the provision endpoint of process-user-data.
+// The endpoint listens on the forwarder port, since agent-protocol-forwarder starts only after
+// the cloud config is provisioned.
+type httpsProvisioner struct {
+	tlsConfig     *tlsutil.TLSConfig
+	forwarderPort string
+	dialer        putil.ContextDialer
+}
+
+func (p *httpsProvisioner) do(ctx context.Context, w *warmInstance, method string, body io.Reader) error {
+	config, err := tlsutil.GetTLSConfigFor(p.tlsConfig)
+	if err != nil {
+		return fmt.Errorf("creating TLS config: %w", err)
+	}
+	config.ServerName = w.serverName
+
+	transport := &http.Transport{TLSClientConfig: config}
+	if p.dialer != nil {
+		transport.DialContext = p.dialer.DialContext
+	}
+
+	client := &http.Client{
+		Transport: transport,
+	}
+	defer client.CloseIdleConnections()
+
+	url := "https://" + net.JoinHostPort(w.instance.IPs[0].String(), p.forwarderPort) + userdata.ProvisionURLPath
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return fmt.Errorf("%s %s: %s: %s", method, url, res.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+func (p *httpsProvisioner) Ping(ctx context.Context, w *warmInstance) error {
+	return p.do(ctx, w, http.MethodGet, nil)
+}
+
+func (p *httpsProvisioner) Provision(ctx context.Context, w *warmInstance, cloudConfig cloudinit.CloudConfigGenerator) error {
+	data, err := cloudConfig.Generate()
+	if err != nil {
+		return fmt.Errorf("generating cloud config: %w", err)
+	}
+	return p.do(ctx, w, http.MethodPut, strings.NewReader(data))
+}
@@ -0,0 +1,302 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	cri "github.com/containerd/containerd/pkg/cri/annotations"
+	pb "github.com/kata-containers/kata-containers/src/runtime/protocols/hypervisor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/forwarder"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/paths"
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util/cloudinit"
+)
+
+type warmPoolProvider struct {
+	mutex   sync.Mutex
+	created map[string]provider.InstanceTypeSpec
+	deleted []string
+}
+
+func (p *warmPoolProvider) CreateInstance(ctx context.Context, podName, sandboxID string, cloudConfig cloudinit.CloudConfigGenerator, spec provider.InstanceTypeSpec) (*provider.Instance, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	id := fmt.Sprintf("%s-%.8s", podName, sandboxID)
+	if p.created == nil {
+		p.created = make(map[string]provider.InstanceTypeSpec)
+	}
+	p.created[id] = spec
+
+	return &provider.Instance{
+		ID:   id,
+		Name: id,
+		IPs:  []netip.Addr{netip.MustParseAddr("127.0.0.1")},
+	}, nil
+}
+
+func (p *warmPoolProvider) DeleteInstance(ctx context.Context, instanceID string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.deleted = append(p.deleted, instanceID)
+	return nil
+}
+
+func (p *warmPoolProvider) Teardown() error {
+	return nil
+}
+
+func (p *warmPoolProvider) ConfigVerifier() error {
+	return nil
+}
+
+func (p *warmPoolProvider) counts() (created, deleted int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.created), len(p.deleted)
+}
+
+type mockProvisioner struct {
+	mutex       sync.Mutex
+	provisioned map[string]string
+}
+
+func (p *mockProvisioner) Ping(ctx context.Context, w *warmInstance) error {
+	return nil
+}
+
+func (p *mockProvisioner) Provision(ctx context.Context, w *warmInstance, cloudConfig cloudinit.CloudConfigGenerator) error {
+	data, err := cloudConfig.Generate()
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.provisioned == nil {
+		p.provisioned = make(map[string]string)
+	}
+	p.provisioned[w.instance.ID] = data
+	return nil
+}
+
+func mockBootstrap(serverName string) (cloudinit.CloudConfigGenerator, error) {
+	return &cloudinit.CloudConfig{
+		WriteFiles: []cloudinit.WriteFile{{Path: paths.ProvisionCfgPath, Content: serverName}},
+	}, nil
+}
+
+func (p *warmPool) idleCount() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var n int
+	for _, idle := range p.idle {
+		n += len(idle)
+	}
+	return n
+}
+
+func TestWarmPoolSpecs(t *testing.T) {
+	var specs WarmPoolSpecs
+
+	require.NoError(t, specs.Set("2, t3.large=1,t3.small:ami-123=3"))
+	assert.Equal(t, WarmPoolSpecs{
+		{Size: 2},
+		{InstanceType: "t3.large", Size: 1},
+		{InstanceType: "t3.small", Image: "ami-123", Size: 3},
+	}, specs)
+	assert.Equal(t, "2,t3.large=1,t3.small:ami-123=3", specs.String())
+
+	assert.Error(t, specs.Set("t3.large=abc"))
+	assert.Error(t, specs.Set("-1"))
+}
+
+func TestWarmPool(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, WarmPoolStateFile)
+
+	// An instance recorded by a previous process is deleted at start up
+	stale, err := json.Marshal([]warmInstanceState{{ID: "stale", Name: "podvm-warm-pool-stale"}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(statePath, stale, 0o600))
+
+	p := &warmPoolProvider{}
+	specs := WarmPoolSpecs{{Size: 2}, {InstanceType: "large", Size: 1}}
+
+	pool := newWarmPool(specs, p, &mockProvisioner{}, mockBootstrap, statePath)
+
+	var publishMutex sync.Mutex
+	var published []string
+	pool.publish = func(ctx context.Context, instanceIDs []string) error {
+		publishMutex.Lock()
+		defer publishMutex.Unlock()
+		published = instanceIDs
+		return nil
+	}
+	lastPublished := func() []string {
+		publishMutex.Lock()
+		defer publishMutex.Unlock()
+		return published
+	}
+
+	pool.Start()
+
+	require.Eventually(t, func() bool { return pool.idleCount() == 3 }, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return len(lastPublished()) == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	_, deleted := p.counts()
+	assert.Equal(t, 1, deleted)
+
+	data, err := os.ReadFile(statePath)
+	require.NoError(t, err)
+	var states []warmInstanceState
+	require.NoError(t, json.Unmarshal(data, &states))
+	assert.Len(t, states, 3)
+
+	// Pod VMs with resource requirements are not served from the pool
+	assert.Nil(t, pool.Claim(provider.InstanceTypeSpec{VCPUs: 2}))
+	assert.Nil(t, pool.Claim(provider.InstanceTypeSpec{InstanceType: "small"}))
+
+	w := pool.Claim(provider.InstanceTypeSpec{InstanceType: "large"})
+	require.NotNil(t, w)
+	p.mutex.Lock()
+	assert.Equal(t, "large", p.created[w.instance.ID].InstanceType)
+	p.mutex.Unlock()
+
+	// The pool is refilled in the background. The claimed instance remains published until it is released,
+	// since the orphan collector would otherwise delete it if its PeerPod is not created.
+	require.Eventually(t, func() bool {
+		created, _ := p.counts()
+		return created == 4 && pool.idleCount() == 3 && len(lastPublished()) == 4
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, lastPublished(), w.instance.ID)
+
+	pool.Release(w.instance.ID)
+	require.Eventually(t, func() bool { return len(lastPublished()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	assert.NotContains(t, lastPublished(), w.instance.ID)
+
+	pool.Drain()
+
+	assert.Empty(t, lastPublished())
+
+	created, deleted := p.counts()
+	assert.Equal(t, 4, created)
+	assert.Equal(t, 1+3, deleted)
+	assert.NotContains(t, p.deleted, w.instance.ID)
+	assert.NoFileExists(t, statePath)
+	assert.Nil(t, pool.Claim(provider.InstanceTypeSpec{}))
+}
+
+func TestWarmPoolClaimedInstance(t *testing.T) {
+	p := &warmPoolProvider{}
+	pool := newWarmPool(WarmPoolSpecs{{Size: 2}}, p, &mockProvisioner{}, mockBootstrap, filepath.Join(t.TempDir(), WarmPoolStateFile))
+
+	var publishMutex sync.Mutex
+	var published []string
+	pool.publish = func(ctx context.Context, instanceIDs []string) error {
+		publishMutex.Lock()
+		defer publishMutex.Unlock()
+		published = instanceIDs
+		return nil
+	}
+	lastPublished := func() []string {
+		publishMutex.Lock()
+		defer publishMutex.Unlock()
+		return published
+	}
+
+	pool.Start()
+
+	require.Eventually(t, func() bool { return pool.idleCount() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	// A claimed instance whose PeerPod could not be created is never released
+	unowned := pool.Claim(provider.InstanceTypeSpec{})
+	require.NotNil(t, unowned)
+	discarded := pool.Claim(provider.InstanceTypeSpec{})
+	require.NotNil(t, discarded)
+
+	// A discarded instance is deleted, so it is no longer published
+	pool.Discard(discarded)
+	require.Eventually(t, func() bool {
+		created, _ := p.counts()
+		ids := lastPublished()
+		return created == 4 && len(ids) == 3 && !slices.Contains(ids, discarded.instance.ID)
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, lastPublished(), unowned.instance.ID)
+
+	// The unowned instance is not deleted when the pool is drained, and remains published
+	pool.Drain()
+
+	assert.Equal(t, []string{unowned.instance.ID}, lastPublished())
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	assert.NotContains(t, p.deleted, unowned.instance.ID)
+}
+
+func TestCloudServiceWarmPool(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	p := &warmPoolProvider{}
+	provisioner := &mockProvisioner{}
+
+	cfg := &ServerConfig{
+		PodsDir:       dir,
+		ForwarderPort: forwarder.DefaultListenPort,
+	}
+
+	s := NewService(p, &mockProxyFactory{podsDir: dir}, &mockWorkerNode{}, cfg).(*cloudService)
+	s.warmPool = newWarmPool(WarmPoolSpecs{{Size: 1}}, p, provisioner, mockBootstrap, filepath.Join(dir, WarmPoolStateFile))
+	s.warmPool.Start()
+
+	require.Eventually(t, func() bool { return s.warmPool.idleCount() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	sandboxID := "123"
+	_, err := s.CreateVM(ctx, &pb.CreateVMRequest{
+		Id: sandboxID,
+		Annotations: map[string]string{
+			cri.SandboxNamespace: "default",
+			cri.SandboxName:      "mypod",
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = s.StartVM(ctx, &pb.StartVMRequest{Id: sandboxID})
+	require.NoError(t, err)
+
+	instanceID, err := s.GetInstanceID(ctx, "default", "mypod", false)
+	require.NoError(t, err)
+
+	// The pod runs on the warm pool instance, which received the per-pod cloud config
+	provisioner.mutex.Lock()
+	userData, ok := provisioner.provisioned[instanceID]
+	provisioner.mutex.Unlock()
+	require.True(t, ok, "instance %s is not provisioned", instanceID)
+	assert.Contains(t, userData, forwarder.DefaultConfigPath)
+
+	_, err = s.StopVM(ctx, &pb.StopVMRequest{Id: sandboxID})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Teardown())
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	assert.NotContains(t, p.created, "mypod-123")
+	assert.Contains(t, p.deleted, instanceID)
+	assert.Len(t, p.deleted, len(p.created))
+}
@@ -0,0 +1,115 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package defaultpolicy
+
+import (
+	"fmt"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// SelectorsKey is the key of the selectors in the ConfigMap of default policies.
+// The other keys of the ConfigMap are kata agent policies, which are referred to by selectors.
+const SelectorsKey = "selectors.yaml"
+
+// Selector selects the default policy of pods whose attributes match all of its conditions.
+// Conditions that are empty match any pod.
+type Selector struct {
+	Name            string            `yaml:"name"`
+	Namespaces      []string          `yaml:"namespaces"`
+	NamespaceLabels map[string]string `yaml:"namespaceLabels"`
+	PodLabels       map[string]string `yaml:"podLabels"`
+	// Policy is the key of the policy in the ConfigMap
+	Policy string `yaml:"policy"`
+}
+
+// Set is a set of default policies. The first selector that matches a pod selects its default policy.
+type Set struct {
+	Selectors []Selector
+	Policies  map[string]string
+}
+
+// Pod is a pod whose default policy is selected
+type Pod struct {
+	Namespace       string
+	PodLabels       map[string]string
+	NamespaceLabels map[string]string
+}
+
+// Parse parses and validates the data of a ConfigMap of default policies. Unknown fields are rejected.
+func Parse(data map[string]string) (*Set, error) {
+	selectors, ok := data[SelectorsKey]
+	if !ok {
+		return nil, fmt.Errorf("%s is missing", SelectorsKey)
+	}
+
+	set := &Set{Policies: map[string]string{}}
+	if err := yaml.UnmarshalStrict([]byte(selectors), &set.Selectors); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", SelectorsKey, err)
+	}
+
+	for key, policy := range data {
+		if key != SelectorsKey {
+			set.Policies[key] = policy
+		}
+	}
+
+	for i, selector := range set.Selectors {
+		if selector.Name == "" {
+			set.Selectors[i].Name = fmt.Sprintf("selectors[%d]", i)
+		}
+		if _, ok := set.Policies[selector.Policy]; !ok {
+			return nil, fmt.Errorf("policy %q of selector %s is not in the ConfigMap", selector.Policy, set.Selectors[i].Name)
+		}
+	}
+
+	return set, nil
+}
+
+// Select returns the name of the selector that matches the pod and its policy.
+// ok is false when no selector matches.
+func (s *Set) Select(pod *Pod) (name, policy string, ok bool) {
+	for _, selector := range s.Selectors {
+		if selector.matches(pod) {
+			return selector.Name, s.Policies[selector.Policy], true
+		}
+	}
+	return "", "", false
+}
+
+// UsesLabels reports whether a selector refers to labels of pods or namespaces,
+// which are looked up only when one does
+func (s *Set) UsesLabels() bool {
+	for _, selector := range s.Selectors {
+		if len(selector.NamespaceLabels) > 0 || len(selector.PodLabels) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (selector *Selector) matches(pod *Pod) bool {
+	if len(selector.Namespaces) > 0 && !contains(selector.Namespaces, pod.Namespace) {
+		return false
+	}
+	return matchLabels(selector.NamespaceLabels, pod.NamespaceLabels) && matchLabels(selector.PodLabels, pod.PodLabels)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func matchLabels(selector, labels map[string]string) bool {
+	for key, value := range selector {
+		if v, ok := labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package defaultpolicy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSelectors = `
+- name: production
+  namespaceLabels:
+    env: production
+  policy: strict.rego
+- namespaces: [ci, dev]
+  podLabels:
+    debug: "true"
+  policy: allow-all.rego
+- name: default
+  policy: default.rego
+`
+
+func testData() map[string]string {
+	return map[string]string{
+		SelectorsKey:     testSelectors,
+		"strict.rego":    "strict",
+		"allow-all.rego": "allow-all",
+		"default.rego":   "default",
+	}
+}
+
+func TestSelect(t *testing.T) {
+	set, err := Parse(testData())
+	require.NoError(t, err)
+	assert.True(t, set.UsesLabels())
+
+	for _, tc := range []struct {
+		pod    Pod
+		name   string
+		policy string
+	}{
+		{Pod{Namespace: "shop", NamespaceLabels: map[string]string{"env": "production"}}, "production", "strict"},
+		{Pod{Namespace: "ci", PodLabels: map[string]string{"debug": "true"}}, "selectors[1]", "allow-all"},
+		{Pod{Namespace: "shop", PodLabels: map[string]string{"debug": "true"}}, "default", "default"},
+		{Pod{Namespace: "ci"}, "default", "default"},
+	} {
+		name, policy, ok := set.Select(&tc.pod)
+		assert.True(t, ok, tc.pod)
+		assert.Equal(t, tc.name, name, tc.pod)
+		assert.Equal(t, tc.policy, policy, tc.pod)
+	}
+
+	set, err = Parse(map[string]string{SelectorsKey: "- namespaces: [ci]\n  policy: a.rego\n", "a.rego": "a"})
+	require.NoError(t, err)
+	assert.False(t, set.UsesLabels())
+	_, _, ok := set.Select(&Pod{Namespace: "default"})
+	assert.False(t, ok)
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, data := range map[string]map[string]string{
+		"missing selectors": {"default.rego": "default"},
+		"unknown field":     {SelectorsKey: "- policy: default.rego\n  labels: {a: b}\n", "default.rego": "default"},
+		"missing policy":    {SelectorsKey: "- policy: missing.rego\n"},
+	} {
+		_, err := Parse(data)
+		assert.Error(t, err, name)
+	}
+}
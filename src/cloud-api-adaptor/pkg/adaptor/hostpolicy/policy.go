@@ -0,0 +1,212 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package hostpolicy
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Version is the version of the rule set format
+const Version = "v1"
+
+const (
+	ActionAllow = "allow"
+	ActionDeny  = "deny"
+)
+
+// Request is an agent request that is evaluated by a policy
+type Request struct {
+	Method          string
+	Namespace       string
+	Pod             string
+	PodLabels       map[string]string
+	NamespaceLabels map[string]string
+	ContainerID     string
+	// Command is the command line of ExecProcess
+	Command []string
+	// Path is the file path of CopyFile
+	Path string
+}
+
+// Decision is the result of the evaluation of a request
+type Decision struct {
+	Allowed bool
+	// Reason describes the rule that made the decision
+	Reason string
+}
+
+// Policy decides whether agent requests are forwarded to pod VMs. It is evaluated on the worker node
+// in addition to the kata agent policy, which is enforced inside pod VMs.
+type Policy interface {
+	Evaluate(req *Request) Decision
+	// UsesLabels reports whether the policy refers to labels of pods or namespaces,
+	// which are looked up only when it does
+	UsesLabels() bool
+}
+
+// RuleSet is a policy of rules. The first rule that matches a request decides whether it is allowed,
+// and requests that match no rule are decided by the default action.
+type RuleSet struct {
+	Version string `yaml:"version"`
+	// Default is the action for requests that match no rule, allow if empty
+	Default string `yaml:"default"`
+	Rules   []Rule `yaml:"rules"`
+}
+
+// Rule matches requests whose attributes match all of its conditions. Conditions that are empty match any request.
+type Rule struct {
+	Name   string `yaml:"name"`
+	Action string `yaml:"action"`
+	// Methods are agent API methods, e.g. ExecProcess
+	Methods         []string          `yaml:"methods"`
+	Namespaces      []string          `yaml:"namespaces"`
+	NamespaceLabels map[string]string `yaml:"namespaceLabels"`
+	PodLabels       map[string]string `yaml:"podLabels"`
+	// Commands are glob patterns of the executable of ExecProcess, e.g. /bin/* or sh
+	Commands []string `yaml:"commands"`
+	// Paths are glob patterns of the file path of CopyFile. A pattern ending with /** matches any path under a directory.
+	Paths []string `yaml:"paths"`
+}
+
+// Load reads a rule set from a YAML file
+func Load(file string) (*RuleSet, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading host policy %s: %w", file, err)
+	}
+
+	rules, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing host policy %s: %w", file, err)
+	}
+	return rules, nil
+}
+
+// Parse parses and validates a YAML rule set. Unknown fields are rejected.
+func Parse(data []byte) (*RuleSet, error) {
+	var rules RuleSet
+	if err := yaml.UnmarshalStrict(data, &rules); err != nil {
+		return nil, err
+	}
+
+	if rules.Version != Version {
+		return nil, fmt.Errorf("unsupported version %q, expected %q", rules.Version, Version)
+	}
+	if rules.Default == "" {
+		rules.Default = ActionAllow
+	}
+	if !validAction(rules.Default) {
+		return nil, fmt.Errorf("invalid default action %q, expected %s or %s", rules.Default, ActionAllow, ActionDeny)
+	}
+
+	for i, rule := range rules.Rules {
+		if rule.Name == "" {
+			rules.Rules[i].Name = fmt.Sprintf("rules[%d]", i)
+		}
+		if !validAction(rule.Action) {
+			return nil, fmt.Errorf("invalid action %q of rule %s, expected %s or %s", rule.Action, rules.Rules[i].Name, ActionAllow, ActionDeny)
+		}
+		for _, pattern := range append(rule.Commands, rule.Paths...) {
+			if _, err := path.Match(strings.TrimSuffix(pattern, "/**"), ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q of rule %s: %w", pattern, rules.Rules[i].Name, err)
+			}
+		}
+	}
+
+	return &rules, nil
+}
+
+func validAction(action string) bool {
+	return action == ActionAllow || action == ActionDeny
+}
+
+func (r *RuleSet) Evaluate(req *Request) Decision {
+	for _, rule := range r.Rules {
+		if rule.matches(req) {
+			return Decision{
+				Allowed: rule.Action == ActionAllow,
+				Reason:  fmt.Sprintf("rule %s", rule.Name),
+			}
+		}
+	}
+	return Decision{
+		Allowed: r.Default == ActionAllow,
+		Reason:  "default action",
+	}
+}
+
+func (r *RuleSet) UsesLabels() bool {
+	for _, rule := range r.Rules {
+		if len(rule.NamespaceLabels) > 0 || len(rule.PodLabels) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *Rule) matches(req *Request) bool {
+	if len(rule.Methods) > 0 && !contains(rule.Methods, req.Method) {
+		return false
+	}
+	if len(rule.Namespaces) > 0 && !contains(rule.Namespaces, req.Namespace) {
+		return false
+	}
+	if !matchLabels(rule.NamespaceLabels, req.NamespaceLabels) || !matchLabels(rule.PodLabels, req.PodLabels) {
+		return false
+	}
+	if len(rule.Commands) > 0 {
+		if len(req.Command) == 0 || !matchAny(rule.Commands, req.Command[0]) {
+			return false
+		}
+	}
+	if len(rule.Paths) > 0 {
+		if req.Path == "" || !matchAny(rule.Paths, path.Clean(req.Path)) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func matchLabels(selector, labels map[string]string) bool {
+	for key, value := range selector {
+		if v, ok := labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAny reports whether s matches any of the patterns. Patterns without a slash match the base name of s.
+func matchAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if s == dir || strings.HasPrefix(s, dir+"/") {
+				return true
+			}
+			continue
+		}
+		target := s
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(s)
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
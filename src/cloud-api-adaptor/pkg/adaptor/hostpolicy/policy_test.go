@@ -0,0 +1,145 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package hostpolicy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRules = `
+version: v1
+default: allow
+rules:
+- name: allow-exec-of-debug-pods
+  action: allow
+  methods: [ExecProcess]
+  podLabels:
+    debug: "true"
+- name: no-exec-in-production
+  action: deny
+  methods: [ExecProcess]
+  namespaceLabels:
+    env: production
+- name: no-shell
+  action: deny
+  methods: [ExecProcess]
+  commands: [sh, bash, "/tmp/*"]
+- name: protect-etc
+  action: deny
+  methods: [CopyFile]
+  namespaces: [default]
+  paths: ["/etc/**"]
+`
+
+func TestRuleSet(t *testing.T) {
+	rules, err := Parse([]byte(testRules))
+	require.NoError(t, err)
+	assert.True(t, rules.UsesLabels())
+
+	production := map[string]string{"env": "production"}
+
+	tests := []struct {
+		name    string
+		req     Request
+		allowed bool
+		reason  string
+	}{
+		{
+			name:    "exec in production",
+			req:     Request{Method: "ExecProcess", NamespaceLabels: production, Command: []string{"ls"}},
+			allowed: false,
+			reason:  "rule no-exec-in-production",
+		},
+		{
+			name:    "exec in a debug pod in production",
+			req:     Request{Method: "ExecProcess", NamespaceLabels: production, PodLabels: map[string]string{"debug": "true"}},
+			allowed: true,
+			reason:  "rule allow-exec-of-debug-pods",
+		},
+		{
+			name:    "exec of a shell",
+			req:     Request{Method: "ExecProcess", Command: []string{"/bin/sh", "-c", "id"}},
+			allowed: false,
+			reason:  "rule no-shell",
+		},
+		{
+			name:    "exec of a binary in /tmp",
+			req:     Request{Method: "ExecProcess", Command: []string{"/tmp/x"}},
+			allowed: false,
+			reason:  "rule no-shell",
+		},
+		{
+			name:    "exec of another command",
+			req:     Request{Method: "ExecProcess", Command: []string{"/usr/bin/ls"}},
+			allowed: true,
+			reason:  "default action",
+		},
+		{
+			name:    "copy to /etc",
+			req:     Request{Method: "CopyFile", Namespace: "default", Path: "/etc/ssl/../passwd"},
+			allowed: false,
+			reason:  "rule protect-etc",
+		},
+		{
+			name:    "copy to /etc in another namespace",
+			req:     Request{Method: "CopyFile", Namespace: "kube-system", Path: "/etc/passwd"},
+			allowed: true,
+			reason:  "default action",
+		},
+		{
+			name:    "copy to /etcetera",
+			req:     Request{Method: "CopyFile", Namespace: "default", Path: "/etcetera"},
+			allowed: true,
+			reason:  "default action",
+		},
+		{
+			name:    "other method",
+			req:     Request{Method: "CreateContainer", NamespaceLabels: production},
+			allowed: true,
+			reason:  "default action",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := rules.Evaluate(&tt.req)
+			assert.Equal(t, tt.allowed, decision.Allowed)
+			assert.Equal(t, tt.reason, decision.Reason)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"missing version": "rules: []",
+		"unknown version": "version: v2",
+		"unknown field":   "version: v1\nrules:\n- action: deny\n  method: [ExecProcess]",
+		"invalid default": "version: v1\ndefault: block",
+		"invalid action":  "version: v1\nrules:\n- action: block",
+		"invalid pattern": "version: v1\nrules:\n- action: deny\n  paths: [\"/etc/[\"]",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse([]byte(data))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("version: v1\ndefault: deny\n"), 0o600))
+
+	rules, err := Load(file)
+	require.NoError(t, err)
+	assert.False(t, rules.UsesLabels())
+	assert.Equal(t, Decision{Allowed: false, Reason: "default action"}, rules.Evaluate(&Request{Method: "CreateContainer"}))
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,49 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package k8sops
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
+)
+
+// ConfigMapReader reads the data of a ConfigMap
+type ConfigMapReader struct {
+	client    k8sclient.Interface
+	namespace string
+	name      string
+}
+
+// NewConfigMapReader returns a reader of a ConfigMap. configMapName is either <namespace>/<name> or <name>.
+// In the latter case, the namespace of the cloud-api-adaptor service account is used.
+func NewConfigMapReader(configMapName string) (*ConfigMapReader, error) {
+	namespace, name, err := splitObjectName(configMapName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ConfigMap name: %w", err)
+	}
+
+	config, err := getKubeConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s config: %v", err)
+	}
+
+	cli, err := getClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %v", err)
+	}
+
+	return &ConfigMapReader{client: cli, namespace: namespace, name: name}, nil
+}
+
+// Data returns the current data of the ConfigMap
+func (r *ConfigMapReader) Data(ctx context.Context) (map[string]string, error) {
+	cm, err := r.client.CoreV1().ConfigMaps(r.namespace).Get(ctx, r.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("getting ConfigMap %s/%s: %w", r.namespace, r.name, err)
+	}
+	return cm.Data, nil
+}
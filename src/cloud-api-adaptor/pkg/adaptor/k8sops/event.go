@@ -0,0 +1,84 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package k8sops
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const eventComponent = "cloud-api-adaptor"
+
+// PodEventRecorder records Kubernetes Events on pods, so that the progress of pod VMs
+// is shown by kubectl describe pod
+type PodEventRecorder struct {
+	client      k8sclient.Interface
+	broadcaster record.EventBroadcaster
+	recorder    record.EventRecorder
+}
+
+func NewPodEventRecorder() (*PodEventRecorder, error) {
+	config, err := getKubeConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s config: %v", err)
+	}
+
+	cli, err := getClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %v", err)
+	}
+
+	return newPodEventRecorder(cli), nil
+}
+
+func newPodEventRecorder(client k8sclient.Interface) *PodEventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+
+	source := v1.EventSource{
+		Component: eventComponent,
+		Host:      os.Getenv("NODE_NAME"),
+	}
+
+	return &PodEventRecorder{
+		client:      client,
+		broadcaster: broadcaster,
+		recorder:    broadcaster.NewRecorder(scheme.Scheme, source),
+	}
+}
+
+// PodReference returns a reference to a pod that events are recorded on
+func (r *PodEventRecorder) PodReference(ctx context.Context, namespace, name string) (*v1.ObjectReference, error) {
+	pod, err := r.client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("getting pod %s/%s: %w", namespace, name, err)
+	}
+
+	return &v1.ObjectReference{
+		Kind:            "Pod",
+		APIVersion:      "v1",
+		Namespace:       pod.Namespace,
+		Name:            pod.Name,
+		UID:             pod.UID,
+		ResourceVersion: pod.ResourceVersion,
+	}, nil
+}
+
+// Eventf records an event on a pod asynchronously
+func (r *PodEventRecorder) Eventf(pod *v1.ObjectReference, eventType, reason, messageFmt string, args ...interface{}) {
+	r.recorder.Eventf(pod, eventType, reason, messageFmt, args...)
+}
+
+// Shutdown stops recording events. Events that are not sent yet are discarded.
+func (r *PodEventRecorder) Shutdown() {
+	r.broadcaster.Shutdown()
+}
@@ -0,0 +1,49 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package k8sops
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPodEventRecorder(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "mypod", Namespace: "default", UID: "1234"},
+	})
+
+	recorder := newPodEventRecorder(client)
+	defer recorder.Shutdown()
+
+	_, err := recorder.PodReference(ctx, "default", "nosuchpod")
+	assert.Error(t, err)
+
+	pod, err := recorder.PodReference(ctx, "default", "mypod")
+	require.NoError(t, err)
+
+	recorder.Eventf(pod, v1.EventTypeNormal, "InstanceCreated", "Created instance %s", "i-123")
+
+	var events *v1.EventList
+	require.Eventually(t, func() bool {
+		events, err = client.CoreV1().Events("default").List(ctx, metav1.ListOptions{})
+		return err == nil && len(events.Items) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	event := events.Items[0]
+	assert.Equal(t, "InstanceCreated", event.Reason)
+	assert.Equal(t, "Created instance i-123", event.Message)
+	assert.Equal(t, v1.EventTypeNormal, event.Type)
+	assert.Equal(t, "Pod", event.InvolvedObject.Kind)
+	assert.Equal(t, "mypod", event.InvolvedObject.Name)
+	assert.Equal(t, "1234", string(event.InvolvedObject.UID))
+	assert.Equal(t, eventComponent, event.Source.Component)
+}
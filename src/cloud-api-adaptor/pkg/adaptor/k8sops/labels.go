@@ -0,0 +1,46 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package k8sops
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
+)
+
+// LabelLookup looks up labels of pods and their namespaces
+type LabelLookup struct {
+	client k8sclient.Interface
+}
+
+func NewLabelLookup() (*LabelLookup, error) {
+	config, err := getKubeConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s config: %v", err)
+	}
+
+	cli, err := getClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %v", err)
+	}
+
+	return &LabelLookup{client: cli}, nil
+}
+
+// PodLabels returns the labels of a pod and its namespace
+func (l *LabelLookup) PodLabels(ctx context.Context, namespace, name string) (podLabels, namespaceLabels map[string]string, err error) {
+	pod, err := l.client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting pod %s/%s: %w", namespace, name, err)
+	}
+
+	ns, err := l.client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting namespace %s: %w", namespace, err)
+	}
+
+	return pod.Labels, ns.Labels, nil
+}
@@ -210,6 +210,35 @@ func addAuths(cli *k8sclient.Clientset, namespace string, secretName string, aut
 	return nil
 }
 
+// AnnotateNode sets an annotation of the node of NODE_NAME, or removes the annotation if value is empty
+func AnnotateNode(ctx context.Context, key, value string) error {
+	nodeName := os.Getenv("NODE_NAME")
+
+	config, err := getKubeConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s config: %v", err)
+	}
+
+	cli, err := getClient(config)
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %v", err)
+	}
+
+	var v *string
+	if value != "" {
+		v = &value
+	}
+	patch, err := json.Marshal(map[string]any{"metadata": map[string]any{"annotations": map[string]*string{key: v}}})
+	if err != nil {
+		return err
+	}
+
+	if _, err := cli.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to patch annotation %s of node %s: %w", key, nodeName, err)
+	}
+	return nil
+}
+
 // patchNodeAnnotation sets a single annotation on a node via a merge patch.
 func patchNodeAnnotation(c *k8sclient.Clientset, nodeName, key, value string) error {
 	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, key, value)
@@ -121,7 +121,11 @@ func (s *PeerPodService) ReleasePeerPod(podname string, podns string, instanceID
 	defer s.mutex.Unlock()
 	ownedPPName, ok := s.podToPP[string(pod.UID)]
 	if !ok {
-		return errors.New("pod to PeerPod mapping not found")
+		// The mapping is lost when cloud-api-adaptor restarts, so look up the PeerPod from the API server
+		ownedPPName, err = s.findPeerPod(pod, instanceID)
+		if err != nil {
+			return fmt.Errorf("pod to PeerPod mapping not found: %w", err)
+		}
 	}
 	result := peerPodV1alpha1.PeerPod{}
 	patch := []byte(`[{"op": "remove", "path": "/metadata/finalizers"}]`)
@@ -133,3 +137,23 @@ func (s *PeerPodService) ReleasePeerPod(podname string, podns string, instanceID
 	logger.Printf("%s's owned PeerPod object can now be deleted", podname)
 	return nil
 }
+
+// find the PeerPod object owned by the pod for a given instance
+func (s *PeerPodService) findPeerPod(pod *v1.Pod, instanceID string) (string, error) {
+	ppList := peerPodV1alpha1.PeerPodList{}
+	err := s.uclient.Get().Namespace(pod.Namespace).Resource("peerPods").Do(context.TODO()).Into(&ppList)
+	if err != nil {
+		return "", err
+	}
+	for _, pp := range ppList.Items {
+		if pp.Spec.InstanceID != instanceID {
+			continue
+		}
+		for _, ref := range pp.OwnerReferences {
+			if ref.UID == pod.UID {
+				return pp.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no PeerPod owned by %s for instance %s", pod.Name, instanceID)
+}
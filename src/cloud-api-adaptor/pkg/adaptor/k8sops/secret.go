@@ -0,0 +1,124 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package k8sops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tlsutil"
+)
+
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+type secretCredentialStore struct {
+	client    k8sclient.Interface
+	namespace string
+	name      string
+}
+
+// NewSecretCredentialStore returns a credential store backed by a Kubernetes Secret.
+// secretName is either <namespace>/<name> or <name>. In the latter case, the namespace
+// of the cloud-api-adaptor service account is used.
+func NewSecretCredentialStore(secretName string) (tlsutil.CredentialStore, error) {
+	namespace, name, err := splitObjectName(secretName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret name: %w", err)
+	}
+
+	config, err := getKubeConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s config: %v", err)
+	}
+
+	cli, err := getClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %v", err)
+	}
+
+	return newSecretCredentialStore(cli, namespace, name), nil
+}
+
+// splitObjectName splits <namespace>/<name> or <name>. In the latter case, the namespace
+// of the cloud-api-adaptor service account is used.
+func splitObjectName(objectName string) (namespace, name string, err error) {
+	namespace, name, found := strings.Cut(objectName, "/")
+	if !found {
+		name = namespace
+		data, err := os.ReadFile(serviceAccountNamespaceFile)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to determine the namespace of %s: %w", name, err)
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+	if namespace == "" || name == "" {
+		return "", "", fmt.Errorf("%q", objectName)
+	}
+	return namespace, name, nil
+}
+
+func newSecretCredentialStore(client k8sclient.Interface, namespace, name string) *secretCredentialStore {
+	return &secretCredentialStore{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+func (s *secretCredentialStore) Load(ctx context.Context) (*tlsutil.Credentials, error) {
+	secret, err := s.client.CoreV1().Secrets(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret %s/%s: %w", s.namespace, s.name, err)
+	}
+
+	creds := &tlsutil.Credentials{
+		CACert:     secret.Data[tlsutil.CACertName],
+		CAKey:      secret.Data[tlsutil.CAKeyName],
+		ClientCert: secret.Data[tlsutil.ClientCertName],
+		ClientKey:  secret.Data[tlsutil.ClientKeyName],
+	}
+
+	return creds, nil
+}
+
+func (s *secretCredentialStore) Create(ctx context.Context, creds *tlsutil.Credentials) error {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      s.name,
+			Namespace: s.namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "cloud-api-adaptor",
+			},
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			tlsutil.CACertName:     creds.CACert,
+			tlsutil.CAKeyName:      creds.CAKey,
+			tlsutil.ClientCertName: creds.ClientCert,
+			tlsutil.ClientKeyName:  creds.ClientKey,
+		},
+	}
+
+	_, err := s.client.CoreV1().Secrets(s.namespace).Create(ctx, secret, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return tlsutil.ErrCredentialsExist
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create secret %s/%s: %w", s.namespace, s.name, err)
+	}
+
+	logger.Printf("stored credentials in secret %s/%s", s.namespace, s.name)
+
+	return nil
+}
@@ -0,0 +1,37 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package k8sops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tlsutil"
+)
+
+func TestSecretCredentialStore(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+
+	store1 := newSecretCredentialStore(client, "confidential-containers-system", "peer-pods-ca")
+	store2 := newSecretCredentialStore(client, "confidential-containers-system", "peer-pods-ca")
+
+	creds, err := store1.Load(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, creds)
+
+	creds1, err := tlsutil.LoadOrCreateCredentials(ctx, store1, "agent-protocol-forwarder", "cloud-api-adaptor")
+	require.NoError(t, err)
+
+	// Another instance sharing the secret gets the same trust root and client identity
+	creds2, err := tlsutil.LoadOrCreateCredentials(ctx, store2, "agent-protocol-forwarder", "cloud-api-adaptor")
+	require.NoError(t, err)
+	assert.Equal(t, creds1, creds2)
+
+	assert.ErrorIs(t, store2.Create(ctx, creds1), tlsutil.ErrCredentialsExist)
+}
@@ -0,0 +1,167 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+)
+
+const namespace = "cloud_api_adaptor"
+
+// Operations of a cloud provider, used as the operation label
+const (
+	OpCreateInstance = "create_instance"
+	OpDeleteInstance = "delete_instance"
+	OpAssignPodIPs   = "assign_pod_ips"
+	OpUnassignPodIPs = "unassign_pod_ips"
+)
+
+// Results of an operation, used as the result label
+const (
+	ResultSuccess = "success"
+	ResultError   = "error"
+)
+
+// Error classes, used as the error_class label
+const (
+	ErrorClassTimeout              = "timeout"
+	ErrorClassCanceled             = "canceled"
+	ErrorClassInsufficientCapacity = "insufficient_capacity"
+	ErrorClassQuotaExceeded        = "quota_exceeded"
+	ErrorClassInstanceNotFound     = "instance_not_found"
+	ErrorClassAuth                 = "auth"
+	ErrorClassInvalidSpec          = "invalid_spec"
+	ErrorClassOther                = "other"
+)
+
+// Pod VM boot and cloud API calls take from seconds to minutes
+var durationBuckets = []float64{1, 2.5, 5, 10, 20, 30, 45, 60, 90, 120, 180, 300, 600}
+
+var registry = prometheus.NewRegistry()
+
+var (
+	ProviderDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "provider_request_duration_seconds",
+		Help:      "Duration of cloud provider requests.",
+		Buckets:   durationBuckets,
+	}, []string{"provider", "operation", "result"})
+
+	ProviderFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_request_failures_total",
+		Help:      "Number of failed cloud provider requests.",
+	}, []string{"provider", "operation", "error_class"})
+
+	StartVMDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "start_vm_duration_seconds",
+		Help:      "End-to-end duration of StartVM requests, from instance creation until the agent proxy is ready.",
+		Buckets:   durationBuckets,
+	}, []string{"result"})
+
+	PodNetworkSetupDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "pod_network_setup_duration_seconds",
+		Help:      "Duration of setting up the pod network tunnel on the worker node.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+
+	AgentProxyConnectDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "agent_proxy_connect_duration_seconds",
+		Help:      "Duration until the agent proxy connects to agent-protocol-forwarder on a pod VM.",
+		Buckets:   durationBuckets,
+	}, []string{"result"})
+
+	AgentProxyRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "agent_proxy_requests_total",
+		Help:      "Number of kata agent requests forwarded by the agent proxy.",
+	}, []string{"method", "code"})
+
+	ActiveSandboxes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_sandboxes",
+		Help:      "Number of sandboxes managed by cloud-api-adaptor.",
+	})
+
+	AllocatedVXLANIDs = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "allocated_vxlan_ids",
+		Help:      "Number of VXLAN IDs allocated to sandboxes.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		ProviderDuration,
+		ProviderFailures,
+		StartVMDuration,
+		PodNetworkSetupDuration,
+		AgentProxyConnectDuration,
+		AgentProxyRequests,
+		ActiveSandboxes,
+		AllocatedVXLANIDs,
+	)
+}
+
+// Handler returns an HTTP handler that serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Result returns the result label for an error
+func Result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultSuccess
+}
+
+// ErrorClass returns the error_class label for an error
+func ErrorClass(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	case errors.Is(err, provider.ErrInsufficientCapacity):
+		return ErrorClassInsufficientCapacity
+	case errors.Is(err, provider.ErrQuotaExceeded):
+		return ErrorClassQuotaExceeded
+	case errors.Is(err, provider.ErrInstanceNotFound):
+		return ErrorClassInstanceNotFound
+	case errors.Is(err, provider.ErrAuth):
+		return ErrorClassAuth
+	case errors.Is(err, provider.ErrInvalidSpec):
+		return ErrorClassInvalidSpec
+	default:
+		return ErrorClassOther
+	}
+}
+
+// ObserveProviderRequest records the duration and the result of a cloud provider request started at start
+func ObserveProviderRequest(provider, operation string, start time.Time, err error) {
+	ProviderDuration.WithLabelValues(provider, operation, Result(err)).Observe(time.Since(start).Seconds())
+	if err != nil {
+		ProviderFailures.WithLabelValues(provider, operation, ErrorClass(err)).Inc()
+	}
+}
+
+// ObserveDuration records the time elapsed since start in a histogram labeled by result
+func ObserveDuration(h *prometheus.HistogramVec, start time.Time, err error) {
+	h.WithLabelValues(Result(err)).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,61 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+)
+
+func TestErrorClass(t *testing.T) {
+	for _, tc := range []struct {
+		err   error
+		class string
+	}{
+		{fmt.Errorf("creating instance: %w", context.DeadlineExceeded), ErrorClassTimeout},
+		{context.Canceled, ErrorClassCanceled},
+		{fmt.Errorf("creating instance: %w", provider.WrapError(provider.ErrQuotaExceeded, errors.New("VcpuLimitExceeded"))), ErrorClassQuotaExceeded},
+		{provider.ErrAuth, ErrorClassAuth},
+		{errors.New("boom"), ErrorClassOther},
+	} {
+		if got := ErrorClass(tc.err); got != tc.class {
+			t.Errorf("ErrorClass(%v) = %q, want %q", tc.err, got, tc.class)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	start := time.Now()
+	ObserveProviderRequest("test", OpCreateInstance, start, nil)
+	ObserveProviderRequest("test", OpDeleteInstance, start, context.DeadlineExceeded)
+	ObserveDuration(StartVMDuration, start, nil)
+	ActiveSandboxes.Set(3)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`cloud_api_adaptor_provider_request_duration_seconds_count{operation="create_instance",provider="test",result="success"} 1`,
+		`cloud_api_adaptor_provider_request_duration_seconds_count{operation="delete_instance",provider="test",result="error"} 1`,
+		`cloud_api_adaptor_provider_request_failures_total{error_class="timeout",operation="delete_instance",provider="test"} 1`,
+		`cloud_api_adaptor_start_vm_duration_seconds_count{result="success"} 1`,
+		`cloud_api_adaptor_active_sandboxes 3`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics do not contain %q", want)
+		}
+	}
+}
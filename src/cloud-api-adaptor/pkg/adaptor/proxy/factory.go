@@ -4,13 +4,36 @@
 package proxy
 
 import (
+	"context"
+	"fmt"
 	"time"
 
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/attestation"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/audit"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tlsutil"
+	putil "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util"
+)
+
+const (
+	caOrgName     = "agent-protocol-forwarder"
+	clientOrgName = "cloud-api-adaptor"
 )
 
 type Factory interface {
-	New(serverName, socketPath string) AgentProxy
+	// New creates an agent proxy for a pod VM. filter is evaluated for each agent request if not nil.
+	New(serverName, socketPath string, filter RequestFilter) AgentProxy
+}
+
+// FactoryOptions are the optional settings of an agent proxy factory. Rendezvous, Dialer, Verifier and Auditor
+// are passed to each agent proxy, as described in Options.
+type FactoryOptions struct {
+	// CredentialStore keeps the generated CA and client credentials across restarts of cloud-api-adaptor
+	CredentialStore tlsutil.CredentialStore
+
+	Rendezvous Rendezvous
+	Dialer     putil.ContextDialer
+	Verifier   attestation.Verifier
+	Auditor    *audit.Auditor
 }
 
 type factory struct {
@@ -18,27 +41,54 @@ type factory struct {
 	tlsConfig    *tlsutil.TLSConfig
 	caService    tlsutil.CAService
 	proxyTimeout time.Duration
+	opts         FactoryOptions
 }
 
-func NewFactory(pauseImage string, tlsConfig *tlsutil.TLSConfig, proxyTimeout time.Duration) Factory {
+// NewFactory creates an agent proxy factory
+func NewFactory(pauseImage string, tlsConfig *tlsutil.TLSConfig, proxyTimeout time.Duration, opts FactoryOptions) (Factory, error) {
+	credStore := opts.CredentialStore
 
-	if tlsConfig != nil && !tlsConfig.HasCertAuth() {
+	// Credentials are loaded from a store when it is specified,
+	// so that they remain the same across restarts of cloud-api-adaptor
+	var creds *tlsutil.Credentials
+
+	if tlsConfig != nil && credStore != nil && (!tlsConfig.HasCertAuth() || !tlsConfig.HasCA()) {
 
-		certPEM, keyPEM, err := tlsutil.NewClientCertificate("cloud-api-adaptor")
+		c, err := tlsutil.LoadOrCreateCredentials(context.Background(), credStore, caOrgName, clientOrgName)
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("failed to load or create agent proxy credentials: %w", err)
+		}
+		creds = c
+	}
+
+	if tlsConfig != nil && !tlsConfig.HasCertAuth() {
+
+		if creds != nil {
+			tlsConfig.CertData = creds.ClientCert
+			tlsConfig.KeyData = creds.ClientKey
+		} else {
+			certPEM, keyPEM, err := tlsutil.NewClientCertificate(clientOrgName)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.CertData = certPEM
+			tlsConfig.KeyData = keyPEM
 		}
-		tlsConfig.CertData = certPEM
-		tlsConfig.KeyData = keyPEM
 	}
 
 	var caService tlsutil.CAService
 
 	if tlsConfig != nil && !tlsConfig.HasCA() {
 
-		s, err := tlsutil.NewCAService("agent-protocol-forwarder")
+		var s tlsutil.CAService
+		var err error
+		if creds != nil {
+			s, err = tlsutil.NewCAServiceFromPEM(caOrgName, creds.CACert, creds.CAKey)
+		} else {
+			s, err = tlsutil.NewCAService(caOrgName)
+		}
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 		caService = s
 		tlsConfig.CAData = caService.RootCertificate()
@@ -49,10 +99,17 @@ func NewFactory(pauseImage string, tlsConfig *tlsutil.TLSConfig, proxyTimeout ti
 		tlsConfig:    tlsConfig,
 		caService:    caService,
 		proxyTimeout: proxyTimeout,
-	}
+		opts:         opts,
+	}, nil
 }
 
-func (f *factory) New(serverName, socketPath string) AgentProxy {
+func (f *factory) New(serverName, socketPath string, filter RequestFilter) AgentProxy {
 
-	return NewAgentProxy(serverName, socketPath, f.pauseImage, f.tlsConfig, f.caService, f.proxyTimeout)
+	return NewAgentProxy(serverName, socketPath, f.pauseImage, f.tlsConfig, f.caService, f.proxyTimeout, Options{
+		Rendezvous: f.opts.Rendezvous,
+		Dialer:     f.opts.Dialer,
+		Verifier:   f.opts.Verifier,
+		Auditor:    f.opts.Auditor,
+		Filter:     filter,
+	})
 }
@@ -17,15 +17,26 @@ import (
 	"time"
 
 	retry "github.com/avast/retry-go/v4"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/metrics"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/agentproto"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/attestation"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/audit"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tlsutil"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tracing"
+	putil "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util"
 	"github.com/containerd/ttrpc"
 	pb "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/agent/protocols/grpc"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/status"
 )
 
 const (
 	SocketName          = "agent.ttrpc"
 	DefaultProxyTimeout = 5 * time.Minute
 
+	// The agent connection is checked at this interval, and re-established when it is lost
+	agentKeepaliveInterval = 30 * time.Second
+
 	// The server TLS certificate must have this as SAN
 	// TODO: Avoid hard coding of server name
 	podvmServername = "podvm-server"
@@ -44,6 +55,11 @@ type AgentProxy interface {
 type agentProxy struct {
 	tlsConfig    *tlsutil.TLSConfig
 	caService    tlsutil.CAService
+	rendezvous   Rendezvous
+	dialer       putil.ContextDialer
+	verifier     attestation.Verifier
+	auditor      *audit.Auditor
+	filter       RequestFilter
 	readyCh      chan struct{}
 	stopCh       chan struct{}
 	serverName   string
@@ -53,7 +69,22 @@ type agentProxy struct {
 	stopOnce     sync.Once
 }
 
-func NewAgentProxy(serverName, socketPath, pauseImage string, tlsConfig *tlsutil.TLSConfig, caService tlsutil.CAService, proxyTimeout time.Duration) AgentProxy {
+// Options are the optional settings of an agent proxy. The zero value dials the pod VM directly,
+// and forwards all requests without recording them.
+type Options struct {
+	// Rendezvous makes the agent proxy wait for the pod VM to connect to it instead of dialing the pod VM
+	Rendezvous Rendezvous
+	// Dialer dials the pod VM, for example through a chain of proxies
+	Dialer putil.ContextDialer
+	// Verifier accepts the pod VM only if it accepts the evidence in the certificate of the pod VM
+	Verifier attestation.Verifier
+	// Auditor records the requests forwarded to the pod VM
+	Auditor *audit.Auditor
+	// Filter is evaluated for each agent request
+	Filter RequestFilter
+}
+
+func NewAgentProxy(serverName, socketPath, pauseImage string, tlsConfig *tlsutil.TLSConfig, caService tlsutil.CAService, proxyTimeout time.Duration, opts Options) AgentProxy {
 	return &agentProxy{
 		serverName:   serverName,
 		socketPath:   socketPath,
@@ -63,17 +94,27 @@ func NewAgentProxy(serverName, socketPath, pauseImage string, tlsConfig *tlsutil
 		pauseImage:   pauseImage,
 		tlsConfig:    tlsConfig,
 		caService:    caService,
+		rendezvous:   opts.Rendezvous,
+		dialer:       opts.Dialer,
+		verifier:     opts.Verifier,
+		auditor:      opts.Auditor,
+		filter:       opts.Filter,
 	}
 }
 
 func (p *agentProxy) dial(ctx context.Context, address string) (net.Conn, error) {
+	if p.rendezvous != nil {
+		return p.dialReverse(ctx)
+	}
+
 	var conn net.Conn
 
-	var dialer interface {
-		DialContext(ctx context.Context, network, address string) (net.Conn, error)
-	}
+	var dialer putil.ContextDialer = &net.Dialer{Timeout: p.proxyTimeout}
 
-	netDialer := &net.Dialer{Timeout: p.proxyTimeout}
+	// Connections are established through the configured proxies, if any
+	if p.dialer != nil {
+		dialer = p.dialer
+	}
 
 	if p.tlsConfig != nil {
 
@@ -95,12 +136,19 @@ func (p *agentProxy) dial(ctx context.Context, address string) (net.Conn, error)
 			config.ServerName = podvmServername
 		}
 
-		dialer = &tls.Dialer{
-			NetDialer: netDialer,
-			Config:    config,
+		// In attested TLS mode, the pod VM presents a self-signed certificate with evidence of its TEE,
+		// which must be accepted by the verifier before the connection is used
+		if p.verifier != nil {
+			config.InsecureSkipVerify = true
+			config.VerifyConnection = func(cs tls.ConnectionState) error {
+				if len(cs.PeerCertificates) == 0 {
+					return errors.New("pod VM did not present a certificate")
+				}
+				return attestation.VerifyCertificate(ctx, p.verifier, cs.PeerCertificates[0], p.serverName)
+			}
 		}
-	} else {
-		dialer = netDialer
+
+		dialer = &tlsDialer{forward: dialer, config: config}
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, p.proxyTimeout)
@@ -129,6 +177,43 @@ func (p *agentProxy) dial(ctx context.Context, address string) (net.Conn, error)
 	return conn, nil
 }
 
+// tlsDialer establishes TLS connections over connections of another dialer
+type tlsDialer struct {
+	forward putil.ContextDialer
+	config  *tls.Config
+}
+
+func (d *tlsDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.forward.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConn := tls.Client(conn, d.config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// dialReverse waits for the pod VM to connect to the rendezvous listener in reverse connect mode
+func (p *agentProxy) dialReverse(ctx context.Context) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.proxyTimeout)
+	defer cancel()
+
+	logger.Printf("Waiting for reverse agent proxy connection from %s", p.serverName)
+	conn, err := p.rendezvous.Dial(ctx, p.serverName)
+	if err != nil {
+		err = fmt.Errorf("failed to establish reverse agent proxy connection from %s: %w", p.serverName, err)
+		logger.Print(err)
+		return nil, err
+	}
+
+	logger.Printf("established reverse agent proxy connection from %s", p.serverName)
+	return conn, nil
+}
+
 func (p *agentProxy) Start(ctx context.Context, serverURL *url.URL) error {
 	if err := os.MkdirAll(filepath.Dir(p.socketPath), os.ModePerm); err != nil {
 		return fmt.Errorf("failed to create parent directories for socket: %s", p.socketPath)
@@ -148,18 +233,37 @@ func (p *agentProxy) Start(ctx context.Context, serverURL *url.URL) error {
 		return p.dial(ctx, serverURL.Host)
 	}
 
-	proxyService := newProxyService(dialer, p.pauseImage)
+	// Requests forwarded to the pod VM are recorded when auditing is enabled
+	var auditor agentproto.Auditor
+	if p.auditor != nil {
+		auditor = p.auditor.ForPodVM(p.serverName)
+	}
+
+	proxyService := newProxyService(dialer, p.pauseImage, auditor, p.filter)
 	defer func() {
 		if err := proxyService.Close(); err != nil {
 			logger.Printf("error closing agent proxy connection: %v", err)
 		}
+		if p.rendezvous != nil {
+			p.rendezvous.Release(p.serverName)
+		}
 	}()
 
-	if err := proxyService.Connect(ctx); err != nil {
+	connectStart := time.Now()
+	connectCtx, span := tracing.Start(ctx, "AgentProxy.Connect")
+	err = proxyService.Connect(connectCtx)
+	tracing.End(span, err)
+	metrics.ObserveDuration(metrics.AgentProxyConnectDuration, connectStart, err)
+	if err != nil {
 		return fmt.Errorf("error connecting to agent: %v", err)
 	}
 
-	ttrpcServer, err := ttrpc.NewServer()
+	// Forwarded requests are traced as children of the span in ctx, which represents the pod
+	ttrpcServer, err := ttrpc.NewServer(ttrpc.WithChainUnaryServerInterceptor(
+		countRequests,
+		tracing.UnaryServerInterceptor(trace.SpanContextFromContext(ctx)),
+		proxyService.filterRequests,
+	))
 	if err != nil {
 		return fmt.Errorf("failed to create TTRPC server: %w", err)
 	}
@@ -199,6 +303,13 @@ func (p *agentProxy) Start(ctx context.Context, serverURL *url.URL) error {
 	return nil
 }
 
+// countRequests counts forwarded requests by method and status code
+func countRequests(ctx context.Context, unmarshal ttrpc.Unmarshaler, info *ttrpc.UnaryServerInfo, method ttrpc.Method) (interface{}, error) {
+	res, err := method(ctx, unmarshal)
+	metrics.AgentProxyRequests.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+	return res, err
+}
+
 func (p *agentProxy) Ready() chan struct{} {
 	return p.readyCh
 }
@@ -5,12 +5,15 @@ package proxy
 
 import (
 	"context"
+	"crypto/tls"
+	"io"
 	"net"
 	"net/url"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/attestation"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tlsutil"
 	"github.com/containerd/ttrpc"
 	pb "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/agent/protocols/grpc"
@@ -62,7 +65,7 @@ func TestNewAgentProxy(t *testing.T) {
 
 	socketPath := testSocketPathDummy
 
-	proxy := NewAgentProxy(testServerNamePodVM, socketPath, "", nil, nil, 0)
+	proxy := NewAgentProxy(testServerNamePodVM, socketPath, "", nil, nil, 0, Options{})
 	p, ok := proxy.(*agentProxy)
 	if !ok {
 		t.Fatalf("expect %T, got %T", &agentProxy{}, proxy)
@@ -86,7 +89,7 @@ func TestStartStop(t *testing.T) {
 		Host:   agentListener.Addr().String(),
 	}
 
-	proxy := NewAgentProxy(testServerNamePodVM, socketPath, "", nil, nil, testTimeout5SecondProxy)
+	proxy := NewAgentProxy(testServerNamePodVM, socketPath, "", nil, nil, testTimeout5SecondProxy, Options{})
 	p, ok := proxy.(*agentProxy)
 	if !ok {
 		t.Fatalf("expect %T, got %T", &agentProxy{}, proxy)
@@ -194,17 +197,133 @@ func TestDialerFailure(t *testing.T) {
 	}
 }
 
+// countingDialer stands in for a chain of proxies
+type countingDialer struct {
+	net.Dialer
+	dials int
+}
+
+func (d *countingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.dials++
+	return d.Dialer.DialContext(ctx, network, address)
+}
+
+func TestDialerTLSOverDialer(t *testing.T) {
+	caService, err := tlsutil.NewCAService("test-ca")
+	require.NoError(t, err)
+	clientCert, clientKey, err := tlsutil.NewClientCertificate("test-client")
+	require.NoError(t, err)
+	serverCert, serverKey, err := caService.Issue(testServerNamePodVM)
+	require.NoError(t, err)
+
+	serverConfig, err := tlsutil.GetTLSConfigFor(&tlsutil.TLSConfig{CertData: serverCert, KeyData: serverKey, CAData: clientCert})
+	require.NoError(t, err)
+	listener, err := tls.Listen(testNetworkTCP, testListenAddressProxy, serverConfig)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(conn, conn)
+	}()
+
+	dialer := &countingDialer{}
+	tlsConfig := &tlsutil.TLSConfig{CAData: caService.RootCertificate(), CertData: clientCert, KeyData: clientKey}
+	p := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", tlsConfig, caService, testTimeout5SecondProxy, Options{Dialer: dialer}).(*agentProxy)
+
+	conn, err := p.dial(context.Background(), listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, 1, dialer.dials)
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+}
+
+func TestDialAttestedTLS(t *testing.T) {
+	caService, err := tlsutil.NewCAService("test-ca")
+	require.NoError(t, err)
+	clientCert, clientKey, err := tlsutil.NewClientCertificate("test-client")
+	require.NoError(t, err)
+
+	// The pod VM presents a self-signed certificate with evidence of the sample attester
+	attester, err := attestation.NewAttester(attestation.SampleAttester)
+	require.NoError(t, err)
+	serverCert, serverKey, err := attestation.NewCertificate(context.Background(), attester, testServerNamePodVM)
+	require.NoError(t, err)
+
+	serverConfig, err := tlsutil.GetTLSConfigFor(&tlsutil.TLSConfig{CertData: serverCert, KeyData: serverKey, CAData: clientCert})
+	require.NoError(t, err)
+	listener, err := tls.Listen(testNetworkTCP, testListenAddressProxy, serverConfig)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	verifier, err := attestation.NewVerifier(attestation.SampleVerifier)
+	require.NoError(t, err)
+	tlsConfig := &tlsutil.TLSConfig{CAData: caService.RootCertificate(), CertData: clientCert, KeyData: clientKey}
+
+	t.Run("accepted evidence", func(t *testing.T) {
+		p := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", tlsConfig, caService, testTimeout5SecondProxy, Options{Verifier: verifier}).(*agentProxy)
+
+		conn, err := p.dial(context.Background(), listener.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("ping"))
+		require.NoError(t, err)
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(conn, buf)
+		require.NoError(t, err)
+		assert.Equal(t, "ping", string(buf))
+	})
+
+	t.Run("evidence of another pod VM", func(t *testing.T) {
+		p := NewAgentProxy(testServerName, testSocketPathTest, "", tlsConfig, caService, testTimeout1Second, Options{Verifier: verifier}).(*agentProxy)
+
+		_, err := p.dial(context.Background(), listener.Addr().String())
+		assert.Error(t, err)
+	})
+
+	t.Run("without verifier", func(t *testing.T) {
+		p := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", tlsConfig, caService, testTimeout1Second, Options{}).(*agentProxy)
+
+		_, err := p.dial(context.Background(), listener.Addr().String())
+		assert.Error(t, err)
+	})
+}
+
 // Test CAService method
 func TestCAService(t *testing.T) {
 	t.Run("CAService returns nil when not set", func(t *testing.T) {
-		proxy := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", nil, nil, 0)
+		proxy := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", nil, nil, 0, Options{})
 		p := proxy.(*agentProxy)
 		assert.Nil(t, p.CAService())
 	})
 
 	t.Run("CAService returns service when set", func(t *testing.T) {
 		mockCAService := &mockCAService{}
-		proxy := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", nil, mockCAService, 0)
+		proxy := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", nil, mockCAService, 0, Options{})
 		p := proxy.(*agentProxy)
 		assert.Equal(t, mockCAService, p.CAService())
 	})
@@ -213,7 +332,7 @@ func TestCAService(t *testing.T) {
 // Test ClientCA method
 func TestClientCA(t *testing.T) {
 	t.Run("ClientCA returns nil when tlsConfig is nil", func(t *testing.T) {
-		proxy := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", nil, nil, 0)
+		proxy := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", nil, nil, 0, Options{})
 		p := proxy.(*agentProxy)
 		assert.Nil(t, p.ClientCA())
 	})
@@ -222,7 +341,7 @@ func TestClientCA(t *testing.T) {
 		tlsConfig := &tlsutil.TLSConfig{
 			CAFile: testCAFilePath,
 		}
-		proxy := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", tlsConfig, nil, 0)
+		proxy := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", tlsConfig, nil, 0, Options{})
 		p := proxy.(*agentProxy)
 		assert.Nil(t, p.ClientCA())
 	})
@@ -232,7 +351,7 @@ func TestClientCA(t *testing.T) {
 		tlsConfig := &tlsutil.TLSConfig{
 			CertData: certData,
 		}
-		proxy := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", tlsConfig, nil, 0)
+		proxy := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", tlsConfig, nil, 0, Options{})
 		p := proxy.(*agentProxy)
 		result := p.ClientCA()
 		assert.Equal(t, string(certData), string(result))
@@ -241,14 +360,14 @@ func TestClientCA(t *testing.T) {
 
 // Test Ready channel
 func TestReady(t *testing.T) {
-	proxy := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", nil, nil, 0)
+	proxy := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", nil, nil, 0, Options{})
 	readyCh := proxy.Ready()
 	assert.NotNil(t, readyCh)
 }
 
 // Test multiple Shutdown calls
 func TestMultipleShutdown(t *testing.T) {
-	proxy := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", nil, nil, 0)
+	proxy := NewAgentProxy(testServerNamePodVM, testSocketPathTest, "", nil, nil, 0, Options{})
 	p := proxy.(*agentProxy)
 
 	// First shutdown
@@ -262,7 +381,7 @@ func TestMultipleShutdown(t *testing.T) {
 func TestStartInvalidSocketPath(t *testing.T) {
 	// Use a path that cannot be created
 	socketPath := testSocketPathInvalid
-	proxy := NewAgentProxy(testServerNamePodVM, socketPath, "", nil, nil, testTimeout5SecondProxy)
+	proxy := NewAgentProxy(testServerNamePodVM, socketPath, "", nil, nil, testTimeout5SecondProxy, Options{})
 
 	serverURL := &url.URL{
 		Scheme: testSchemeGRPC,
@@ -278,7 +397,7 @@ func TestStartConnectionFailure(t *testing.T) {
 	dir := t.TempDir()
 	socketPath := filepath.Join(dir, testSocketFileName)
 
-	proxy := NewAgentProxy(testServerNamePodVM, socketPath, "", nil, nil, testTimeout1Second)
+	proxy := NewAgentProxy(testServerNamePodVM, socketPath, "", nil, nil, testTimeout1Second, Options{})
 
 	// Use an address that will fail to connect
 	serverURL := &url.URL{
@@ -296,17 +415,19 @@ func TestStartConnectionFailure(t *testing.T) {
 // Test NewFactory
 func TestNewFactory(t *testing.T) {
 	t.Run("NewFactory with nil TLS config", func(t *testing.T) {
-		proxyFactory := NewFactory(testPauseImageLatest, nil, testTimeout5SecondProxy)
+		proxyFactory, err := NewFactory(testPauseImageLatest, nil, testTimeout5SecondProxy, FactoryOptions{})
+		require.NoError(t, err)
 		assert.NotNil(t, proxyFactory)
 
 		// Just verify it's not nil and can create proxies
-		proxy := proxyFactory.New(testServerName, testSocketPathTest)
+		proxy := proxyFactory.New(testServerName, testSocketPathTest, nil)
 		assert.NotNil(t, proxy)
 	})
 
 	t.Run("Factory.New creates AgentProxy", func(t *testing.T) {
-		proxyFactory := NewFactory(testPauseImageLatest, nil, testTimeout5SecondProxy)
-		proxy := proxyFactory.New(testServerName, testSocketPathTest)
+		proxyFactory, err := NewFactory(testPauseImageLatest, nil, testTimeout5SecondProxy, FactoryOptions{})
+		require.NoError(t, err)
+		proxy := proxyFactory.New(testServerName, testSocketPathTest, nil)
 
 		assert.NotNil(t, proxy)
 
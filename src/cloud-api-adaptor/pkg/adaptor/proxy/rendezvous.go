@@ -0,0 +1,269 @@
+// Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tlsutil"
+)
+
+const (
+	DefaultRendezvousListenAddr = "0.0.0.0:15151"
+
+	rendezvousHandshakeTimeout = 30 * time.Second
+)
+
+// Rendezvous accepts the connections that agent-protocol-forwarder opens from pod VMs in reverse connect mode,
+// for pod VMs that the worker node cannot reach. Each connection is identified by the server certificate that
+// the CA service issued for the pod VM, and is handed over to the agent proxy with the same server name.
+type Rendezvous interface {
+	Start() error
+	Dial(ctx context.Context, serverName string) (net.Conn, error)
+	Release(serverName string)
+	Addr() string
+	Close() error
+}
+
+type rendezvous struct {
+	listenAddr string
+	tlsConfig  *tlsutil.TLSConfig
+	listener   net.Listener
+	mutex      sync.Mutex
+	conns      map[string]chan net.Conn
+	closeCh    chan struct{}
+	closeOnce  sync.Once
+}
+
+// NewRendezvous creates a rendezvous listener. The TLS configuration is read when the listener is started,
+// so that it can be completed with the credentials generated by the agent proxy factory.
+func NewRendezvous(listenAddr string, tlsConfig *tlsutil.TLSConfig) Rendezvous {
+	return &rendezvous{
+		listenAddr: listenAddr,
+		tlsConfig:  tlsConfig,
+		conns:      map[string]chan net.Conn{},
+		closeCh:    make(chan struct{}),
+	}
+}
+
+func (r *rendezvous) Start() error {
+	if r.tlsConfig == nil {
+		return errors.New("reverse connect mode requires TLS")
+	}
+
+	config, err := tlsutil.GetTLSConfigFor(r.tlsConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create tls config: %w", err)
+	}
+
+	// The server name of a pod VM is not known before the handshake, so the certificate chain is
+	// verified in VerifyConnection and the server name is taken from the verified certificate
+	roots, skipVerify := config.RootCAs, config.InsecureSkipVerify
+	config.InsecureSkipVerify = true
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		_, err := podVMServerName(cs, roots, skipVerify)
+		return err
+	}
+
+	listener, err := net.Listen("tcp", r.listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", r.listenAddr, err)
+	}
+	r.listener = listener
+
+	logger.Printf("Listening for reverse connections from pod VMs on %s", listener.Addr())
+
+	go r.serve(config, roots, skipVerify)
+
+	return nil
+}
+
+func (r *rendezvous) serve(config *tls.Config, roots *x509.CertPool, skipVerify bool) {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.Printf("error accepting reverse connection: %v", err)
+			}
+			return
+		}
+
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), rendezvousHandshakeTimeout)
+			defer cancel()
+
+			tlsConn := tls.Client(conn, config)
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				logger.Printf("rejected reverse connection from %s: %v", conn.RemoteAddr(), err)
+				conn.Close()
+				return
+			}
+
+			serverName, err := podVMServerName(tlsConn.ConnectionState(), roots, skipVerify)
+			if err != nil {
+				logger.Printf("rejected reverse connection from %s: %v", conn.RemoteAddr(), err)
+				conn.Close()
+				return
+			}
+
+			logger.Printf("accepted reverse connection from pod VM %s at %s", serverName, conn.RemoteAddr())
+			r.deliver(serverName, tlsConn)
+		}()
+	}
+}
+
+// podVMServerName verifies the server certificate of a pod VM, and returns the server name it was issued for
+func podVMServerName(cs tls.ConnectionState, roots *x509.CertPool, skipVerify bool) (string, error) {
+	if len(cs.PeerCertificates) == 0 {
+		return "", errors.New("pod VM did not present a certificate")
+	}
+	leaf := cs.PeerCertificates[0]
+
+	if !skipVerify {
+		intermediates := x509.NewCertPool()
+		for _, cert := range cs.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		opts := x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		if _, err := leaf.Verify(opts); err != nil {
+			return "", fmt.Errorf("verifying pod VM certificate: %w", err)
+		}
+	}
+
+	if len(leaf.DNSNames) > 0 {
+		return leaf.DNSNames[0], nil
+	}
+	if leaf.Subject.CommonName != "" {
+		return leaf.Subject.CommonName, nil
+	}
+	return "", errors.New("pod VM certificate has no server name")
+}
+
+func (r *rendezvous) channel(serverName string) chan net.Conn {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ch, ok := r.conns[serverName]
+	if !ok {
+		ch = make(chan net.Conn, 1)
+		r.conns[serverName] = ch
+	}
+	return ch
+}
+
+// deliver queues a connection for the agent proxy of a pod VM. A connection that is still queued
+// is stale, since the forwarder only dials a new connection after the previous one is closed.
+// A connection from a pod VM that has no agent proxy on this node is closed immediately, so that
+// connections of pod VMs of other nodes are not kept open.
+func (r *rendezvous) deliver(serverName string, conn net.Conn) {
+	r.mutex.Lock()
+	ch, ok := r.conns[serverName]
+	r.mutex.Unlock()
+
+	if !ok {
+		logger.Printf("closing reverse connection from unknown pod VM %s at %s", serverName, conn.RemoteAddr())
+		conn.Close()
+		return
+	}
+
+	for {
+		select {
+		case ch <- conn:
+			return
+		default:
+		}
+		select {
+		case stale := <-ch:
+			stale.Close()
+		default:
+		}
+	}
+}
+
+// Dial waits for the pod VM with the server name to connect. The server name is known to the
+// rendezvous listener from the first call of Dial until Release is called.
+func (r *rendezvous) Dial(ctx context.Context, serverName string) (net.Conn, error) {
+	select {
+	case conn := <-r.channel(serverName):
+		return conn, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for reverse connection from pod VM %s: %w", serverName, ctx.Err())
+	case <-r.closeCh:
+		return nil, net.ErrClosed
+	}
+}
+
+// Release discards the queued connection of a pod VM when its agent proxy is shut down
+func (r *rendezvous) Release(serverName string) {
+	r.mutex.Lock()
+	ch, ok := r.conns[serverName]
+	delete(r.conns, serverName)
+	r.mutex.Unlock()
+
+	if !ok {
+		return
+	}
+	select {
+	case conn := <-ch:
+		conn.Close()
+	default:
+	}
+}
+
+// ResolveReverseConnectAddr returns the address that pod VMs dial to reach the rendezvous listener of this
+// node. Each node needs its own address, so an address without a host, such as ":15151", is completed
+// with the IP address of the node.
+func ResolveReverseConnectAddr(addr, nodeIP string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid reverse connect address %q: %w", addr, err)
+	}
+	if host != "" {
+		return addr, nil
+	}
+	if nodeIP == "" {
+		return "", fmt.Errorf("reverse connect address %q has no host, and the node IP address is not known", addr)
+	}
+	return net.JoinHostPort(nodeIP, port), nil
+}
+
+func (r *rendezvous) Addr() string {
+	if r.listener == nil {
+		return r.listenAddr
+	}
+	return r.listener.Addr().String()
+}
+
+func (r *rendezvous) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.closeCh)
+		if r.listener != nil {
+			err = r.listener.Close()
+		}
+
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		for serverName, ch := range r.conns {
+			select {
+			case conn := <-ch:
+				conn.Close()
+			default:
+			}
+			delete(r.conns, serverName)
+		}
+	})
+	return err
+}
@@ -0,0 +1,121 @@
+// Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tlsutil"
+)
+
+// connectPodVM dials the rendezvous listener like agent-protocol-forwarder, and echoes what it receives.
+// The returned channel is closed when the connection is closed.
+func connectPodVM(t *testing.T, addr string, caService tlsutil.CAService, serverName string, clientCA []byte) <-chan struct{} {
+	certPEM, keyPEM, err := caService.Issue(serverName)
+	require.NoError(t, err)
+
+	config, err := tlsutil.GetTLSConfigFor(&tlsutil.TLSConfig{CertData: certPEM, KeyData: keyPEM, CAData: clientCA})
+	require.NoError(t, err)
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tlsConn := tls.Server(conn, config)
+		_, _ = io.Copy(tlsConn, tlsConn)
+	}()
+	return done
+}
+
+func TestRendezvous(t *testing.T) {
+	caService, err := tlsutil.NewCAService("test-ca")
+	require.NoError(t, err)
+	clientCert, clientKey, err := tlsutil.NewClientCertificate("test-client")
+	require.NoError(t, err)
+
+	r := NewRendezvous("127.0.0.1:0", &tlsutil.TLSConfig{
+		CAData:   caService.RootCertificate(),
+		CertData: clientCert,
+		KeyData:  clientKey,
+	})
+	require.NoError(t, r.Start())
+	defer r.Close()
+
+	// The connection of a pod VM without an agent proxy on this node is closed
+	done := connectPodVM(t, r.Addr(), caService, "podvm-c", clientCert)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection of an unknown pod VM was not closed")
+	}
+
+	// The connection of a pod VM is handed over to the agent proxy with the same server name.
+	// The server name is registered as Dial does before the pod VM connects.
+	r.(*rendezvous).channel("podvm-a")
+	connectPodVM(t, r.Addr(), caService, "podvm-a", clientCert)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := r.Dial(ctx, "podvm-a")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+
+	// A pod VM with a certificate of another CA is rejected
+	otherCA, err := tlsutil.NewCAService("other-ca")
+	require.NoError(t, err)
+	connectPodVM(t, r.Addr(), otherCA, "podvm-b", clientCert)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err = r.Dial(ctx, "podvm-b")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, r.Close())
+	_, err = r.Dial(context.Background(), "podvm-a")
+	assert.ErrorIs(t, err, net.ErrClosed)
+}
+
+func TestResolveReverseConnectAddr(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		addr    string
+		nodeIP  string
+		want    string
+		wantErr bool
+	}{
+		{name: "host", addr: "203.0.113.1:15151", nodeIP: "192.0.2.1", want: "203.0.113.1:15151"},
+		{name: "node IP", addr: ":15151", nodeIP: "192.0.2.1", want: "192.0.2.1:15151"},
+		{name: "node IPv6", addr: ":15151", nodeIP: "2001:db8::1", want: "[2001:db8::1]:15151"},
+		{name: "no node IP", addr: ":15151", wantErr: true},
+		{name: "no port", addr: "203.0.113.1", nodeIP: "192.0.2.1", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ResolveReverseConnectAddr(tc.addr, tc.nodeIP)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -13,15 +13,24 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/containerd/ttrpc"
+
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/agentproto"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/redact"
 	pb "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/agent/protocols/grpc"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
+// RequestFilter is evaluated for each agent request before it is forwarded to the pod VM.
+// The request is rejected when it returns an error, which should have the gRPC code PermissionDenied.
+type RequestFilter func(ctx context.Context, method string, req any) error
+
 type proxyService struct {
 	agentproto.Redirector
 	pauseImage string
+	filter     RequestFilter
+	auditor    agentproto.Auditor
 }
 
 const (
@@ -33,14 +42,44 @@ const (
 	defaultGPUsAnnotation = "io.katacontainers.config.hypervisor.default_gpus"
 )
 
-func newProxyService(dialer func(context.Context) (net.Conn, error), pauseImage string) *proxyService {
+func newProxyService(dialer func(context.Context) (net.Conn, error), pauseImage string, auditor agentproto.Auditor, filter RequestFilter) *proxyService {
 
-	redirector := agentproto.NewRedirector(dialer)
+	opts := []agentproto.RedirectorOption{agentproto.WithKeepalive(agentKeepaliveInterval)}
+	if auditor != nil {
+		opts = append(opts, agentproto.WithAuditor(auditor))
+	}
+	redirector := agentproto.NewRedirector(dialer, opts...)
 
 	return &proxyService{
 		Redirector: redirector,
 		pauseImage: pauseImage,
+		filter:     filter,
+		auditor:    auditor,
+	}
+}
+
+// filterRequests is a ttrpc server interceptor that rejects requests denied by the request filter,
+// before they reach the redirector. Rejected requests are recorded by the auditor.
+func (s *proxyService) filterRequests(ctx context.Context, unmarshal ttrpc.Unmarshaler, info *ttrpc.UnaryServerInfo, method ttrpc.Method) (interface{}, error) {
+	if s.filter == nil {
+		return method(ctx, unmarshal)
 	}
+
+	name := agentproto.MethodName(info.FullMethod)
+
+	return method(ctx, func(req interface{}) error {
+		if err := unmarshal(req); err != nil {
+			return err
+		}
+		if err := s.filter(ctx, name, req); err != nil {
+			logger.Printf("%s is rejected: %v", name, err)
+			if s.auditor != nil {
+				s.auditor.Record(name, req, err)
+			}
+			return err
+		}
+		return nil
+	})
 }
 
 // AgentServiceService methods
@@ -67,7 +106,7 @@ func (s *proxyService) CreateContainer(ctx context.Context, req *pb.CreateContai
 	}
 	if len(req.OCI.Annotations) > 0 {
 		logger.Print("    annotations:")
-		for k, v := range req.OCI.Annotations {
+		for k, v := range redact.Annotations(req.OCI.Annotations) {
 			logger.Printf("        %s: %s", k, v)
 		}
 	}
@@ -200,7 +239,7 @@ func (s *proxyService) CreateContainer(ctx context.Context, req *pb.CreateContai
 			logger.Printf("failed to marshal cloud_volumes annotation: %v", err)
 		} else {
 			req.OCI.Annotations[util.CloudVolumesAnnotationKey] = string(cvJSON)
-			logger.Printf("Set cloud_volumes annotation: %s", string(cvJSON))
+			logger.Printf("Set cloud_volumes annotation: %s", redact.CloudVolumes(string(cvJSON)))
 		}
 	}
 
@@ -226,7 +265,7 @@ func isNodePublishVolumeTargetPath(volumePath, directVolumesDir string) bool {
 
 func (s *proxyService) SetPolicy(ctx context.Context, req *pb.SetPolicyRequest) (*emptypb.Empty, error) {
 
-	logger.Printf("SetPolicy: policy:%s", req.Policy)
+	logger.Printf("SetPolicy: policy:%s", redact.Policy(req.Policy))
 
 	res, err := s.Redirector.SetPolicy(ctx, req)
 
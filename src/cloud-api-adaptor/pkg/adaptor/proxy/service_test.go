@@ -17,6 +17,9 @@ import (
 	pb "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/agent/protocols/grpc"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 // Test constants
@@ -132,11 +135,59 @@ func TestNewProxyService(t *testing.T) {
 		return nil, nil
 	}
 
-	service := newProxyService(dialer, testPauseImage)
+	service := newProxyService(dialer, testPauseImage, nil, nil)
 	assert.NotNil(t, service, "expected non-nil service")
 	assert.Equal(t, testPauseImage, service.pauseImage, "expected pause:3.9")
 }
 
+type recordingAuditor struct {
+	methods []string
+}
+
+func (a *recordingAuditor) Record(method string, req any, err error) {
+	a.methods = append(a.methods, method)
+}
+
+func TestProxyServiceFilterRequests(t *testing.T) {
+	dialer := func(ctx context.Context) (net.Conn, error) {
+		return nil, nil
+	}
+	filter := func(ctx context.Context, method string, req any) error {
+		if r, ok := req.(*pb.ExecProcessRequest); ok && r.ContainerId == "denied" {
+			return status.Error(codes.PermissionDenied, "denied")
+		}
+		return nil
+	}
+	auditor := &recordingAuditor{}
+	service := newProxyService(dialer, testPauseImage, auditor, filter)
+
+	info := &ttrpc.UnaryServerInfo{FullMethod: "/grpc.AgentService/ExecProcess"}
+	forwarded := 0
+	method := func(ctx context.Context, unmarshal func(interface{}) error) (interface{}, error) {
+		var req pb.ExecProcessRequest
+		if err := unmarshal(&req); err != nil {
+			return nil, err
+		}
+		forwarded++
+		return &emptypb.Empty{}, nil
+	}
+	unmarshalAs := func(containerID string) ttrpc.Unmarshaler {
+		return func(req interface{}) error {
+			req.(*pb.ExecProcessRequest).ContainerId = containerID
+			return nil
+		}
+	}
+
+	_, err := service.filterRequests(context.Background(), unmarshalAs("allowed"), info, method)
+	require.NoError(t, err)
+	assert.Equal(t, 1, forwarded)
+
+	_, err = service.filterRequests(context.Background(), unmarshalAs("denied"), info, method)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, 1, forwarded)
+	assert.Equal(t, []string{"ExecProcess"}, auditor.methods)
+}
+
 // createContainerRequestBuilder helps build CreateContainerRequest with fluent API
 type createContainerRequestBuilder struct {
 	req *pb.CreateContainerRequest
@@ -300,7 +351,7 @@ func TestProxyServiceCreateContainer(t *testing.T) {
 					return net.Dial(testNetworkTCP, errorAgentListener.Addr().String())
 				}
 
-				errorService := newProxyService(errorDialer, "")
+				errorService := newProxyService(errorDialer, "", nil, nil)
 				err = errorService.Connect(context.Background())
 				require.NoError(t, err, "failed to connect")
 
@@ -463,7 +514,7 @@ func setupMockAgentAndService(t *testing.T) (*proxyService, func()) {
 		return net.Dial(testNetworkTCP, agentListener.Addr().String())
 	}
 
-	service := newProxyService(dialer, "")
+	service := newProxyService(dialer, "", nil, nil)
 	err := service.Connect(context.Background())
 	require.NoError(t, err)
 
@@ -19,6 +19,7 @@ import (
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/proxy"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/vminfo"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/audit"
 	pbPodVMInfo "github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/proto/podvminfo"
 	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
 )
@@ -40,6 +41,8 @@ type server struct {
 	cloudService            cloud.Service
 	vmInfoService           pbPodVMInfo.PodVMInfoService
 	workerNode              podnetwork.WorkerNode
+	rendezvous              proxy.Rendezvous
+	auditor                 *audit.Auditor
 	ttRPC                   *ttrpc.Server
 	readyCh                 chan struct{}
 	stopCh                  chan struct{}
@@ -51,10 +54,25 @@ type server struct {
 	isOwner                 bool
 }
 
-func NewServer(provider provider.Provider, cfg *cloud.ServerConfig, workerNode podnetwork.WorkerNode) Server {
-	logger.Printf("server config: %#v", cfg)
+func NewServer(provider provider.Provider, cfg *cloud.ServerConfig, workerNode podnetwork.WorkerNode) (Server, error) {
+	logger.Printf("server config: %#v", cfg.Redact())
 
-	agentFactory := proxy.NewFactory(cfg.PauseImage, cfg.TLSConfig, cfg.ProxyTimeout)
+	// In reverse connect mode, pod VMs connect to the rendezvous listener instead of being dialed
+	var rendezvous proxy.Rendezvous
+	if cfg.ReverseConnectAddr != "" {
+		rendezvous = proxy.NewRendezvous(cfg.RendezvousListenAddr, cfg.TLSConfig)
+	}
+
+	agentFactory, err := proxy.NewFactory(cfg.PauseImage, cfg.TLSConfig, cfg.ProxyTimeout, proxy.FactoryOptions{
+		CredentialStore: cfg.CredentialStore,
+		Rendezvous:      rendezvous,
+		Dialer:          cfg.AgentDialer,
+		Verifier:        cfg.AttestationVerifier,
+		Auditor:         cfg.Auditor,
+	})
+	if err != nil {
+		return nil, err
+	}
 	cloudService := cloud.NewService(provider, agentFactory, workerNode, cfg)
 	vmInfoService := vminfo.NewService(cloudService)
 
@@ -63,12 +81,14 @@ func NewServer(provider provider.Provider, cfg *cloud.ServerConfig, workerNode p
 		cloudService:            cloudService,
 		vmInfoService:           vmInfoService,
 		workerNode:              workerNode,
+		rendezvous:              rendezvous,
+		auditor:                 cfg.Auditor,
 		readyCh:                 make(chan struct{}),
 		stopCh:                  make(chan struct{}),
 		enableCloudConfigVerify: cfg.EnableCloudConfigVerify,
 		PeerPodsLimitPerNode:    cfg.PeerPodsLimitPerNode,
 		ownerUID:                os.Getenv("POD_UID"),
-	}
+	}, nil
 }
 
 func (s *server) Start(ctx context.Context) (err error) {
@@ -86,6 +106,21 @@ func (s *server) Start(ctx context.Context) (err error) {
 		}
 	}
 
+	if s.rendezvous != nil {
+		if err := s.rendezvous.Start(); err != nil {
+			return err
+		}
+		defer s.rendezvous.Close()
+	}
+
+	if s.auditor != nil {
+		defer func() {
+			if err := s.auditor.Close(); err != nil {
+				logger.Printf("error closing audit sink: %v", err)
+			}
+		}()
+	}
+
 	ttRPC, err := ttrpc.NewServer()
 	if err != nil {
 		return err
@@ -159,7 +159,11 @@ func newServer(t *testing.T, socketPath, podsDir string) Server {
 		EnableCloudConfigVerify: false,
 		PeerPodsLimitPerNode:    -1,
 	}
-	return NewServer(provider, serverConfig, &mockWorkerNode{})
+	s, err := NewServer(provider, serverConfig, &mockWorkerNode{})
+	if err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+	return s
 }
 
 func testServerShutdown(t *testing.T, s Server, socketPath, dir string, serverErrCh chan error) {
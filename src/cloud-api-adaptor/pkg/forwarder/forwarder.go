@@ -11,14 +11,20 @@ import (
 	"log"
 	"net"
 	"sync"
+	"time"
 
+	retry "github.com/avast/retry-go/v4"
 	"github.com/containerd/ttrpc"
 	pb "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/agent/protocols/grpc"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/forwarder/interceptor"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/attestation"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tlsutil"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tracing"
+	putil "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util"
 )
 
 var logger = log.New(log.Writer(), "[forwarder] ", log.LstdFlags|log.Lmsgprefix)
@@ -32,6 +38,8 @@ const (
 	DefaultKataAgentSocketPath = "/run/kata-containers/agent.sock"
 	DefaultPodNamespace        = "/run/netns/podns"
 	AgentURLPath               = "/agent"
+
+	attestedTLSAttempts = 30
 )
 
 type Config struct {
@@ -43,6 +51,11 @@ type Config struct {
 	TLSServerCert string `json:"tls-server-cert,omitempty"`
 	TLSClientCA   string `json:"tls-client-ca,omitempty"`
 
+	// TLSAttester is set in attested TLS mode. The forwarder then generates its server key and certificate
+	// for TLSServerName, with evidence of the attester, instead of using TLSServerKey and TLSServerCert.
+	TLSAttester   string `json:"tls-attester,omitempty"`
+	TLSServerName string `json:"tls-server-name,omitempty"`
+
 	// MinTLSVersion and CipherSuites carry the operator-injected TLS profile through
 	// user-data to the agent protocol forwarder running inside the peer pod VM.
 	// These fields are serialized to apf.json and are immutable after VM boot —
@@ -50,8 +63,20 @@ type Config struct {
 	MinTLSVersion string   `json:"tls-min-version,omitempty"`
 	CipherSuites  []string `json:"tls-cipher-suites,omitempty"`
 
+	// ReverseConnectAddr is the address of the rendezvous listener of cloud-api-adaptor. When it is set,
+	// the forwarder dials out to it instead of listening, for pod VMs that the worker node cannot reach.
+	ReverseConnectAddr string `json:"reverse-connect-addr,omitempty"`
+
 	PpPrivateKey []byte `json:"sc-pp-prv,omitempty"`
 	WnPublicKey  []byte `json:"sc-wn-pub,omitempty"`
+
+	// Tracing is set when cloud-api-adaptor exports traces, so that spans of the pod VM join them
+	Tracing *tracing.Config `json:"tracing,omitempty"`
+}
+
+// Redact returns a copy of the config without private keys
+func (c Config) Redact() Config {
+	return *putil.RedactStruct(&c, "TLSServerKey", "PpPrivateKey", "PodNetwork.WireGuardPrivateKey").(*Config)
 }
 
 type Daemon interface {
@@ -68,6 +93,9 @@ type daemon struct {
 	readyCh             chan struct{}
 	stopCh              chan struct{}
 	listenAddr          string
+	reverseConnectAddr  string
+	attester            string
+	serverName          string
 	stopOnce            sync.Once
 	externalNetViaPodVM bool
 }
@@ -93,12 +121,15 @@ func NewDaemon(spec *Config, listenAddr string, tlsConfig *tlsutil.TLSConfig, in
 	}
 
 	daemon := &daemon{
-		listenAddr:  listenAddr,
-		tlsConfig:   tlsConfig,
-		interceptor: interceptor,
-		podNode:     podNode,
-		readyCh:     make(chan struct{}),
-		stopCh:      make(chan struct{}),
+		listenAddr:         listenAddr,
+		reverseConnectAddr: spec.ReverseConnectAddr,
+		attester:           spec.TLSAttester,
+		serverName:         spec.TLSServerName,
+		tlsConfig:          tlsConfig,
+		interceptor:        interceptor,
+		podNode:            podNode,
+		readyCh:            make(chan struct{}),
+		stopCh:             make(chan struct{}),
 	}
 
 	if spec.PodNetwork != nil {
@@ -121,12 +152,32 @@ func (d *daemon) Start(ctx context.Context) error {
 		}
 	}()
 
+	// Generate a server certificate with evidence in attested TLS mode
+
+	if d.attester != "" {
+		if err := d.setupAttestedTLS(ctx); err != nil {
+			return err
+		}
+	}
+
 	// Set up agent protocol interceptor
 
 	var listener net.Listener
 
-	logger.Printf("Starting agent-protocol-forwarder listener on address %v", d.listenAddr)
-	if d.tlsConfig != nil {
+	if d.reverseConnectAddr != "" {
+		logger.Printf("Starting agent-protocol-forwarder in reverse connect mode to address %v", d.reverseConnectAddr)
+		if d.tlsConfig == nil {
+			return errors.New("reverse connect mode requires TLS")
+		}
+
+		tlsConfig, err := tlsutil.GetTLSConfigFor(d.tlsConfig)
+		if err != nil {
+			return fmt.Errorf("Failed to create tls config: %v", err)
+		}
+
+		listener = newReverseListener(d.reverseConnectAddr, tlsConfig)
+	} else if d.tlsConfig != nil {
+		logger.Printf("Starting agent-protocol-forwarder listener on address %v", d.listenAddr)
 		logger.Printf("TLS is configured. Configure TLS listener")
 
 		// Create a TLS configuration object
@@ -141,6 +192,8 @@ func (d *daemon) Start(ctx context.Context) error {
 			return err
 		}
 	} else {
+		logger.Printf("Starting agent-protocol-forwarder listener on address %v", d.listenAddr)
+
 		var err error
 
 		listener, err = net.Listen("tcp", d.listenAddr)
@@ -152,7 +205,8 @@ func (d *daemon) Start(ctx context.Context) error {
 
 	d.listenAddr = listener.Addr().String()
 
-	ttrpcServer, err := ttrpc.NewServer()
+	// Requests continue the trace context propagated by cloud-api-adaptor in ttrpc metadata
+	ttrpcServer, err := ttrpc.NewServer(ttrpc.WithUnaryServerInterceptor(tracing.UnaryServerInterceptor(trace.SpanContext{})))
 	if err != nil {
 		return fmt.Errorf("failed to create TTRPC server: %w", err)
 	}
@@ -190,6 +244,45 @@ func (d *daemon) Start(ctx context.Context) error {
 	return nil
 }
 
+func (d *daemon) setupAttestedTLS(ctx context.Context) error {
+	if d.tlsConfig == nil {
+		return errors.New("attested TLS mode requires TLS")
+	}
+
+	attester, err := attestation.NewAttester(d.attester)
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("Attested TLS is configured. Generating a server certificate with evidence of %s attester", d.attester)
+
+	// The attestation agent may not be ready yet when the forwarder starts
+	var certPEM, keyPEM []byte
+	err = retry.Do(
+		func() error {
+			var err error
+			if certPEM, keyPEM, err = attestation.NewCertificate(ctx, attester, d.serverName); err != nil {
+				logger.Printf("Retrying failed generation of attested server certificate: %v", err)
+			}
+			return err
+		},
+		retry.Attempts(attestedTLSAttempts),
+		retry.Context(ctx),
+		retry.MaxDelay(5*time.Second),
+		retry.LastErrorOnly(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to generate attested server certificate: %w", err)
+	}
+
+	d.tlsConfig.CertData = certPEM
+	d.tlsConfig.KeyData = keyPEM
+	d.tlsConfig.CertFile = ""
+	d.tlsConfig.KeyFile = ""
+
+	return nil
+}
+
 func (d *daemon) Shutdown() error {
 	d.stopOnce.Do(func() {
 		close(d.stopCh)
@@ -692,3 +692,35 @@ func TestDaemonWithTLSConfig(t *testing.T) {
 		assert.Nil(t, daemonImpl.tlsConfig)
 	})
 }
+
+func TestConfigRedact(t *testing.T) {
+	config := Config{
+		PodName:       "web",
+		TLSServerKey:  "server key",
+		TLSServerCert: "server cert",
+		PpPrivateKey:  []byte("private key"),
+		WnPublicKey:   []byte("public key"),
+		PodNetwork: &tunneler.Config{
+			TunnelType:             "wireguard",
+			WireGuardPrivateKey:    []byte("wireguard private key"),
+			WireGuardPeerPublicKey: []byte("wireguard public key"),
+		},
+	}
+
+	data, err := json.Marshal(config.Redact())
+	require.NoError(t, err)
+
+	var redacted Config
+	require.NoError(t, json.Unmarshal(data, &redacted))
+	assert.Equal(t, "web", redacted.PodName)
+	assert.Equal(t, "server cert", redacted.TLSServerCert)
+	assert.Equal(t, []byte("public key"), redacted.WnPublicKey)
+	assert.NotEqual(t, "server key", redacted.TLSServerKey)
+	assert.Empty(t, redacted.PpPrivateKey)
+	assert.Equal(t, "wireguard", redacted.PodNetwork.TunnelType)
+	assert.Equal(t, []byte("wireguard public key"), redacted.PodNetwork.WireGuardPeerPublicKey)
+	assert.Empty(t, redacted.PodNetwork.WireGuardPrivateKey)
+
+	assert.Equal(t, "server key", config.TLSServerKey)
+	assert.Equal(t, []byte("wireguard private key"), config.PodNetwork.WireGuardPrivateKey)
+}
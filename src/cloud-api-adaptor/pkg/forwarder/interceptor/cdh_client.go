@@ -10,8 +10,10 @@ import (
 	"time"
 
 	"github.com/containerd/ttrpc"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/forwarder/interceptor/cdhpb"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tracing"
 )
 
 const cdhServiceName = "api.SecureMountService"
@@ -48,7 +50,7 @@ func newCDHClient(ctx context.Context, socketPath string) (*cdhClient, error) {
 		return nil, fmt.Errorf("dialing CDH socket %s after %d attempts: %w", socketPath, maxAttempts, err)
 	}
 
-	client := ttrpc.NewClient(conn)
+	client := ttrpc.NewClient(conn, ttrpc.WithUnaryClientInterceptor(tracing.UnaryClientInterceptor()))
 	return &cdhClient{conn: conn, client: client}, nil
 }
 
@@ -59,7 +61,12 @@ func (c *cdhClient) close() {
 	}
 }
 
-func (c *cdhClient) secureMount(ctx context.Context, volumeType string, options map[string]string, flags []string, mountPoint string) error {
+func (c *cdhClient) secureMount(ctx context.Context, volumeType string, options map[string]string, flags []string, mountPoint string) (err error) {
+	ctx, span := tracing.Start(ctx, "CDH SecureMount",
+		attribute.String("volume.type", volumeType),
+		attribute.String("mount.point", mountPoint))
+	defer func() { tracing.End(span, err) }()
+
 	req := &cdhpb.SecureMountRequest{
 		VolumeType: volumeType,
 		Options:    options,
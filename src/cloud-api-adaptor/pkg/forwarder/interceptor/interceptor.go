@@ -23,10 +23,12 @@ import (
 	pb "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/agent/protocols/grpc"
 	"github.com/moby/sys/mountinfo"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/protobuf/types/known/emptypb"
 
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/agentproto"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tracing"
 )
 
 const (
@@ -160,97 +162,8 @@ func (i *interceptor) CreateContainer(ctx context.Context, req *pb.CreateContain
 	}
 
 	if cvJSON, ok := req.OCI.Annotations[util.CloudVolumesAnnotationKey]; ok && cvJSON != "" {
-		var cloudVolumes map[string]util.CloudVolumeAnnotation
-		if err := json.Unmarshal([]byte(cvJSON), &cloudVolumes); err != nil {
-			return nil, fmt.Errorf("corrupt cloud_volumes annotation (pod would start without volumes): %w", err)
-		}
-
-		volNames := make([]string, 0, len(cloudVolumes))
-		for k := range cloudVolumes {
-			volNames = append(volNames, k)
-		}
-		sort.Strings(volNames)
-
-		for _, volName := range volNames {
-			volInfo := cloudVolumes[volName]
-			mountPoint := volInfo.MountPoint
-			fsType := volInfo.FSType
-			lunStr := volInfo.LUN
-			if mountPoint == "" || lunStr == "" {
-				return nil, fmt.Errorf("cloud volume %s missing required mount_point or lun field", volName)
-			}
-
-			safeName := filepath.Base(volName)
-			if safeName != volName || safeName == "." || safeName == ".." {
-				return nil, fmt.Errorf("cloud volume %q has unsafe name", volName)
-			}
-
-			if fsType == "" {
-				fsType = "ext4"
-			}
-			if !allowedFSTypes[fsType] {
-				return nil, fmt.Errorf("cloud volume %s requests unsupported filesystem type %q (allowed: ext4, ext3, xfs)", volName, fsType)
-			}
-
-			lunIdx, err := strconv.Atoi(lunStr)
-			if err != nil {
-				return nil, fmt.Errorf("cloud volume %s has invalid lun %q: %w", volName, lunStr, err)
-			}
-
-			diskID := volInfo.DiskID
-			device, err := findDataDiskDevice(lunIdx, diskID)
-			if err != nil {
-				return nil, fmt.Errorf("cloud volume %s: %w", volName, err)
-			}
-			logger.Printf("cloud volume %s: LUN %d -> device %s", volName, lunIdx, device)
-
-			hostMountPoint := filepath.Join(cloudVolumeMountBase, safeName)
-			if err := os.MkdirAll(hostMountPoint, 0o755); err != nil {
-				return nil, fmt.Errorf("creating mount point for %s: %w", volName, err)
-			}
-
-			if err := waitForDevice(device); err != nil {
-				return nil, fmt.Errorf("cloud volume %s device %s not available: %w", volName, device, err)
-			}
-
-			if volInfo.EncryptType != "" {
-				mapperName := "caa-" + safeName
-				if err := secureMount(ctx, device, hostMountPoint, fsType, volInfo.EncryptType, volInfo.KeyID, mapperName); err != nil {
-					return nil, fmt.Errorf("failed to secure-mount cloud volume %s at %s: %w", volName, hostMountPoint, err)
-				}
-				i.cloudMounts = append(i.cloudMounts, cloudMount{path: hostMountPoint, encrypted: true, mapperName: mapperName})
-			} else {
-				if err := formatAndMount(device, hostMountPoint, fsType); err != nil {
-					return nil, fmt.Errorf("failed to mount cloud volume %s at %s: %w", volName, hostMountPoint, err)
-				}
-				i.cloudMounts = append(i.cloudMounts, cloudMount{path: hostMountPoint, encrypted: false})
-			}
-
-			if fsGroupStr := volInfo.FSGroup; fsGroupStr != "" {
-				if gid, err := strconv.Atoi(fsGroupStr); err == nil {
-					logger.Printf("cloud volume %s: applying fsGroup %d to %s", volName, gid, hostMountPoint)
-					if err := os.Chown(hostMountPoint, -1, gid); err != nil {
-						logger.Printf("WARNING: failed to chown %s to gid %d: %v", hostMountPoint, gid, err)
-					}
-					if err := os.Chmod(hostMountPoint, 0o2775); err != nil {
-						logger.Printf("WARNING: failed to chmod %s: %v", hostMountPoint, err)
-					}
-				}
-			}
-
-			rewrote := false
-			for idx, m := range req.OCI.Mounts {
-				if m.Destination == mountPoint {
-					req.OCI.Mounts[idx].Source = hostMountPoint
-					req.OCI.Mounts[idx].Type = "bind"
-					logger.Printf("cloud volume %s: rewrote mount source to %s", volName, hostMountPoint)
-					rewrote = true
-					break
-				}
-			}
-			if !rewrote {
-				logger.Printf("WARNING: cloud volume %s mount_point %q not found in container mounts", volName, mountPoint)
-			}
+		if err := i.mountCloudVolumes(ctx, req, cvJSON); err != nil {
+			return nil, err
 		}
 	}
 
@@ -285,6 +198,107 @@ func (i *interceptor) CreateContainer(ctx context.Context, req *pb.CreateContain
 	return res, err
 }
 
+// mountCloudVolumes mounts the cloud volumes attached to the pod VM, and rewrites the container mounts to use them
+func (i *interceptor) mountCloudVolumes(ctx context.Context, req *pb.CreateContainerRequest, cvJSON string) (err error) {
+	ctx, span := tracing.Start(ctx, "MountCloudVolumes", attribute.String("container.id", req.ContainerId))
+	defer func() { tracing.End(span, err) }()
+
+	var cloudVolumes map[string]util.CloudVolumeAnnotation
+	if err := json.Unmarshal([]byte(cvJSON), &cloudVolumes); err != nil {
+		return fmt.Errorf("corrupt cloud_volumes annotation (pod would start without volumes): %w", err)
+	}
+
+	volNames := make([]string, 0, len(cloudVolumes))
+	for k := range cloudVolumes {
+		volNames = append(volNames, k)
+	}
+	sort.Strings(volNames)
+
+	for _, volName := range volNames {
+		volInfo := cloudVolumes[volName]
+		mountPoint := volInfo.MountPoint
+		fsType := volInfo.FSType
+		lunStr := volInfo.LUN
+		if mountPoint == "" || lunStr == "" {
+			return fmt.Errorf("cloud volume %s missing required mount_point or lun field", volName)
+		}
+
+		safeName := filepath.Base(volName)
+		if safeName != volName || safeName == "." || safeName == ".." {
+			return fmt.Errorf("cloud volume %q has unsafe name", volName)
+		}
+
+		if fsType == "" {
+			fsType = "ext4"
+		}
+		if !allowedFSTypes[fsType] {
+			return fmt.Errorf("cloud volume %s requests unsupported filesystem type %q (allowed: ext4, ext3, xfs)", volName, fsType)
+		}
+
+		lunIdx, err := strconv.Atoi(lunStr)
+		if err != nil {
+			return fmt.Errorf("cloud volume %s has invalid lun %q: %w", volName, lunStr, err)
+		}
+
+		diskID := volInfo.DiskID
+		device, err := findDataDiskDevice(lunIdx, diskID)
+		if err != nil {
+			return fmt.Errorf("cloud volume %s: %w", volName, err)
+		}
+		logger.Printf("cloud volume %s: LUN %d -> device %s", volName, lunIdx, device)
+
+		hostMountPoint := filepath.Join(cloudVolumeMountBase, safeName)
+		if err := os.MkdirAll(hostMountPoint, 0o755); err != nil {
+			return fmt.Errorf("creating mount point for %s: %w", volName, err)
+		}
+
+		if err := waitForDevice(device); err != nil {
+			return fmt.Errorf("cloud volume %s device %s not available: %w", volName, device, err)
+		}
+
+		if volInfo.EncryptType != "" {
+			mapperName := "caa-" + safeName
+			if err := secureMount(ctx, device, hostMountPoint, fsType, volInfo.EncryptType, volInfo.KeyID, mapperName); err != nil {
+				return fmt.Errorf("failed to secure-mount cloud volume %s at %s: %w", volName, hostMountPoint, err)
+			}
+			i.cloudMounts = append(i.cloudMounts, cloudMount{path: hostMountPoint, encrypted: true, mapperName: mapperName})
+		} else {
+			if err := formatAndMount(device, hostMountPoint, fsType); err != nil {
+				return fmt.Errorf("failed to mount cloud volume %s at %s: %w", volName, hostMountPoint, err)
+			}
+			i.cloudMounts = append(i.cloudMounts, cloudMount{path: hostMountPoint, encrypted: false})
+		}
+
+		if fsGroupStr := volInfo.FSGroup; fsGroupStr != "" {
+			if gid, err := strconv.Atoi(fsGroupStr); err == nil {
+				logger.Printf("cloud volume %s: applying fsGroup %d to %s", volName, gid, hostMountPoint)
+				if err := os.Chown(hostMountPoint, -1, gid); err != nil {
+					logger.Printf("WARNING: failed to chown %s to gid %d: %v", hostMountPoint, gid, err)
+				}
+				if err := os.Chmod(hostMountPoint, 0o2775); err != nil {
+					logger.Printf("WARNING: failed to chmod %s: %v", hostMountPoint, err)
+				}
+			}
+		}
+
+		rewrote := false
+		for idx, m := range req.OCI.Mounts {
+			if m.Destination == mountPoint {
+				req.OCI.Mounts[idx].Source = hostMountPoint
+				req.OCI.Mounts[idx].Type = "bind"
+				logger.Printf("cloud volume %s: rewrote mount source to %s", volName, hostMountPoint)
+				rewrote = true
+				break
+			}
+		}
+		if !rewrote {
+			logger.Printf("WARNING: cloud volume %s mount_point %q not found in container mounts", volName, mountPoint)
+		}
+	}
+
+	return nil
+}
+
 func isTargetPath(path, targetPath string) bool {
 	return targetPath != "" && targetPath == path
 }
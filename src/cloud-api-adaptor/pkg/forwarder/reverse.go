@@ -0,0 +1,134 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package forwarder
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	retry "github.com/avast/retry-go/v4"
+)
+
+const (
+	reverseConnectMaxDelay = 5 * time.Second
+
+	// reverseConnectMinInterval limits how often a new connection is dialed when cloud-api-adaptor
+	// closes connections right away, e.g. because the pod VM is not known to it yet
+	reverseConnectMinInterval = time.Second
+)
+
+// reverseListener is a net.Listener that dials out to the rendezvous listener of cloud-api-adaptor
+// instead of accepting inbound connections. It keeps one connection open at a time, and dials a new
+// one when the previous connection is closed. The forwarder remains the TLS server on the
+// connection, so cloud-api-adaptor authenticates it with the certificate issued for the pod VM.
+type reverseListener struct {
+	addr      string
+	tlsConfig *tls.Config
+	dialer    net.Dialer
+	active    chan struct{}
+	lastDial  time.Time
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+func newReverseListener(addr string, tlsConfig *tls.Config) *reverseListener {
+	return &reverseListener{
+		addr:      addr,
+		tlsConfig: tlsConfig,
+		active:    make(chan struct{}, 1),
+		closeCh:   make(chan struct{}),
+	}
+}
+
+func (l *reverseListener) Accept() (net.Conn, error) {
+	select {
+	case l.active <- struct{}{}:
+	case <-l.closeCh:
+		return nil, net.ErrClosed
+	}
+
+	// Only one Accept holds the active slot at a time, so lastDial is not accessed concurrently
+	if wait := time.Until(l.lastDial.Add(reverseConnectMinInterval)); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-l.closeCh:
+			timer.Stop()
+			<-l.active
+			return nil, net.ErrClosed
+		}
+	}
+	l.lastDial = time.Now()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-l.closeCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var conn net.Conn
+	err := retry.Do(
+		func() error {
+			var err error
+			if conn, err = l.dialer.DialContext(ctx, "tcp", l.addr); err != nil {
+				logger.Printf("Retrying failed reverse connection to %s: %v", l.addr, err)
+			}
+			return err
+		},
+		retry.Attempts(0),
+		retry.Context(ctx),
+		retry.MaxDelay(reverseConnectMaxDelay),
+		retry.LastErrorOnly(true),
+	)
+	if err != nil {
+		<-l.active
+		return nil, net.ErrClosed
+	}
+
+	logger.Printf("established reverse connection to %s", l.addr)
+
+	if l.tlsConfig != nil {
+		conn = tls.Server(conn, l.tlsConfig)
+	}
+
+	return &reverseConn{Conn: conn, listener: l}, nil
+}
+
+func (l *reverseListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closeCh)
+	})
+	return nil
+}
+
+func (l *reverseListener) Addr() net.Addr {
+	return reverseAddr(l.addr)
+}
+
+// reverseConn lets the listener dial the next connection once it is closed
+type reverseConn struct {
+	net.Conn
+	listener *reverseListener
+	once     sync.Once
+}
+
+func (c *reverseConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		<-c.listener.active
+	})
+	return err
+}
+
+type reverseAddr string
+
+func (a reverseAddr) Network() string { return "tcp" }
+func (a reverseAddr) String() string  { return string(a) }
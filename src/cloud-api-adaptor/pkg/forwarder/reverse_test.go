@@ -0,0 +1,65 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package forwarder
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseListener(t *testing.T) {
+	rendezvous, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer rendezvous.Close()
+
+	l := newReverseListener(rendezvous.Addr().String(), nil)
+	assert.Equal(t, rendezvous.Addr().String(), l.Addr().String())
+
+	accept := func() <-chan net.Conn {
+		ch := make(chan net.Conn, 1)
+		go func() {
+			conn, err := l.Accept()
+			if err == nil {
+				ch <- conn
+			}
+			close(ch)
+		}()
+		return ch
+	}
+
+	start := time.Now()
+	first := <-accept()
+	require.NotNil(t, first)
+	server, err := rendezvous.Accept()
+	require.NoError(t, err)
+	defer server.Close()
+
+	// The next connection is dialed only after the previous one is closed
+	next := accept()
+	select {
+	case <-next:
+		t.Fatal("a second connection was dialed while the first one is open")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, first.Close())
+	second := <-next
+	require.NotNil(t, second)
+	defer second.Close()
+
+	// Connections are not dialed more often than the minimum interval
+	assert.GreaterOrEqual(t, time.Since(start), reverseConnectMinInterval)
+
+	// Accept returns an error after the listener is closed
+	closed := accept()
+	require.NoError(t, l.Close())
+	_, ok := <-closed
+	assert.False(t, ok)
+	_, err = l.Accept()
+	assert.ErrorIs(t, err, net.ErrClosed)
+}
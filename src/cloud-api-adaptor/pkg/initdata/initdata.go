@@ -101,3 +101,53 @@ func DecodeAnnotation(annotation string) ([]byte, error) {
 	reader := strings.NewReader(annotation)
 	return decode(reader)
 }
+
+// PolicyKey is the key of the kata agent policy in the data of initdata
+const PolicyKey = "policy.rego"
+
+// HasPolicy reports whether encoded initdata carries a kata agent policy. Empty initdata carries none.
+func HasPolicy(encoded string) (bool, error) {
+	if encoded == "" {
+		return false, nil
+	}
+	id, err := Parse(strings.NewReader(encoded))
+	if err != nil {
+		return false, err
+	}
+	_, ok := id.Body.Data[PolicyKey]
+	return ok, nil
+}
+
+// WithDefaultPolicy returns encoded initdata that carries policy as its kata agent policy, unless the initdata
+// already carries one. Empty initdata is replaced by new initdata with only the policy. The second return value
+// reports whether the policy was added.
+func WithDefaultPolicy(encoded, policy string) (string, bool, error) {
+	body := &InitDataBody{
+		Algorithm: "sha256",
+		Version:   "0.1.0",
+	}
+	if encoded != "" {
+		id, err := Parse(strings.NewReader(encoded))
+		if err != nil {
+			return "", false, err
+		}
+		if _, ok := id.Body.Data[PolicyKey]; ok {
+			return encoded, false, nil
+		}
+		body = id.Body
+	}
+	if body.Data == nil {
+		body.Data = map[string]string{}
+	}
+	body.Data[PolicyKey] = policy
+
+	initdataToml, err := toml.Marshal(body)
+	if err != nil {
+		return "", false, err
+	}
+	merged, err := Encode(string(initdataToml))
+	if err != nil {
+		return "", false, err
+	}
+	return merged, true, nil
+}
@@ -0,0 +1,61 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package initdata
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = "package agent_policy\n\ndefault ExecProcessRequest := false\n"
+
+func TestWithDefaultPolicy(t *testing.T) {
+	// Empty initdata
+	has, err := HasPolicy("")
+	require.NoError(t, err)
+	assert.False(t, has)
+	merged, added, err := WithDefaultPolicy("", testPolicy)
+	require.NoError(t, err)
+	assert.True(t, added)
+	id, err := Parse(strings.NewReader(merged))
+	require.NoError(t, err)
+	assert.Equal(t, "sha256", id.Body.Algorithm)
+	assert.Equal(t, testPolicy, id.Body.Data[PolicyKey])
+	assert.NotEmpty(t, id.Digest)
+
+	// Initdata without a policy keeps its algorithm and other files
+	encoded, err := Encode("algorithm = \"sha384\"\nversion = \"0.1.0\"\n\n[data]\n\"aa.toml\" = '''\n[token_configs]\n'''\n")
+	require.NoError(t, err)
+	has, err = HasPolicy(encoded)
+	require.NoError(t, err)
+	assert.False(t, has)
+	merged, added, err = WithDefaultPolicy(encoded, testPolicy)
+	require.NoError(t, err)
+	assert.True(t, added)
+	id, err = Parse(strings.NewReader(merged))
+	require.NoError(t, err)
+	assert.Equal(t, "sha384", id.Body.Algorithm)
+	assert.Equal(t, "[token_configs]\n", id.Body.Data["aa.toml"])
+	assert.Equal(t, testPolicy, id.Body.Data[PolicyKey])
+	assert.Len(t, id.Digest, 96)
+
+	// Initdata with a policy is unchanged
+	encoded, err = Encode("algorithm = \"sha256\"\nversion = \"0.1.0\"\n\n[data]\n\"policy.rego\" = '''\npackage agent_policy\n'''\n")
+	require.NoError(t, err)
+	has, err = HasPolicy(encoded)
+	require.NoError(t, err)
+	assert.True(t, has)
+	merged, added, err = WithDefaultPolicy(encoded, testPolicy)
+	require.NoError(t, err)
+	assert.False(t, added)
+	assert.Equal(t, encoded, merged)
+
+	_, _, err = WithDefaultPolicy("not initdata", testPolicy)
+	assert.Error(t, err)
+	_, err = HasPolicy("not initdata")
+	assert.Error(t, err)
+}
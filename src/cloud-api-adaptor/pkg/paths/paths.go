@@ -8,5 +8,6 @@ const (
 	ScratchSpacePath = "/run/peerpod/scratch-space.marker"
 	AgentCfgPath     = "/run/peerpod/agent-config.toml"
 	ForwarderCfgPath = "/run/peerpod/apf.json"
+	ProvisionCfgPath = "/run/peerpod/provision.json"
 	UserDataPath     = "/media/cidata/user-data"
 )
@@ -14,7 +14,10 @@ import (
 	"unicode"
 
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler/geneve"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler/routed"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler/vxlan"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler/wireguard"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
 )
 
@@ -23,6 +26,9 @@ var ErrNoSecondaryInterface = errors.New("no valid secondary interface found")
 
 func init() {
 	tunneler.Register("vxlan", vxlan.NewWorkerNodeTunneler, vxlan.NewPodNodeTunneler)
+	tunneler.Register("geneve", geneve.NewWorkerNodeTunneler, geneve.NewPodNodeTunneler)
+	tunneler.Register("wireguard", wireguard.NewWorkerNodeTunneler, wireguard.NewPodNodeTunneler)
+	tunneler.Register(routed.TunnelType, routed.NewWorkerNodeTunneler, routed.NewPodNodeTunneler)
 }
 
 // extractInterfaceNumber splits the interface name into prefix and numeric parts
@@ -51,6 +57,7 @@ func extractInterfaceNumber(iface string) (prefix string, num int) {
 
 // findPrimaryInterface identifies the primary interface on the given network namespace.
 // An interface is considered to be primary if it is attached to the default route.
+// The IPv4 default route is preferred, and the IPv6 one is used on IPv6 only networks.
 func findPrimaryInterface(ns netops.Namespace) (string, netip.Addr, error) {
 	var primaryDev string
 	var primaryPrefix string
@@ -62,6 +69,12 @@ func findPrimaryInterface(ns netops.Namespace) (string, netip.Addr, error) {
 	if err != nil {
 		return "", gw, fmt.Errorf("failed to get routes on namespace %q: %w", ns.Path(), err)
 	}
+	if len(routes) == 0 {
+		routes, err = ns.RouteList(&netops.Route{Destination: netops.DefaultPrefixV6})
+		if err != nil {
+			return "", gw, fmt.Errorf("failed to get routes on namespace %q: %w", ns.Path(), err)
+		}
+	}
 
 	for _, r := range routes {
 		// Default route check
@@ -91,6 +104,16 @@ func findPrimaryInterface(ns netops.Namespace) (string, netip.Addr, error) {
 	return primaryDev, gw, nil
 }
 
+// defaultGateway returns the gateway of the default route on dev for the address family of prefix
+func defaultGateway(routes []*netops.Route, dev string, prefix netip.Prefix) netip.Addr {
+	for _, r := range routes {
+		if r.Device == dev && r.Destination.Bits() == 0 && r.Gateway.IsValid() && r.Gateway.Is4() == prefix.Addr().Is4() {
+			return r.Gateway
+		}
+	}
+	return netip.Addr{}
+}
+
 func setupExternalNetwork(hostNS netops.Namespace, hostPrimaryInterface string, podNS netops.Namespace) error {
 
 	// Get the secondary interface details
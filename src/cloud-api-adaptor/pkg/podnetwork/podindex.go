@@ -0,0 +1,185 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package podnetwork
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// PodIndexFile is the name of the file under PodsDir that records the pod indexes allocated on the node
+const PodIndexFile = "pod-index.json"
+
+// netnsDir is the directory where container runtimes create pod network namespaces
+var netnsDir = "/var/run/netns"
+
+// podIndex allocates a unique index number to each pod network namespace. Tunnel IDs and ports are derived from the index.
+// When path is set, indexes are persisted in the file, so that they are not reused after cloud-api-adaptor restarts.
+// The file is locked while it is updated, so that processes on the same node, such as an old and a new cloud-api-adaptor
+// during a rolling update, do not allocate the same index.
+type podIndex struct {
+	path    string
+	mutex   sync.Mutex
+	indexes map[string]int
+}
+
+func newPodIndex(path string) *podIndex {
+	return &podIndex{
+		path:    path,
+		indexes: make(map[string]int),
+	}
+}
+
+// Allocate returns the index of a pod network namespace. The lowest unused index is allocated to a new namespace.
+func (p *podIndex) Allocate(nsPath string) (int, error) {
+
+	var index int
+
+	err := p.update(func(indexes map[string]int) error {
+		if i, ok := indexes[nsPath]; ok {
+			index = i
+			return nil
+		}
+
+		used := make(map[int]bool, len(indexes))
+		for _, i := range indexes {
+			used[i] = true
+		}
+		for used[index] {
+			index++
+		}
+		indexes[nsPath] = index
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate a pod index for %s: %w", nsPath, err)
+	}
+
+	return index, nil
+}
+
+// Release frees the index of a pod network namespace
+func (p *podIndex) Release(nsPath string) error {
+
+	err := p.update(func(indexes map[string]int) error {
+		delete(indexes, nsPath)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release the pod index of %s: %w", nsPath, err)
+	}
+
+	return nil
+}
+
+// Rebuild drops indexes of network namespaces that no longer exist, and records indexes found in tunnel devices
+// of live network namespaces. A live index takes precedence over a recorded index of another namespace.
+func (p *podIndex) Rebuild(live map[string]int) error {
+
+	return p.update(func(indexes map[string]int) error {
+
+		for nsPath := range indexes {
+			if _, err := os.Stat(nsPath); errors.Is(err, os.ErrNotExist) {
+				logger.Printf("release pod index %d of netns %s, which no longer exists", indexes[nsPath], nsPath)
+				delete(indexes, nsPath)
+			}
+		}
+
+		for nsPath, index := range live {
+			for other, i := range indexes {
+				if i == index && other != nsPath {
+					if _, ok := live[other]; !ok {
+						logger.Printf("pod index %d of netns %s is used by netns %s", index, other, nsPath)
+						delete(indexes, other)
+					}
+				}
+			}
+			indexes[nsPath] = index
+		}
+
+		return nil
+	})
+}
+
+func (p *podIndex) update(fn func(indexes map[string]int) error) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.path == "" {
+		return fn(p.indexes)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o700); err != nil {
+		return fmt.Errorf("creating %s: %w", filepath.Dir(p.path), err)
+	}
+
+	// The lock is held on a separate file, since the index file is replaced on every update.
+	// It is released when the lock file is closed.
+	lockFile, err := os.OpenFile(p.path+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening %s.lock: %w", p.path, err)
+	}
+	defer lockFile.Close()
+
+	if err := unix.Flock(int(lockFile.Fd()), unix.LOCK_EX); err != nil {
+		return fmt.Errorf("locking %s.lock: %w", p.path, err)
+	}
+
+	indexes := make(map[string]int)
+
+	data, err := os.ReadFile(p.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("reading %s: %w", p.path, err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &indexes); err != nil {
+			return fmt.Errorf("decoding %s: %w", p.path, err)
+		}
+	}
+
+	if err := fn(indexes); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(p.path, indexes)
+}
+
+func writeFileAtomic(path string, v any) error {
+
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("creating a temporary file for %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("syncing %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", tmp.Name(), err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("storing %s: %w", path, err)
+	}
+
+	return nil
+}
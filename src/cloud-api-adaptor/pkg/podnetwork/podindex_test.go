@@ -0,0 +1,134 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package podnetwork
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPodIndexAllocate(t *testing.T) {
+	p := newPodIndex("")
+
+	for i, nsPath := range []string{"/run/netns/a", "/run/netns/b", "/run/netns/c"} {
+		index, err := p.Allocate(nsPath)
+		require.NoError(t, err)
+		assert.Equal(t, i, index)
+	}
+
+	index, err := p.Allocate("/run/netns/b")
+	require.NoError(t, err)
+	assert.Equal(t, 1, index, "the same netns gets the same index")
+
+	require.NoError(t, p.Release("/run/netns/b"))
+
+	index, err = p.Allocate("/run/netns/d")
+	require.NoError(t, err)
+	assert.Equal(t, 1, index, "the lowest released index is reused")
+
+	index, err = p.Allocate("/run/netns/e")
+	require.NoError(t, err)
+	assert.Equal(t, 3, index)
+}
+
+func TestPodIndexPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), PodIndexFile)
+
+	p1 := newPodIndex(path)
+	for _, nsPath := range []string{"/run/netns/a", "/run/netns/b"} {
+		_, err := p1.Allocate(nsPath)
+		require.NoError(t, err)
+	}
+
+	// A new process does not reuse indexes allocated by the previous one
+	p2 := newPodIndex(path)
+	index, err := p2.Allocate("/run/netns/c")
+	require.NoError(t, err)
+	assert.Equal(t, 2, index)
+
+	index, err = p2.Allocate("/run/netns/a")
+	require.NoError(t, err)
+	assert.Equal(t, 0, index)
+
+	require.NoError(t, p2.Release("/run/netns/a"))
+
+	index, err = p1.Allocate("/run/netns/d")
+	require.NoError(t, err)
+	assert.Equal(t, 0, index)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestPodIndexConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), PodIndexFile)
+
+	const n = 20
+
+	var wg sync.WaitGroup
+	indexes := make([]int, n)
+
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Separate allocators share the file, as separate processes do
+			index, err := newPodIndex(path).Allocate(filepath.Join("/run/netns", string(rune('a'+i))))
+			assert.NoError(t, err)
+			indexes[i] = index
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[int]bool)
+	for _, index := range indexes {
+		assert.False(t, seen[index], "index %d is allocated twice", index)
+		seen[index] = true
+	}
+}
+
+func TestPodIndexRebuild(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, PodIndexFile)
+
+	existing := func(name string) string {
+		nsPath := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(nsPath, nil, 0o600))
+		return nsPath
+	}
+
+	a := existing("a")
+	b := existing("b")
+	c := existing("c")
+	deleted := filepath.Join(dir, "deleted")
+
+	p := newPodIndex(path)
+	for _, nsPath := range []string{a, deleted, b} {
+		_, err := p.Allocate(nsPath)
+		require.NoError(t, err)
+	}
+
+	// The tunnel device of c uses index 2, which was recorded for b
+	require.NoError(t, newPodIndex(path).Rebuild(map[string]int{a: 0, c: 2}))
+
+	p = newPodIndex(path)
+
+	index, err := p.Allocate(a)
+	require.NoError(t, err)
+	assert.Equal(t, 0, index)
+
+	index, err = p.Allocate(c)
+	require.NoError(t, err)
+	assert.Equal(t, 2, index)
+
+	index, err = p.Allocate(b)
+	require.NoError(t, err)
+	assert.Equal(t, 1, index, "the index of the deleted netns is reused")
+}
@@ -4,6 +4,7 @@
 package podnetwork
 
 import (
+	"errors"
 	"fmt"
 	"net/netip"
 	"testing"
@@ -48,6 +49,34 @@ func (t *mockPodNodeTunneler) Teardown(nsPath, hostInterface string, config *tun
 	return nil
 }
 
+type failingWorkerNodeTunneler struct {
+	mockWorkerNodeTunneler
+}
+
+func (t *failingWorkerNodeTunneler) Teardown(nsPath, hostInterface string, config *tunneler.Config) error {
+	return errors.New("teardown failure")
+}
+
+func TestWorkerNodeTeardownReleasesIndex(t *testing.T) {
+
+	n := &workerNode{
+		NetworkConfig: &tunneler.NetworkConfig{TunnelType: "mock", HostInterface: "ens0"},
+		tunneler:      &failingWorkerNodeTunneler{},
+		podIndex:      newPodIndex(""),
+	}
+
+	nsPath := "/run/netns/a"
+	index, err := n.podIndex.Allocate(nsPath)
+	require.NoError(t, err)
+
+	err = n.Teardown(nsPath, &tunneler.Config{TunnelType: "mock", Index: index})
+	require.ErrorContains(t, err, "teardown failure")
+
+	next, err := n.podIndex.Allocate("/run/netns/b")
+	require.NoError(t, err)
+	require.Equal(t, index, next, "the index of a failed teardown is released")
+}
+
 func TestWorkerNode(t *testing.T) {
 	testutils.SkipTestIfNotRoot(t)
 
@@ -200,6 +229,101 @@ func TestPodNode(t *testing.T) {
 	}
 }
 
+func TestDualStack(t *testing.T) {
+	testutils.SkipTestIfNotRoot(t)
+
+	mockTunnelType := "mock"
+	tunneler.Register(mockTunnelType, newMockWorkerNodeTunneler, newMockPodNodeTunneler)
+
+	workerNodeNS, _ := tuntest.NewNamedNS(t, "test-workernode")
+	defer tuntest.DeleteNamedNS(t, workerNodeNS)
+
+	tuntest.BridgeAdd(t, workerNodeNS, "ens0")
+	tuntest.AddrAdd(t, workerNodeNS, "ens0", "fd00:10::2/64")
+	tuntest.RouteAdd(t, workerNodeNS, "::/0", "fd00:10::1", "ens0")
+
+	workerPodNS, _ := tuntest.NewNamedNS(t, "test-workerpod")
+	defer tuntest.DeleteNamedNS(t, workerPodNS)
+
+	tuntest.BridgeAdd(t, workerPodNS, "eth0")
+	tuntest.AddrAdd(t, workerPodNS, "eth0", "fd00:16::2/64")
+	tuntest.AddrAdd(t, workerPodNS, "eth0", "172.16.0.2/24")
+	tuntest.RouteAdd(t, workerPodNS, "", "172.16.0.1", "eth0")
+	tuntest.RouteAdd(t, workerPodNS, "::/0", "fd00:16::1", "eth0")
+
+	var config *tunneler.Config
+
+	err := workerNodeNS.Run(func() error {
+
+		workerNode, err := NewWorkerNode(&tunneler.NetworkConfig{
+			TunnelType:     mockTunnelType,
+			PodSubnetCIDRs: tunneler.SubnetCIDRs{"10.96.0.0/16", "fd00:96::/112"},
+		})
+		require.Nil(t, err)
+
+		config, err = workerNode.Inspect(workerPodNS.Path())
+		require.Nil(t, err)
+
+		return workerNode.Teardown(workerPodNS.Path(), config)
+	})
+	require.Nil(t, err)
+
+	require.Equal(t, "fd00:10::2/64", config.WorkerNodeIP.String())
+	require.Equal(t, "172.16.0.2/24", config.PodIP.String())
+	require.Equal(t, []netip.Prefix{netip.MustParsePrefix("172.16.0.2/24"), netip.MustParsePrefix("fd00:16::2/64")}, config.PodIPs)
+
+	var routes []string
+	for _, r := range config.Routes {
+		dst := netip.PrefixFrom(r.Dst.Addr().Unmap(), r.Dst.Bits())
+		routes = append(routes, fmt.Sprintf("%s via %s dev %s", dst, r.GW, r.Dev))
+	}
+	require.ElementsMatch(t, []string{
+		"0.0.0.0/0 via 172.16.0.1 dev eth0",
+		"172.16.0.0/24 via invalid IP dev eth0",
+		"::/0 via fd00:16::1 dev eth0",
+		"fd00:16::/64 via invalid IP dev eth0",
+		"10.96.0.0/16 via 172.16.0.1 dev eth0",
+		"fd00:96::/112 via fd00:16::1 dev eth0",
+	}, routes)
+
+	podNodeNS, _ := tuntest.NewNamedNS(t, "test-podnode")
+	defer tuntest.DeleteNamedNS(t, podNodeNS)
+
+	tuntest.BridgeAdd(t, podNodeNS, "ens0")
+	tuntest.AddrAdd(t, podNodeNS, "ens0", "fd00:10::3/64")
+	tuntest.RouteAdd(t, podNodeNS, "::/0", "fd00:10::1", "ens0")
+
+	podNS, _ := tuntest.NewNamedNS(t, "test-pod")
+	defer tuntest.DeleteNamedNS(t, podNS)
+
+	tuntest.BridgeAdd(t, podNS, "eth0")
+	for _, addr := range config.PodIPs {
+		tuntest.AddrAdd(t, podNS, "eth0", addr.String())
+	}
+
+	err = podNodeNS.Run(func() error {
+
+		podNode := NewPodNode(podNS.Path(), "", config)
+
+		if err := podNode.Setup(); err != nil {
+			return err
+		}
+		return podNode.Teardown()
+	})
+	require.Nil(t, err)
+
+	podRoutes, err := podNS.RouteList()
+	require.Nil(t, err)
+
+	var defaultRoutes []string
+	for _, r := range podRoutes {
+		if r.Destination.Bits() == 0 {
+			defaultRoutes = append(defaultRoutes, r.Gateway.String())
+		}
+	}
+	require.ElementsMatch(t, []string{"172.16.0.1", "fd00:16::1"}, defaultRoutes)
+}
+
 func TestPluginDetectHostInterface(t *testing.T) {
 	testutils.SkipTestIfNotRoot(t)
 
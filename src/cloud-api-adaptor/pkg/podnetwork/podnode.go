@@ -95,14 +95,18 @@ func (n *podNode) Setup() error {
 		return fmt.Errorf("failed to set up tunnel %q: %w", n.config.TunnelType, err)
 	}
 
-	if !n.config.PodIP.IsSingleIP() {
+	for _, podIP := range n.config.PodAddrs() {
+		if podIP.IsSingleIP() {
+			continue
+		}
+
 		// Delete the nRoute that was automatically added by kernel for eth0
 		// CNI plugins like PTP and GKE need this trick, otherwise adding a route will fail in a later step.
 		// The deleted route will be restored again in the cases of usual CNI plugins such as Flannel and Calico.
 		// https://github.com/containernetworking/plugins/blob/acf8ddc8e1128e6f68a34f7fe91122afeb1fa93d/plugins/main/ptp/ptp.go#L58-L61
 
 		nRoute := netops.Route{
-			Destination: n.config.PodIP.Masked(),
+			Destination: podIP.Masked(),
 			Device:      n.config.InterfaceName,
 		}
 		if err := podNS.RouteDel(&nRoute); err != nil {
@@ -238,10 +242,12 @@ func detectIP(hostNS netops.Namespace, hostInterface string, timeout time.Durati
 		if err != nil {
 			return netip.Addr{}, fmt.Errorf("failed to get addresses assigned %s on netns %s: %w", hostLink.Name(), hostLink.Namespace().Path(), err)
 		}
-		if len(prefixes) > 1 {
+		// GetAddr returns IPv4 addresses first. An IPv6 address is used on IPv6 only networks,
+		// where SLAAC may assign more than one address to an interface.
+		if len(prefixes) > 1 && prefixes[1].Addr().Is4() {
 			return netip.Addr{}, fmt.Errorf("more than one IP address assigned on %s (netns: %s)", hostLink.Name(), hostLink.Namespace().Path())
 		}
-		if len(prefixes) == 1 {
+		if len(prefixes) > 0 {
 			return prefixes[0].Addr(), nil
 		}
 
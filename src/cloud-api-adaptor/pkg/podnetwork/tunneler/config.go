@@ -3,19 +3,33 @@
 
 package tunneler
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
+)
 
 type TunnelerConfigurator interface {
 	Tunneler
 	Configure(*NetworkConfig, *Config) error
 }
 
+// IndexInspector is implemented by worker node tunnelers that can find the index of a pod
+// from the tunnel device in its network namespace
+type IndexInspector interface {
+	InspectIndex(n *NetworkConfig, nsPath string) (index int, found bool, err error)
+}
+
 type NetworkConfig struct {
 	TunnelType          string
 	HostInterface       string
 	VXLAN               VXLANConfig
+	Geneve              GeneveConfig
+	WireGuard           WireGuardConfig
 	ExternalNetViaPodVM bool
 	PodSubnetCIDRs      SubnetCIDRs
+	PodIndexFile        string
+	FirewallBackend     string
 }
 
 type VXLANConfig struct {
@@ -23,6 +37,18 @@ type VXLANConfig struct {
 	MinID int
 }
 
+type GeneveConfig struct {
+	Port  int
+	MinID int
+	// Options are added to all packets of pod tunnels. When they are set, each pod has its own
+	// UDP port, which is Port plus the index of the pod.
+	Options GeneveOptions
+}
+
+type WireGuardConfig struct {
+	Port int
+}
+
 type SubnetCIDRs []string
 
 func (i *SubnetCIDRs) String() string {
@@ -37,3 +63,27 @@ func (i *SubnetCIDRs) Set(value string) error {
 	}
 	return nil
 }
+
+type GeneveOptions []netops.GeneveOption
+
+func (o *GeneveOptions) String() string {
+	var options []string
+	for _, option := range *o {
+		options = append(options, option.String())
+	}
+	return strings.Join(options, ",")
+}
+
+func (o *GeneveOptions) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		option, err := netops.ParseGeneveOption(part)
+		if err != nil {
+			return err
+		}
+		*o = append(*o, option)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package encap
+
+import (
+	"fmt"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
+)
+
+const (
+	firewallOutputChainName     = "peerpod-OUTPUT"
+	firewallPreRoutingChainName = "peerpod-PREROUTING"
+)
+
+// firewallRules returns rules that disable connection tracking of tunnel packets to and from the remote address.
+// They are rendered by the iptables or nftables backend of netops.
+func firewallRules(link Link, tunnel *Tunnel) []*netops.FirewallRule {
+
+	comment := link.FirewallComment(tunnel.ID)
+
+	return []*netops.FirewallRule{
+		{
+			Chain:       firewallOutputChainName,
+			Hook:        netops.FirewallHookOutput,
+			Destination: tunnel.Remote,
+			DstPort:     tunnel.Port,
+			Comment:     comment,
+		},
+		{
+			Chain:   firewallPreRoutingChainName,
+			Hook:    netops.FirewallHookPreRouting,
+			Source:  tunnel.Remote,
+			DstPort: tunnel.Port,
+			Comment: comment,
+		},
+	}
+}
+
+func firewallSetup(ns netops.Namespace, link Link, tunnel *Tunnel) error {
+
+	firewall := netops.NewFirewall(ns)
+
+	for _, rule := range firewallRules(link, tunnel) {
+		if err := firewall.RuleAdd(rule); err != nil {
+			return fmt.Errorf("failed to add %s rule: %w", firewall.Backend(), err)
+		}
+	}
+
+	return nil
+}
+
+func firewallTeardown(ns netops.Namespace, link Link, tunnel *Tunnel) error {
+
+	firewall := netops.NewFirewall(ns)
+
+	for _, rule := range firewallRules(link, tunnel) {
+		if err := firewall.RuleDel(rule); err != nil {
+			return fmt.Errorf("failed to delete %s rule: %w", firewall.Backend(), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package encap implements the worker node and pod node tunnelers that carry the Ethernet frames of a pod
+// over UDP with a tunnel interface such as VXLAN or Geneve. The type of the tunnel interface is a Link.
+package encap
+
+import (
+	"net/netip"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
+)
+
+const (
+	maxMTU   = 1450
+	maxMTUv6 = 1430 // an IPv6 underlay header is 20 bytes longer
+)
+
+// Link is a type of tunnel interfaces
+type Link interface {
+	// Name is the name of the link type, such as vxlan. Tunnel interfaces are named after it.
+	Name() string
+	// Configure sets the UDP port, the VNI and the options of the tunnel of a pod in config
+	Configure(n *tunneler.NetworkConfig, config *tunneler.Config)
+	// Tunnel returns the tunnel to remote with the parameters set by Configure
+	Tunnel(remote netip.Addr, config *tunneler.Config) *Tunnel
+	// Device returns a tunnel interface device for the tunnel
+	Device(tunnel *Tunnel) netops.Device
+	// Inspect returns the tunnel of a device, or false if the device is not of the link type.
+	// The remote address and the VNI of an external tunnel are not set.
+	Inspect(device netops.Device) (*Tunnel, bool)
+	// Index returns the index of the pod of an inspected tunnel
+	Index(n *tunneler.NetworkConfig, tunnel *Tunnel) int
+	// FirewallComment returns the comment of the firewall rules of the tunnel with the VNI
+	FirewallComment(id int) string
+}
+
+// Tunnel is the UDP tunnel of a pod
+type Tunnel struct {
+	netops.TunnelKey
+	// External is true when the tunnel interface takes the remote address, the VNI and the options of each
+	// packet from its tunnel key, which is set by tc filters
+	External bool
+}
+
+// optionsLen returns the length of the Geneve options in the header of each packet
+func (t *Tunnel) optionsLen() int {
+	var n int
+	for _, option := range t.GeneveOptions {
+		n += 4 + len(option.Data)
+	}
+	return n
+}
@@ -0,0 +1,158 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package encap
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
+)
+
+type podNodeTunneler struct {
+	link   Link
+	logger *log.Logger
+	// hostInterface is the name of the tunnel interface while it is created on the host
+	hostInterface string
+}
+
+// NewPodNodeTunneler returns a pod node tunneler that moves a tunnel interface of the link type
+// to the pod network namespace, and assigns the pod addresses to it
+func NewPodNodeTunneler(link Link) tunneler.Tunneler {
+	return &podNodeTunneler{
+		link:          link,
+		logger:        newLogger(link),
+		hostInterface: link.Name() + "0",
+	}
+}
+
+func (t *podNodeTunneler) Setup(nsPath string, podNodeIPs []netip.Addr, config *tunneler.Config) error {
+
+	podInterface := config.InterfaceName
+	if podInterface == "" {
+		return errors.New("InterfaceName is not specified")
+	}
+
+	nodeAddr := config.WorkerNodeIP
+
+	if !nodeAddr.IsValid() {
+		return fmt.Errorf("WorkerNodeIP is not specified: %#v", config.WorkerNodeIP)
+	}
+
+	podAddrs := config.PodAddrs()
+	if len(podAddrs) == 0 {
+		return fmt.Errorf("PodIP is not specified: %#v", config.PodIP)
+	}
+
+	hostNS, err := netops.OpenCurrentNamespace()
+	if err != nil {
+		return fmt.Errorf("failed to get host network namespace: %w", err)
+	}
+	defer hostNS.Close()
+
+	podNS, err := netops.OpenNamespace(nsPath)
+	if err != nil {
+		return fmt.Errorf("failed to get a pod network namespace: %s: %w", nsPath, err)
+	}
+	defer podNS.Close()
+
+	name := t.link.Name()
+	hostInterface := t.hostInterface
+	tunnel := t.link.Tunnel(nodeAddr.Addr(), config)
+
+	if err := firewallSetup(hostNS, t.link, tunnel); err != nil {
+		return err
+	}
+
+	t.logger.Printf("Creating %s interface %s on %s with remote %s, id %d, port %d", name, hostInterface, hostNS.Path(), tunnel.Remote, tunnel.ID, tunnel.Port)
+
+	link, err := hostNS.LinkAdd(hostInterface, t.link.Device(tunnel))
+	if err != nil {
+		return fmt.Errorf("failed to add %s interface %s: %w", name, hostInterface, err)
+	}
+
+	if err := link.SetNamespace(podNS); err != nil {
+		return fmt.Errorf("failed to move %s interface %s to netns %s: %w", name, hostInterface, podNS.Path(), err)
+	}
+
+	if err := link.SetName(podInterface); err != nil {
+		return fmt.Errorf("failed to rename %s interface %s on netns %s: %w", name, hostInterface, podNS.Path(), err)
+	}
+
+	if err := link.SetHardwareAddr(config.PodHwAddr); err != nil {
+		return fmt.Errorf("failed to set pod HW address %s on %s: %w", config.PodHwAddr, podInterface, err)
+	}
+
+	mtu := int(config.MTU)
+	if nodeAddr.Addr().Is6() {
+		mtu = min(mtu, maxMTUv6)
+	}
+	if mtu > maxMTU {
+		mtu = maxMTU
+	}
+	mtu -= tunnel.optionsLen()
+	if err := link.SetMTU(mtu); err != nil {
+		return fmt.Errorf("failed to set MTU of %s to %d on %s: %w", podInterface, mtu, nsPath, err)
+	}
+
+	for _, podAddr := range podAddrs {
+		if err := link.AddAddr(podAddr); err != nil {
+			return fmt.Errorf("failed to add pod IP %s to %s on %s: %w", podAddr, podInterface, nsPath, err)
+		}
+	}
+
+	if tunnel.External {
+		if err := podNS.TunnelKeyAdd(podInterface, &tunnel.TunnelKey); err != nil {
+			return fmt.Errorf("failed to set the tunnel key of %s on %s: %w", podInterface, nsPath, err)
+		}
+	}
+
+	if err := link.SetUp(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *podNodeTunneler) Teardown(nsPath, hostInterface string, config *tunneler.Config) error {
+
+	ifName := config.InterfaceName
+
+	hostNS, err := netops.OpenCurrentNamespace()
+	if err != nil {
+		return fmt.Errorf("failed to get host network namespace: %w", err)
+	}
+	defer hostNS.Close()
+
+	podNS, err := netops.OpenNamespace(nsPath)
+	if err != nil {
+		return fmt.Errorf("failed to get a pod network namespace: %s: %w", nsPath, err)
+	}
+	defer podNS.Close()
+
+	name := t.link.Name()
+
+	link, err := podNS.LinkFind(ifName)
+	if err != nil {
+		return fmt.Errorf("failed to find %s interface %q on netns %s: %w", name, ifName, podNS.Path(), err)
+	}
+
+	tunnel, err := inspect(t.link, podNS, link, ifName)
+	if err != nil {
+		return err
+	}
+
+	if err := link.Delete(); err != nil {
+		return fmt.Errorf("failed to delete %s interface %s at %s: %w", name, ifName, podNS.Path(), err)
+	}
+
+	if err := firewallTeardown(hostNS, t.link, tunnel); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,281 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package encap
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
+)
+
+type workerNodeTunneler struct {
+	link   Link
+	logger *log.Logger
+	// hostInterfacePrefix is the name prefix of tunnel interfaces created on the host
+	hostInterfacePrefix string
+	// secondPodInterface is the name of the tunnel interface in the pod network namespace
+	secondPodInterface string
+}
+
+// NewWorkerNodeTunneler returns a worker node tunneler that moves a tunnel interface of the link type to the
+// network namespace of a pod, and redirects the traffic of the pod interface to it
+func NewWorkerNodeTunneler(link Link) tunneler.Tunneler {
+	return &workerNodeTunneler{
+		link:                link,
+		logger:              newLogger(link),
+		hostInterfacePrefix: "pp" + link.Name(),
+		secondPodInterface:  link.Name() + "1",
+	}
+}
+
+func newLogger(link Link) *log.Logger {
+	return log.New(log.Writer(), fmt.Sprintf("[tunneler/%s] ", link.Name()), log.LstdFlags|log.Lmsgprefix)
+}
+
+func (t *workerNodeTunneler) Configure(n *tunneler.NetworkConfig, config *tunneler.Config) error {
+
+	t.link.Configure(n, config)
+
+	return nil
+}
+
+// InspectIndex finds the index of a pod from the tunnel interface in its network namespace
+func (t *workerNodeTunneler) InspectIndex(n *tunneler.NetworkConfig, nsPath string) (int, bool, error) {
+
+	podNS, err := netops.OpenNamespace(nsPath)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get a network namespace: %s: %w", nsPath, err)
+	}
+	defer podNS.Close()
+
+	link, err := podNS.LinkFind(t.secondPodInterface)
+	if err != nil {
+		return 0, false, nil
+	}
+
+	device, err := link.GetDevice()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get device info of %s on netns %s: %w", t.secondPodInterface, nsPath, err)
+	}
+
+	tunnel, ok := t.link.Inspect(device)
+	if !ok {
+		return 0, false, nil
+	}
+
+	return t.link.Index(n, tunnel), true, nil
+}
+
+func (t *workerNodeTunneler) Setup(nsPath string, podNodeIPs []netip.Addr, config *tunneler.Config) error {
+
+	var dstAddr netip.Addr
+
+	numIPs := len(podNodeIPs)
+	if numIPs == 0 {
+		return fmt.Errorf("pod node has no IPs")
+	}
+
+	if config.Dedicated {
+		if numIPs < 2 {
+			return fmt.Errorf("dedicated tunnel missing destination address")
+		}
+		dstAddr = podNodeIPs[1]
+	} else {
+		dstAddr = podNodeIPs[0]
+	}
+
+	hostNS, err := netops.OpenCurrentNamespace()
+	if err != nil {
+		return fmt.Errorf("failed to get current network namespace: %w", err)
+	}
+	defer func() {
+		if e := hostNS.Close(); e != nil {
+			err = fmt.Errorf("failed to close the original network namespace: %w (previous error: %v)", e, err)
+		}
+	}()
+
+	podNS, err := netops.OpenNamespace(nsPath)
+	if err != nil {
+		return fmt.Errorf("failed to get a network namespace: %s: %w", nsPath, err)
+	}
+	defer func() {
+		if e := podNS.Close(); e != nil {
+			err = fmt.Errorf("failed to close the pod network namespace: %w (previous error: %v)", e, err)
+		}
+	}()
+
+	name := t.link.Name()
+	tunnel := t.link.Tunnel(dstAddr, config)
+
+	if err := firewallSetup(hostNS, t.link, tunnel); err != nil {
+		return err
+	}
+
+	index := 1
+
+	links, err := hostNS.LinkList()
+	if err != nil {
+		return fmt.Errorf("failed to get interfaces on host: %w", err)
+	}
+
+	var hostInterface string
+	var hostLink netops.Link
+
+	for {
+		hostInterface = fmt.Sprintf("%s%d", t.hostInterfacePrefix, index)
+		var found bool
+		for _, link := range links {
+			if link.Name() == hostInterface {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+
+			t.logger.Printf("%s %s (remote %s:%d, id: %d) created at %s", name, hostInterface, dstAddr.String(), tunnel.Port, tunnel.ID, hostNS.Path())
+			hostLink, err = hostNS.LinkAdd(hostInterface, t.link.Device(tunnel))
+			if err == nil {
+				t.logger.Printf("%s %s created at %s", name, hostInterface, hostNS.Path())
+				break
+			}
+			t.logger.Printf("%s %s created at %s: %v", name, hostInterface, hostNS.Path(), err)
+			if !errors.Is(err, os.ErrExist) {
+				return fmt.Errorf("failed to add %s interface %s: %w", name, hostInterface, err)
+			}
+		}
+		index++
+		if index > 5 {
+			return fmt.Errorf("failed to create %s interface %s: too many", name, hostInterface)
+		}
+	}
+
+	secondPodInterface := t.secondPodInterface
+
+	if err := hostLink.SetNamespace(podNS); err != nil {
+		return fmt.Errorf("failed to move %s interface %s to netns %s: %w", name, hostInterface, podNS.Path(), err)
+	}
+	t.logger.Printf("%s %s is moved to %s", name, hostInterface, podNS.Path())
+
+	podTunnelInterface, err := podNS.LinkFind(hostInterface)
+	if err != nil {
+		return fmt.Errorf("failed to find %s interface %q on pod netns %s to %s: %w", name, hostInterface, podNS.Path(), secondPodInterface, err)
+	}
+
+	if err := podTunnelInterface.SetName(secondPodInterface); err != nil {
+		return fmt.Errorf("failed to change %s interface name %s on netns %s to %s: %w", name, hostInterface, podNS.Path(), secondPodInterface, err)
+	}
+
+	if err := podTunnelInterface.SetUp(); err != nil {
+		return err
+	}
+
+	podInterface := config.InterfaceName
+
+	t.logger.Printf("Add tc redirect filters between %s and %s on pod network namespace %s", podInterface, secondPodInterface, nsPath)
+
+	if tunnel.External {
+		err = podNS.TunnelRedirectAdd(podInterface, secondPodInterface, &tunnel.TunnelKey)
+	} else {
+		err = podNS.RedirectAdd(podInterface, secondPodInterface)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add a tc redirect filter from %s to %s: %w", podInterface, secondPodInterface, err)
+	}
+
+	if err := podNS.RedirectAdd(secondPodInterface, podInterface); err != nil {
+		return fmt.Errorf("failed to add a tc redirect filter from %s to %s: %w", secondPodInterface, podInterface, err)
+	}
+
+	return nil
+}
+
+func (t *workerNodeTunneler) Teardown(nsPath, hostInterface string, config *tunneler.Config) error {
+
+	hostNS, err := netops.OpenCurrentNamespace()
+	if err != nil {
+		return fmt.Errorf("failed to get current network namespace: %w", err)
+	}
+	defer func() {
+		if e := hostNS.Close(); e != nil {
+			err = fmt.Errorf("failed to close the original network namespace: %w (previous error: %v)", e, err)
+		}
+	}()
+
+	podNS, err := netops.OpenNamespace(nsPath)
+	if err != nil {
+		return fmt.Errorf("failed to get a network namespace: %s: %w", nsPath, err)
+	}
+	defer func() {
+		if e := podNS.Close(); e != nil {
+			err = fmt.Errorf("failed close the pod network namespace: %w (previous error: %v)", e, err)
+		}
+	}()
+
+	name := t.link.Name()
+	secondPodInterface := t.secondPodInterface
+
+	podTunnelInterface, err := podNS.LinkFind(secondPodInterface)
+	if err != nil {
+		return fmt.Errorf("failed to find %s interface %q on pod netns %s to %s: %w", name, secondPodInterface, podNS.Path(), secondPodInterface, err)
+	}
+
+	tunnel, err := inspect(t.link, podNS, podTunnelInterface, config.InterfaceName)
+	if err != nil {
+		return err
+	}
+
+	t.logger.Printf("Delete tc redirect filters on %s and %s in the network namespace %s", config.InterfaceName, hostInterface, nsPath)
+
+	if err := podNS.RedirectDel(config.InterfaceName); err != nil {
+		return fmt.Errorf("failed to delete a tc redirect filter from %s to %s: %w", config.InterfaceName, secondPodInterface, err)
+	}
+
+	if err := podNS.RedirectDel(secondPodInterface); err != nil {
+		return fmt.Errorf("failed to delete a tc redirect filter from %s to %s: %w", secondPodInterface, config.InterfaceName, err)
+	}
+
+	t.logger.Printf("Delete %s interface %s in the network namespace %s", name, secondPodInterface, nsPath)
+
+	if err := podTunnelInterface.Delete(); err != nil {
+		return fmt.Errorf("failed to delete %s interface %s at %s: %w", name, secondPodInterface, podNS.Path(), err)
+	}
+
+	if err := firewallTeardown(hostNS, t.link, tunnel); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// inspect returns the tunnel of a tunnel interface. The tunnel key of an external tunnel interface
+// is taken from the tc filter that sets it on keyInterface.
+func inspect(l Link, ns netops.Namespace, link netops.Link, keyInterface string) (*Tunnel, error) {
+
+	device, err := link.GetDevice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device info of %s: %w", link.Name(), err)
+	}
+
+	tunnel, ok := l.Inspect(device)
+	if !ok {
+		return nil, fmt.Errorf("not a %s interface: %s", l.Name(), link.Name())
+	}
+
+	if tunnel.External {
+		key, err := ns.TunnelKeyGet(keyInterface)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the tunnel key of %s: %w", link.Name(), err)
+		}
+		tunnel.Remote = key.Remote
+		tunnel.ID = key.ID
+	}
+
+	return tunnel, nil
+}
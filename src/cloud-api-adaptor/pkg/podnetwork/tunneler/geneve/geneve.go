@@ -0,0 +1,97 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package geneve
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler/encap"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
+)
+
+const (
+	DefaultGenevePort  = 6081
+	DefaultGeneveMinID = 555000
+)
+
+// link is a Geneve link. A Geneve tunnel with options uses an external Geneve interface. Since only one
+// external interface can listen on a UDP port, each pod then has its own port, which is the port of
+// the network config plus the index of the pod.
+type link struct{}
+
+func NewWorkerNodeTunneler() (tunneler.Tunneler, error) {
+	return encap.NewWorkerNodeTunneler(link{}), nil
+}
+
+func NewPodNodeTunneler() (tunneler.Tunneler, error) {
+	return encap.NewPodNodeTunneler(link{}), nil
+}
+
+func (link) Name() string {
+	return "geneve"
+}
+
+func (link) Configure(n *tunneler.NetworkConfig, config *tunneler.Config) {
+	config.GenevePort = n.Geneve.Port
+	config.GeneveID = n.Geneve.MinID + config.Index
+
+	if len(n.Geneve.Options) > 0 {
+		config.GenevePort += config.Index
+		config.GeneveOptions = n.Geneve.Options
+	}
+}
+
+func (link) Tunnel(remote netip.Addr, config *tunneler.Config) *encap.Tunnel {
+	return &encap.Tunnel{
+		TunnelKey: netops.TunnelKey{
+			Remote:        remote,
+			ID:            config.GeneveID,
+			Port:          config.GenevePort,
+			GeneveOptions: config.GeneveOptions,
+		},
+		External: len(config.GeneveOptions) > 0,
+	}
+}
+
+func (link) Device(tunnel *encap.Tunnel) netops.Device {
+	if tunnel.External {
+		return &netops.Geneve{
+			Port:     tunnel.Port,
+			External: true,
+		}
+	}
+	return &netops.Geneve{
+		Remote: tunnel.Remote,
+		ID:     tunnel.ID,
+		Port:   tunnel.Port,
+	}
+}
+
+func (link) Inspect(device netops.Device) (*encap.Tunnel, bool) {
+	geneveDevice, ok := device.(*netops.Geneve)
+	if !ok {
+		return nil, false
+	}
+	return &encap.Tunnel{
+		TunnelKey: netops.TunnelKey{
+			Remote: geneveDevice.Remote,
+			ID:     geneveDevice.ID,
+			Port:   geneveDevice.Port,
+		},
+		External: geneveDevice.External,
+	}, true
+}
+
+func (link) Index(n *tunneler.NetworkConfig, tunnel *encap.Tunnel) int {
+	if tunnel.External {
+		return tunnel.Port - n.Geneve.Port
+	}
+	return tunnel.ID - n.Geneve.MinID
+}
+
+func (link) FirewallComment(id int) string {
+	return fmt.Sprintf("peerpod [geneve vni:%d]", id)
+}
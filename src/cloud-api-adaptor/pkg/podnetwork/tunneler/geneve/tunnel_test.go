@@ -0,0 +1,73 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package geneve
+
+import (
+	"net/netip"
+	"testing"
+
+	testutils "github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/internal/testing"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tuntest"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
+)
+
+func skipTestIfNoGeneve(t *testing.T) {
+	t.Helper()
+
+	ns, _ := tuntest.NewNamedNS(t, "test-geneveprobe")
+	defer tuntest.DeleteNamedNS(t, ns)
+
+	if _, err := ns.LinkAdd("geneveprobe", &netops.Geneve{Remote: netip.MustParseAddr("192.168.0.2"), ID: DefaultGeneveMinID, Port: DefaultGenevePort}); err != nil {
+		t.Skipf("Geneve is not available: %v", err)
+	}
+}
+
+func TestGeneve(t *testing.T) {
+	testutils.SkipTestIfNotRoot(t)
+	skipTestIfNoGeneve(t)
+
+	tuntest.RunTunnelTest(t, "geneve", NewWorkerNodeTunneler, NewPodNodeTunneler, false)
+
+}
+
+func TestGeneveOptions(t *testing.T) {
+
+	var options tunneler.GeneveOptions
+	if err := options.Set("0102:80:00112233"); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	n := &tunneler.NetworkConfig{
+		Geneve: tunneler.GeneveConfig{Port: DefaultGenevePort, MinID: DefaultGeneveMinID, Options: options},
+	}
+	config := &tunneler.Config{Index: 3}
+
+	link{}.Configure(n, config)
+
+	if config.GenevePort != DefaultGenevePort+3 {
+		t.Errorf("Expect port %d, got %d", DefaultGenevePort+3, config.GenevePort)
+	}
+	if len(config.GeneveOptions) != 1 {
+		t.Errorf("Expect 1 option, got %d", len(config.GeneveOptions))
+	}
+
+	tunnel := link{}.Tunnel(netip.MustParseAddr("192.168.0.2"), config)
+	if !tunnel.External {
+		t.Error("Expect an external tunnel")
+	}
+
+	device, ok := link{}.Device(tunnel).(*netops.Geneve)
+	if !ok || !device.External || device.Remote.IsValid() || device.ID != 0 || device.Port != DefaultGenevePort+3 {
+		t.Errorf("Expect an external Geneve device on port %d, got %#v", DefaultGenevePort+3, device)
+	}
+
+	inspected, ok := link{}.Inspect(device)
+	if !ok {
+		t.Fatal("Expect a Geneve tunnel")
+	}
+	if index := (link{}).Index(n, inspected); index != 3 {
+		t.Errorf("Expect index 3, got %d", index)
+	}
+}
@@ -0,0 +1,115 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package routed
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
+)
+
+const hostIPVLANInterface = "pprt0"
+
+type podNodeTunneler struct {
+}
+
+func NewPodNodeTunneler() (tunneler.Tunneler, error) {
+	return &podNodeTunneler{}, nil
+}
+
+func (t *podNodeTunneler) Setup(nsPath string, podNodeIPs []netip.Addr, config *tunneler.Config) error {
+
+	podInterface := config.InterfaceName
+	if podInterface == "" {
+		return errors.New("InterfaceName is not specified")
+	}
+
+	if len(podNodeIPs) == 0 {
+		return fmt.Errorf("pod node has no IPs")
+	}
+
+	podAddrs := config.PodAddrs()
+	if len(podAddrs) == 0 {
+		return fmt.Errorf("PodIP is not specified: %#v", config.PodIP)
+	}
+
+	hostNS, err := netops.OpenCurrentNamespace()
+	if err != nil {
+		return fmt.Errorf("failed to get host network namespace: %w", err)
+	}
+	defer hostNS.Close()
+
+	podNS, err := netops.OpenNamespace(nsPath)
+	if err != nil {
+		return fmt.Errorf("failed to get a pod network namespace: %s: %w", nsPath, err)
+	}
+	defer podNS.Close()
+
+	// The cloud provider assigns the pod IP addresses to the primary interface of the pod VM
+	hostLink, gateway, err := findUplink(hostNS, podNodeIPs[0])
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("Creating ipvlan interface %s on %s with gateway %s", hostIPVLANInterface, hostLink.Name(), gateway)
+
+	ipvlan, err := hostNS.LinkAdd(hostIPVLANInterface, &netops.IPVLAN{Parent: hostLink})
+	if err != nil {
+		return fmt.Errorf("failed to add ipvlan interface %s on %s: %w", hostIPVLANInterface, hostLink.Name(), err)
+	}
+
+	if err := ipvlan.SetNamespace(podNS); err != nil {
+		return fmt.Errorf("failed to move ipvlan interface %s to netns %s: %w", hostIPVLANInterface, podNS.Path(), err)
+	}
+
+	if err := ipvlan.SetName(podInterface); err != nil {
+		return fmt.Errorf("failed to rename ipvlan interface %s on netns %s: %w", hostIPVLANInterface, podNS.Path(), err)
+	}
+
+	// The MTU of an ipvlan interface cannot exceed that of its parent
+	hostMTU, err := hostLink.GetMTU()
+	if err != nil {
+		return err
+	}
+	mtu := min(config.MTU, hostMTU)
+	if err := ipvlan.SetMTU(mtu); err != nil {
+		return fmt.Errorf("failed to set MTU of %s to %d on %s: %w", podInterface, mtu, nsPath, err)
+	}
+
+	for _, podAddr := range podAddrs {
+		if err := ipvlan.AddAddr(podAddr); err != nil {
+			return fmt.Errorf("failed to add pod IP %s to %s on %s: %w", podAddr, podInterface, nsPath, err)
+		}
+	}
+
+	if err := ipvlan.SetUp(); err != nil {
+		return err
+	}
+
+	route := &netops.Route{
+		Destination: netops.DefaultPrefix,
+		Gateway:     gateway,
+		Device:      podInterface,
+		Onlink:      true,
+	}
+	if err := podNS.RouteAdd(route); err != nil {
+		return fmt.Errorf("failed to add a default route via %s on pod network namespace %s: %w", gateway, podNS.Path(), err)
+	}
+
+	return nil
+}
+
+func (t *podNodeTunneler) Teardown(nsPath, hostInterface string, config *tunneler.Config) error {
+
+	podNS, err := netops.OpenNamespace(nsPath)
+	if err != nil {
+		return fmt.Errorf("failed to get a pod network namespace: %s: %w", nsPath, err)
+	}
+	defer podNS.Close()
+
+	return deleteIPVLANLink(podNS, config.InterfaceName)
+}
@@ -0,0 +1,222 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package routed
+
+import (
+	"net/netip"
+	"testing"
+
+	testutils "github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/internal/testing"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tuntest"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
+)
+
+func sysctlSet(t *testing.T, ns netops.Namespace, key, value string) {
+	t.Helper()
+
+	if err := ns.SysctlSet(key, value); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+}
+
+func skipTestIfNoIPVLAN(t *testing.T) {
+	t.Helper()
+
+	ns, _ := tuntest.NewNamedNS(t, "test-ipvlanprobe")
+	defer tuntest.DeleteNamedNS(t, ns)
+
+	tuntest.VethAdd(t, ns, "veth0", ns, "veth1")
+
+	parent, err := ns.LinkFind("veth0")
+	if err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+	if _, err := ns.LinkAdd("ipvlanprobe", &netops.IPVLAN{Parent: parent}); err != nil {
+		t.Skipf("ipvlan is not available: %v", err)
+	}
+}
+
+// TestRouted uses a router namespace in place of the cloud network. The router forwards
+// the pod IP address to the pod VM as the cloud network does once the provider assigns it.
+func TestRouted(t *testing.T) {
+	testutils.SkipTestIfNotRoot(t)
+	skipTestIfNoIPVLAN(t)
+
+	const (
+		gatewayIP    = "10.128.0.1"
+		gatewayAddr  = gatewayIP + "/24"
+		podAddr      = "10.128.0.2/24"
+		otherPodAddr = "10.128.0.3/24"
+		routerIP     = "10.10.254.1"
+		routerHwAddr = "0a:58:0a:0a:fe:01"
+		workerIP     = "10.10.0.1"
+		workerAddr   = workerIP + "/16"
+		podNodeIP    = "10.10.1.2"
+		podNodeAddr  = podNodeIP + "/16"
+		podInterface = "eth0"
+	)
+
+	routerNS, _ := tuntest.NewNamedNS(t, "test-router")
+	defer tuntest.DeleteNamedNS(t, routerNS)
+
+	workerNS, _ := tuntest.NewNamedNS(t, "test-worker")
+	defer tuntest.DeleteNamedNS(t, workerNS)
+
+	workerPodNS, _ := tuntest.NewNamedNS(t, "test-workerpod")
+	defer tuntest.DeleteNamedNS(t, workerPodNS)
+
+	otherPodNS, _ := tuntest.NewNamedNS(t, "test-otherpod")
+	defer tuntest.DeleteNamedNS(t, otherPodNS)
+
+	podNodeNS, _ := tuntest.NewNamedNS(t, "test-podvm")
+	defer tuntest.DeleteNamedNS(t, podNodeNS)
+
+	podNS, _ := tuntest.NewNamedNS(t, "test-pod")
+	defer tuntest.DeleteNamedNS(t, podNS)
+
+	tuntest.BridgeAdd(t, routerNS, "br0")
+	tuntest.HwAddrAdd(t, routerNS, "br0", routerHwAddr)
+	tuntest.AddrAdd(t, routerNS, "br0", routerIP+"/16")
+	sysctlSet(t, routerNS, "net.ipv4.ip_forward", "1")
+	sysctlSet(t, routerNS, "net.ipv4.conf.all.send_redirects", "0")
+	sysctlSet(t, routerNS, "net.ipv4.conf.br0.send_redirects", "0")
+
+	tuntest.VethAdd(t, workerNS, "enc0", routerNS, "worker-eth0")
+	tuntest.LinkSetMaster(t, routerNS, "worker-eth0", "br0")
+	tuntest.AddrAdd(t, workerNS, "enc0", workerAddr)
+	tuntest.RouteAdd(t, workerNS, "", routerIP, "enc0")
+	if err := workerNS.NeighborAdd(&netops.Neighbor{IP: netip.MustParseAddr(routerIP), HardwareAddr: routerHwAddr, Dev: "enc0", State: netops.NeighborStatePermanent}); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	// The worker node receives replies from the pod IP address on its primary interface
+	sysctlSet(t, workerNS, "net.ipv4.ip_forward", "1")
+	sysctlSet(t, workerNS, "net.ipv4.conf.all.rp_filter", "0")
+	sysctlSet(t, workerNS, "net.ipv4.conf.enc0.rp_filter", "0")
+
+	tuntest.BridgeAdd(t, workerNS, "cni0")
+	tuntest.AddrAdd(t, workerNS, "cni0", gatewayAddr)
+
+	tuntest.VethAdd(t, workerNS, "veth0", workerPodNS, "eth0")
+	tuntest.LinkSetMaster(t, workerNS, "veth0", "cni0")
+	tuntest.AddrAdd(t, workerPodNS, "eth0", podAddr)
+	tuntest.RouteAdd(t, workerPodNS, "", gatewayIP, "eth0")
+
+	tuntest.VethAdd(t, workerNS, "veth1", otherPodNS, "eth0")
+	tuntest.LinkSetMaster(t, workerNS, "veth1", "cni0")
+	tuntest.AddrAdd(t, otherPodNS, "eth0", otherPodAddr)
+	tuntest.RouteAdd(t, otherPodNS, "", gatewayIP, "eth0")
+
+	tuntest.VethAdd(t, podNodeNS, "enc0", routerNS, "podvm-eth0")
+	tuntest.LinkSetMaster(t, routerNS, "podvm-eth0", "br0")
+	tuntest.AddrAdd(t, podNodeNS, "enc0", podNodeAddr)
+	tuntest.RouteAdd(t, podNodeNS, "", routerIP, "enc0")
+
+	// The cloud network routes the pod subnet to the worker node, and the pod IP address to the pod VM
+	tuntest.RouteAdd(t, routerNS, "10.128.0.0/24", workerIP, "br0")
+	tuntest.RouteAdd(t, routerNS, "10.128.0.2/32", podNodeIP, "br0")
+
+	workerNodeTunneler, err := NewWorkerNodeTunneler()
+	if err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+	podNodeTunneler, err := NewPodNodeTunneler()
+	if err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	config := &tunneler.Config{
+		PodIP:         netip.MustParsePrefix(podAddr),
+		Routes:        []*tunneler.Route{{GW: netip.MustParseAddr(gatewayIP)}},
+		InterfaceName: podInterface,
+		MTU:           1500,
+		TunnelType:    TunnelType,
+		WorkerNodeIP:  netip.MustParsePrefix(workerAddr),
+	}
+
+	if err := workerNodeTunneler.(tunneler.TunnelerConfigurator).Configure(&tunneler.NetworkConfig{TunnelType: TunnelType}, config); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	podNodeIPs := []netip.Addr{netip.MustParseAddr(podNodeIP)}
+
+	if err := workerNS.Run(func() error {
+		return workerNodeTunneler.Setup(workerPodNS.Path(), podNodeIPs, config)
+	}); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	if err := podNodeNS.Run(func() error {
+		return podNodeTunneler.Setup(podNS.Path(), podNodeIPs, config)
+	}); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	podIP := netip.MustParsePrefix(podAddr).Addr()
+
+	httpServer := tuntest.StartHTTPServer(t, podNS, netip.AddrPortFrom(podIP, 8080))
+	defer httpServer.Shutdown(t)
+
+	otherHTTPServer := tuntest.StartHTTPServer(t, otherPodNS, netip.AddrPortFrom(netip.MustParsePrefix(otherPodAddr).Addr(), 8080))
+	defer otherHTTPServer.Shutdown(t)
+
+	tuntest.ConnectToHTTPServer(t, workerNS, netip.AddrPortFrom(podIP, 8080), netip.AddrPortFrom(netip.MustParseAddr(gatewayIP), 0))
+	tuntest.ConnectToHTTPServer(t, otherPodNS, netip.AddrPortFrom(podIP, 8080), netip.AddrPortFrom(netip.MustParsePrefix(otherPodAddr).Addr(), 0))
+	tuntest.ConnectToHTTPServer(t, podNS, netip.AddrPortFrom(netip.MustParsePrefix(otherPodAddr).Addr(), 8080), netip.AddrPortFrom(podIP, 0))
+
+	if err := workerNS.Run(func() error {
+		return workerNodeTunneler.Teardown(workerPodNS.Path(), "enc0", config)
+	}); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	if err := podNodeNS.Run(func() error {
+		return podNodeTunneler.Teardown(podNS.Path(), "enc0", config)
+	}); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+
+	tun, err := NewWorkerNodeTunneler()
+	if err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	networkConfig := &tunneler.NetworkConfig{TunnelType: TunnelType}
+	config := &tunneler.Config{
+		PodIP:  netip.MustParsePrefix("10.128.0.2/24"),
+		PodIPs: []netip.Prefix{netip.MustParsePrefix("10.128.0.2/24"), netip.MustParsePrefix("fd00::2/64")},
+		Routes: []*tunneler.Route{{GW: netip.MustParseAddr("10.128.0.1")}},
+	}
+
+	if err := tun.(tunneler.TunnelerConfigurator).Configure(networkConfig, config); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	if e, a := netip.MustParsePrefix("10.128.0.2/32"), config.PodIP; e != a {
+		t.Errorf("Expect %s, got %s", e, a)
+	}
+	if e, a := 1, len(config.PodIPs); e != a {
+		t.Errorf("Expect %d, got %d", e, a)
+	}
+	if len(config.Routes) != 0 {
+		t.Errorf("Expect no routes, got %v", config.Routes)
+	}
+
+	config = &tunneler.Config{
+		PodIP:               netip.MustParsePrefix("10.128.0.2/24"),
+		ExternalNetViaPodVM: true,
+	}
+	if err := tun.(tunneler.TunnelerConfigurator).Configure(networkConfig, config); err == nil {
+		t.Error("Expect an error for the external network via pod VM")
+	}
+
+	config = &tunneler.Config{PodIP: netip.MustParsePrefix("fd00::2/64")}
+	if err := tun.(tunneler.TunnelerConfigurator).Configure(networkConfig, config); err == nil {
+		t.Error("Expect an error for IPv6-only pods")
+	}
+}
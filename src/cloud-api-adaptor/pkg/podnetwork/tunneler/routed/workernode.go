@@ -0,0 +1,276 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package routed
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
+)
+
+var logger = log.New(log.Writer(), "[tunneler/routed] ", log.LstdFlags|log.Lmsgprefix)
+
+// TunnelType is the name of the routed tunnel type. It requires a cloud provider that implements provider.PodIPAssigner.
+const TunnelType = "routed"
+
+const (
+	hostIPVLANInterfacePrefix = "pprt"
+	secondPodInterface        = "routed1"
+)
+
+type workerNodeTunneler struct {
+}
+
+func NewWorkerNodeTunneler() (tunneler.Tunneler, error) {
+	return &workerNodeTunneler{}, nil
+}
+
+// Configure replaces the routes of the pod network with a host route for each pod IP address.
+// The pod IP addresses are assigned to the pod VM in the cloud network, so the pod VM uses
+// the gateway of the cloud network instead of the gateway of the CNI plugin.
+func (t *workerNodeTunneler) Configure(n *tunneler.NetworkConfig, config *tunneler.Config) error {
+
+	if config.ExternalNetViaPodVM {
+		return errors.New("routed tunnel type does not support the external network via pod VM")
+	}
+
+	var podIPs []netip.Prefix
+	for _, podIP := range config.PodAddrs() {
+		if !podIP.Addr().Is4() {
+			logger.Printf("pod IP %s is not routed: only IPv4 is supported", podIP)
+			continue
+		}
+		podIPs = append(podIPs, netip.PrefixFrom(podIP.Addr(), 32))
+	}
+	if len(podIPs) == 0 {
+		return fmt.Errorf("pod has no IPv4 address: %v", config.PodAddrs())
+	}
+
+	config.PodIP = podIPs[0]
+	config.PodIPs = podIPs
+	config.Routes = nil
+	config.Neighbors = nil
+
+	return nil
+}
+
+func (t *workerNodeTunneler) Setup(nsPath string, podNodeIPs []netip.Addr, config *tunneler.Config) error {
+
+	if len(podNodeIPs) == 0 {
+		return fmt.Errorf("pod node has no IPs")
+	}
+
+	hostNS, err := netops.OpenCurrentNamespace()
+	if err != nil {
+		return fmt.Errorf("failed to get current network namespace: %w", err)
+	}
+	defer hostNS.Close()
+
+	podNS, err := netops.OpenNamespace(nsPath)
+	if err != nil {
+		return fmt.Errorf("failed to get a network namespace: %s: %w", nsPath, err)
+	}
+	defer podNS.Close()
+
+	hostLink, gateway, err := findUplink(hostNS, config.WorkerNodeIP.Addr())
+	if err != nil {
+		return err
+	}
+
+	gatewayHwAddr, err := findHardwareAddr(hostNS, hostLink.Name(), gateway)
+	if err != nil {
+		return err
+	}
+
+	// Packets to the pod IP addresses are sent to the gateway of the cloud network with no encapsulation
+	// from an ipvlan interface that shares the hardware address of the host interface.
+	hostIPVLANInterface := fmt.Sprintf("%s%d", hostIPVLANInterfacePrefix, config.Index)
+
+	ipvlan, err := hostNS.LinkAdd(hostIPVLANInterface, &netops.IPVLAN{Parent: hostLink})
+	if err != nil {
+		return fmt.Errorf("failed to add ipvlan interface %s on %s: %w", hostIPVLANInterface, hostLink.Name(), err)
+	}
+	logger.Printf("ipvlan %s (parent %s, gateway %s) created at %s", hostIPVLANInterface, hostLink.Name(), gateway, hostNS.Path())
+
+	if err := ipvlan.SetNamespace(podNS); err != nil {
+		return fmt.Errorf("failed to move ipvlan interface %s to netns %s: %w", hostIPVLANInterface, podNS.Path(), err)
+	}
+	logger.Printf("ipvlan %s is moved to %s", hostIPVLANInterface, podNS.Path())
+
+	if err := ipvlan.SetName(secondPodInterface); err != nil {
+		return fmt.Errorf("failed to change ipvlan interface name %s on netns %s to %s: %w", hostIPVLANInterface, podNS.Path(), secondPodInterface, err)
+	}
+
+	if err := ipvlan.SetUp(); err != nil {
+		return err
+	}
+
+	podInterface := config.InterfaceName
+
+	// The pod network namespace forwards packets from the CNI plugin, such as probes of kubelet, to the pod VM.
+	// The pod IP addresses are removed from the pod interface, and the pod interface answers ARP requests for them instead.
+	for _, sysctl := range []struct{ key, value string }{
+		{"net.ipv4.ip_forward", "1"},
+		{"net.ipv4.conf.all.rp_filter", "0"},
+		{"net.ipv4.conf." + podInterface + ".rp_filter", "0"},
+		{"net.ipv4.conf." + podInterface + ".proxy_arp", "1"},
+	} {
+		if err := podNS.SysctlSet(sysctl.key, sysctl.value); err != nil {
+			return err
+		}
+	}
+
+	podLink, err := podNS.LinkFind(podInterface)
+	if err != nil {
+		return fmt.Errorf("failed to find pod interface %q on netns %s: %w", podInterface, podNS.Path(), err)
+	}
+
+	addrs, err := podLink.GetAddr()
+	if err != nil {
+		return err
+	}
+
+	if err := podNS.NeighborAdd(&netops.Neighbor{
+		IP:           gateway,
+		HardwareAddr: gatewayHwAddr,
+		Dev:          secondPodInterface,
+		State:        netops.NeighborStatePermanent,
+	}); err != nil {
+		return err
+	}
+
+	for _, podIP := range config.PodAddrs() {
+		for _, addr := range addrs {
+			if addr.Addr() != podIP.Addr() {
+				continue
+			}
+			if err := podLink.DelAddr(addr); err != nil {
+				return err
+			}
+		}
+
+		route := &netops.Route{
+			Destination: podIP,
+			Gateway:     gateway,
+			Device:      secondPodInterface,
+			Onlink:      true,
+		}
+		if err := podNS.RouteAdd(route); err != nil {
+			return fmt.Errorf("failed to add a route to %s via %s on pod network namespace %s: %w", podIP, gateway, podNS.Path(), err)
+		}
+		logger.Printf("added a route to %s via %s dev %s on %s", podIP, gateway, secondPodInterface, podNS.Path())
+	}
+
+	return nil
+}
+
+func (t *workerNodeTunneler) Teardown(nsPath, hostInterface string, config *tunneler.Config) error {
+
+	podNS, err := netops.OpenNamespace(nsPath)
+	if err != nil {
+		return fmt.Errorf("failed to get a network namespace: %s: %w", nsPath, err)
+	}
+	defer podNS.Close()
+
+	logger.Printf("Delete ipvlan interface %s in the network namespace %s", secondPodInterface, nsPath)
+
+	return deleteIPVLANLink(podNS, secondPodInterface)
+}
+
+// findUplink returns the host interface that has addr and the gateway of its default route
+func findUplink(ns netops.Namespace, addr netip.Addr) (netops.Link, netip.Addr, error) {
+
+	if !addr.Is4() {
+		return nil, netip.Addr{}, fmt.Errorf("routed tunnel type requires an IPv4 host address: %q", addr)
+	}
+
+	links, err := ns.LinkList()
+	if err != nil {
+		return nil, netip.Addr{}, err
+	}
+
+	var uplink netops.Link
+	for _, link := range links {
+		prefixes, err := link.GetAddr()
+		if err != nil {
+			return nil, netip.Addr{}, err
+		}
+		for _, prefix := range prefixes {
+			if prefix.Addr() == addr {
+				uplink = link
+			}
+		}
+	}
+	if uplink == nil {
+		return nil, netip.Addr{}, fmt.Errorf("failed to find an interface with %s on netns %s", addr, ns.Path())
+	}
+
+	routes, err := ns.RouteList(&netops.Route{Destination: netops.DefaultPrefix})
+	if err != nil {
+		return nil, netip.Addr{}, fmt.Errorf("failed to get routes on netns %s: %w", ns.Path(), err)
+	}
+
+	for _, r := range routes {
+		if r.Device == uplink.Name() && r.Gateway.IsValid() {
+			return uplink, r.Gateway.Unmap(), nil
+		}
+	}
+
+	return nil, netip.Addr{}, fmt.Errorf("failed to find the default gateway of %s on netns %s", uplink.Name(), ns.Path())
+}
+
+// findHardwareAddr returns the hardware address of ip in the neighbor table of dev
+func findHardwareAddr(ns netops.Namespace, dev string, ip netip.Addr) (string, error) {
+
+	neighbors, err := ns.NeighborList(&netops.Neighbor{Dev: dev})
+	if err != nil {
+		return "", fmt.Errorf("failed to get neighbors of %s on netns %s: %w", dev, ns.Path(), err)
+	}
+
+	for _, neighbor := range neighbors {
+		if neighbor.IP.Unmap() != ip || neighbor.HardwareAddr == "" {
+			continue
+		}
+		switch neighbor.State.String() {
+		case "incomplete", "failed":
+			continue
+		}
+		return neighbor.HardwareAddr, nil
+	}
+
+	return "", fmt.Errorf("hardware address of %s is not found in the neighbor table of %s on netns %s", ip, dev, ns.Path())
+}
+
+func deleteIPVLANLink(ns netops.Namespace, name string) error {
+
+	link, err := ns.LinkFind(name)
+	if errors.Is(err, os.ErrNotExist) {
+		// The interface is gone with a deleted network namespace, or by a previous teardown
+		logger.Printf("ipvlan interface %s is already deleted on netns %s", name, ns.Path())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find ipvlan interface %q on netns %s: %w", name, ns.Path(), err)
+	}
+
+	device, err := link.GetDevice()
+	if err != nil {
+		return fmt.Errorf("failed to get device info of %s: %w", name, err)
+	}
+
+	if _, ok := device.(*netops.IPVLAN); !ok {
+		return fmt.Errorf("not an ipvlan interface: %s", name)
+	}
+
+	if err := link.Delete(); err != nil {
+		return fmt.Errorf("failed to delete ipvlan interface %s at %s: %w", name, ns.Path(), err)
+	}
+
+	return nil
+}
@@ -16,19 +16,47 @@ type Tunneler interface {
 }
 
 type Config struct {
-	PodIP               netip.Prefix `json:"podip"`
-	PodHwAddr           string       `json:"pod-hw-addr"`
-	InterfaceName       string       `json:"interface"`
-	WorkerNodeIP        netip.Prefix `json:"worker-node-ip"`
-	TunnelType          string       `json:"tunnel-type"`
-	Routes              []*Route     `json:"routes"`
-	Neighbors           []*Neighbor  `json:"neighbors"`
-	MTU                 int          `json:"mtu"`
-	Index               int          `json:"index"`
-	VXLANPort           int          `json:"vxlan-port,omitempty"`
-	VXLANID             int          `json:"vxlan-id,omitempty"`
-	Dedicated           bool         `json:"dedicated"`
-	ExternalNetViaPodVM bool         `json:"external-net-via-pod-vm"`
+	// PodIPs has all addresses of the pod interface. PodIP is the first of them,
+	// and is kept for pod VM images that only know a single pod address.
+	PodIP               netip.Prefix   `json:"podip"`
+	PodIPs              []netip.Prefix `json:"podips,omitempty"`
+	PodHwAddr           string         `json:"pod-hw-addr"`
+	InterfaceName       string         `json:"interface"`
+	WorkerNodeIP        netip.Prefix   `json:"worker-node-ip"`
+	TunnelType          string         `json:"tunnel-type"`
+	Routes              []*Route       `json:"routes"`
+	Neighbors           []*Neighbor    `json:"neighbors"`
+	MTU                 int            `json:"mtu"`
+	Index               int            `json:"index"`
+	VXLANPort           int            `json:"vxlan-port,omitempty"`
+	VXLANID             int            `json:"vxlan-id,omitempty"`
+	GenevePort          int            `json:"geneve-port,omitempty"`
+	GeneveID            int            `json:"geneve-id,omitempty"`
+	Dedicated           bool           `json:"dedicated"`
+	ExternalNetViaPodVM bool           `json:"external-net-via-pod-vm"`
+
+	// GeneveOptions are the TLV options of a Geneve tunnel. When they are set, the tunnel interfaces
+	// are external and the options are added to packets by tc filters.
+	GeneveOptions []netops.GeneveOption `json:"geneve-options,omitempty"`
+
+	// WireGuard keys are generated for each pod by the worker node. The private key of the pod VM end
+	// and the public key of the worker node end are delivered to the pod VM via apf.json.
+	// The private key of the worker node end never leaves the worker node. It is kept in the sandbox state.
+	WireGuardPort          int    `json:"wireguard-port,omitempty"`
+	WireGuardPrivateKey    []byte `json:"wireguard-private-key,omitempty"`
+	WireGuardPeerPublicKey []byte `json:"wireguard-peer-public-key,omitempty"`
+	WireGuardWorkerNodeKey []byte `json:"-"`
+}
+
+// PodAddrs returns the addresses to be assigned to the pod interface
+func (c *Config) PodAddrs() []netip.Prefix {
+	if len(c.PodIPs) > 0 {
+		return c.PodIPs
+	}
+	if c.PodIP.IsValid() {
+		return []netip.Prefix{c.PodIP}
+	}
+	return nil
 }
 
 type Route struct {
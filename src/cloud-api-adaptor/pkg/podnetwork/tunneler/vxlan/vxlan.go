@@ -0,0 +1,77 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package vxlan
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler/encap"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
+)
+
+const (
+	DefaultVXLANPort  = 4789
+	DefaultVXLANMinID = 555000
+)
+
+type link struct{}
+
+func NewWorkerNodeTunneler() (tunneler.Tunneler, error) {
+	return encap.NewWorkerNodeTunneler(link{}), nil
+}
+
+func NewPodNodeTunneler() (tunneler.Tunneler, error) {
+	return encap.NewPodNodeTunneler(link{}), nil
+}
+
+func (link) Name() string {
+	return "vxlan"
+}
+
+func (link) Configure(n *tunneler.NetworkConfig, config *tunneler.Config) {
+	config.VXLANPort = n.VXLAN.Port
+	config.VXLANID = n.VXLAN.MinID + config.Index
+}
+
+func (link) Tunnel(remote netip.Addr, config *tunneler.Config) *encap.Tunnel {
+	return &encap.Tunnel{
+		TunnelKey: netops.TunnelKey{
+			Remote: remote,
+			ID:     config.VXLANID,
+			Port:   config.VXLANPort,
+		},
+	}
+}
+
+func (link) Device(tunnel *encap.Tunnel) netops.Device {
+	return &netops.VXLAN{
+		Group: tunnel.Remote,
+		ID:    tunnel.ID,
+		Port:  tunnel.Port,
+	}
+}
+
+func (link) Inspect(device netops.Device) (*encap.Tunnel, bool) {
+	vxlanDevice, ok := device.(*netops.VXLAN)
+	if !ok {
+		return nil, false
+	}
+	return &encap.Tunnel{
+		TunnelKey: netops.TunnelKey{
+			Remote: vxlanDevice.Group,
+			ID:     vxlanDevice.ID,
+			Port:   vxlanDevice.Port,
+		},
+	}, true
+}
+
+func (link) Index(n *tunneler.NetworkConfig, tunnel *encap.Tunnel) int {
+	return tunnel.ID - n.VXLAN.MinID
+}
+
+func (link) FirewallComment(id int) string {
+	return fmt.Sprintf("peerpod [vni:%d]", id)
+}
@@ -0,0 +1,111 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package wireguard
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
+)
+
+const hostWireGuardInterface = "wg0"
+
+type podNodeTunneler struct {
+}
+
+func NewPodNodeTunneler() (tunneler.Tunneler, error) {
+	return &podNodeTunneler{}, nil
+}
+
+func (t *podNodeTunneler) Setup(nsPath string, podNodeIPs []netip.Addr, config *tunneler.Config) error {
+
+	podInterface := config.InterfaceName
+	if podInterface == "" {
+		return errors.New("InterfaceName is not specified")
+	}
+
+	nodeAddr := config.WorkerNodeIP
+	if !nodeAddr.IsValid() {
+		return fmt.Errorf("WorkerNodeIP is not specified: %#v", config.WorkerNodeIP)
+	}
+
+	podAddrs := config.PodAddrs()
+	if len(podAddrs) == 0 {
+		return fmt.Errorf("PodIP is not specified: %#v", config.PodIP)
+	}
+
+	if len(config.WireGuardPrivateKey) == 0 || len(config.WireGuardPeerPublicKey) == 0 {
+		return errors.New("WireGuard keys are not specified")
+	}
+
+	hostNS, err := netops.OpenCurrentNamespace()
+	if err != nil {
+		return fmt.Errorf("failed to get host network namespace: %w", err)
+	}
+	defer hostNS.Close()
+
+	podNS, err := netops.OpenNamespace(nsPath)
+	if err != nil {
+		return fmt.Errorf("failed to get a pod network namespace: %s: %w", nsPath, err)
+	}
+	defer podNS.Close()
+
+	device := &netops.WireGuard{
+		PrivateKey: config.WireGuardPrivateKey,
+		ListenPort: config.WireGuardPort,
+		Peers: []*netops.WireGuardPeer{
+			{
+				PublicKey:           config.WireGuardPeerPublicKey,
+				Endpoint:            netip.AddrPortFrom(nodeAddr.Addr(), uint16(config.WireGuardPort)),
+				AllowedIPs:          allowedIPs,
+				PersistentKeepalive: persistentKeepalive,
+			},
+		},
+	}
+	logger.Printf("Creating WireGuard interface %s on %s with endpoint %s:%d", hostWireGuardInterface, hostNS.Path(), nodeAddr.Addr(), config.WireGuardPort)
+
+	wg, err := hostNS.LinkAdd(hostWireGuardInterface, device)
+	if err != nil {
+		return fmt.Errorf("failed to add wireguard interface %s: %w", hostWireGuardInterface, err)
+	}
+
+	if err := wg.SetNamespace(podNS); err != nil {
+		return fmt.Errorf("failed to move wireguard interface %s to netns %s: %w", hostWireGuardInterface, podNS.Path(), err)
+	}
+
+	if err := wg.SetName(podInterface); err != nil {
+		return fmt.Errorf("failed to rename wireguard interface %s on netns %s: %w", hostWireGuardInterface, podNS.Path(), err)
+	}
+
+	mtu := min(config.MTU, maxMTU)
+	if err := wg.SetMTU(mtu); err != nil {
+		return fmt.Errorf("failed to set MTU of %s to %d on %s: %w", podInterface, mtu, nsPath, err)
+	}
+
+	for _, podAddr := range podAddrs {
+		if err := wg.AddAddr(podAddr); err != nil {
+			return fmt.Errorf("failed to add pod IP %s to %s on %s: %w", podAddr, podInterface, nsPath, err)
+		}
+	}
+
+	if err := wg.SetUp(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *podNodeTunneler) Teardown(nsPath, hostInterface string, config *tunneler.Config) error {
+
+	podNS, err := netops.OpenNamespace(nsPath)
+	if err != nil {
+		return fmt.Errorf("failed to get a pod network namespace: %s: %w", nsPath, err)
+	}
+	defer podNS.Close()
+
+	return deleteWireGuardLink(podNS, config.InterfaceName)
+}
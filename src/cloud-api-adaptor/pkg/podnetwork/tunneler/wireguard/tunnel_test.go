@@ -0,0 +1,121 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package wireguard
+
+import (
+	"crypto/ecdh"
+	"testing"
+
+	testutils "github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/internal/testing"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tuntest"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
+)
+
+func skipTestIfNoWireGuard(t *testing.T) {
+	t.Helper()
+
+	ns, _ := tuntest.NewNamedNS(t, "test-wgprobe")
+	defer tuntest.DeleteNamedNS(t, ns)
+
+	if _, err := ns.LinkAdd("wgprobe", &netops.WireGuard{PrivateKey: make([]byte, netops.WireGuardKeyLen)}); err != nil {
+		t.Skipf("WireGuard is not available: %v", err)
+	}
+}
+
+func TestWireGuard(t *testing.T) {
+	testutils.SkipTestIfNotRoot(t)
+	skipTestIfNoWireGuard(t)
+
+	tuntest.RunTunnelTest(t, "wireguard", NewWorkerNodeTunneler, NewPodNodeTunneler, false)
+}
+
+func TestWireGuardDedicated(t *testing.T) {
+	testutils.SkipTestIfNotRoot(t)
+	skipTestIfNoWireGuard(t)
+
+	tuntest.RunTunnelTest(t, "wireguard", NewWorkerNodeTunneler, NewPodNodeTunneler, true)
+}
+
+func TestConfigure(t *testing.T) {
+
+	tun, err := NewWorkerNodeTunneler()
+	if err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	networkConfig := &tunneler.NetworkConfig{
+		TunnelType: "wireguard",
+		WireGuard:  tunneler.WireGuardConfig{Port: DefaultWireGuardPort},
+	}
+	config := &tunneler.Config{Index: 3}
+
+	if err := tun.(tunneler.TunnelerConfigurator).Configure(networkConfig, config); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	if e, a := DefaultWireGuardPort+3, config.WireGuardPort; e != a {
+		t.Errorf("Expect %d, got %d", e, a)
+	}
+
+	workerNodeKey, err := ecdh.X25519().NewPrivateKey(config.WireGuardWorkerNodeKey)
+	if err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+	if !workerNodeKey.PublicKey().Equal(mustPublicKey(t, config.WireGuardPeerPublicKey)) {
+		t.Error("Expect the peer public key to match the worker node key")
+	}
+
+	if _, err := ecdh.X25519().NewPrivateKey(config.WireGuardPrivateKey); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	other := &tunneler.Config{Index: 3}
+	if err := tun.(tunneler.TunnelerConfigurator).Configure(networkConfig, other); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+	if string(other.WireGuardPrivateKey) == string(config.WireGuardPrivateKey) {
+		t.Error("Expect keys to be generated for each pod")
+	}
+}
+
+func mustPublicKey(t *testing.T, b []byte) *ecdh.PublicKey {
+	t.Helper()
+
+	key, err := ecdh.X25519().NewPublicKey(b)
+	if err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+	return key
+}
+
+func TestInspectIndex(t *testing.T) {
+	testutils.SkipTestIfNotRoot(t)
+	skipTestIfNoWireGuard(t)
+
+	podNS, _ := tuntest.NewNamedNS(t, "test-wgindex")
+	defer tuntest.DeleteNamedNS(t, podNS)
+
+	if _, err := podNS.LinkAdd(secondPodInterface, &netops.WireGuard{PrivateKey: make([]byte, netops.WireGuardKeyLen), ListenPort: DefaultWireGuardPort + 2}); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	tun, err := NewWorkerNodeTunneler()
+	if err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	networkConfig := &tunneler.NetworkConfig{
+		TunnelType: "wireguard",
+		WireGuard:  tunneler.WireGuardConfig{Port: DefaultWireGuardPort},
+	}
+
+	index, found, err := tun.(tunneler.IndexInspector).InspectIndex(networkConfig, podNS.Path())
+	if err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+	if !found || index != 2 {
+		t.Errorf("Expect index 2, got %d (found: %v)", index, found)
+	}
+}
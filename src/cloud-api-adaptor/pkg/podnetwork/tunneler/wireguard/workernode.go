@@ -0,0 +1,247 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package wireguard
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"time"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
+)
+
+var logger = log.New(log.Writer(), "[tunneler/wireguard] ", log.LstdFlags|log.Lmsgprefix)
+
+const (
+	DefaultWireGuardPort         = 51820
+	hostWireGuardInterfacePrefix = "ppwg"
+	secondPodInterface           = "wg1"
+	persistentKeepalive          = 25 * time.Second
+	maxMTU                       = 1420
+	maxHostInterfaceAttempts     = 5
+)
+
+var allowedIPs = []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0")}
+
+type workerNodeTunneler struct {
+}
+
+func NewWorkerNodeTunneler() (tunneler.Tunneler, error) {
+	return &workerNodeTunneler{}, nil
+}
+
+// Configure generates a pair of WireGuard keys for each end of the tunnel of a pod
+func (t *workerNodeTunneler) Configure(n *tunneler.NetworkConfig, config *tunneler.Config) error {
+
+	workerNodeKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate a WireGuard key: %w", err)
+	}
+
+	podNodeKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate a WireGuard key: %w", err)
+	}
+
+	config.WireGuardPort = n.WireGuard.Port + config.Index
+	config.WireGuardPrivateKey = podNodeKey.Bytes()
+	config.WireGuardPeerPublicKey = workerNodeKey.PublicKey().Bytes()
+	config.WireGuardWorkerNodeKey = workerNodeKey.Bytes()
+
+	return nil
+}
+
+// InspectIndex finds the index of a pod from the listen port of the WireGuard interface in its network namespace
+func (t *workerNodeTunneler) InspectIndex(n *tunneler.NetworkConfig, nsPath string) (int, bool, error) {
+
+	podNS, err := netops.OpenNamespace(nsPath)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get a network namespace: %s: %w", nsPath, err)
+	}
+	defer podNS.Close()
+
+	link, err := podNS.LinkFind(secondPodInterface)
+	if err != nil {
+		return 0, false, nil
+	}
+
+	device, err := link.GetDevice()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get device info of %s on netns %s: %w", secondPodInterface, nsPath, err)
+	}
+
+	wgDevice, ok := device.(*netops.WireGuard)
+	if !ok {
+		return 0, false, nil
+	}
+
+	return wgDevice.ListenPort - n.WireGuard.Port, true, nil
+}
+
+func (t *workerNodeTunneler) Setup(nsPath string, podNodeIPs []netip.Addr, config *tunneler.Config) error {
+
+	var dstAddr netip.Addr
+
+	numIPs := len(podNodeIPs)
+	if numIPs == 0 {
+		return fmt.Errorf("pod node has no IPs")
+	}
+
+	if config.Dedicated {
+		if numIPs < 2 {
+			return fmt.Errorf("dedicated tunnel missing destination address")
+		}
+		dstAddr = podNodeIPs[1]
+	} else {
+		dstAddr = podNodeIPs[0]
+	}
+
+	if len(config.WireGuardWorkerNodeKey) == 0 {
+		return errors.New("WireGuard key of the worker node is not generated")
+	}
+
+	podNodeKey, err := ecdh.X25519().NewPrivateKey(config.WireGuardPrivateKey)
+	if err != nil {
+		return fmt.Errorf("invalid WireGuard private key of the pod node: %w", err)
+	}
+
+	hostNS, err := netops.OpenCurrentNamespace()
+	if err != nil {
+		return fmt.Errorf("failed to get current network namespace: %w", err)
+	}
+	defer hostNS.Close()
+
+	podNS, err := netops.OpenNamespace(nsPath)
+	if err != nil {
+		return fmt.Errorf("failed to get a network namespace: %s: %w", nsPath, err)
+	}
+	defer podNS.Close()
+
+	device := &netops.WireGuard{
+		PrivateKey: config.WireGuardWorkerNodeKey,
+		ListenPort: config.WireGuardPort,
+		Peers: []*netops.WireGuardPeer{
+			{
+				PublicKey:           podNodeKey.PublicKey().Bytes(),
+				Endpoint:            netip.AddrPortFrom(dstAddr, uint16(config.WireGuardPort)),
+				AllowedIPs:          allowedIPs,
+				PersistentKeepalive: persistentKeepalive,
+			},
+		},
+	}
+
+	// The WireGuard interface is created on the host network namespace, so that encrypted packets are sent and received there
+	var hostWireGuardInterface string
+	var hostWireGuardLink netops.Link
+
+	for index := 1; ; index++ {
+		if index > maxHostInterfaceAttempts {
+			return fmt.Errorf("failed to create wireguard interface %s: too many", hostWireGuardInterface)
+		}
+
+		hostWireGuardInterface = fmt.Sprintf("%s%d", hostWireGuardInterfacePrefix, index)
+
+		hostWireGuardLink, err = hostNS.LinkAdd(hostWireGuardInterface, device)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("failed to add wireguard interface %s: %w", hostWireGuardInterface, err)
+		}
+	}
+	logger.Printf("wireguard %s (remote %s:%d) created at %s", hostWireGuardInterface, dstAddr, config.WireGuardPort, hostNS.Path())
+
+	if err := hostWireGuardLink.SetNamespace(podNS); err != nil {
+		return fmt.Errorf("failed to move wireguard interface %s to netns %s: %w", hostWireGuardInterface, podNS.Path(), err)
+	}
+	logger.Printf("wireguard %s is moved to %s", hostWireGuardInterface, podNS.Path())
+
+	if err := hostWireGuardLink.SetName(secondPodInterface); err != nil {
+		return fmt.Errorf("failed to change wireguard interface name %s on netns %s to %s: %w", hostWireGuardInterface, podNS.Path(), secondPodInterface, err)
+	}
+
+	mtu := min(config.MTU, maxMTU)
+	if err := hostWireGuardLink.SetMTU(mtu); err != nil {
+		return fmt.Errorf("failed to set MTU of %s to %d on %s: %w", secondPodInterface, mtu, nsPath, err)
+	}
+
+	if err := hostWireGuardLink.SetUp(); err != nil {
+		return err
+	}
+
+	// WireGuard is a layer 3 tunnel. Packets from the pod VM are routed to the pod interface,
+	// while the pod network namespace still owns the pod IP address and answers ARP requests for it.
+	for _, sysctl := range []struct{ key, value string }{
+		{"net.ipv4.ip_forward", "1"},
+		{"net.ipv4.conf.all.rp_filter", "0"},
+		{"net.ipv4.conf." + secondPodInterface + ".rp_filter", "0"},
+		{"net.ipv4.conf." + secondPodInterface + ".accept_local", "1"},
+	} {
+		if err := podNS.SysctlSet(sysctl.key, sysctl.value); err != nil {
+			return err
+		}
+	}
+
+	podInterface := config.InterfaceName
+
+	logger.Printf("Add tc redirect filters from %s to %s on pod network namespace %s", podInterface, secondPodInterface, nsPath)
+
+	if err := podNS.RedirectIPAdd(podInterface, secondPodInterface); err != nil {
+		return fmt.Errorf("failed to add a tc redirect filter from %s to %s: %w", podInterface, secondPodInterface, err)
+	}
+
+	return nil
+}
+
+func (t *workerNodeTunneler) Teardown(nsPath, hostInterface string, config *tunneler.Config) error {
+
+	podNS, err := netops.OpenNamespace(nsPath)
+	if err != nil {
+		return fmt.Errorf("failed to get a network namespace: %s: %w", nsPath, err)
+	}
+	defer podNS.Close()
+
+	logger.Printf("Delete tc redirect filters on %s in the network namespace %s", config.InterfaceName, nsPath)
+
+	if err := podNS.RedirectDel(config.InterfaceName); err != nil {
+		return fmt.Errorf("failed to delete a tc redirect filter from %s to %s: %w", config.InterfaceName, secondPodInterface, err)
+	}
+
+	logger.Printf("Delete wireguard interface %s in the network namespace %s", secondPodInterface, nsPath)
+
+	if err := deleteWireGuardLink(podNS, secondPodInterface); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func deleteWireGuardLink(ns netops.Namespace, name string) error {
+
+	link, err := ns.LinkFind(name)
+	if err != nil {
+		return fmt.Errorf("failed to find wireguard interface %q on netns %s: %w", name, ns.Path(), err)
+	}
+
+	device, err := link.GetDevice()
+	if err != nil {
+		return fmt.Errorf("failed to get device info of %s: %w", name, err)
+	}
+
+	if _, ok := device.(*netops.WireGuard); !ok {
+		return fmt.Errorf("not a WireGuard interface: %s", name)
+	}
+
+	if err := link.Delete(); err != nil {
+		return fmt.Errorf("failed to delete wireguard interface %s at %s: %w", name, ns.Path(), err)
+	}
+
+	return nil
+}
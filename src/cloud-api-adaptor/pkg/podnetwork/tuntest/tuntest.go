@@ -13,7 +13,6 @@ import (
 	testutils "github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/internal/testing"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
-	"github.com/coreos/go-iptables/iptables"
 )
 
 type testPod struct {
@@ -62,16 +61,7 @@ func RunTunnelTest(t *testing.T, tunnelType string, newWorkerNodeTunneler, newPo
 	workerNS, _ := NewNamedNS(t, "test-worker")
 	defer DeleteNamedNS(t, workerNS)
 
-	if err := workerNS.Run(func() error {
-		ipt, err := iptables.New(iptables.IPFamily(iptables.ProtocolIPv4))
-		if err != nil {
-			return err
-		}
-		if err := ipt.Append("filter", "FORWARD", "-i", "cni0", "-j", "ACCEPT"); err != nil {
-			return err
-		}
-		return ipt.ChangePolicy("filter", "FORWARD", "DROP")
-	}); err != nil {
+	if err := netops.NewFirewall(workerNS).RestrictForward("cni0"); err != nil {
 		t.Fatalf("Expect no error, got %v", err)
 	}
 
@@ -144,6 +134,21 @@ func RunTunnelTest(t *testing.T, tunnelType string, newWorkerNodeTunneler, newPo
 			pod.config.VXLANID = 555000 + i // vxlan.DefaultVXLANMinID + index
 		}
 
+		if tunnelType == "geneve" {
+			pod.config.GenevePort = 6081     // geneve.DefaultGenevePort
+			pod.config.GeneveID = 555000 + i // geneve.DefaultGeneveMinID + index
+		}
+
+		if tunnelType == "wireguard" {
+			networkConfig := &tunneler.NetworkConfig{
+				TunnelType: tunnelType,
+				WireGuard:  tunneler.WireGuardConfig{Port: 51820}, // wireguard.DefaultWireGuardPort
+			}
+			if err := pod.workerNodeTunneler.(tunneler.TunnelerConfigurator).Configure(networkConfig, pod.config); err != nil {
+				t.Fatalf("Expect no error, got %v", err)
+			}
+		}
+
 		podNodeIPs := []netip.Addr{getIP(t, pod.podNodePrimaryAddr)}
 
 		if dedicated {
@@ -4,9 +4,11 @@
 package podnetwork
 
 import (
+	"errors"
 	"fmt"
 	"net/netip"
-	"sync"
+	"os"
+	"path/filepath"
 
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/podnetwork/tunneler"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/netops"
@@ -23,24 +25,7 @@ type WorkerNode interface {
 type workerNode struct {
 	*tunneler.NetworkConfig
 	tunneler tunneler.TunnelerConfigurator
-}
-
-// TODO: Pod index is reset when this process restarts.
-// We need to manage a persistent unique index number for each pod VM
-var podIndexManager podIndex
-
-type podIndex struct {
-	index int
-	mutex sync.Mutex
-}
-
-func (p *podIndex) Get() int {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
-
-	index := p.index
-	p.index++
-	return index
+	podIndex *podIndex
 }
 
 func NewWorkerNode(networkConfig *tunneler.NetworkConfig) (WorkerNode, error) {
@@ -55,19 +40,75 @@ func NewWorkerNode(networkConfig *tunneler.NetworkConfig) (WorkerNode, error) {
 		return nil, fmt.Errorf("internal error: Configure is not defined: %T", t)
 	}
 
+	if err := netops.SetFirewallBackend(networkConfig.FirewallBackend); err != nil {
+		return nil, err
+	}
+	logger.Printf("Using %s firewall backend", netops.GetFirewallBackend())
+
 	wn := &workerNode{
 		NetworkConfig: networkConfig,
 		tunneler:      tun,
+		podIndex:      newPodIndex(networkConfig.PodIndexFile),
+	}
+
+	if err := wn.podIndex.Rebuild(wn.liveIndexes()); err != nil {
+		return nil, fmt.Errorf("failed to rebuild pod indexes: %w", err)
 	}
 
 	return wn, nil
 }
 
-func (n *workerNode) Inspect(nsPath string) (*tunneler.Config, error) {
+// liveIndexes returns the indexes of pods found in tunnel devices of existing network namespaces
+func (n *workerNode) liveIndexes() map[string]int {
+
+	inspector, ok := n.tunneler.(tunneler.IndexInspector)
+	if !ok {
+		return nil
+	}
+
+	entries, err := os.ReadDir(netnsDir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Printf("failed to read %s: %v", netnsDir, err)
+		}
+		return nil
+	}
+
+	live := make(map[string]int)
+
+	for _, entry := range entries {
+		nsPath := filepath.Join(netnsDir, entry.Name())
+
+		index, found, err := inspector.InspectIndex(n.NetworkConfig, nsPath)
+		if err != nil {
+			logger.Printf("failed to inspect the pod index of netns %s: %v", nsPath, err)
+			continue
+		}
+		if found {
+			live[nsPath] = index
+		}
+	}
+
+	return live
+}
+
+func (n *workerNode) Inspect(nsPath string) (_ *tunneler.Config, err error) {
+
+	index, err := n.podIndex.Allocate(nsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			if e := n.podIndex.Release(nsPath); e != nil {
+				logger.Printf("%v", e)
+			}
+		}
+	}()
 
 	config := &tunneler.Config{
 		TunnelType:          n.TunnelType,
-		Index:               podIndexManager.Get(),
+		Index:               index,
 		ExternalNetViaPodVM: n.ExternalNetViaPodVM,
 	}
 
@@ -102,10 +143,14 @@ func (n *workerNode) Inspect(nsPath string) (*tunneler.Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get IP address on %s (netns: %s): %w", hostInterface, hostNS.Path(), err)
 	}
-	if len(addrs) != 1 {
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no IP address assigned on %s (netns: %s)", hostInterface, hostNS.Path())
+	}
+	if len(addrs) > 1 && addrs[1].Addr().Is4() == addrs[0].Addr().Is4() {
 		logger.Printf("more than one IP address (%v) assigned on %s (netns: %s)", addrs, hostInterface, hostNS.Path())
 	}
-	// Use the first IP as the workerNodeIP
+	// Use the first IP as the workerNodeIP. GetAddr returns IPv4 addresses first, so
+	// an IPv6 underlay is used only when the host interface has no IPv4 address.
 	// TBD: Might be faster to retrieve using K8s downward API
 	config.WorkerNodeIP = addrs[0]
 
@@ -152,12 +197,13 @@ func (n *workerNode) Inspect(nsPath string) (*tunneler.Config, error) {
 		return nil, fmt.Errorf("failed to find pod interface %q on netns %s): %w", podInterface, podNS.Path(), err)
 	}
 
-	podIP, err := getPodIP(podLink)
+	podIPs, err := getPodIPs(podLink)
 	if err != nil {
 		return nil, err
 	}
 
-	config.PodIP = podIP
+	config.PodIP = podIPs[0]
+	config.PodIPs = podIPs
 	config.PodHwAddr, err = podLink.GetHardwareAddr()
 	if err != nil {
 		logger.Printf("failed to get Mac address of the Pod interface")
@@ -178,6 +224,10 @@ func (n *workerNode) Inspect(nsPath string) (*tunneler.Config, error) {
 	}
 
 	for _, route := range routes {
+		if dst := route.Destination.Addr(); dst.Is6() && dst.IsLinkLocalUnicast() {
+			// The kernel adds the IPv6 link-local route to every interface
+			continue
+		}
 		r := &tunneler.Route{
 			Dst:      route.Destination,
 			Dev:      route.Device,
@@ -196,9 +246,17 @@ func (n *workerNode) Inspect(nsPath string) (*tunneler.Config, error) {
 				logger.Printf("failed to parse CIDR %q: %s", cidr, err)
 				continue
 			}
+			gw := gatewayAddr
+			if !gw.IsValid() || gw.Is4() != prefix.Addr().Is4() {
+				gw = defaultGateway(routes, podInterface, prefix)
+			}
+			if !gw.IsValid() {
+				logger.Printf("no gateway found for CIDR %q on %s", cidr, podInterface)
+				continue
+			}
 			route := &tunneler.Route{
 				Dst: prefix,
-				GW:  gatewayAddr,
+				GW:  gw,
 				Dev: podInterface,
 			}
 			config.Routes = append(config.Routes, route)
@@ -231,7 +289,14 @@ func (n *workerNode) Setup(nsPath string, podNodeIPs []netip.Addr, config *tunne
 	return nil
 }
 
-func (n *workerNode) Teardown(nsPath string, config *tunneler.Config) error {
+func (n *workerNode) Teardown(nsPath string, config *tunneler.Config) (err error) {
+
+	// The pod index is released even if the teardown fails, since the pod network namespace is going away
+	defer func() {
+		if e := n.podIndex.Release(nsPath); e != nil {
+			err = errors.Join(err, e)
+		}
+	}()
 
 	hostNS, err := netops.OpenCurrentNamespace()
 	if err != nil {
@@ -259,24 +324,21 @@ func (n *workerNode) Teardown(nsPath string, config *tunneler.Config) error {
 	return nil
 }
 
-func getPodIP(podLink netops.Link) (netip.Prefix, error) {
+func getPodIPs(podLink netops.Link) ([]netip.Prefix, error) {
 
 	prefixes, err := podLink.GetAddr()
 	if err != nil {
-		return netip.Prefix{}, fmt.Errorf("failed to get IP address on %s of netns %s: %w", podLink.Name(), podLink.Namespace().Path(), err)
+		return nil, fmt.Errorf("failed to get IP address on %s of netns %s: %w", podLink.Name(), podLink.Namespace().Path(), err)
 	}
 
 	var ips []netip.Prefix
 	for _, prefix := range prefixes {
-		if prefix.IsValid() && prefix.Addr().Is4() {
+		if prefix.IsValid() {
 			ips = append(ips, prefix)
 		}
 	}
 	if len(ips) < 1 {
-		return netip.Prefix{}, fmt.Errorf("no IPv4 address found on %s of netns %s", podLink.Name(), podLink.Namespace().Path())
-	}
-	if len(ips) > 1 {
-		return netip.Prefix{}, fmt.Errorf("more than one IPv4 addresses found on %s of netns %s", podLink.Name(), podLink.Namespace().Path())
+		return nil, fmt.Errorf("no IP address found on %s of netns %s", podLink.Name(), podLink.Namespace().Path())
 	}
-	return ips[0], nil
+	return ips, nil
 }
@@ -13,6 +13,8 @@ import (
 	"time"
 
 	"golang.org/x/sys/unix"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/adaptor/metrics"
 )
 
 var logger = log.New(log.Writer(), "[probe/probe] ", log.LstdFlags|log.Lmsgprefix)
@@ -22,6 +24,9 @@ var startTime time.Time
 
 const DefaultCCRuntimeClassName string = "kata-remote"
 
+// DefaultProbeHost is the default listen address of the probe and metrics server
+const DefaultProbeHost = "127.0.0.1"
+
 func StartupHandler(w http.ResponseWriter, r *http.Request) {
 	opened, err := checker.IsSocketOpen()
 	if err != nil {
@@ -48,13 +53,16 @@ func StartupHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func Start(ctx context.Context, socketPath string) {
+func Start(ctx context.Context, socketPath, host string) {
 	startTime = time.Now()
 
 	port := os.Getenv("PROBE_PORT")
 	if port == "" {
 		port = "8000"
 	}
+	if host == "" {
+		host = DefaultProbeHost
+	}
 	logger.Printf("Using port: %s", port)
 	podsReadizProbesDone = false
 
@@ -77,13 +85,14 @@ func Start(ctx context.Context, socketPath string) {
 			})
 		},
 	}
-	ln, err := lc.Listen(ctx, "tcp", "127.0.0.1:"+port)
+	ln, err := lc.Listen(ctx, "tcp", net.JoinHostPort(host, port))
 	if err != nil {
 		logger.Printf("failed to listen on probe port: %s", err)
 		return
 	}
 	mux := http.NewServeMux()
 	mux.HandleFunc("/startup", StartupHandler)
+	mux.Handle("/metrics", metrics.Handler())
 	server := &http.Server{Handler: mux}
 	serveCtx, serveCancel := context.WithCancel(ctx)
 	defer serveCancel()
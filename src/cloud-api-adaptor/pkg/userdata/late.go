@@ -0,0 +1,225 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package userdata
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tlsutil"
+)
+
+// Pod VMs in a warm pool of cloud-api-adaptor are created before the pod they will run is known.
+// Their user data contains only a provision config file. process-user-data then listens for
+// the per-pod cloud config on a mutually authenticated TLS endpoint, and provisions the files
+// received there as if they were delivered in user data.
+
+const (
+	// ProvisionURLPath is the URL path of the endpoint that receives the per-pod cloud config.
+	// GET returns 204 while the endpoint waits for a cloud config. PUT delivers a cloud config.
+	ProvisionURLPath = "/provision"
+
+	maxCloudConfigSize = 1 << 20
+)
+
+// ProvisionConfig is stored at paths.ProvisionCfgPath in the user data of warm pool VMs
+type ProvisionConfig struct {
+	ListenAddr    string   `json:"listen-addr"`
+	TLSServerCert string   `json:"tls-server-cert"`
+	TLSServerKey  string   `json:"tls-server-key"`
+	TLSClientCA   string   `json:"tls-client-ca"`
+	MinTLSVersion string   `json:"tls-min-version,omitempty"`
+	CipherSuites  []string `json:"tls-cipher-suites,omitempty"`
+}
+
+type cloudConfigReceiver struct {
+	cfg    *Config
+	mutex  sync.Mutex
+	done   bool
+	doneCh chan struct{}
+}
+
+func (r *cloudConfigReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != ProvisionURLPath {
+		http.NotFound(w, req)
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	switch req.Method {
+	case http.MethodGet:
+		if r.done {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPut:
+		if r.done {
+			http.Error(w, "cloud config is already provisioned", http.StatusConflict)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxCloudConfigSize))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to read cloud config: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		cc, err := parseUserData(body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to parse cloud config: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if err := r.provision(cc); err != nil {
+			logger.Printf("failed to provision received cloud config: %v\n", err)
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		logger.Printf("Provisioned cloud config received from %s\n", req.RemoteAddr)
+
+		r.done = true
+		close(r.doneCh)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (r *cloudConfigReceiver) provision(cc *CloudConfig) error {
+	var files []WriteFile
+	for _, wf := range cc.WriteFiles {
+		// A received cloud config must not ask for another delivery
+		if wf.Path == r.cfg.provisionPath {
+			logger.Printf("File: %s is not allowed in a received cloud config.\n", wf.Path)
+			continue
+		}
+		files = append(files, wf)
+	}
+
+	if err := processCloudConfig(r.cfg, &CloudConfig{WriteFiles: files}); err != nil {
+		return fmt.Errorf("failed to process cloud config: %w", err)
+	}
+
+	if err := extractInitdataAndHash(r.cfg); err != nil {
+		return fmt.Errorf("failed to extract initdata hash: %w", err)
+	}
+
+	return nil
+}
+
+func loadProvisionConfig(path string) (*ProvisionConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var pc ProvisionConfig
+	if err := json.Unmarshal(data, &pc); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+
+	if pc.TLSServerCert == "" || pc.TLSServerKey == "" || pc.TLSClientCA == "" {
+		return nil, fmt.Errorf("%s does not contain TLS credentials", path)
+	}
+
+	return &pc, nil
+}
+
+// receiveCloudConfig waits until a cloud config is delivered to the provision endpoint and provisioned successfully.
+// There is no timeout, since a warm pool VM may stay idle for a long time before it is claimed by a pod.
+func receiveCloudConfig(ctx context.Context, cfg *Config) error {
+	pc, err := loadProvisionConfig(cfg.provisionPath)
+	if err != nil {
+		return err
+	}
+
+	listener, err := provisionListener(pc)
+	if err != nil {
+		return err
+	}
+
+	return serveCloudConfig(ctx, cfg, listener)
+}
+
+func provisionListener(pc *ProvisionConfig) (net.Listener, error) {
+	tlsConfig, err := tlsutil.GetTLSConfigFor(&tlsutil.TLSConfig{
+		CertData:      []byte(pc.TLSServerCert),
+		KeyData:       []byte(pc.TLSServerKey),
+		CAData:        []byte(pc.TLSClientCA),
+		MinTLSVersion: pc.MinTLSVersion,
+		CipherSuites:  pc.CipherSuites,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tls config: %w", err)
+	}
+
+	listener, err := tls.Listen("tcp", pc.ListenAddr, tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", pc.ListenAddr, err)
+	}
+
+	return listener, nil
+}
+
+func serveCloudConfig(ctx context.Context, cfg *Config, listener net.Listener) error {
+	receiver := &cloudConfigReceiver{
+		cfg:    cfg,
+		doneCh: make(chan struct{}),
+	}
+
+	server := &http.Server{
+		Handler:           receiver,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	logger.Printf("Waiting for cloud config on %s%s\n", listener.Addr(), ProvisionURLPath)
+
+	var err error
+	select {
+	case <-receiver.doneCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case err = <-serverErr:
+		return fmt.Errorf("failed to serve provision endpoint: %w", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if shutdownErr := server.Shutdown(shutdownCtx); shutdownErr != nil {
+		logger.Printf("failed to shut down provision endpoint: %v\n", shutdownErr)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// The provision config holds a private key that is no longer needed
+	if err := os.Remove(cfg.provisionPath); err != nil {
+		logger.Printf("failed to remove %s: %v\n", cfg.provisionPath, err)
+	}
+
+	return nil
+}
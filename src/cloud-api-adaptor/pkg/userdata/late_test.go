@@ -0,0 +1,173 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package userdata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tlsutil"
+)
+
+func TestReceiveCloudConfig(t *testing.T) {
+	tempDir := t.TempDir()
+
+	var authPath = filepath.Join(tempDir, "auth.json")
+	var initdataPath = filepath.Join(tempDir, "initdata")
+	var aaPath = filepath.Join(tempDir, "aa.toml")
+	var digestPath = filepath.Join(tempDir, "initdata.digest")
+	var provisionPath = filepath.Join(tempDir, "provision.json")
+
+	cfg := Config{
+		fetchTimeout:  180,
+		digestPath:    digestPath,
+		initdataPath:  initdataPath,
+		provisionPath: provisionPath,
+		parentPath:    tempDir,
+		writeFiles:    []string{authPath, initdataPath, provisionPath},
+		initdataFiles: []string{aaPath},
+	}
+
+	caService, err := tlsutil.NewCAService("test-ca")
+	if err != nil {
+		t.Fatalf("failed to create a CA service: %v", err)
+	}
+	serverCert, serverKey, err := caService.Issue("podvm-warm-pool-12345678")
+	if err != nil {
+		t.Fatalf("failed to issue a server certificate: %v", err)
+	}
+	clientCert, clientKey, err := tlsutil.NewClientCertificate("test-client")
+	if err != nil {
+		t.Fatalf("failed to create a client certificate: %v", err)
+	}
+	otherCert, otherKey, err := tlsutil.NewClientCertificate("other-client")
+	if err != nil {
+		t.Fatalf("failed to create a client certificate: %v", err)
+	}
+
+	pc := &ProvisionConfig{
+		ListenAddr:    "127.0.0.1:0",
+		TLSServerCert: string(serverCert),
+		TLSServerKey:  string(serverKey),
+		TLSClientCA:   string(clientCert),
+	}
+	if err := writeFile(provisionPath, []byte(`{"listen-addr": "127.0.0.1:0"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadProvisionConfig(provisionPath); err == nil {
+		t.Fatalf("expected an error loading a provision config without TLS credentials")
+	}
+
+	listener, err := provisionListener(pc)
+	if err != nil {
+		t.Fatalf("failed to create a provision listener: %v", err)
+	}
+
+	errCh := make(chan error)
+	go func() {
+		errCh <- serveCloudConfig(context.Background(), &cfg, listener)
+	}()
+
+	client := func(certPEM, keyPEM []byte) *http.Client {
+		tlsConfig, err := tlsutil.GetTLSConfigFor(&tlsutil.TLSConfig{
+			CAData:   caService.RootCertificate(),
+			CertData: certPEM,
+			KeyData:  keyPEM,
+		})
+		if err != nil {
+			t.Fatalf("failed to create a client TLS config: %v", err)
+		}
+		tlsConfig.ServerName = "podvm-warm-pool-12345678"
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	}
+	url := fmt.Sprintf("https://%s%s", listener.Addr(), ProvisionURLPath)
+
+	// A client that is not trusted by the provision config is rejected
+	if _, err := client(otherCert, otherKey).Get(url); err == nil {
+		t.Fatalf("expected an error from an untrusted client")
+	}
+
+	c := client(clientCert, clientKey)
+
+	res, err := c.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("GET %s returned %d, want %d", url, res.StatusCode, http.StatusNoContent)
+	}
+
+	put := func(body string) int {
+		req, err := http.NewRequest(http.MethodPut, url, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("PUT %s failed: %v", url, err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	if status := put("write_files: [unknown]"); status != http.StatusBadRequest {
+		t.Fatalf("PUT with an invalid cloud config returned %d, want %d", status, http.StatusBadRequest)
+	}
+
+	content := fmt.Sprintf(`#cloud-config
+write_files:
+- path: %s
+  content: |
+%s
+- path: %s
+  content: |
+%s
+- path: %s
+  content: |
+    {}
+`,
+		authPath,
+		indentTextBlock(testAuthJSON, 4),
+		initdataPath,
+		indentTextBlock(ccInitData, 4),
+		provisionPath,
+	)
+	if status := put(content); status != http.StatusNoContent {
+		t.Fatalf("PUT with a cloud config returned %d, want %d", status, http.StatusNoContent)
+	}
+
+	if err := <-errCh; err != nil {
+		t.Fatalf("serveCloudConfig returned err: %v", err)
+	}
+
+	data, _ := os.ReadFile(authPath)
+	if string(data) != testAuthJSON {
+		t.Fatalf("file content does not match auth json fixture: got %q", string(data))
+	}
+
+	data, _ = os.ReadFile(aaPath)
+	if string(data) != testAAConfig {
+		t.Fatalf("file content does not match aa config fixture: got %q", string(data))
+	}
+
+	data, _ = os.ReadFile(digestPath)
+	if string(data) != testCheckSum {
+		t.Fatalf("initdata digest %s does not match %s", string(data), testCheckSum)
+	}
+
+	if _, err := os.Stat(provisionPath); !os.IsNotExist(err) {
+		t.Fatalf("provision config was not removed: %v", err)
+	}
+
+	// The endpoint is closed once a cloud config is provisioned
+	if _, err := c.Get(url); err == nil {
+		t.Fatalf("expected an error from a closed provision endpoint")
+	}
+}
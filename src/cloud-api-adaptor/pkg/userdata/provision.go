@@ -38,13 +38,14 @@ const (
 )
 
 var logger = log.New(log.Writer(), "[userdata/provision] ", log.LstdFlags|log.Lmsgprefix)
-var WriteFilesList = []string{paths.AACfgPath, paths.CDHCfgPath, paths.ForwarderCfgPath, paths.AuthFilePath, paths.InitDataPath, paths.ScratchSpacePath}
+var WriteFilesList = []string{paths.AACfgPath, paths.CDHCfgPath, paths.ForwarderCfgPath, paths.AuthFilePath, paths.InitDataPath, paths.ScratchSpacePath, paths.ProvisionCfgPath}
 var InitdDataFilesList = []string{paths.AACfgPath, paths.CDHCfgPath, PolicyPath}
 
 type Config struct {
 	fetchTimeout  int
 	digestPath    string
 	initdataPath  string
+	provisionPath string
 	parentPath    string
 	writeFiles    []string
 	initdataFiles []string
@@ -55,6 +56,7 @@ func NewConfig(fetchTimeout int) *Config {
 		fetchTimeout:  fetchTimeout,
 		parentPath:    ConfigParent,
 		initdataPath:  paths.InitDataPath,
+		provisionPath: paths.ProvisionCfgPath,
 		digestPath:    DigestPath,
 		writeFiles:    WriteFilesList,
 		initdataFiles: InitdDataFilesList,
@@ -324,6 +326,14 @@ func ProvisionFiles(cfg *Config) error {
 		logger.Printf("unsupported user data provider, we extract and calculate initdata hash only.\n")
 	}
 
+	// warm pool VMs receive the per-pod config files from cloud-api-adaptor after boot
+	if _, err := os.Stat(cfg.provisionPath); err == nil {
+		if err := receiveCloudConfig(bg, cfg); err != nil {
+			return fmt.Errorf("failed to receive cloud config: %w", err)
+		}
+		return nil
+	}
+
 	if err := extractInitdataAndHash(cfg); err != nil {
 		return fmt.Errorf("failed to extract initdata hash: %w", err)
 	}
@@ -7,16 +7,74 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"net"
 	"sync"
+	"time"
 
+	retry "github.com/avast/retry-go/v4"
 	"github.com/containerd/ttrpc"
 	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/agent/protocols"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/tracing"
+
 	pb "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/agent/protocols/grpc"
 )
 
+var logger = log.New(log.Writer(), "[util/agentproto] ", log.LstdFlags|log.Lmsgprefix)
+
+const (
+	redialAttempts   = 3
+	redialDelay      = 500 * time.Millisecond
+	redialMaxDelay   = 5 * time.Second
+	keepaliveTimeout = 10 * time.Second
+)
+
+// idempotentMethods are the agent requests that are retried on a new connection when the connection
+// to the agent is lost while they are in flight. The other requests may have been processed by the agent,
+// so they fail with a RetryableError instead.
+var idempotentMethods = map[string]bool{
+	"Check":             true,
+	"Version":           true,
+	"StatsContainer":    true,
+	"ReadStdout":        true,
+	"ReadStderr":        true,
+	"ListInterfaces":    true,
+	"ListRoutes":        true,
+	"GetIPTables":       true,
+	"GetMetrics":        true,
+	"GetGuestDetails":   true,
+	"GetOOMEvent":       true,
+	"GetVolumeStats":    true,
+	"GetDiagnosticData": true,
+}
+
+// ErrRedirectorClosed is returned by requests made after the redirector is closed
+var ErrRedirectorClosed = errors.New("agent redirector is closed")
+
+// RetryableError is returned by a request that is not safe to repeat when the connection to the agent
+// is lost while it is in flight. The agent may or may not have processed the request. The connection is
+// re-established by the next request. The error is reported with the gRPC code Unavailable.
+type RetryableError struct {
+	Method string
+	Err    error
+}
+
+func (e *RetryableError) Error() string {
+	return fmt.Sprintf("agent connection was lost during %s, the request can be retried: %v", e.Method, e.Err)
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RetryableError) GRPCStatus() *status.Status {
+	return status.New(codes.Unavailable, e.Error())
+}
+
 type Redirector interface {
 	pb.AgentServiceService
 	pb.HealthService
@@ -25,415 +83,429 @@ type Redirector interface {
 	Close() error
 }
 
+// RedirectorOption configures a redirector
+type RedirectorOption func(*redirector)
+
+// WithKeepalive makes the redirector check the health of the agent connection at the interval.
+// A connection that fails the health check is closed and re-established.
+func WithKeepalive(interval time.Duration) RedirectorOption {
+	return func(s *redirector) {
+		s.keepaliveInterval = interval
+	}
+}
+
+// Auditor records the requests sent by a redirector along with their results
+type Auditor interface {
+	Record(method string, req any, err error)
+}
+
+// WithAuditor makes the redirector record each request in the auditor
+func WithAuditor(auditor Auditor) RedirectorOption {
+	return func(s *redirector) {
+		s.auditor = auditor
+	}
+}
+
 type redirector struct {
+	dialer            func(context.Context) (net.Conn, error)
+	keepaliveInterval time.Duration
+	auditor           Auditor
+
+	mutex       sync.Mutex
 	agentClient *client
-	ttrpcClient *ttrpc.Client
-	dialer      func(context.Context) (net.Conn, error)
-	once        sync.Once
+	connected   bool
+	closed      bool
+	stopCh      chan struct{}
+	stopOnce    sync.Once
 }
 
 type client struct {
 	pb.AgentServiceService
 	pb.HealthService
+
+	ttrpcClient *ttrpc.Client
+	// done is closed when the ttrpc client is closed, either explicitly or because the connection is lost
+	done chan struct{}
+}
+
+func (c *client) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
 }
 
-func NewRedirector(dialer func(context.Context) (net.Conn, error)) Redirector {
+func NewRedirector(dialer func(context.Context) (net.Conn, error), opts ...RedirectorOption) Redirector {
 
-	return &redirector{
+	s := &redirector{
 		dialer: dialer,
+		stopCh: make(chan struct{}),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *redirector) Connect(ctx context.Context) error {
 
-	var err error
+	if _, err := s.connect(ctx); err != nil {
+		return fmt.Errorf("agent connection is not established: %w", err)
+	}
+	return nil
+}
+
+// connect returns the client of the current agent connection. If there is no connection, or the connection
+// is lost, a new connection is established. Reconnection is retried with backoff.
+func (s *redirector) connect(ctx context.Context) (*client, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil, ErrRedirectorClosed
+	}
+
+	if s.agentClient != nil {
+		if !s.agentClient.isClosed() {
+			return s.agentClient, nil
+		}
+		logger.Print("agent connection is lost, reconnecting")
+		s.agentClient = nil
+	}
+
+	attempts := uint(1)
+	if s.connected {
+		attempts = redialAttempts
+	}
+
+	var conn net.Conn
+	err := retry.Do(
+		func() error {
+			var err error
+			conn, err = s.dialer(ctx)
+			return err
+		},
+		retry.Attempts(attempts),
+		retry.Context(ctx),
+		retry.Delay(redialDelay),
+		retry.MaxDelay(redialMaxDelay),
+		retry.LastErrorOnly(true),
+		retry.OnRetry(func(n uint, err error) {
+			logger.Printf("Retrying failed agent reconnection (attempt %d): %v", n+1, err)
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	ttrpcClient := ttrpc.NewClient(conn,
+		ttrpc.WithUnaryClientInterceptor(tracing.UnaryClientInterceptor()),
+		ttrpc.WithOnClose(func() { close(done) }),
+	)
+
+	s.agentClient = &client{
+		AgentServiceService: pb.NewAgentServiceClient(ttrpcClient),
+		HealthService:       pb.NewHealthClient(ttrpcClient),
+		ttrpcClient:         ttrpcClient,
+		done:                done,
+	}
+
+	if !s.connected {
+		s.connected = true
+		if s.keepaliveInterval > 0 {
+			go s.keepalive()
+		}
+	}
+
+	return s.agentClient, nil
+}
+
+// disconnect closes the connection of c, so that the next request establishes a new connection
+func (s *redirector) disconnect(c *client) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	s.once.Do(func() {
+	if err := c.ttrpcClient.Close(); err != nil {
+		logger.Printf("error closing agent connection: %v", err)
+	}
+	if s.agentClient == c {
+		s.agentClient = nil
+	}
+}
+
+// keepalive checks the health of the agent connection until the redirector is closed.
+// Connections that are lost or fail the health check are re-established.
+func (s *redirector) keepalive() {
+	ticker := time.NewTicker(s.keepaliveInterval)
+	defer ticker.Stop()
 
-		conn, e := s.dialer(ctx)
-		if e != nil {
-			err = e
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stopCh
+		cancel()
+	}()
+
+	for {
+		select {
+		case <-s.stopCh:
 			return
+		case <-ticker.C:
 		}
 
-		s.ttrpcClient = ttrpc.NewClient(conn)
+		c, err := s.connect(ctx)
+		if err != nil {
+			if !errors.Is(err, ErrRedirectorClosed) && ctx.Err() == nil {
+				logger.Printf("agent keepalive failed to reconnect: %v", err)
+			}
+			continue
+		}
 
-		s.agentClient = &client{
-			AgentServiceService: pb.NewAgentServiceClient(s.ttrpcClient),
-			HealthService:       pb.NewHealthClient(s.ttrpcClient),
+		checkCtx, checkCancel := context.WithTimeout(ctx, keepaliveTimeout)
+		_, err = c.Check(checkCtx, &pb.CheckRequest{})
+		checkCancel()
+		if err != nil && ctx.Err() == nil {
+			logger.Printf("agent keepalive health check failed, closing the connection: %v", err)
+			s.disconnect(c)
 		}
+	}
+}
+
+func (s *redirector) Close() error {
+	// Stop the keepalive before waiting for a reconnection it may be making
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
 	})
 
-	if err != nil {
-		return fmt.Errorf("agent connection is not established: %w", err)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil
 	}
+	s.closed = true
 
 	if s.agentClient == nil {
-		return errors.New("agent connection is not established")
+		return nil
 	}
+	return s.agentClient.ttrpcClient.Close()
+}
 
-	return nil
+// isConnectionLost reports whether a request failed because the connection of c was lost
+func isConnectionLost(c *client, err error) bool {
+	return errors.Is(err, ttrpc.ErrClosed) || c.isClosed()
 }
 
-func (s *redirector) Close() error {
-	client := s.ttrpcClient
-	if client == nil {
-		return nil
+// invoke sends a request to the agent. When the connection is lost while the request is in flight,
+// an idempotent request is retried once on a new connection, and other requests fail with a RetryableError.
+func invoke[Req, Res any](ctx context.Context, s *redirector, method string, req Req, call func(*client, context.Context, Req) (Res, error)) (res Res, err error) {
+	if s.auditor != nil {
+		defer func() {
+			s.auditor.Record(method, req, err)
+		}()
+	}
+
+	var zero Res
+
+	for attempt := 0; ; attempt++ {
+		c, err := s.connect(ctx)
+		if err != nil {
+			return zero, fmt.Errorf("agent connection is not established: %w", err)
+		}
+
+		res, err := call(c, ctx, req)
+		if err == nil || ctx.Err() != nil || !isConnectionLost(c, err) {
+			return res, err
+		}
+
+		s.disconnect(c)
+
+		if !idempotentMethods[method] || attempt > 0 {
+			return zero, &RetryableError{Method: method, Err: err}
+		}
+		logger.Printf("agent connection was lost during %s, retrying: %v", method, err)
 	}
-	return client.Close()
 }
 
 // AgentServiceService methods
 
 func (s *redirector) CreateContainer(ctx context.Context, req *pb.CreateContainerRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.CreateContainer(ctx, req)
+	return invoke(ctx, s, "CreateContainer", req, (*client).CreateContainer)
 }
 
 func (s *redirector) StartContainer(ctx context.Context, req *pb.StartContainerRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.StartContainer(ctx, req)
+	return invoke(ctx, s, "StartContainer", req, (*client).StartContainer)
 }
 
 func (s *redirector) RemoveContainer(ctx context.Context, req *pb.RemoveContainerRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.RemoveContainer(ctx, req)
+	return invoke(ctx, s, "RemoveContainer", req, (*client).RemoveContainer)
 }
 
 func (s *redirector) ExecProcess(ctx context.Context, req *pb.ExecProcessRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.ExecProcess(ctx, req)
+	return invoke(ctx, s, "ExecProcess", req, (*client).ExecProcess)
 }
 
 func (s *redirector) SignalProcess(ctx context.Context, req *pb.SignalProcessRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.SignalProcess(ctx, req)
+	return invoke(ctx, s, "SignalProcess", req, (*client).SignalProcess)
 }
 
 func (s *redirector) WaitProcess(ctx context.Context, req *pb.WaitProcessRequest) (res *pb.WaitProcessResponse, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.WaitProcess(ctx, req)
+	return invoke(ctx, s, "WaitProcess", req, (*client).WaitProcess)
 }
 
 func (s *redirector) UpdateContainer(ctx context.Context, req *pb.UpdateContainerRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.UpdateContainer(ctx, req)
+	return invoke(ctx, s, "UpdateContainer", req, (*client).UpdateContainer)
 }
 
 func (s *redirector) UpdateEphemeralMounts(ctx context.Context, req *pb.UpdateEphemeralMountsRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.UpdateEphemeralMounts(ctx, req)
+	return invoke(ctx, s, "UpdateEphemeralMounts", req, (*client).UpdateEphemeralMounts)
 }
 
 func (s *redirector) StatsContainer(ctx context.Context, req *pb.StatsContainerRequest) (res *pb.StatsContainerResponse, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.StatsContainer(ctx, req)
+	return invoke(ctx, s, "StatsContainer", req, (*client).StatsContainer)
 }
 
 func (s *redirector) PauseContainer(ctx context.Context, req *pb.PauseContainerRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.PauseContainer(ctx, req)
+	return invoke(ctx, s, "PauseContainer", req, (*client).PauseContainer)
 }
 
 func (s *redirector) ResumeContainer(ctx context.Context, req *pb.ResumeContainerRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.ResumeContainer(ctx, req)
+	return invoke(ctx, s, "ResumeContainer", req, (*client).ResumeContainer)
 }
 
 func (s *redirector) RemoveStaleVirtiofsShareMounts(ctx context.Context, req *pb.RemoveStaleVirtiofsShareMountsRequest) (res *emptypb.Empty, err error) {
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.RemoveStaleVirtiofsShareMounts(ctx, req)
+	return invoke(ctx, s, "RemoveStaleVirtiofsShareMounts", req, (*client).RemoveStaleVirtiofsShareMounts)
 }
 
 func (s *redirector) WriteStdin(ctx context.Context, req *pb.WriteStreamRequest) (res *pb.WriteStreamResponse, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.WriteStdin(ctx, req)
+	return invoke(ctx, s, "WriteStdin", req, (*client).WriteStdin)
 }
 
 func (s *redirector) ReadStdout(ctx context.Context, req *pb.ReadStreamRequest) (res *pb.ReadStreamResponse, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.ReadStdout(ctx, req)
+	return invoke(ctx, s, "ReadStdout", req, (*client).ReadStdout)
 }
 
 func (s *redirector) ReadStderr(ctx context.Context, req *pb.ReadStreamRequest) (res *pb.ReadStreamResponse, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.ReadStderr(ctx, req)
+	return invoke(ctx, s, "ReadStderr", req, (*client).ReadStderr)
 }
 
 func (s *redirector) CloseStdin(ctx context.Context, req *pb.CloseStdinRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.CloseStdin(ctx, req)
+	return invoke(ctx, s, "CloseStdin", req, (*client).CloseStdin)
 }
 
 func (s *redirector) TtyWinResize(ctx context.Context, req *pb.TtyWinResizeRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.TtyWinResize(ctx, req)
+	return invoke(ctx, s, "TtyWinResize", req, (*client).TtyWinResize)
 }
 
 func (s *redirector) UpdateInterface(ctx context.Context, req *pb.UpdateInterfaceRequest) (res *protocols.Interface, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.UpdateInterface(ctx, req)
+	return invoke(ctx, s, "UpdateInterface", req, (*client).UpdateInterface)
 }
 
 func (s *redirector) UpdateRoutes(ctx context.Context, req *pb.UpdateRoutesRequest) (res *pb.Routes, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.UpdateRoutes(ctx, req)
+	return invoke(ctx, s, "UpdateRoutes", req, (*client).UpdateRoutes)
 }
 
 func (s *redirector) ListInterfaces(ctx context.Context, req *pb.ListInterfacesRequest) (res *pb.Interfaces, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.ListInterfaces(ctx, req)
+	return invoke(ctx, s, "ListInterfaces", req, (*client).ListInterfaces)
 }
 
 func (s *redirector) ListRoutes(ctx context.Context, req *pb.ListRoutesRequest) (res *pb.Routes, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.ListRoutes(ctx, req)
+	return invoke(ctx, s, "ListRoutes", req, (*client).ListRoutes)
 }
 
 func (s *redirector) AddARPNeighbors(ctx context.Context, req *pb.AddARPNeighborsRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.AddARPNeighbors(ctx, req)
+	return invoke(ctx, s, "AddARPNeighbors", req, (*client).AddARPNeighbors)
 }
 
 func (s *redirector) GetIPTables(ctx context.Context, req *pb.GetIPTablesRequest) (res *pb.GetIPTablesResponse, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.GetIPTables(ctx, req)
+	return invoke(ctx, s, "GetIPTables", req, (*client).GetIPTables)
 }
 
 func (s *redirector) SetIPTables(ctx context.Context, req *pb.SetIPTablesRequest) (res *pb.SetIPTablesResponse, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.SetIPTables(ctx, req)
+	return invoke(ctx, s, "SetIPTables", req, (*client).SetIPTables)
 }
 
 func (s *redirector) GetMetrics(ctx context.Context, req *pb.GetMetricsRequest) (res *pb.Metrics, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.GetMetrics(ctx, req)
+	return invoke(ctx, s, "GetMetrics", req, (*client).GetMetrics)
 }
 
 func (s *redirector) MemAgentMemcgSet(ctx context.Context, req *pb.MemAgentMemcgConfig) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.MemAgentMemcgSet(ctx, req)
+	return invoke(ctx, s, "MemAgentMemcgSet", req, (*client).MemAgentMemcgSet)
 }
 
 func (s *redirector) MemAgentCompactSet(ctx context.Context, req *pb.MemAgentCompactConfig) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.MemAgentCompactSet(ctx, req)
+	return invoke(ctx, s, "MemAgentCompactSet", req, (*client).MemAgentCompactSet)
 }
 
 func (s *redirector) CreateSandbox(ctx context.Context, req *pb.CreateSandboxRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.CreateSandbox(ctx, req)
+	return invoke(ctx, s, "CreateSandbox", req, (*client).CreateSandbox)
 }
 
 func (s *redirector) DestroySandbox(ctx context.Context, req *pb.DestroySandboxRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.DestroySandbox(ctx, req)
+	return invoke(ctx, s, "DestroySandbox", req, (*client).DestroySandbox)
 }
 
 func (s *redirector) OnlineCPUMem(ctx context.Context, req *pb.OnlineCPUMemRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.OnlineCPUMem(ctx, req)
+	return invoke(ctx, s, "OnlineCPUMem", req, (*client).OnlineCPUMem)
 }
 
 func (s *redirector) ReseedRandomDev(ctx context.Context, req *pb.ReseedRandomDevRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.ReseedRandomDev(ctx, req)
+	return invoke(ctx, s, "ReseedRandomDev", req, (*client).ReseedRandomDev)
 }
 
 func (s *redirector) GetGuestDetails(ctx context.Context, req *pb.GuestDetailsRequest) (res *pb.GuestDetailsResponse, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.GetGuestDetails(ctx, req)
+	return invoke(ctx, s, "GetGuestDetails", req, (*client).GetGuestDetails)
 }
 
 func (s *redirector) MemHotplugByProbe(ctx context.Context, req *pb.MemHotplugByProbeRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.MemHotplugByProbe(ctx, req)
+	return invoke(ctx, s, "MemHotplugByProbe", req, (*client).MemHotplugByProbe)
 }
 
 func (s *redirector) SetGuestDateTime(ctx context.Context, req *pb.SetGuestDateTimeRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.SetGuestDateTime(ctx, req)
+	return invoke(ctx, s, "SetGuestDateTime", req, (*client).SetGuestDateTime)
 }
 
 func (s *redirector) CopyFile(ctx context.Context, req *pb.CopyFileRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.CopyFile(ctx, req)
+	return invoke(ctx, s, "CopyFile", req, (*client).CopyFile)
 }
 
 func (s *redirector) GetOOMEvent(ctx context.Context, req *pb.GetOOMEventRequest) (res *pb.OOMEvent, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.GetOOMEvent(ctx, req)
+	return invoke(ctx, s, "GetOOMEvent", req, (*client).GetOOMEvent)
 }
 
 func (s *redirector) AddSwap(ctx context.Context, req *pb.AddSwapRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.AddSwap(ctx, req)
+	return invoke(ctx, s, "AddSwap", req, (*client).AddSwap)
 }
 
 func (s *redirector) AddSwapPath(ctx context.Context, req *pb.AddSwapPathRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.AddSwapPath(ctx, req)
+	return invoke(ctx, s, "AddSwapPath", req, (*client).AddSwapPath)
 }
 
 func (s *redirector) GetVolumeStats(ctx context.Context, req *pb.VolumeStatsRequest) (res *pb.VolumeStatsResponse, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.GetVolumeStats(ctx, req)
+	return invoke(ctx, s, "GetVolumeStats", req, (*client).GetVolumeStats)
 }
 
 func (s *redirector) ResizeVolume(ctx context.Context, req *pb.ResizeVolumeRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.ResizeVolume(ctx, req)
+	return invoke(ctx, s, "ResizeVolume", req, (*client).ResizeVolume)
 }
 
 func (s *redirector) SetPolicy(ctx context.Context, req *pb.SetPolicyRequest) (res *emptypb.Empty, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.SetPolicy(ctx, req)
+	return invoke(ctx, s, "SetPolicy", req, (*client).SetPolicy)
 }
 
 func (s *redirector) GetDiagnosticData(ctx context.Context, req *pb.GetDiagnosticDataRequest) (res *pb.GetDiagnosticDataResponse, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.GetDiagnosticData(ctx, req)
+	return invoke(ctx, s, "GetDiagnosticData", req, (*client).GetDiagnosticData)
 }
 
 // HealthService methods
 
 func (s *redirector) Check(ctx context.Context, req *pb.CheckRequest) (res *pb.HealthCheckResponse, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.Check(ctx, req)
+	return invoke(ctx, s, "Check", req, (*client).Check)
 }
 
 func (s *redirector) Version(ctx context.Context, req *pb.CheckRequest) (res *pb.VersionCheckResponse, err error) {
-
-	if err := s.Connect(ctx); err != nil {
-		return nil, err
-	}
-	return s.agentClient.Version(ctx, req)
+	return invoke(ctx, s, "Version", req, (*client).Version)
 }
@@ -0,0 +1,195 @@
+// Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package agentproto
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containerd/ttrpc"
+	pb "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/agent/protocols/grpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type mockHealth struct{}
+
+func (mockHealth) Check(ctx context.Context, req *pb.CheckRequest) (*pb.HealthCheckResponse, error) {
+	return &pb.HealthCheckResponse{}, nil
+}
+
+func (mockHealth) Version(ctx context.Context, req *pb.CheckRequest) (*pb.VersionCheckResponse, error) {
+	return &pb.VersionCheckResponse{}, nil
+}
+
+// pipeDialer serves each dialed connection with a ttrpc server that implements the health service
+type pipeDialer struct {
+	mutex sync.Mutex
+	conns []net.Conn
+}
+
+func (d *pipeDialer) dial(ctx context.Context) (net.Conn, error) {
+	clientConn, serverConn := net.Pipe()
+
+	server, err := ttrpc.NewServer()
+	if err != nil {
+		return nil, err
+	}
+	pb.RegisterHealthService(server, mockHealth{})
+	go func() {
+		_ = server.Serve(context.Background(), &singleConnListener{conn: serverConn})
+	}()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.conns = append(d.conns, serverConn)
+
+	return clientConn, nil
+}
+
+func (d *pipeDialer) dials() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return len(d.conns)
+}
+
+// drop closes the server side of the latest connection
+func (d *pipeDialer) drop() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.conns[len(d.conns)-1].Close()
+}
+
+type singleConnListener struct {
+	conn net.Conn
+	once sync.Once
+	done chan struct{}
+}
+
+func (l *singleConnListener) Accept() (net.Conn, error) {
+	var conn net.Conn
+	l.once.Do(func() {
+		l.done = make(chan struct{})
+		conn = l.conn
+	})
+	if conn != nil {
+		return conn, nil
+	}
+	<-l.done
+	return nil, net.ErrClosed
+}
+
+func (l *singleConnListener) Close() error {
+	return nil
+}
+
+func (l *singleConnListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+func TestRedirectorReconnect(t *testing.T) {
+	ctx := context.Background()
+	d := &pipeDialer{}
+	s := NewRedirector(d.dial).(*redirector)
+	defer s.Close()
+
+	require.NoError(t, s.Connect(ctx))
+	assert.Equal(t, 1, d.dials())
+
+	// loseConnection is a request that fails because the connection drops while it is in flight
+	loseConnection := func(c *client, ctx context.Context, req *pb.CheckRequest) (*emptypb.Empty, error) {
+		if d.dials() == 1 {
+			d.drop()
+			<-c.done
+			return nil, ttrpc.ErrClosed
+		}
+		return &emptypb.Empty{}, nil
+	}
+
+	// An idempotent request is retried on a new connection
+	_, err := invoke(ctx, s, "StatsContainer", &pb.CheckRequest{}, loseConnection)
+	require.NoError(t, err)
+	assert.Equal(t, 2, d.dials())
+
+	// Other requests fail with a retryable error, and the next request reconnects
+	loseConnection = func(c *client, ctx context.Context, req *pb.CheckRequest) (*emptypb.Empty, error) {
+		if d.dials() == 2 {
+			d.drop()
+			<-c.done
+			return nil, ttrpc.ErrClosed
+		}
+		return &emptypb.Empty{}, nil
+	}
+	_, err = invoke(ctx, s, "CreateContainer", &pb.CheckRequest{}, loseConnection)
+	var retryable *RetryableError
+	require.ErrorAs(t, err, &retryable)
+	assert.Equal(t, "CreateContainer", retryable.Method)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	_, err = invoke(ctx, s, "CreateContainer", &pb.CheckRequest{}, loseConnection)
+	require.NoError(t, err)
+	assert.Equal(t, 3, d.dials())
+
+	// Errors of the agent are returned as is
+	agentErr := status.Error(codes.NotFound, "container not found")
+	_, err = invoke(ctx, s, "StatsContainer", &pb.CheckRequest{}, func(c *client, ctx context.Context, req *pb.CheckRequest) (*emptypb.Empty, error) {
+		return nil, agentErr
+	})
+	assert.Equal(t, agentErr, err)
+	assert.Equal(t, 3, d.dials())
+
+	require.NoError(t, s.Close())
+	_, err = s.Check(ctx, &pb.CheckRequest{})
+	assert.True(t, errors.Is(err, ErrRedirectorClosed))
+}
+
+func TestRedirectorKeepalive(t *testing.T) {
+	d := &pipeDialer{}
+	s := NewRedirector(d.dial, WithKeepalive(10*time.Millisecond))
+	defer s.Close()
+
+	require.NoError(t, s.Connect(context.Background()))
+
+	// A lost connection is re-established without a request
+	d.drop()
+	require.Eventually(t, func() bool { return d.dials() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, s.Close())
+}
+
+type mockAuditor struct {
+	methods []string
+	errs    []error
+}
+
+func (a *mockAuditor) Record(method string, req any, err error) {
+	a.methods = append(a.methods, method)
+	a.errs = append(a.errs, err)
+}
+
+func TestRedirectorAuditor(t *testing.T) {
+	ctx := context.Background()
+	d := &pipeDialer{}
+	auditor := &mockAuditor{}
+	s := NewRedirector(d.dial, WithAuditor(auditor))
+	defer s.Close()
+
+	_, err := s.Check(ctx, &pb.CheckRequest{})
+	require.NoError(t, err)
+
+	// Requests that fail are recorded along with the error
+	_, err = s.StatsContainer(ctx, &pb.StatsContainerRequest{})
+	require.Error(t, err)
+
+	assert.Equal(t, []string{"Check", "StatsContainer"}, auditor.methods)
+	assert.NoError(t, auditor.errs[0])
+	assert.Equal(t, err, auditor.errs[1])
+}
@@ -0,0 +1,64 @@
+// Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package agentproto
+
+import (
+	"path"
+	"reflect"
+
+	pb "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/agent/protocols/grpc"
+)
+
+// RequestInfo is the information of an agent request that is relevant to auditing and policies
+type RequestInfo struct {
+	ContainerID string
+	ExecID      string
+	// Command is the command line of ExecProcess
+	Command []string
+	// Path is the file path of CopyFile
+	Path string
+}
+
+// DescribeRequest returns the information of an agent request
+func DescribeRequest(req any) RequestInfo {
+	info := RequestInfo{
+		ContainerID: stringField(req, "ContainerId"),
+		ExecID:      stringField(req, "ExecId"),
+	}
+
+	switch r := req.(type) {
+	case *pb.ExecProcessRequest:
+		if r.Process != nil {
+			info.Command = r.Process.Args
+		}
+	case *pb.CopyFileRequest:
+		info.Path = r.Path
+	}
+
+	return info
+}
+
+// MethodName returns the agent API method name of a full ttrpc method name, such as /grpc.AgentService/ExecProcess
+func MethodName(fullMethod string) string {
+	return path.Base(fullMethod)
+}
+
+// stringField returns the value of a string field of a request, or an empty string if there is no such field
+func stringField(req any, name string) string {
+	v := reflect.ValueOf(req)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	f := v.FieldByName(name)
+	if !f.IsValid() || f.Kind() != reflect.String {
+		return ""
+	}
+	return f.String()
+}
@@ -0,0 +1,217 @@
+// Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+var logger = log.New(log.Writer(), "[util/attestation] ", log.LstdFlags|log.Lmsgprefix)
+
+const (
+	// SampleAttester and SampleVerifier produce and accept evidence without a TEE. They are for testing only.
+	SampleAttester = "sample"
+	SampleVerifier = "sample"
+
+	// AttestationAgentAttester gets evidence from the attestation agent of the pod VM
+	AttestationAgentAttester = "attestation-agent"
+
+	// DefaultAttestationAgentURL is the evidence endpoint of the attestation agent, served by api-server-rest
+	DefaultAttestationAgentURL = "http://127.0.0.1:8006/aa/evidence"
+
+	sampleTEE = "sample"
+)
+
+// Evidence is evidence of a TEE along with the TEE type
+type Evidence struct {
+	TEE      string `json:"tee"`
+	Evidence []byte `json:"evidence"`
+}
+
+// Attester produces evidence of the TEE that a pod VM runs in
+type Attester interface {
+	// GetEvidence returns evidence whose report data is bound to runtimeData
+	GetEvidence(ctx context.Context, runtimeData []byte) (*Evidence, error)
+}
+
+// Verifier appraises evidence of pod VMs
+type Verifier interface {
+	// Verify returns an error unless the evidence is genuine and its report data is bound to runtimeData
+	Verify(ctx context.Context, evidence *Evidence, runtimeData []byte) error
+	// Attester returns the name of the attester that produces evidence this verifier accepts
+	Attester() string
+}
+
+// NewAttester returns the attester with the name
+func NewAttester(name string) (Attester, error) {
+	switch name {
+	case SampleAttester:
+		logger.Printf("WARNING: the sample attester does not provide any security. Use it only for testing")
+		return sampleAttester{}, nil
+	case AttestationAgentAttester:
+		return &attestationAgentAttester{url: DefaultAttestationAgentURL, detectTEE: detectTEE}, nil
+	default:
+		return nil, fmt.Errorf("unknown attester %q", name)
+	}
+}
+
+// NewVerifier returns a verifier for the spec, which is either "sample" or the http(s) URL of a remote verifier
+func NewVerifier(spec string) (Verifier, error) {
+	if spec == SampleVerifier {
+		logger.Printf("WARNING: the sample verifier accepts evidence produced without a TEE. Use it only for testing")
+		return sampleVerifier{}, nil
+	}
+
+	u, err := url.Parse(spec)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("verifier must be %q or an http(s) URL: %q", SampleVerifier, spec)
+	}
+	return &remoteVerifier{url: u.String(), client: http.DefaultClient}, nil
+}
+
+// sampleEvidence has the format of the evidence of the sample attester of guest-components
+type sampleEvidence struct {
+	SVN        string `json:"svn"`
+	ReportData string `json:"report_data"`
+}
+
+type sampleAttester struct{}
+
+func (sampleAttester) GetEvidence(ctx context.Context, runtimeData []byte) (*Evidence, error) {
+	evidence, err := json.Marshal(&sampleEvidence{
+		SVN:        "1",
+		ReportData: base64.StdEncoding.EncodeToString(runtimeData),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Evidence{TEE: sampleTEE, Evidence: evidence}, nil
+}
+
+type sampleVerifier struct{}
+
+func (sampleVerifier) Verify(ctx context.Context, evidence *Evidence, runtimeData []byte) error {
+	if evidence.TEE != sampleTEE {
+		return fmt.Errorf("sample verifier does not accept evidence of TEE %q", evidence.TEE)
+	}
+
+	var sample sampleEvidence
+	if err := json.Unmarshal(evidence.Evidence, &sample); err != nil {
+		return fmt.Errorf("parsing sample evidence: %w", err)
+	}
+	if sample.ReportData != base64.StdEncoding.EncodeToString(runtimeData) {
+		return errors.New("report data of the sample evidence does not match")
+	}
+	return nil
+}
+
+func (sampleVerifier) Attester() string {
+	return SampleAttester
+}
+
+// attestationAgentAttester gets evidence from the REST API of the attestation agent.
+// The attestation agent puts the runtime data in the report data of the evidence.
+type attestationAgentAttester struct {
+	url       string
+	detectTEE func() (string, error)
+}
+
+func (a *attestationAgentAttester) GetEvidence(ctx context.Context, runtimeData []byte) (*Evidence, error) {
+	tee, err := a.detectTEE()
+	if err != nil {
+		return nil, err
+	}
+
+	u := a.url + "?runtime_data=" + url.QueryEscape(base64.RawURLEncoding.EncodeToString(runtimeData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("getting evidence from attestation agent: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading evidence from attestation agent: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting evidence from attestation agent: %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+
+	return &Evidence{TEE: tee, Evidence: body}, nil
+}
+
+// detectTEE returns the TEE type from the guest device of the TEE
+func detectTEE() (string, error) {
+	for _, tee := range []struct{ name, device string }{
+		{"tdx", "/dev/tdx_guest"},
+		{"snp", "/dev/sev-guest"},
+	} {
+		if _, err := os.Stat(tee.device); err == nil {
+			return tee.name, nil
+		}
+	}
+	return "", errors.New("no supported TEE found")
+}
+
+// remoteVerifier sends evidence to a verification service. The service is expected to
+// respond with a 2xx status when the evidence is genuine and bound to the runtime data.
+type remoteVerifier struct {
+	url    string
+	client *http.Client
+}
+
+type verificationRequest struct {
+	TEE         string `json:"tee"`
+	Evidence    []byte `json:"evidence"`
+	RuntimeData []byte `json:"runtime_data"`
+}
+
+func (v *remoteVerifier) Verify(ctx context.Context, evidence *Evidence, runtimeData []byte) error {
+	body, err := json.Marshal(&verificationRequest{
+		TEE:         evidence.TEE,
+		Evidence:    evidence.Evidence,
+		RuntimeData: runtimeData,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending evidence to verifier: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return fmt.Errorf("verifier rejected %s evidence: %s: %s", evidence.TEE, res.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (v *remoteVerifier) Attester() string {
+	return AttestationAgentAttester
+}
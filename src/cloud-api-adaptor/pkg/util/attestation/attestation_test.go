@@ -0,0 +1,172 @@
+// Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package attestation
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testServerName = "podvm-test-12345678"
+
+func parseCertificate(t *testing.T, certPEM []byte) *x509.Certificate {
+	t.Helper()
+
+	block, _ := pem.Decode(certPEM)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestSampleCertificate(t *testing.T) {
+	ctx := context.Background()
+
+	attester, err := NewAttester(SampleAttester)
+	require.NoError(t, err)
+	verifier, err := NewVerifier(SampleVerifier)
+	require.NoError(t, err)
+	assert.Equal(t, SampleAttester, verifier.Attester())
+
+	certPEM, keyPEM, err := NewCertificate(ctx, attester, testServerName)
+	require.NoError(t, err)
+	assert.NotEmpty(t, keyPEM)
+
+	cert := parseCertificate(t, certPEM)
+	assert.Equal(t, []string{testServerName}, cert.DNSNames)
+
+	assert.NoError(t, VerifyCertificate(ctx, verifier, cert, testServerName))
+	assert.Error(t, VerifyCertificate(ctx, verifier, cert, "podvm-other"))
+
+	// Evidence bound to another public key is rejected
+	otherPEM, _, err := NewCertificate(ctx, attester, testServerName)
+	require.NoError(t, err)
+	other := parseCertificate(t, otherPEM)
+	other.RawSubjectPublicKeyInfo = cert.RawSubjectPublicKeyInfo
+	assert.Error(t, VerifyCertificate(ctx, verifier, other, testServerName))
+}
+
+func TestVerifyCertificateWithoutEvidence(t *testing.T) {
+	ctx := context.Background()
+
+	verifier, err := NewVerifier(SampleVerifier)
+	require.NoError(t, err)
+
+	certPEM, _, err := NewCertificate(ctx, sampleAttester{}, testServerName)
+	require.NoError(t, err)
+	cert := parseCertificate(t, certPEM)
+	cert.Extensions = nil
+
+	err = VerifyCertificate(ctx, verifier, cert, testServerName)
+	assert.ErrorContains(t, err, "does not carry evidence")
+}
+
+func TestCMW(t *testing.T) {
+	evidenceJSON := []byte(`{"tee":"sample","evidence":"ZXZpZGVuY2U="}`)
+
+	cmw, err := marshalCMW(evidenceJSON)
+	require.NoError(t, err)
+
+	// The extension value is a UTF8String with a JSON record CMW
+	var record string
+	_, err = asn1.UnmarshalWithParams(cmw, &record, "utf8")
+	require.NoError(t, err)
+	var members []string
+	require.NoError(t, json.Unmarshal([]byte(record), &members))
+	assert.Equal(t, []string{evidenceMediaType, base64.RawURLEncoding.EncodeToString(evidenceJSON)}, members)
+
+	got, err := unmarshalCMW(cmw)
+	require.NoError(t, err)
+	assert.Equal(t, evidenceJSON, got)
+
+	for name, value := range map[string]string{
+		"other type":     `["application/eat+cwt","ZXZpZGVuY2U"]`,
+		"not a record":   `{"type":"x"}`,
+		"too few":        `["` + evidenceMediaType + `"]`,
+		"invalid base64": `["` + evidenceMediaType + `","!"]`,
+	} {
+		cmw, err := asn1.MarshalWithParams(value, "utf8")
+		require.NoError(t, err)
+		_, err = unmarshalCMW(cmw)
+		assert.Error(t, err, name)
+	}
+
+	// Raw evidence JSON, which is not a UTF8String, is rejected
+	_, err = unmarshalCMW(evidenceJSON)
+	assert.Error(t, err)
+}
+
+func TestNewVerifier(t *testing.T) {
+	for _, spec := range []string{"", "unknown", "ftp://verifier", "https://"} {
+		_, err := NewVerifier(spec)
+		assert.Error(t, err, spec)
+	}
+
+	_, err := NewAttester("unknown")
+	assert.Error(t, err)
+}
+
+func TestRemoteVerifier(t *testing.T) {
+	ctx := context.Background()
+	runtimeData := RuntimeData(testServerName, []byte("public key"))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req verificationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Stand in for a verification service with the sample verifier
+		if err := (sampleVerifier{}).Verify(r.Context(), &Evidence{TEE: req.TEE, Evidence: req.Evidence}, req.RuntimeData); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	verifier, err := NewVerifier(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, AttestationAgentAttester, verifier.Attester())
+
+	evidence, err := sampleAttester{}.GetEvidence(ctx, runtimeData)
+	require.NoError(t, err)
+
+	assert.NoError(t, verifier.Verify(ctx, evidence, runtimeData))
+
+	err = verifier.Verify(ctx, evidence, RuntimeData("podvm-other", []byte("public key")))
+	assert.ErrorContains(t, err, "403")
+}
+
+func TestAttestationAgentAttester(t *testing.T) {
+	ctx := context.Background()
+
+	var runtimeData string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runtimeData = r.URL.Query().Get("runtime_data")
+		_, _ = w.Write([]byte("quote"))
+	}))
+	defer server.Close()
+
+	attester := &attestationAgentAttester{
+		url:       server.URL,
+		detectTEE: func() (string, error) { return "tdx", nil },
+	}
+
+	evidence, err := attester.GetEvidence(ctx, []byte{0xff, 0xfe})
+	require.NoError(t, err)
+	assert.Equal(t, "tdx", evidence.TEE)
+	assert.Equal(t, []byte("quote"), evidence.Evidence)
+	assert.Equal(t, "__4", runtimeData)
+}
@@ -0,0 +1,191 @@
+// Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package attestation
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Attested TLS works as follows
+//
+// 1. agent-protocol-forwarder generates a private key inside the TEE, which never leaves the pod VM
+// 2. It gets evidence whose report data is bound to the server name of the pod VM and the public key
+// 3. It creates a self-signed server certificate that carries the evidence in an extension
+// 4. cloud-api-adaptor sends the evidence in the certificate to a verifier during the TLS handshake,
+//    and aborts the connection before any request is forwarded unless the verifier accepts it
+
+// evidenceExtensionOID is the OID of the TCG DICE conceptual message wrapper (CMW) extension, which carries the evidence
+var evidenceExtensionOID = asn1.ObjectIdentifier{2, 23, 133, 5, 4, 9}
+
+// evidenceMediaType is the CMW type of the evidence, which is the JSON encoding of Evidence
+const evidenceMediaType = "application/vnd.confidential-containers.peerpod-evidence+json"
+
+// marshalCMW encodes the evidence as a JSON record CMW, ["<media type>", "<base64url value>"], in the json
+// alternative of the ASN.1 CMW, CHOICE { json UTF8String, cbor OCTET STRING }, as in draft-ietf-rats-msg-wrap
+func marshalCMW(evidenceJSON []byte) ([]byte, error) {
+	record, err := json.Marshal([]string{evidenceMediaType, base64.RawURLEncoding.EncodeToString(evidenceJSON)})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.MarshalWithParams(string(record), "utf8")
+}
+
+// unmarshalCMW returns the evidence in a JSON record CMW created by marshalCMW
+func unmarshalCMW(value []byte) ([]byte, error) {
+	var record string
+	if rest, err := asn1.UnmarshalWithParams(value, &record, "utf8"); err != nil {
+		return nil, fmt.Errorf("parsing CMW: %w", err)
+	} else if len(rest) > 0 {
+		return nil, errors.New("trailing data after CMW")
+	}
+
+	// A JSON record CMW may have a third member with the indicator of the conceptual message type
+	var members []json.RawMessage
+	if err := json.Unmarshal([]byte(record), &members); err != nil {
+		return nil, fmt.Errorf("parsing CMW record: %w", err)
+	}
+	if len(members) != 2 && len(members) != 3 {
+		return nil, fmt.Errorf("CMW record has %d members", len(members))
+	}
+	var mediaType, encoded string
+	if err := json.Unmarshal(members[0], &mediaType); err != nil {
+		return nil, fmt.Errorf("parsing CMW type: %w", err)
+	}
+	if mediaType != evidenceMediaType {
+		return nil, fmt.Errorf("unsupported CMW type %q", mediaType)
+	}
+	if err := json.Unmarshal(members[1], &encoded); err != nil {
+		return nil, fmt.Errorf("parsing CMW value: %w", err)
+	}
+	return base64.RawURLEncoding.DecodeString(encoded)
+}
+
+const certificateValidFor = 2 * 365 * 24 * time.Hour
+
+// RuntimeData returns the data that the report data of the evidence is bound to
+func RuntimeData(serverName string, publicKeyInfo []byte) []byte {
+	h := sha512.New()
+	h.Write([]byte(serverName))
+	h.Write([]byte{0})
+	h.Write(publicKeyInfo)
+	return h.Sum(nil)
+}
+
+// NewCertificate generates a private key and a self-signed server certificate for the server name,
+// which carries evidence of the attester bound to the public key
+func NewCertificate(ctx context.Context, attester Attester, serverName string) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate ECDSA key: %w", err)
+	}
+
+	publicKeyInfo, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal a public key: %w", err)
+	}
+
+	evidence, err := attester.GetEvidence(ctx, RuntimeData(serverName, publicKeyInfo))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get evidence: %w", err)
+	}
+
+	evidenceJSON, err := json.Marshal(evidence)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal evidence: %w", err)
+	}
+	cmw, err := marshalCMW(evidenceJSON)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal evidence extension: %w", err)
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate a serial number of a new certificate: %w", err)
+	}
+
+	notBefore := time.Now().UTC().Add(-5 * time.Minute)
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: serverName},
+		DNSNames:              []string{serverName},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(certificateValidFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		ExtraExtensions: []pkix.Extension{
+			{Id: evidenceExtensionOID, Value: cmw},
+		},
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create a certificate: %w", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to convert a private key to PKCS #8 form: %w", err)
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	return certPEM, keyPEM, nil
+}
+
+// VerifyCertificate checks that the certificate was issued for the server name, and that the verifier
+// accepts the evidence in the certificate as bound to its public key
+func VerifyCertificate(ctx context.Context, verifier Verifier, cert *x509.Certificate, serverName string) error {
+	if err := cert.VerifyHostname(serverName); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return fmt.Errorf("certificate of %s is not valid at %s", serverName, now.UTC().Format(time.RFC3339))
+	}
+
+	var cmw []byte
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(evidenceExtensionOID) {
+			cmw = ext.Value
+			break
+		}
+	}
+	if cmw == nil {
+		return errors.New("certificate does not carry evidence")
+	}
+
+	evidenceJSON, err := unmarshalCMW(cmw)
+	if err != nil {
+		return fmt.Errorf("parsing evidence in certificate: %w", err)
+	}
+
+	var evidence Evidence
+	if err := json.Unmarshal(evidenceJSON, &evidence); err != nil {
+		return fmt.Errorf("parsing evidence in certificate: %w", err)
+	}
+
+	if err := verifier.Verify(ctx, &evidence, RuntimeData(serverName, cert.RawSubjectPublicKeyInfo)); err != nil {
+		return fmt.Errorf("verifying evidence of %s: %w", serverName, err)
+	}
+
+	return nil
+}
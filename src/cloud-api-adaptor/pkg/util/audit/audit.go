@@ -0,0 +1,341 @@
+// Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/status"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util/agentproto"
+	putil "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util"
+)
+
+var logger = log.New(log.Writer(), "[util/audit] ", log.LstdFlags|log.Lmsgprefix)
+
+// Level is the verbosity of the audit records of an agent request
+type Level int
+
+const (
+	// LevelNone does not record the request
+	LevelNone Level = iota
+	// LevelMetadata records the request name, container and exec IDs, exec command line,
+	// copied file path, result and time
+	LevelMetadata
+	// LevelRequest additionally records the request payload, with sensitive fields redacted
+	LevelRequest
+)
+
+var levelNames = map[string]Level{
+	"none":     LevelNone,
+	"metadata": LevelMetadata,
+	"request":  LevelRequest,
+}
+
+const redacted = putil.Replacement
+
+// MinKeySize is the minimum size of the HMAC key of audit records in bytes
+const MinKeySize = 32
+
+// sensitiveFields are the request fields whose values are redacted in the recorded payload.
+// Names are matched case insensitively, and environment variables keep their names.
+var sensitiveFields = map[string]bool{
+	"data":     true,
+	"env":      true,
+	"policy":   true,
+	"initdata": true,
+}
+
+// Levels are the verbosity levels of agent requests
+type Levels struct {
+	Default Level
+	Methods map[string]Level
+}
+
+// ParseLevels parses a comma separated list of <method>=<level> pairs, where the method "*" sets the level of
+// the methods that are not listed, e.g. "*=metadata,ReadStdout=none,ExecProcess=request".
+// The level of methods that are not listed is metadata by default.
+func ParseLevels(spec string) (Levels, error) {
+	levels := Levels{Default: LevelMetadata, Methods: map[string]Level{}}
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		method, name, ok := strings.Cut(item, "=")
+		if !ok {
+			return Levels{}, fmt.Errorf("invalid audit level %q, expected <method>=<level>", item)
+		}
+		level, ok := levelNames[strings.TrimSpace(name)]
+		if !ok {
+			return Levels{}, fmt.Errorf("unknown audit level %q of %s, expected none, metadata or request", name, method)
+		}
+		if method = strings.TrimSpace(method); method == "*" {
+			levels.Default = level
+		} else {
+			levels.Methods[method] = level
+		}
+	}
+	return levels, nil
+}
+
+func (l Levels) of(method string) Level {
+	if level, ok := l.Methods[method]; ok {
+		return level
+	}
+	return l.Default
+}
+
+// Record is an audit record of an agent request forwarded to a pod VM.
+// Records form an HMAC chain, so that removed or modified records are detected by Verify.
+// The HMAC key is kept outside the log, so that records cannot be rewritten with a new valid chain.
+type Record struct {
+	Time        time.Time       `json:"time"`
+	PodVM       string          `json:"pod_vm"`
+	Method      string          `json:"method"`
+	ContainerID string          `json:"container_id,omitempty"`
+	ExecID      string          `json:"exec_id,omitempty"`
+	Command     []string        `json:"command,omitempty"`
+	Path        string          `json:"path,omitempty"`
+	Code        string          `json:"code"`
+	Error       string          `json:"error,omitempty"`
+	Request     json.RawMessage `json:"request,omitempty"`
+	// BrokenLog and LastVerifiedHash are set in the first record of an audit log file that replaces a file
+	// that failed verification. They name the file that was moved aside, and the hash of its last verified record.
+	BrokenLog        string `json:"broken_log,omitempty"`
+	LastVerifiedHash string `json:"last_verified_hash,omitempty"`
+	PrevHash         string `json:"prev_hash"`
+	Hash             string `json:"hash"`
+}
+
+// digest returns the HMAC-SHA256 of the record, which covers all fields except the hash itself
+func (r *Record) digest(key []byte) (string, error) {
+	c := *r
+	c.Hash = ""
+	data, err := json.Marshal(&c)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// LoadKey reads the HMAC key of audit records from a file, such as a mounted Kubernetes Secret.
+// Leading and trailing white space is ignored.
+func LoadKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading audit key: %w", err)
+	}
+	key := bytes.TrimSpace(data)
+	if len(key) < MinKeySize {
+		return nil, fmt.Errorf("audit key in %s is shorter than %d bytes", path, MinKeySize)
+	}
+	return key, nil
+}
+
+// Sink stores audit records
+type Sink interface {
+	Write(record *Record) error
+	Close() error
+}
+
+// Auditor records agent requests in a sink
+type Auditor struct {
+	sink   Sink
+	levels Levels
+	key    []byte
+
+	mutex    sync.Mutex
+	lastHash string
+	now      func() time.Time
+}
+
+// NewAuditor creates an auditor that chains records with the HMAC key. When the sink already has records,
+// such as an existing audit log file, lastHash is the hash of its last record, which new records are chained to.
+func NewAuditor(sink Sink, levels Levels, key []byte, lastHash string) *Auditor {
+	return &Auditor{
+		sink:     sink,
+		levels:   levels,
+		key:      key,
+		lastHash: lastHash,
+		now:      time.Now,
+	}
+}
+
+// ForPodVM returns a recorder of the requests forwarded to the pod VM
+func (a *Auditor) ForPodVM(podVM string) *PodVMAuditor {
+	return &PodVMAuditor{auditor: a, podVM: podVM}
+}
+
+// Close closes the sink
+func (a *Auditor) Close() error {
+	return a.sink.Close()
+}
+
+func (a *Auditor) record(podVM, method string, req any, err error) {
+	level := a.levels.of(method)
+	if level == LevelNone {
+		return
+	}
+
+	record := &Record{
+		PodVM:  podVM,
+		Method: method,
+		Code:   status.Code(err).String(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	info := agentproto.DescribeRequest(req)
+	record.ContainerID = info.ContainerID
+	record.ExecID = info.ExecID
+	record.Command = info.Command
+	record.Path = info.Path
+
+	if level >= LevelRequest {
+		payload, err := redactRequest(req)
+		if err != nil {
+			logger.Printf("failed to record %s request payload: %v", method, err)
+		} else {
+			record.Request = payload
+		}
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	record.Time = a.now().UTC()
+	record.PrevHash = a.lastHash
+	hash, err := record.digest(a.key)
+	if err != nil {
+		logger.Printf("failed to hash audit record of %s: %v", method, err)
+		return
+	}
+	record.Hash = hash
+
+	if err := a.sink.Write(record); err != nil {
+		logger.Printf("failed to write audit record of %s to %s: %v", method, podVM, err)
+		return
+	}
+	a.lastHash = hash
+}
+
+// PodVMAuditor records the requests forwarded to a pod VM
+type PodVMAuditor struct {
+	auditor *Auditor
+	podVM   string
+}
+
+// Record records an agent request and its result
+func (p *PodVMAuditor) Record(method string, req any, err error) {
+	p.auditor.record(p.podVM, method, req, err)
+}
+
+// redactRequest returns the JSON representation of a request with the values of sensitive fields redacted
+func redactRequest(req any) (json.RawMessage, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(redactValue(v))
+}
+
+func redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if !sensitiveFields[strings.ToLower(key)] {
+				v[key] = redactValue(value)
+				continue
+			}
+			if strings.EqualFold(key, "env") {
+				v[key] = redactEnv(value)
+			} else if value != nil {
+				v[key] = redacted
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	}
+	return v
+}
+
+// redactEnv keeps the names of environment variables and redacts their values
+func redactEnv(v any) any {
+	list, ok := v.([]any)
+	if !ok {
+		return redacted
+	}
+	for i, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			list[i] = redacted
+			continue
+		}
+		name, _, _ := strings.Cut(s, "=")
+		list[i] = name + "=" + redacted
+	}
+	return list
+}
+
+// Verify checks the HMAC chain of JSON lines audit records with the key, and returns the hash of the last record.
+// When verification fails, the hash of the last verified record is returned along with the error.
+func Verify(r io.Reader, key []byte) (string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxRecordSize)
+
+	var lastHash string
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return lastHash, fmt.Errorf("parsing audit record %d: %w", n, err)
+		}
+		if record.PrevHash != lastHash {
+			return lastHash, fmt.Errorf("audit record %d is not chained to the previous record", n)
+		}
+		hash, err := record.digest(key)
+		if err != nil {
+			return lastHash, err
+		}
+		if !hmac.Equal([]byte(hash), []byte(record.Hash)) {
+			return lastHash, fmt.Errorf("audit record %d was modified", n)
+		}
+		lastHash = record.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return lastHash, fmt.Errorf("audit record exceeds %d bytes: %w", maxRecordSize, err)
+		}
+		return lastHash, err
+	}
+	return lastHash, nil
+}
@@ -0,0 +1,255 @@
+// Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/agent/protocols/grpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testPodVM = "podvm-test-12345678"
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+// memorySink keeps records in memory
+type memorySink struct {
+	mutex   sync.Mutex
+	records []*Record
+}
+
+func (s *memorySink) Write(record *Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	return nil
+}
+
+func TestParseLevels(t *testing.T) {
+	levels, err := ParseLevels("")
+	require.NoError(t, err)
+	assert.Equal(t, LevelMetadata, levels.of("CreateContainer"))
+
+	levels, err = ParseLevels("*=none, ExecProcess=request,CopyFile=metadata")
+	require.NoError(t, err)
+	assert.Equal(t, LevelNone, levels.of("CreateContainer"))
+	assert.Equal(t, LevelRequest, levels.of("ExecProcess"))
+	assert.Equal(t, LevelMetadata, levels.of("CopyFile"))
+
+	for _, spec := range []string{"ExecProcess", "ExecProcess=verbose"} {
+		_, err := ParseLevels(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestAuditorRecord(t *testing.T) {
+	sink := &memorySink{}
+	levels, err := ParseLevels("ReadStdout=none,SetPolicy=request,ExecProcess=request")
+	require.NoError(t, err)
+	auditor := NewAuditor(sink, levels, testKey, "")
+	auditor.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+	podVM := auditor.ForPodVM(testPodVM)
+
+	podVM.Record("ExecProcess", &pb.ExecProcessRequest{
+		ContainerId: "c1",
+		ExecId:      "e1",
+		Process:     &pb.Process{Args: []string{"sh", "-c", "id"}, Env: []string{"PATH=/bin", "TOKEN=secret"}},
+	}, nil)
+	podVM.Record("CopyFile", &pb.CopyFileRequest{Path: "/etc/hosts", Data: []byte("secret")}, status.Error(codes.PermissionDenied, "denied"))
+	podVM.Record("SetPolicy", &pb.SetPolicyRequest{Policy: "package agent_policy"}, nil)
+	podVM.Record("ReadStdout", &pb.ReadStreamRequest{ContainerId: "c1"}, nil)
+
+	require.Len(t, sink.records, 3)
+
+	exec := sink.records[0]
+	assert.Equal(t, testPodVM, exec.PodVM)
+	assert.Equal(t, "ExecProcess", exec.Method)
+	assert.Equal(t, "c1", exec.ContainerID)
+	assert.Equal(t, "e1", exec.ExecID)
+	assert.Equal(t, []string{"sh", "-c", "id"}, exec.Command)
+	assert.Equal(t, "OK", exec.Code)
+	assert.Equal(t, "2026-01-02T03:04:05Z", exec.Time.Format(time.RFC3339))
+	assert.Contains(t, string(exec.Request), "TOKEN="+redacted)
+	assert.Contains(t, string(exec.Request), "PATH="+redacted)
+	assert.NotContains(t, string(exec.Request), "secret")
+
+	copyFile := sink.records[1]
+	assert.Equal(t, "/etc/hosts", copyFile.Path)
+	assert.Equal(t, "PermissionDenied", copyFile.Code)
+	assert.Contains(t, copyFile.Error, "denied")
+	assert.Empty(t, copyFile.Request)
+
+	policy := sink.records[2]
+	assert.NotContains(t, string(policy.Request), "agent_policy")
+
+	// Records are chained
+	assert.Empty(t, exec.PrevHash)
+	assert.Equal(t, exec.Hash, copyFile.PrevHash)
+	assert.Equal(t, copyFile.Hash, policy.PrevHash)
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pods", FileName)
+
+	auditor, err := New("file", filepath.Dir(path), testKey, Levels{Default: LevelMetadata})
+	require.NoError(t, err)
+	auditor.ForPodVM(testPodVM).Record("CreateContainer", &pb.CreateContainerRequest{ContainerId: "c1"}, nil)
+	require.NoError(t, auditor.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// Records written after a restart continue the hash chain
+	auditor, err = New("file", filepath.Dir(path), testKey, Levels{Default: LevelMetadata})
+	require.NoError(t, err)
+	auditor.ForPodVM(testPodVM).Record("StartContainer", &pb.StartContainerRequest{ContainerId: "c1"}, nil)
+	require.NoError(t, auditor.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+
+	_, err = Verify(bytes.NewReader(data), testKey)
+	assert.NoError(t, err)
+
+	// Records cannot be verified, or rewritten with a valid chain, without the key
+	_, err = Verify(bytes.NewReader(data), []byte("fedcba9876543210fedcba9876543210"))
+	assert.ErrorContains(t, err, "modified")
+
+	// Modified and removed records are detected
+	tampered := strings.Replace(string(data), `"container_id":"c1"`, `"container_id":"c2"`, 1)
+	_, err = Verify(strings.NewReader(tampered), testKey)
+	assert.ErrorContains(t, err, "modified")
+
+	_, err = Verify(strings.NewReader(lines[1]), testKey)
+	assert.ErrorContains(t, err, "not chained")
+}
+
+func TestFileSinkBrokenChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+
+	auditor, err := New("file", filepath.Dir(path), testKey, Levels{Default: LevelMetadata})
+	require.NoError(t, err)
+	auditor.ForPodVM(testPodVM).Record("CreateContainer", &pb.CreateContainerRequest{ContainerId: "c1"}, nil)
+	auditor.ForPodVM(testPodVM).Record("StartContainer", &pb.StartContainerRequest{ContainerId: "c1"}, nil)
+	require.NoError(t, auditor.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var first Record
+	require.NoError(t, json.Unmarshal([]byte(strings.SplitN(string(data), "\n", 2)[0]), &first))
+
+	// Tamper with the second record
+	tampered := strings.Replace(string(data), `"method":"StartContainer"`, `"method":"RemoveContainer"`, 1)
+	require.NoError(t, os.WriteFile(path, []byte(tampered), 0o600))
+
+	// The broken file is moved aside, and the new file starts with a record that names it
+	sink, lastHash, err := NewFileSink(path, testKey)
+	require.NoError(t, err)
+	require.NoError(t, sink.Close())
+
+	broken, err := filepath.Glob(path + ".broken-*")
+	require.NoError(t, err)
+	require.Len(t, broken, 1)
+	moved, err := os.ReadFile(broken[0])
+	require.NoError(t, err)
+	assert.Equal(t, tampered, string(moved))
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	hash, err := Verify(bytes.NewReader(data), testKey)
+	require.NoError(t, err)
+	assert.Equal(t, lastHash, hash)
+
+	var record Record
+	require.NoError(t, json.Unmarshal(bytes.TrimSpace(data), &record))
+	assert.Equal(t, BrokenLogMethod, record.Method)
+	assert.Equal(t, filepath.Base(broken[0]), record.BrokenLog)
+	assert.Equal(t, first.Hash, record.LastVerifiedHash)
+	assert.Contains(t, record.Error, "audit record 2 was modified")
+	assert.Empty(t, record.PrevHash)
+
+	// New records are chained to it
+	auditor, err = New("file", filepath.Dir(path), testKey, Levels{Default: LevelMetadata})
+	require.NoError(t, err)
+	auditor.ForPodVM(testPodVM).Record("StartContainer", &pb.StartContainerRequest{ContainerId: "c1"}, nil)
+	require.NoError(t, auditor.Close())
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	_, err = Verify(bytes.NewReader(data), testKey)
+	assert.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(data)), "\n"), 2)
+}
+
+func TestWebhookSink(t *testing.T) {
+	var mutex sync.Mutex
+	var received []Record
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var record Record
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mutex.Lock()
+		received = append(received, record)
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	auditor, err := New(server.URL, "", testKey, Levels{Default: LevelMetadata})
+	require.NoError(t, err)
+	auditor.ForPodVM(testPodVM).Record("RemoveContainer", &pb.RemoveContainerRequest{ContainerId: "c1"}, nil)
+
+	// Close sends the queued records
+	require.NoError(t, auditor.Close())
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Len(t, received, 1)
+	assert.Equal(t, "RemoveContainer", received[0].Method)
+	assert.Equal(t, "c1", received[0].ContainerID)
+
+	_, err = New("ftp://audit", "", testKey, Levels{})
+	assert.Error(t, err)
+
+	_, err = New(server.URL, "", []byte("short"), Levels{})
+	assert.Error(t, err)
+}
+
+func TestLoadKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+
+	require.NoError(t, os.WriteFile(path, append(testKey, '\n'), 0o600))
+	key, err := LoadKey(path)
+	require.NoError(t, err)
+	assert.Equal(t, testKey, key)
+
+	require.NoError(t, os.WriteFile(path, []byte("short\n"), 0o600))
+	_, err = LoadKey(path)
+	assert.ErrorContains(t, err, "shorter")
+
+	_, err = LoadKey(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
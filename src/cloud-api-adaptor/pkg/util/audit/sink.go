@@ -0,0 +1,217 @@
+// Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// FileName is the name of the audit log file in the pods directory
+	FileName = "audit.jsonl"
+
+	// BrokenLogMethod is the method of the first record of an audit log file that replaces a file that failed verification
+	BrokenLogMethod = "BrokenAuditLog"
+
+	maxRecordSize  = 16 * 1024 * 1024
+	webhookQueue   = 1024
+	webhookTimeout = 10 * time.Second
+)
+
+// New creates an auditor that writes to the sink specified by spec, which is either "file" for a JSON lines file
+// in podsDir, or the http(s) URL of a webhook. Records are chained with the HMAC key.
+func New(spec, podsDir string, key []byte, levels Levels) (*Auditor, error) {
+	if len(key) < MinKeySize {
+		return nil, fmt.Errorf("audit key must be at least %d bytes", MinKeySize)
+	}
+
+	if spec == "file" {
+		path := filepath.Join(podsDir, FileName)
+		sink, lastHash, err := NewFileSink(path, key)
+		if err != nil {
+			return nil, err
+		}
+		logger.Printf("recording agent requests in %s", path)
+		return NewAuditor(sink, levels, key, lastHash), nil
+	}
+
+	u, err := url.Parse(spec)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("audit sink must be \"file\" or an http(s) URL: %q", spec)
+	}
+	logger.Printf("sending records of agent requests to %s", u.Redacted())
+	return NewAuditor(NewWebhookSink(u.String(), http.DefaultClient), levels, key, ""), nil
+}
+
+// FileSink appends records to a JSON lines file
+type FileSink struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// NewFileSink opens the file for appending, and returns the hash of its last record.
+// When the existing records fail verification with the key, the file is moved aside, and a new file is
+// started with a record that names the moved file and the hash of its last verified record.
+func NewFileSink(path string, key []byte) (*FileSink, string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, "", fmt.Errorf("creating a directory for audit log %s: %w", path, err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, "", fmt.Errorf("opening audit log %s: %w", path, err)
+	}
+
+	lastHash, verifyErr := Verify(file, key)
+	if verifyErr == nil {
+		return &FileSink{file: file}, lastHash, nil
+	}
+	file.Close()
+
+	now := time.Now().UTC()
+	brokenPath := path + ".broken-" + now.Format("20060102T150405Z")
+	if err := os.Rename(path, brokenPath); err != nil {
+		return nil, "", fmt.Errorf("audit log %s failed verification (%v), and cannot be moved aside: %w", path, verifyErr, err)
+	}
+	logger.Printf("WARNING: audit log %s failed verification, and was moved to %s: %v", path, brokenPath, verifyErr)
+
+	file, err = os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, "", fmt.Errorf("opening audit log %s: %w", path, err)
+	}
+
+	record := &Record{
+		Time:             now,
+		Method:           BrokenLogMethod,
+		Code:             codes.OK.String(),
+		Error:            verifyErr.Error(),
+		BrokenLog:        filepath.Base(brokenPath),
+		LastVerifiedHash: lastHash,
+	}
+	if record.Hash, err = record.digest(key); err != nil {
+		file.Close()
+		return nil, "", err
+	}
+
+	sink := &FileSink{file: file}
+	if err := sink.Write(record); err != nil {
+		file.Close()
+		return nil, "", fmt.Errorf("writing audit log %s: %w", path, err)
+	}
+
+	return sink, record.Hash, nil
+}
+
+func (s *FileSink) Write(record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, err = s.file.Write(append(data, '\n'))
+	return err
+}
+
+func (s *FileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.file.Close()
+}
+
+// WebhookSink posts each record to a webhook. Records are sent in the background, so that agent requests
+// are not delayed by the webhook. Records that cannot be queued or sent are dropped and logged,
+// and the gap is detected as a broken hash chain.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+	queue  chan *Record
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewWebhookSink creates a sink that posts records to the URL
+func NewWebhookSink(url string, client *http.Client) *WebhookSink {
+	s := &WebhookSink{
+		url:    url,
+		client: client,
+		queue:  make(chan *Record, webhookQueue),
+		done:   make(chan struct{}),
+	}
+	go s.send()
+	return s
+}
+
+func (s *WebhookSink) Write(record *Record) error {
+	select {
+	case s.queue <- record:
+		return nil
+	default:
+		return errors.New("audit webhook queue is full")
+	}
+}
+
+// Close sends the queued records and stops the sink
+func (s *WebhookSink) Close() error {
+	s.once.Do(func() {
+		close(s.queue)
+	})
+	<-s.done
+	return nil
+}
+
+func (s *WebhookSink) send() {
+	defer close(s.done)
+
+	for record := range s.queue {
+		if err := s.post(record); err != nil {
+			logger.Printf("failed to send audit record of %s to %s: %v", record.Method, record.PodVM, err)
+		}
+	}
+}
+
+func (s *WebhookSink) post(record *Record) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return fmt.Errorf("webhook responded with %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
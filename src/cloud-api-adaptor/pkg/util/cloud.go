@@ -95,10 +95,13 @@ func GetPodvmResourcesFromAnnotation(annotations map[string]string) (int64, int6
 	return vcpuInt, memoryInt, gpuInt
 }
 
+// InitdataAnnotationKey is the pod annotation of initdata
+const InitdataAnnotationKey = "io.katacontainers.config.hypervisor.cc_init_data"
+
 // Method to get initdata from annotation. Initdata is delivered as raw
 // string by kata runtime, so we want to compress and base64 it again.
 func GetInitdataFromAnnotation(annotations map[string]string) (string, error) {
-	str := annotations["io.katacontainers.config.hypervisor.cc_init_data"]
+	str := annotations[InitdataAnnotationKey]
 	if str == "" {
 		return "", nil
 	}
@@ -0,0 +1,201 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package netops
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/google/nftables"
+)
+
+const (
+	FirewallBackendAuto     = "auto"
+	FirewallBackendIPTables = "iptables"
+	FirewallBackendNFTables = "nftables"
+)
+
+// FirewallHook identifies the point in the packet path where a firewall rule is evaluated
+type FirewallHook int
+
+const (
+	FirewallHookPreRouting FirewallHook = iota
+	FirewallHookOutput
+)
+
+func (h FirewallHook) String() string {
+	switch h {
+	case FirewallHookPreRouting:
+		return "PREROUTING"
+	case FirewallHookOutput:
+		return "OUTPUT"
+	}
+	return "unknown"
+}
+
+// FirewallRule matches UDP packets and disables connection tracking for them.
+// The rule is evaluated before connection tracking in a chain named Chain that is attached to Hook.
+// The chain is created when the first rule is added, and deleted when the last rule is deleted.
+type FirewallRule struct {
+	Chain       string
+	Hook        FirewallHook
+	Source      netip.Addr
+	Destination netip.Addr
+	DstPort     int
+	Comment     string
+}
+
+func (r *FirewallRule) addr() netip.Addr {
+	if r.Destination.IsValid() {
+		return r.Destination.Unmap()
+	}
+	return r.Source.Unmap()
+}
+
+func (r *FirewallRule) validate() error {
+	if r.Chain == "" {
+		return fmt.Errorf("firewall rule has no chain")
+	}
+	if r.Hook.String() == "unknown" {
+		return fmt.Errorf("unknown firewall hook %d", r.Hook)
+	}
+	if !r.addr().IsValid() {
+		return fmt.Errorf("firewall rule in chain %s has neither source nor destination address", r.Chain)
+	}
+	if r.Source.IsValid() && r.Destination.IsValid() && r.Source.Unmap().Is4() != r.Destination.Unmap().Is4() {
+		return fmt.Errorf("firewall rule in chain %s has source and destination addresses of different families", r.Chain)
+	}
+	if r.DstPort <= 0 || r.DstPort > 0xffff {
+		return fmt.Errorf("invalid destination port %d of firewall rule in chain %s", r.DstPort, r.Chain)
+	}
+	return nil
+}
+
+// Firewall adds and deletes firewall rules in a network namespace
+type Firewall interface {
+	Backend() string
+	RuleAdd(rule *FirewallRule) error
+	RuleDel(rule *FirewallRule) error
+	// RestrictForward drops forwarded packets except those received on inInterfaces, as a node
+	// that only forwards packets from its CNI bridge does
+	RestrictForward(inInterfaces ...string) error
+}
+
+// firewallMutex serializes changes of chains shared by rules of different pods
+var firewallMutex sync.Mutex
+
+var (
+	firewallBackend      string
+	firewallBackendMutex sync.Mutex
+)
+
+// netfilterState is the netfilter state of a host that the firewall backend is selected by
+type netfilterState struct {
+	// nftablesErr is set when nftables cannot be used, for example when the kernel lacks nf_tables
+	nftablesErr error
+	// nftablesTables are the nftables tables, including those of iptables-nft
+	nftablesTables []string
+	// iptablesChains is set when the raw table of iptables-nft has chains of the iptables backend
+	iptablesChains bool
+	// legacyTables are the tables of iptables-legacy
+	legacyTables []string
+}
+
+// readNetfilterState reads the nftables tables via netlink, and the tables of iptables-legacy from procfs
+func readNetfilterState() *netfilterState {
+	var state netfilterState
+
+	conn, err := nftables.New()
+	if err == nil {
+		var tables []*nftables.Table
+		if tables, err = conn.ListTables(); err == nil {
+			for _, table := range tables {
+				state.nftablesTables = append(state.nftablesTables, table.Name)
+			}
+			var chains []*nftables.Chain
+			if chains, err = conn.ListChains(); err == nil {
+				for _, chain := range chains {
+					family := chain.Table.Family
+					if chain.Table.Name == iptablesTable && (family == nftables.TableFamilyIPv4 || family == nftables.TableFamilyIPv6) && strings.HasPrefix(chain.Name, "peerpod-") {
+						state.iptablesChains = true
+					}
+				}
+			}
+		}
+	}
+	state.nftablesErr = err
+
+	// The files exist only when iptables-legacy has loaded its tables
+	for _, path := range []string{"/proc/net/ip_tables_names", "/proc/net/ip6_tables_names"} {
+		if data, err := os.ReadFile(path); err == nil {
+			state.legacyTables = append(state.legacyTables, strings.Fields(string(data))...)
+		}
+	}
+
+	return &state
+}
+
+// selectFirewallBackend returns the firewall backend for the netfilter state. nftables is used unless the kernel
+// lacks nftables, the iptables backend has left rules, or the host only uses iptables-legacy, like iptables-wrapper.
+func selectFirewallBackend(state *netfilterState) string {
+	switch {
+	case state.nftablesErr != nil:
+		return FirewallBackendIPTables
+	case state.iptablesChains:
+		return FirewallBackendIPTables
+	case len(state.legacyTables) > 0 && len(state.nftablesTables) == 0:
+		return FirewallBackendIPTables
+	}
+	return FirewallBackendNFTables
+}
+
+// DetectFirewallBackend returns the firewall backend to be used on this host, based on its netfilter state
+func DetectFirewallBackend() string {
+	return selectFirewallBackend(readNetfilterState())
+}
+
+// SetFirewallBackend sets the backend used by NewFirewall. An empty backend or "auto" selects one with DetectFirewallBackend.
+func SetFirewallBackend(backend string) error {
+
+	switch backend {
+	case "", FirewallBackendAuto:
+		backend = DetectFirewallBackend()
+	case FirewallBackendIPTables, FirewallBackendNFTables:
+	default:
+		return fmt.Errorf("unknown firewall backend %q: must be %s, %s or %s", backend, FirewallBackendAuto, FirewallBackendIPTables, FirewallBackendNFTables)
+	}
+
+	firewallBackendMutex.Lock()
+	defer firewallBackendMutex.Unlock()
+
+	firewallBackend = backend
+
+	return nil
+}
+
+// GetFirewallBackend returns the backend used by NewFirewall. It is detected on the first call if SetFirewallBackend has not been called.
+func GetFirewallBackend() string {
+	firewallBackendMutex.Lock()
+	defer firewallBackendMutex.Unlock()
+
+	if firewallBackend == "" {
+		firewallBackend = DetectFirewallBackend()
+	}
+	return firewallBackend
+}
+
+// NewFirewall returns a firewall of the network namespace using the backend set by SetFirewallBackend
+func NewFirewall(ns Namespace) Firewall {
+	return newFirewall(ns, GetFirewallBackend())
+}
+
+func newFirewall(ns Namespace, backend string) Firewall {
+	if backend == FirewallBackendNFTables {
+		return &nftablesFirewall{ns: ns}
+	}
+	return &iptablesFirewall{ns: ns}
+}
@@ -0,0 +1,158 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package netops
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/go-iptables/iptables"
+)
+
+const (
+	iptablesTable       = "raw"
+	iptablesFilterTable = "filter"
+	iptablesForward     = "FORWARD"
+)
+
+type iptablesFirewall struct {
+	ns Namespace
+}
+
+func (f *iptablesFirewall) Backend() string {
+	return FirewallBackendIPTables
+}
+
+// iptablesSpec renders a rule as arguments of iptables
+func iptablesSpec(rule *FirewallRule) []string {
+
+	spec := []string{"-m", "comment", "--comment", rule.Comment}
+
+	if rule.Destination.IsValid() {
+		spec = append(spec, "-d", rule.Destination.Unmap().String())
+	}
+	if rule.Source.IsValid() {
+		spec = append(spec, "-s", rule.Source.Unmap().String())
+	}
+
+	return append(spec, "-p", "udp", "-m", "udp", "--dport", strconv.Itoa(rule.DstPort), "-j", "NOTRACK")
+}
+
+func (f *iptablesFirewall) run(rule *FirewallRule, fn func(ipt *iptables.IPTables, spec []string) error) error {
+
+	if err := rule.validate(); err != nil {
+		return err
+	}
+
+	protocol := iptables.ProtocolIPv4
+	if rule.addr().Is6() {
+		protocol = iptables.ProtocolIPv6
+	}
+
+	firewallMutex.Lock()
+	defer firewallMutex.Unlock()
+
+	return f.ns.Run(func() error {
+
+		ipt, err := iptables.New(iptables.IPFamily(protocol))
+		if err != nil {
+			return fmt.Errorf("failed to initialize iptables: %w", err)
+		}
+
+		return fn(ipt, iptablesSpec(rule))
+	})
+}
+
+func (f *iptablesFirewall) RuleAdd(rule *FirewallRule) error {
+
+	base := rule.Hook.String()
+
+	return f.run(rule, func(ipt *iptables.IPTables, spec []string) error {
+
+		exists, err := ipt.ChainExists(iptablesTable, rule.Chain)
+		if err != nil {
+			return fmt.Errorf("failed to check the existence of iptables chain %q: %w", rule.Chain, err)
+		}
+
+		if !exists {
+			// Add "-N <chain>"
+			if err := ipt.NewChain(iptablesTable, rule.Chain); err != nil {
+				return fmt.Errorf("failed to create iptables chain %s on table %s: %w", rule.Chain, iptablesTable, err)
+			}
+			// Add "-A <base> -j <chain>"
+			if err := ipt.AppendUnique(iptablesTable, base, "-j", rule.Chain); err != nil {
+				return fmt.Errorf("failed to add iptables rule \"-t %s -A %s -j %s\": %w", iptablesTable, base, rule.Chain, err)
+			}
+		}
+
+		if err := ipt.AppendUnique(iptablesTable, rule.Chain, spec...); err != nil {
+			return fmt.Errorf("failed to add iptables rule \"-t %s -A %s %s\": %w", iptablesTable, rule.Chain, strings.Join(spec, " "), err)
+		}
+
+		return nil
+	})
+}
+
+func (f *iptablesFirewall) RuleDel(rule *FirewallRule) error {
+
+	base := rule.Hook.String()
+
+	return f.run(rule, func(ipt *iptables.IPTables, spec []string) error {
+
+		if err := ipt.Delete(iptablesTable, rule.Chain, spec...); err != nil {
+			return fmt.Errorf("failed to delete iptables rule \"-t %s -A %s %s\": %w", iptablesTable, rule.Chain, strings.Join(spec, " "), err)
+		}
+
+		list, err := ipt.List(iptablesTable, rule.Chain)
+		if err != nil {
+			return fmt.Errorf("failed to list rules in chain %s on table %s: %w", rule.Chain, iptablesTable, err)
+		}
+
+		if len(list) > 1 {
+			// There are remaining rules other than "-N <chain>"
+			return nil
+		}
+
+		// Delete "-A <base> -j <chain>"
+		if err := ipt.DeleteIfExists(iptablesTable, base, "-j", rule.Chain); err != nil {
+			return fmt.Errorf("failed to delete iptables rule \"-t %s -A %s -j %s\": %w", iptablesTable, base, rule.Chain, err)
+		}
+		// Delete "-N <chain>"
+		if err := ipt.DeleteChain(iptablesTable, rule.Chain); err != nil {
+			return fmt.Errorf("failed to delete iptables chain %s on table %s: %w", rule.Chain, iptablesTable, err)
+		}
+
+		return nil
+	})
+}
+
+func (f *iptablesFirewall) RestrictForward(inInterfaces ...string) error {
+
+	firewallMutex.Lock()
+	defer firewallMutex.Unlock()
+
+	return f.ns.Run(func() error {
+
+		for _, protocol := range []iptables.Protocol{iptables.ProtocolIPv4, iptables.ProtocolIPv6} {
+
+			ipt, err := iptables.New(iptables.IPFamily(protocol))
+			if err != nil {
+				return fmt.Errorf("failed to initialize iptables: %w", err)
+			}
+
+			for _, name := range inInterfaces {
+				if err := ipt.AppendUnique(iptablesFilterTable, iptablesForward, "-i", name, "-j", "ACCEPT"); err != nil {
+					return fmt.Errorf("failed to add iptables rule \"-t %s -A %s -i %s -j ACCEPT\": %w", iptablesFilterTable, iptablesForward, name, err)
+				}
+			}
+
+			if err := ipt.ChangePolicy(iptablesFilterTable, iptablesForward, "DROP"); err != nil {
+				return fmt.Errorf("failed to change the policy of iptables chain %s on table %s: %w", iptablesForward, iptablesFilterTable, err)
+			}
+		}
+
+		return nil
+	})
+}
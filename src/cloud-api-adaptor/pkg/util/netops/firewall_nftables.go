@@ -0,0 +1,318 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package netops
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/google/nftables/userdata"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// nftablesTableName is the name of the inet table that holds the chains of all rules
+	nftablesTableName = "peerpod"
+	// nftablesForwardChainName is the name of the chain of RestrictForward
+	nftablesForwardChainName = "peerpod-FORWARD"
+)
+
+type nftablesFirewall struct {
+	ns Namespace
+}
+
+func (f *nftablesFirewall) Backend() string {
+	return FirewallBackendNFTables
+}
+
+var nftablesTable = &nftables.Table{
+	Name:   nftablesTableName,
+	Family: nftables.TableFamilyINet,
+}
+
+func nftablesChain(rule *FirewallRule) *nftables.Chain {
+
+	hook := nftables.ChainHookPrerouting
+	if rule.Hook == FirewallHookOutput {
+		hook = nftables.ChainHookOutput
+	}
+
+	policy := nftables.ChainPolicyAccept
+
+	return &nftables.Chain{
+		Name:     rule.Chain,
+		Table:    nftablesTable,
+		Type:     nftables.ChainTypeFilter,
+		Hooknum:  hook,
+		Priority: nftables.ChainPriorityRaw,
+		Policy:   &policy,
+	}
+}
+
+// nftablesExprs renders a rule as nftables expressions.
+// This is equivalent to "meta nfproto ipv4 ip daddr <addr> meta l4proto udp udp dport <port> notrack".
+func nftablesExprs(rule *FirewallRule) []expr.Any {
+
+	nfproto := byte(unix.NFPROTO_IPV4)
+	addrLen, srcOffset, dstOffset := uint32(4), uint32(12), uint32(16)
+	if rule.addr().Is6() {
+		nfproto = byte(unix.NFPROTO_IPV6)
+		addrLen, srcOffset, dstOffset = 16, 8, 24
+	}
+
+	exprs := []expr.Any{
+		&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{nfproto}},
+	}
+
+	for _, match := range []struct {
+		addr   []byte
+		offset uint32
+	}{
+		{rule.Source.Unmap().AsSlice(), srcOffset},
+		{rule.Destination.Unmap().AsSlice(), dstOffset},
+	} {
+		if match.addr == nil {
+			continue
+		}
+		exprs = append(exprs,
+			&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: match.offset, Len: addrLen},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: match.addr},
+		)
+	}
+
+	port := binary.BigEndian.AppendUint16(nil, uint16(rule.DstPort))
+
+	return append(exprs,
+		&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_UDP}},
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 2, Len: 2},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: port},
+		&expr.Notrack{},
+	)
+}
+
+func nftablesUserData(rule *FirewallRule) []byte {
+	return userdata.AppendString(nil, userdata.TypeComment, rule.Comment)
+}
+
+// nftablesFind returns the rule in rules that is equal to rule
+func nftablesFind(rules []*nftables.Rule, rule *FirewallRule) (*nftables.Rule, error) {
+
+	want, err := nftablesMarshal(nftablesExprs(rule))
+	if err != nil {
+		return nil, err
+	}
+	udata := nftablesUserData(rule)
+
+	for _, r := range rules {
+		if !bytes.Equal(r.UserData, udata) {
+			continue
+		}
+		got, err := nftablesMarshal(r.Exprs)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(got, want) {
+			return r, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func nftablesMarshal(exprs []expr.Any) ([]byte, error) {
+
+	var buf []byte
+	for _, e := range exprs {
+		b, err := expr.Marshal(byte(nftablesTable.Family), e)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal nftables expression %T: %w", e, err)
+		}
+		buf = append(buf, b...)
+	}
+	return buf, nil
+}
+
+// nftablesRules returns the rules in the chain of rule. It returns no rules if the chain does not exist.
+func nftablesRules(conn *nftables.Conn, chain *nftables.Chain) ([]*nftables.Rule, error) {
+
+	rules, err := conn.GetRules(nftablesTable, chain)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, unix.ENOENT) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get nftables rules in chain %s of table %s: %w", chain.Name, nftablesTable.Name, err)
+	}
+	return rules, nil
+}
+
+func (f *nftablesFirewall) run(rule *FirewallRule, fn func(conn *nftables.Conn, chain *nftables.Chain) error) error {
+
+	if err := rule.validate(); err != nil {
+		return err
+	}
+
+	firewallMutex.Lock()
+	defer firewallMutex.Unlock()
+
+	return f.ns.Run(func() error {
+
+		// A netlink socket is opened for each request in the network namespace of the current thread
+		conn, err := nftables.New()
+		if err != nil {
+			return fmt.Errorf("failed to initialize nftables: %w", err)
+		}
+
+		return fn(conn, nftablesChain(rule))
+	})
+}
+
+func (f *nftablesFirewall) RuleAdd(rule *FirewallRule) error {
+
+	return f.run(rule, func(conn *nftables.Conn, chain *nftables.Chain) error {
+
+		conn.AddTable(nftablesTable)
+		conn.AddChain(chain)
+
+		if err := conn.Flush(); err != nil {
+			return fmt.Errorf("failed to create nftables chain %s of table %s: %w", chain.Name, nftablesTable.Name, err)
+		}
+
+		rules, err := nftablesRules(conn, chain)
+		if err != nil {
+			return err
+		}
+
+		existing, err := nftablesFind(rules, rule)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return nil
+		}
+
+		conn.AddRule(&nftables.Rule{
+			Table:    nftablesTable,
+			Chain:    chain,
+			Exprs:    nftablesExprs(rule),
+			UserData: nftablesUserData(rule),
+		})
+
+		if err := conn.Flush(); err != nil {
+			return fmt.Errorf("failed to add nftables rule %q to chain %s of table %s: %w", rule.Comment, chain.Name, nftablesTable.Name, err)
+		}
+
+		return nil
+	})
+}
+
+func (f *nftablesFirewall) RuleDel(rule *FirewallRule) error {
+
+	return f.run(rule, func(conn *nftables.Conn, chain *nftables.Chain) error {
+
+		rules, err := nftablesRules(conn, chain)
+		if err != nil {
+			return err
+		}
+
+		existing, err := nftablesFind(rules, rule)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return fmt.Errorf("failed to find nftables rule %q in chain %s of table %s", rule.Comment, chain.Name, nftablesTable.Name)
+		}
+
+		if err := conn.DelRule(existing); err != nil {
+			return fmt.Errorf("failed to delete nftables rule %q in chain %s of table %s: %w", rule.Comment, chain.Name, nftablesTable.Name, err)
+		}
+
+		if len(rules) == 1 {
+			conn.DelChain(chain)
+		}
+
+		if err := conn.Flush(); err != nil {
+			return fmt.Errorf("failed to delete nftables rule %q in chain %s of table %s: %w", rule.Comment, chain.Name, nftablesTable.Name, err)
+		}
+
+		if len(rules) > 1 {
+			return nil
+		}
+
+		chains, err := conn.ListChainsOfTableFamily(nftablesTable.Family)
+		if err != nil {
+			return fmt.Errorf("failed to list nftables chains: %w", err)
+		}
+		for _, c := range chains {
+			if c.Table.Name == nftablesTable.Name {
+				return nil
+			}
+		}
+
+		conn.DelTable(nftablesTable)
+		if err := conn.Flush(); err != nil {
+			return fmt.Errorf("failed to delete nftables table %s: %w", nftablesTable.Name, err)
+		}
+
+		return nil
+	})
+}
+
+// RestrictForward adds a forward chain with the drop policy. This is equivalent to
+// "iifname <name> accept" for each of inInterfaces.
+func (f *nftablesFirewall) RestrictForward(inInterfaces ...string) error {
+
+	firewallMutex.Lock()
+	defer firewallMutex.Unlock()
+
+	return f.ns.Run(func() error {
+
+		conn, err := nftables.New()
+		if err != nil {
+			return fmt.Errorf("failed to initialize nftables: %w", err)
+		}
+
+		policy := nftables.ChainPolicyDrop
+		chain := &nftables.Chain{
+			Name:     nftablesForwardChainName,
+			Table:    nftablesTable,
+			Type:     nftables.ChainTypeFilter,
+			Hooknum:  nftables.ChainHookForward,
+			Priority: nftables.ChainPriorityFilter,
+			Policy:   &policy,
+		}
+
+		conn.AddTable(nftablesTable)
+		conn.AddChain(chain)
+		conn.FlushChain(chain)
+
+		for _, name := range inInterfaces {
+			// Interface names are compared with IFNAMSIZ bytes padded with zeros
+			ifname := make([]byte, unix.IFNAMSIZ)
+			copy(ifname, name)
+
+			conn.AddRule(&nftables.Rule{
+				Table: nftablesTable,
+				Chain: chain,
+				Exprs: []expr.Any{
+					&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1},
+					&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: ifname},
+					&expr.Verdict{Kind: expr.VerdictAccept},
+				},
+			})
+		}
+
+		if err := conn.Flush(); err != nil {
+			return fmt.Errorf("failed to add nftables chain %s of table %s: %w", chain.Name, nftablesTable.Name, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,356 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package netops
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/go-iptables/iptables"
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+
+	testutils "github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/internal/testing"
+)
+
+func newTestNamespace(t *testing.T) Namespace {
+	t.Helper()
+
+	name := fmt.Sprintf("test-firewall-%d", os.Getpid())
+	nsPath, err := CreateNamedNamespace(name)
+	if err != nil {
+		t.Fatalf("failed to create a named network namespace %s: %v", name, err)
+	}
+
+	ns, err := OpenNamespace(nsPath)
+	if err != nil {
+		t.Fatalf("failed to open a named network namespace %s: %v", nsPath, err)
+	}
+
+	t.Cleanup(func() {
+		if err := ns.Close(); err != nil {
+			t.Errorf("failed to close a network namespace: %v", err)
+		}
+		if err := DeleteNamedNamespace(filepath.Base(nsPath)); err != nil {
+			t.Errorf("failed to delete a named network namespace %s: %v", nsPath, err)
+		}
+	})
+
+	return ns
+}
+
+func testFirewallRules(id int) []*FirewallRule {
+
+	var rules []*FirewallRule
+
+	for _, addr := range []string{"192.168.0.2", "fd00::2"} {
+		comment := fmt.Sprintf("peerpod [vni:%d]", id)
+		rules = append(rules,
+			&FirewallRule{
+				Chain:       "peerpod-OUTPUT",
+				Hook:        FirewallHookOutput,
+				Destination: netip.MustParseAddr(addr),
+				DstPort:     4789,
+				Comment:     comment,
+			},
+			&FirewallRule{
+				Chain:   "peerpod-PREROUTING",
+				Hook:    FirewallHookPreRouting,
+				Source:  netip.MustParseAddr(addr),
+				DstPort: 4789,
+				Comment: comment,
+			},
+		)
+	}
+
+	return rules
+}
+
+// testFirewall adds and deletes rules of two pods, and checks the number of rules in each chain with count.
+// count returns -1 when the chain does not exist.
+func testFirewall(t *testing.T, f Firewall, count func(rule *FirewallRule) int) {
+
+	pod1 := testFirewallRules(555000)
+	pod2 := testFirewallRules(555001)
+
+	for _, rules := range [][]*FirewallRule{pod1, pod2, pod1} {
+		for _, rule := range rules {
+			if err := f.RuleAdd(rule); err != nil {
+				t.Fatalf("Expect no error, got %v", err)
+			}
+		}
+	}
+
+	for _, rule := range pod1 {
+		if e, a := 2, count(rule); e != a {
+			t.Fatalf("Expect %d rules in chain %s (%s), got %d", e, rule.Chain, rule.addr(), a)
+		}
+	}
+
+	for _, rule := range pod1 {
+		if err := f.RuleDel(rule); err != nil {
+			t.Fatalf("Expect no error, got %v", err)
+		}
+	}
+
+	for _, rule := range pod2 {
+		if e, a := 1, count(rule); e != a {
+			t.Fatalf("Expect %d rules in chain %s (%s), got %d", e, rule.Chain, rule.addr(), a)
+		}
+	}
+
+	if err := f.RuleDel(pod1[0]); err == nil {
+		t.Fatal("Expect an error deleting a rule that does not exist, got nil")
+	}
+
+	for _, rule := range pod2 {
+		if err := f.RuleDel(rule); err != nil {
+			t.Fatalf("Expect no error, got %v", err)
+		}
+	}
+
+	for _, rule := range pod2 {
+		if e, a := -1, count(rule); e != a {
+			t.Fatalf("Expect chain %s (%s) to be deleted, got %d rules", rule.Chain, rule.addr(), a)
+		}
+	}
+}
+
+func TestFirewallIPTables(t *testing.T) {
+	testutils.SkipTestIfNotRoot(t)
+
+	for _, cmd := range []string{"iptables", "ip6tables"} {
+		if _, err := exec.LookPath(cmd); err != nil {
+			t.Skipf("%s is not installed", cmd)
+		}
+	}
+
+	ns := newTestNamespace(t)
+
+	f := newFirewall(ns, FirewallBackendIPTables)
+	if e, a := FirewallBackendIPTables, f.Backend(); e != a {
+		t.Fatalf("Expect %q, got %q", e, a)
+	}
+
+	testFirewall(t, f, func(rule *FirewallRule) int {
+		var n int
+		if err := ns.Run(func() error {
+			protocol := iptables.ProtocolIPv4
+			if rule.addr().Is6() {
+				protocol = iptables.ProtocolIPv6
+			}
+			ipt, err := iptables.New(iptables.IPFamily(protocol))
+			if err != nil {
+				return err
+			}
+			exists, err := ipt.ChainExists(iptablesTable, rule.Chain)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				n = -1
+				return nil
+			}
+			list, err := ipt.List(iptablesTable, rule.Chain)
+			if err != nil {
+				return err
+			}
+			// The first entry is "-N <chain>"
+			n = len(list) - 1
+			return nil
+		}); err != nil {
+			t.Fatalf("Expect no error, got %v", err)
+		}
+		return n
+	})
+}
+
+func TestFirewallNFTables(t *testing.T) {
+	testutils.SkipTestIfNotRoot(t)
+
+	ns := newTestNamespace(t)
+
+	if err := ns.Run(func() error {
+		conn, err := nftables.New()
+		if err != nil {
+			return err
+		}
+		_, err = conn.ListTables()
+		return err
+	}); err != nil {
+		t.Skipf("nftables is not available: %v", err)
+	}
+
+	f := newFirewall(ns, FirewallBackendNFTables)
+	if e, a := FirewallBackendNFTables, f.Backend(); e != a {
+		t.Fatalf("Expect %q, got %q", e, a)
+	}
+
+	testFirewall(t, f, func(rule *FirewallRule) int {
+		n := -1
+		if err := ns.Run(func() error {
+			conn, err := nftables.New()
+			if err != nil {
+				return err
+			}
+			chains, err := conn.ListChainsOfTableFamily(nftablesTable.Family)
+			if err != nil {
+				return err
+			}
+			for _, chain := range chains {
+				if chain.Table.Name != nftablesTableName || chain.Name != rule.Chain {
+					continue
+				}
+				rules, err := conn.GetRules(nftablesTable, chain)
+				if err != nil {
+					return err
+				}
+				// Count rules of the same address family, as iptables and ip6tables do
+				nfproto := byte(unix.NFPROTO_IPV4)
+				if rule.addr().Is6() {
+					nfproto = unix.NFPROTO_IPV6
+				}
+				n = 0
+				for _, r := range rules {
+					if cmp, ok := r.Exprs[1].(*expr.Cmp); ok && cmp.Data[0] == nfproto {
+						n++
+					}
+				}
+			}
+			return nil
+		}); err != nil {
+			t.Fatalf("Expect no error, got %v", err)
+		}
+		return n
+	})
+
+	if err := ns.Run(func() error {
+		conn, err := nftables.New()
+		if err != nil {
+			return err
+		}
+		tables, err := conn.ListTables()
+		if err != nil {
+			return err
+		}
+		for _, table := range tables {
+			if table.Name == nftablesTableName {
+				return fmt.Errorf("table %s is not deleted", table.Name)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+}
+
+func TestSelectFirewallBackend(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		state netfilterState
+		want  string
+	}{
+		{name: "no rules", want: FirewallBackendNFTables},
+		{name: "nftables", state: netfilterState{nftablesTables: []string{"filter"}}, want: FirewallBackendNFTables},
+		{name: "iptables-nft", state: netfilterState{nftablesTables: []string{"filter", "nat"}}, want: FirewallBackendNFTables},
+		{name: "iptables-legacy", state: netfilterState{legacyTables: []string{"filter", "nat"}}, want: FirewallBackendIPTables},
+		{name: "iptables-legacy and nftables", state: netfilterState{nftablesTables: []string{"filter"}, legacyTables: []string{"filter"}}, want: FirewallBackendNFTables},
+		{name: "rules of the iptables backend", state: netfilterState{nftablesTables: []string{"raw"}, iptablesChains: true}, want: FirewallBackendIPTables},
+		{name: "no nf_tables", state: netfilterState{nftablesErr: errors.New("protocol not supported")}, want: FirewallBackendIPTables},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if e, a := tc.want, selectFirewallBackend(&tc.state); e != a {
+				t.Fatalf("Expect %q, got %q", e, a)
+			}
+		})
+	}
+}
+
+func TestSetFirewallBackend(t *testing.T) {
+	defer func() {
+		firewallBackend = ""
+	}()
+
+	for _, backend := range []string{FirewallBackendIPTables, FirewallBackendNFTables} {
+		if err := SetFirewallBackend(backend); err != nil {
+			t.Fatalf("Expect no error, got %v", err)
+		}
+		if e, a := backend, GetFirewallBackend(); e != a {
+			t.Fatalf("Expect %q, got %q", e, a)
+		}
+	}
+
+	if err := SetFirewallBackend(FirewallBackendAuto); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+	if e, a := DetectFirewallBackend(), GetFirewallBackend(); e != a {
+		t.Fatalf("Expect %q, got %q", e, a)
+	}
+
+	if err := SetFirewallBackend("ebtables"); err == nil {
+		t.Fatal("Expect an error, got nil")
+	}
+}
+
+func TestFirewallRestrictForward(t *testing.T) {
+	testutils.SkipTestIfNotRoot(t)
+
+	ns := newTestNamespace(t)
+
+	if err := ns.Run(func() error {
+		conn, err := nftables.New()
+		if err != nil {
+			return err
+		}
+		_, err = conn.ListTables()
+		return err
+	}); err != nil {
+		t.Skipf("nftables is not available: %v", err)
+	}
+
+	f := newFirewall(ns, FirewallBackendNFTables)
+
+	// Rules are replaced when it is called again
+	for i := 0; i < 2; i++ {
+		if err := f.RestrictForward("cni0"); err != nil {
+			t.Fatalf("Expect no error, got %v", err)
+		}
+	}
+
+	if err := ns.Run(func() error {
+		conn, err := nftables.New()
+		if err != nil {
+			return err
+		}
+		chains, err := conn.ListChainsOfTableFamily(nftablesTable.Family)
+		if err != nil {
+			return err
+		}
+		for _, chain := range chains {
+			if chain.Table.Name != nftablesTableName || chain.Name != nftablesForwardChainName {
+				continue
+			}
+			if chain.Policy == nil || *chain.Policy != nftables.ChainPolicyDrop {
+				return fmt.Errorf("chain %s does not have the drop policy", chain.Name)
+			}
+			rules, err := conn.GetRules(nftablesTable, chain)
+			if err != nil {
+				return err
+			}
+			if len(rules) != 1 {
+				return fmt.Errorf("expect 1 rule in chain %s, got %d", chain.Name, len(rules))
+			}
+			return nil
+		}
+		return fmt.Errorf("chain %s is not found", nftablesForwardChainName)
+	}); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+}
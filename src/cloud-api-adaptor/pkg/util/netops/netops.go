@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strings"
 
 	"golang.org/x/exp/maps"
 
@@ -29,7 +30,11 @@ type Namespace interface {
 	LinkList() ([]Link, error)
 	Path() string
 	RedirectAdd(src, dst string) error
+	RedirectIPAdd(src, dst string) error
 	RedirectDel(src string) error
+	TunnelRedirectAdd(src, dst string, key *TunnelKey) error
+	TunnelKeyAdd(name string, key *TunnelKey) error
+	TunnelKeyGet(name string) (*TunnelKey, error)
 	RouteAdd(route *Route) error
 	RouteDel(route *Route) error
 	GetDefaultRoutes() ([]*Route, error)
@@ -39,6 +44,7 @@ type Namespace interface {
 	RuleList(rule *Rule) ([]*Rule, error)
 	NeighborAdd(neighbor *Neighbor) error
 	NeighborList(filters ...*Neighbor) ([]*Neighbor, error)
+	SysctlSet(key, value string) error
 	Run(fn func() error) error
 }
 
@@ -184,6 +190,7 @@ type Link interface {
 
 	GetAddr() ([]netip.Prefix, error)
 	AddAddr(prefix netip.Prefix) error
+	DelAddr(prefix netip.Prefix) error
 	GetHardwareAddr() (string, error)
 	SetHardwareAddr(hwAddr string) error
 	GetMTU() (int, error)
@@ -213,30 +220,55 @@ func (l *link) Type() string {
 	return l.nlLink.Type()
 }
 
+// GetAddr returns IPv4 and IPv6 addresses assigned to the link. IPv4 addresses come first.
+// IPv6 link-local addresses, which the kernel assigns to every interface, are not included.
 func (l *link) GetAddr() ([]netip.Prefix, error) {
 
-	addrs, err := l.ns.handle.AddrList(l.nlLink, netlink.FAMILY_V4)
+	addrs, err := l.ns.handle.AddrList(l.nlLink, netlink.FAMILY_ALL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get IP addresses assigned to %s interface %q:  %w", l.Type(), l.Name(), err)
 	}
 
 	var prefixes []netip.Prefix
 	for _, addr := range addrs {
-		prefixes = append(prefixes, toPrefix(addr.IPNet))
+		prefix := toPrefix(addr.IPNet)
+		if prefix.Addr().IsLinkLocalUnicast() && prefix.Addr().Is6() {
+			continue
+		}
+		prefixes = append(prefixes, prefix)
 	}
 
+	sort.SliceStable(prefixes, func(i, j int) bool {
+		return prefixes[i].Addr().Is4() && !prefixes[j].Addr().Is4()
+	})
+
 	return prefixes, nil
 }
 
 func (l *link) AddAddr(prefix netip.Prefix) error {
 
-	if err := l.ns.handle.AddrAdd(l.nlLink, &netlink.Addr{IPNet: toIPNet(prefix)}); err != nil {
+	addr := &netlink.Addr{IPNet: toIPNet(prefix)}
+	if prefix.Addr().Is6() {
+		// The address is moved from another interface, so duplicate address detection is not needed
+		addr.Flags = unix.IFA_F_NODAD
+	}
+
+	if err := l.ns.handle.AddrAdd(l.nlLink, addr); err != nil {
 		return fmt.Errorf("failed to assign an IP address %q to %s: %w", prefix.String(), l.Name(), err)
 	}
 
 	return nil
 }
 
+func (l *link) DelAddr(prefix netip.Prefix) error {
+
+	if err := l.ns.handle.AddrDel(l.nlLink, &netlink.Addr{IPNet: toIPNet(prefix)}); err != nil {
+		return fmt.Errorf("failed to remove an IP address %q from %s: %w", prefix.String(), l.Name(), err)
+	}
+
+	return nil
+}
+
 func (l *link) GetMTU() (int, error) {
 
 	mtu := l.nlLink.Attrs().MTU
@@ -328,6 +360,23 @@ func (l *link) GetDevice() (dev Device, err error) {
 			ID:    v.VxlanId,
 			Port:  v.Port,
 		}
+	case *netlink.Geneve:
+		dev = &Geneve{
+			Remote:   toAddr(v.Remote),
+			ID:       int(v.ID),
+			Port:     int(v.Dport),
+			External: v.FlowBased,
+		}
+	case *netlink.Wireguard:
+		// Keys and peers are not retrieved
+		port, err := l.ns.wireguardListenPort(l.Name())
+		if err != nil {
+			return nil, err
+		}
+		dev = &WireGuard{ListenPort: port}
+	case *netlink.IPVlan:
+		// The parent interface may be in another network namespace, and is not retrieved
+		dev = &IPVLAN{}
 	default:
 		// TODO: Support Bridge, VXLAN, ...
 		return nil, fmt.Errorf("device info is not available: %s", l.nlLink.Type())
@@ -374,6 +423,42 @@ func (d *VXLAN) getLink() netlink.Link {
 	}
 }
 
+// Geneve is a Geneve interface. An external Geneve interface has no remote address and VNI. They are taken
+// from the metadata of each packet along with Geneve options, which are set by TunnelKeyAdd or TunnelRedirectAdd.
+type Geneve struct {
+	Remote   netip.Addr
+	ID       int
+	Port     int
+	External bool
+}
+
+func (d *Geneve) getLink() netlink.Link {
+
+	return &netlink.Geneve{
+		Remote:    toIP(d.Remote),
+		ID:        uint32(d.ID),
+		Dport:     uint16(d.Port),
+		FlowBased: d.External,
+	}
+}
+
+// IPVLAN is an ipvlan interface in L2 mode on top of Parent. It shares the hardware address of
+// the parent interface, and receives unicast packets destined to addresses assigned to it.
+type IPVLAN struct {
+	Parent Link
+}
+
+func (d *IPVLAN) getLink() netlink.Link {
+
+	return &netlink.IPVlan{
+		LinkAttrs: netlink.LinkAttrs{
+			ParentIndex: d.Parent.(*link).nlLink.Attrs().Index,
+		},
+		Mode: netlink.IPVLAN_MODE_L2,
+	}
+}
+
+// LinkFind returns the interface name. The error wraps os.ErrNotExist if it is not found.
 func (ns *namespace) LinkFind(name string) (Link, error) {
 
 	nlLinks, err := ns.handle.LinkList()
@@ -391,7 +476,7 @@ func (ns *namespace) LinkFind(name string) (Link, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("failed to find interface %q on netns %s", name, ns.path)
+	return nil, fmt.Errorf("failed to find interface %q on netns %s: %w", name, ns.path, os.ErrNotExist)
 }
 
 func (ns *namespace) LinkList() ([]Link, error) {
@@ -429,10 +514,20 @@ func (ns *namespace) LinkAdd(name string, device Device) (Link, error) {
 		return nil, fmt.Errorf("failed to find created %s interface %q on %s:  %w", nlLink.Type(), name, ns.Path(), err)
 	}
 
+	if wg, ok := device.(*WireGuard); ok {
+		if err := ns.wireguardSet(name, wg); err != nil {
+			if e := link.Delete(); e != nil {
+				err = fmt.Errorf("%w (failed to delete %s: %v)", err, name, e)
+			}
+			return nil, err
+		}
+	}
+
 	return link, err
 }
 
 var DefaultPrefix = netip.MustParsePrefix("0.0.0.0/0")
+var DefaultPrefixV6 = netip.MustParsePrefix("::/0")
 
 type Route struct {
 	Destination netip.Prefix
@@ -517,7 +612,15 @@ func (ns *namespace) routeListFiltered(filter *Route) ([]*netlink.Route, error)
 		filterMask |= netlink.RT_FILTER_PROTOCOL
 	}
 
-	list, err := ns.handle.RouteListFiltered(netlink.FAMILY_V4, &nlRoute, filterMask)
+	family := netlink.FAMILY_ALL
+	if dst := filter.Destination; dst.IsValid() {
+		family = netlink.FAMILY_V4
+		if dst.Addr().Is6() && !dst.Addr().Is4In6() {
+			family = netlink.FAMILY_V6
+		}
+	}
+
+	list, err := ns.handle.RouteListFiltered(family, &nlRoute, filterMask)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get routes on namespace %q: %w", ns.Path(), err)
 	}
@@ -564,8 +667,13 @@ func (ns *namespace) RouteList(filters ...*Route) ([]*Route, error) {
 
 			onlink := r.Flags&int(netlink.FLAG_ONLINK) != 0
 
+			dst := toPrefix(r.Dst)
+			if r.Dst == nil && r.Family == netlink.FAMILY_V6 {
+				dst = DefaultPrefixV6
+			}
+
 			route := &Route{
-				Destination: toPrefix(r.Dst),
+				Destination: dst,
 				Source:      toAddr(r.Src),
 				Gateway:     toAddr(r.Gw),
 				Device:      dev,
@@ -906,7 +1014,7 @@ func (ns *namespace) NeighborList(filters ...*Neighbor) ([]*Neighbor, error) {
 		}
 
 		msg := netlink.Ndmsg{
-			Family: netlink.FAMILY_V4,
+			Family: netlink.FAMILY_ALL,
 			State:  uint16(filter.State),
 		}
 
@@ -950,6 +1058,16 @@ func (ns *namespace) NeighborList(filters ...*Neighbor) ([]*Neighbor, error) {
 
 // RedirectAdd adds a tc ingress qdisc and redirect filter that redirects all traffic from src to dst
 func (ns *namespace) RedirectAdd(src, dst string) error {
+	return ns.redirectAdd(src, dst, unix.ETH_P_ALL)
+}
+
+// RedirectIPAdd adds a tc ingress qdisc and redirect filters that redirect IPv4 and IPv6 traffic from src to dst.
+// Other traffic such as ARP is processed on src. dst can be a layer 3 interface.
+func (ns *namespace) RedirectIPAdd(src, dst string) error {
+	return ns.redirectAdd(src, dst, unix.ETH_P_IP, unix.ETH_P_IPV6)
+}
+
+func (ns *namespace) redirectAdd(src, dst string, protocols ...uint16) error {
 	srcLink, err := ns.handle.LinkByName(src)
 	if err != nil {
 		return fmt.Errorf("failed to get interface %s: %w", src, err)
@@ -970,31 +1088,33 @@ func (ns *namespace) RedirectAdd(src, dst string) error {
 		return fmt.Errorf("failed to add qdisc to %s: %w", src, err)
 	}
 
-	filter := &netlink.U32{
-		FilterAttrs: netlink.FilterAttrs{
-			LinkIndex: srcLink.Attrs().Index,
-			Parent:    netlink.MakeHandle(0xffff, 0),
-			Protocol:  unix.ETH_P_ALL,
-		},
-		Actions: []netlink.Action{
-			&netlink.MirredAction{
-				ActionAttrs: netlink.ActionAttrs{
-					Action: netlink.TC_ACT_STOLEN,
+	for _, protocol := range protocols {
+		filter := &netlink.U32{
+			FilterAttrs: netlink.FilterAttrs{
+				LinkIndex: srcLink.Attrs().Index,
+				Parent:    netlink.MakeHandle(0xffff, 0),
+				Protocol:  protocol,
+			},
+			Actions: []netlink.Action{
+				&netlink.MirredAction{
+					ActionAttrs: netlink.ActionAttrs{
+						Action: netlink.TC_ACT_STOLEN,
+					},
+					MirredAction: netlink.TCA_EGRESS_REDIR,
+					Ifindex:      dstLink.Attrs().Index,
 				},
-				MirredAction: netlink.TCA_EGRESS_REDIR,
-				Ifindex:      dstLink.Attrs().Index,
 			},
-		},
-	}
+		}
 
-	if err := ns.handle.FilterAdd(filter); err != nil {
-		return fmt.Errorf("failed to add a filter to %s : %w", src, err)
+		if err := ns.handle.FilterAdd(filter); err != nil {
+			return fmt.Errorf("failed to add a filter to %s : %w", src, err)
+		}
 	}
 
 	return nil
 }
 
-// RedirectDel deletes a tc ingress qdisc and redirect filters on src
+// RedirectDel deletes a tc ingress qdisc and redirect filters on src, including the filters of TunnelRedirectAdd
 func (ns *namespace) RedirectDel(src string) error {
 	srcLink, err := ns.handle.LinkByName(src)
 	if err != nil {
@@ -1006,7 +1126,8 @@ func (ns *namespace) RedirectDel(src string) error {
 		return fmt.Errorf("failed to get a list of filters on %s: %w", src, err)
 	}
 	for _, filter := range filters {
-		if _, ok := filter.(*netlink.U32); ok {
+		switch filter.(type) {
+		case *netlink.U32, *netlink.MatchAll:
 			if err = ns.handle.FilterDel(filter); err != nil {
 				return fmt.Errorf("failed to delete a filter to %s : %w", src, err)
 			}
@@ -1028,6 +1149,19 @@ func (ns *namespace) RedirectDel(src string) error {
 	return nil
 }
 
+// SysctlSet sets a kernel parameter such as net.ipv4.conf.eth0.forwarding in the network namespace
+func (ns *namespace) SysctlSet(key, value string) error {
+
+	path := filepath.Join("/proc/sys", strings.ReplaceAll(key, ".", "/"))
+
+	return ns.Run(func() error {
+		if err := os.WriteFile(path, []byte(value), 0o644); err != nil {
+			return fmt.Errorf("failed to set %s to %s (netns: %s): %w", key, value, ns.path, err)
+		}
+		return nil
+	})
+}
+
 func toAddr(ip net.IP) netip.Addr {
 
 	addr, _ := netip.AddrFromSlice(ip)
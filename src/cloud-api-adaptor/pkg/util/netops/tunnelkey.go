@@ -0,0 +1,287 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package netops
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// Attributes of Geneve options of the tunnel_key action (linux/tc_act/tc_tunnel_key.h)
+const (
+	tunnelKeyEncOptsGeneve     = 1
+	tunnelKeyEncOptGeneveClass = 1
+	tunnelKeyEncOptGeneveType  = 2
+	tunnelKeyEncOptGeneveData  = 3
+
+	maxGeneveOptionDataLen = 124
+)
+
+// GeneveOption is a TLV option of Geneve packets. The length of Data is a multiple of 4 bytes.
+type GeneveOption struct {
+	Class uint16 `json:"class"`
+	Type  uint8  `json:"type"`
+	Data  []byte `json:"data"`
+}
+
+// ParseGeneveOption parses a Geneve option in the <class>:<type>:<data> format of tc tunnel_key,
+// where all fields are hexadecimal, e.g. 0102:80:00112233
+func ParseGeneveOption(s string) (GeneveOption, error) {
+
+	fields := strings.Split(s, ":")
+	if len(fields) != 3 {
+		return GeneveOption{}, fmt.Errorf("invalid Geneve option %q, expected <class>:<type>:<data>", s)
+	}
+
+	class, err := strconv.ParseUint(fields[0], 16, 16)
+	if err != nil {
+		return GeneveOption{}, fmt.Errorf("invalid class of Geneve option %q: %w", s, err)
+	}
+	typ, err := strconv.ParseUint(fields[1], 16, 8)
+	if err != nil {
+		return GeneveOption{}, fmt.Errorf("invalid type of Geneve option %q: %w", s, err)
+	}
+	data, err := hex.DecodeString(fields[2])
+	if err != nil {
+		return GeneveOption{}, fmt.Errorf("invalid data of Geneve option %q: %w", s, err)
+	}
+
+	option := GeneveOption{Class: uint16(class), Type: uint8(typ), Data: data}
+	if err := option.validate(); err != nil {
+		return GeneveOption{}, err
+	}
+	return option, nil
+}
+
+func (o GeneveOption) String() string {
+	return fmt.Sprintf("%04x:%02x:%x", o.Class, o.Type, o.Data)
+}
+
+func (o GeneveOption) validate() error {
+	if len(o.Data)%4 != 0 || len(o.Data) > maxGeneveOptionDataLen {
+		return fmt.Errorf("invalid data length of Geneve option %s: %d (must be a multiple of 4 up to %d)", o, len(o.Data), maxGeneveOptionDataLen)
+	}
+	return nil
+}
+
+// TunnelKey is the tunnel metadata of packets that are sent through an external tunnel interface,
+// which takes the remote address, the VNI and the options of each packet from its metadata
+type TunnelKey struct {
+	Remote        netip.Addr
+	ID            int
+	Port          int
+	GeneveOptions []GeneveOption
+}
+
+// TunnelKeyAdd adds a tc clsact qdisc and an egress filter that set the tunnel key of packets sent through
+// the external tunnel interface name
+func (ns *namespace) TunnelKeyAdd(name string, key *TunnelKey) error {
+
+	link, err := ns.handle.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("failed to get interface %s: %w", name, err)
+	}
+
+	qdisc := &netlink.Clsact{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: link.Attrs().Index,
+			Parent:    netlink.HANDLE_CLSACT,
+			Handle:    netlink.MakeHandle(0xffff, 0),
+		},
+	}
+	if err := ns.handle.QdiscAdd(qdisc); err != nil {
+		return fmt.Errorf("failed to add qdisc to %s: %w", name, err)
+	}
+
+	tunnelKey, err := tunnelKeyAction(1, key, netlink.TC_ACT_OK)
+	if err != nil {
+		return err
+	}
+
+	if err := ns.matchAllAdd(link.Attrs().Index, netlink.HANDLE_MIN_EGRESS, tunnelKey); err != nil {
+		return fmt.Errorf("failed to add a tunnel key filter to %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// TunnelRedirectAdd adds a tc ingress qdisc and a filter that redirects all traffic from src to the
+// external tunnel interface dst, and sets the tunnel key of the packets
+func (ns *namespace) TunnelRedirectAdd(src, dst string, key *TunnelKey) error {
+
+	srcLink, err := ns.handle.LinkByName(src)
+	if err != nil {
+		return fmt.Errorf("failed to get interface %s: %w", src, err)
+	}
+
+	dstLink, err := ns.handle.LinkByName(dst)
+	if err != nil {
+		return fmt.Errorf("failed to get interface %s: %w", dst, err)
+	}
+
+	qdisc := &netlink.Ingress{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: srcLink.Attrs().Index,
+			Parent:    netlink.HANDLE_INGRESS,
+		},
+	}
+	if err := ns.handle.QdiscAdd(qdisc); err != nil {
+		return fmt.Errorf("failed to add qdisc to %s: %w", src, err)
+	}
+
+	tunnelKey, err := tunnelKeyAction(1, key, netlink.TC_ACT_PIPE)
+	if err != nil {
+		return err
+	}
+
+	redirect := nl.NewRtAttr(2, nil)
+	redirect.AddRtAttr(nl.TCA_ACT_KIND, nl.ZeroTerminated("mirred"))
+	mirred := nl.TcMirred{
+		TcGen:   nl.TcGen{Action: int32(netlink.TC_ACT_STOLEN)},
+		Eaction: int32(netlink.TCA_EGRESS_REDIR),
+		Ifindex: uint32(dstLink.Attrs().Index),
+	}
+	redirect.AddRtAttr(nl.TCA_ACT_OPTIONS, nil).AddRtAttr(nl.TCA_MIRRED_PARMS, mirred.Serialize())
+
+	if err := ns.matchAllAdd(srcLink.Attrs().Index, netlink.MakeHandle(0xffff, 0), tunnelKey, redirect); err != nil {
+		return fmt.Errorf("failed to add a filter to %s: %w", src, err)
+	}
+
+	return nil
+}
+
+// TunnelKeyGet returns the tunnel key set by the tc filters of TunnelKeyAdd or TunnelRedirectAdd on name.
+// Geneve options are not retrieved.
+func (ns *namespace) TunnelKeyGet(name string) (*TunnelKey, error) {
+
+	link, err := ns.handle.LinkByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interface %s: %w", name, err)
+	}
+
+	qdiscs, err := ns.handle.QdiscList(link)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a list of qdiscs on %s: %w", name, err)
+	}
+
+	for _, qdisc := range qdiscs {
+		var parent uint32
+		switch qdisc.(type) {
+		case *netlink.Ingress:
+			parent = netlink.MakeHandle(0xffff, 0)
+		case *netlink.Clsact:
+			parent = netlink.HANDLE_MIN_EGRESS
+		default:
+			continue
+		}
+
+		filters, err := ns.handle.FilterList(link, parent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get a list of filters on %s: %w", name, err)
+		}
+		for _, filter := range filters {
+			matchAll, ok := filter.(*netlink.MatchAll)
+			if !ok {
+				continue
+			}
+			for _, action := range matchAll.Actions {
+				if a, ok := action.(*netlink.TunnelKeyAction); ok && a.Action == netlink.TCA_TUNNEL_KEY_SET {
+					return &TunnelKey{
+						Remote: toAddr(a.DstAddr),
+						ID:     int(a.KeyID),
+						Port:   int(a.DestPort),
+					}, nil
+				}
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no tunnel key is set on %s", name)
+}
+
+// matchAllAdd adds a matchall filter with actions. The actions are built here since the tunnel_key action
+// of the netlink package does not support tunnel options.
+func (ns *namespace) matchAllAdd(linkIndex int, parent uint32, actions ...*nl.RtAttr) error {
+
+	return ns.Run(func() error {
+
+		req := nl.NewNetlinkRequest(unix.RTM_NEWTFILTER, unix.NLM_F_CREATE|unix.NLM_F_EXCL|unix.NLM_F_ACK)
+		req.AddData(&nl.TcMsg{
+			Family:  nl.FAMILY_ALL,
+			Ifindex: int32(linkIndex),
+			Parent:  parent,
+			Info:    netlink.MakeHandle(0, nl.Swap16(unix.ETH_P_ALL)),
+		})
+		req.AddData(nl.NewRtAttr(nl.TCA_KIND, nl.ZeroTerminated("matchall")))
+
+		options := nl.NewRtAttr(nl.TCA_OPTIONS, nil)
+		table := options.AddRtAttr(nl.TCA_MATCHALL_ACT, nil)
+		for _, action := range actions {
+			table.AddChild(action)
+		}
+		req.AddData(options)
+
+		if _, err := req.Execute(unix.NETLINK_ROUTE, 0); err != nil {
+			return fmt.Errorf("netns: %s: %w", ns.path, err)
+		}
+		return nil
+	})
+}
+
+// tunnelKeyAction returns the tunnel_key set action of key at index in the action table
+func tunnelKeyAction(index int, key *TunnelKey, verdict netlink.TcAct) (*nl.RtAttr, error) {
+
+	remote := key.Remote.Unmap()
+	if !remote.IsValid() {
+		return nil, fmt.Errorf("tunnel key has no remote address")
+	}
+
+	action := nl.NewRtAttr(index, nil)
+	action.AddRtAttr(nl.TCA_ACT_KIND, nl.ZeroTerminated("tunnel_key"))
+	options := action.AddRtAttr(nl.TCA_ACT_OPTIONS, nil)
+
+	parms := nl.TcTunnelKey{
+		TcGen:  nl.TcGen{Action: int32(verdict)},
+		Action: int32(netlink.TCA_TUNNEL_KEY_SET),
+	}
+	options.AddRtAttr(nl.TCA_TUNNEL_KEY_PARMS, parms.Serialize())
+	options.AddRtAttr(nl.TCA_TUNNEL_KEY_ENC_KEY_ID, binary.BigEndian.AppendUint32(nil, uint32(key.ID)))
+
+	// The unspecified source address lets the route to the remote address choose the source address
+	if remote.Is4() {
+		options.AddRtAttr(nl.TCA_TUNNEL_KEY_ENC_IPV4_SRC, netip.IPv4Unspecified().AsSlice())
+		options.AddRtAttr(nl.TCA_TUNNEL_KEY_ENC_IPV4_DST, remote.AsSlice())
+	} else {
+		options.AddRtAttr(nl.TCA_TUNNEL_KEY_ENC_IPV6_SRC, netip.IPv6Unspecified().AsSlice())
+		options.AddRtAttr(nl.TCA_TUNNEL_KEY_ENC_IPV6_DST, remote.AsSlice())
+	}
+
+	if key.Port != 0 {
+		options.AddRtAttr(nl.TCA_TUNNEL_KEY_ENC_DST_PORT, binary.BigEndian.AppendUint16(nil, uint16(key.Port)))
+	}
+
+	if len(key.GeneveOptions) > 0 {
+		// Each option is a nested attribute of its own
+		opts := options.AddRtAttr(unix.NLA_F_NESTED|nl.TCA_TUNNEL_KEY_ENC_OPTS, nil)
+		for _, option := range key.GeneveOptions {
+			if err := option.validate(); err != nil {
+				return nil, err
+			}
+			geneve := opts.AddRtAttr(unix.NLA_F_NESTED|tunnelKeyEncOptsGeneve, nil)
+			geneve.AddRtAttr(tunnelKeyEncOptGeneveClass, binary.BigEndian.AppendUint16(nil, option.Class))
+			geneve.AddRtAttr(tunnelKeyEncOptGeneveType, []byte{option.Type})
+			geneve.AddRtAttr(tunnelKeyEncOptGeneveData, option.Data)
+		}
+	}
+
+	return action, nil
+}
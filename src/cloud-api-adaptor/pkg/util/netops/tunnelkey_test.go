@@ -0,0 +1,103 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package netops
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	testutils "github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/internal/testing"
+)
+
+func TestParseGeneveOption(t *testing.T) {
+
+	for _, tc := range []struct {
+		s      string
+		option GeneveOption
+		err    bool
+	}{
+		{s: "0102:80:00112233", option: GeneveOption{Class: 0x0102, Type: 0x80, Data: []byte{0x00, 0x11, 0x22, 0x33}}},
+		{s: "ffff:01:0011223344556677", option: GeneveOption{Class: 0xffff, Type: 0x01, Data: []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77}}},
+		{s: "0102:80:", option: GeneveOption{Class: 0x0102, Type: 0x80, Data: []byte{}}},
+		{s: "0102:80", err: true},
+		{s: "10000:80:00112233", err: true},
+		{s: "0102:100:00112233", err: true},
+		{s: "0102:80:001122", err: true},
+		{s: "0102:80:0011223g", err: true},
+	} {
+		option, err := ParseGeneveOption(tc.s)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%q: expect error, got %v", tc.s, option)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: expect no error, got %v", tc.s, err)
+			continue
+		}
+		if option.Class != tc.option.Class || option.Type != tc.option.Type || !bytes.Equal(option.Data, tc.option.Data) {
+			t.Errorf("%q: expect %v, got %v", tc.s, tc.option, option)
+		}
+		if option.String() != tc.s {
+			t.Errorf("%q: expect the same string, got %q", tc.s, option.String())
+		}
+	}
+}
+
+func TestTunnelKey(t *testing.T) {
+	testutils.SkipTestIfNotRoot(t)
+
+	ns := newTestNamespace(t)
+
+	for _, name := range []string{"src", "dst", "tun"} {
+		link, err := ns.LinkAdd(name, &Bridge{})
+		if err != nil {
+			t.Fatalf("Expect no error, got %v", err)
+		}
+		if err := link.SetUp(); err != nil {
+			t.Fatalf("Expect no error, got %v", err)
+		}
+	}
+
+	key := &TunnelKey{
+		Remote:        netip.MustParseAddr("192.168.0.2"),
+		ID:            555001,
+		Port:          6082,
+		GeneveOptions: []GeneveOption{{Class: 0x0102, Type: 0x80, Data: []byte{0x00, 0x11, 0x22, 0x33}}},
+	}
+
+	if err := ns.TunnelRedirectAdd("src", "dst", key); err != nil {
+		if errors.Is(err, unix.ENOENT) || errors.Is(err, unix.EOPNOTSUPP) {
+			t.Skipf("tc matchall filter or tunnel_key action is not available: %v", err)
+		}
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	if err := ns.TunnelKeyAdd("tun", key); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	for _, name := range []string{"src", "tun"} {
+		got, err := ns.TunnelKeyGet(name)
+		if err != nil {
+			t.Fatalf("Expect no error, got %v", err)
+		}
+		if got.Remote != key.Remote || got.ID != key.ID || got.Port != key.Port {
+			t.Errorf("Expect tunnel key %v on %s, got %v", key, name, got)
+		}
+	}
+
+	if err := ns.RedirectDel("src"); err != nil {
+		t.Fatalf("Expect no error, got %v", err)
+	}
+
+	if _, err := ns.TunnelKeyGet("src"); err == nil {
+		t.Error("Expect error, got nil")
+	}
+}
@@ -0,0 +1,190 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package netops
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// Generic netlink constants of the WireGuard kernel module
+// https://github.com/torvalds/linux/blob/master/include/uapi/linux/wireguard.h
+const (
+	wgGenlName    = "wireguard"
+	wgGenlVersion = 1
+
+	wgCmdGetDevice = 0
+	wgCmdSetDevice = 1
+
+	wgDeviceAIfname     = 2
+	wgDeviceAPrivateKey = 3
+	wgDeviceAFlags      = 5
+	wgDeviceAListenPort = 6
+	wgDeviceAPeers      = 8
+
+	wgDeviceFReplacePeers = 1
+
+	wgPeerAPublicKey                   = 1
+	wgPeerAFlags                       = 3
+	wgPeerAEndpoint                    = 4
+	wgPeerAPersistentKeepaliveInterval = 5
+	wgPeerAAllowedIPs                  = 9
+
+	wgPeerFReplaceAllowedIPs = 2
+
+	wgAllowedIPAFamily   = 1
+	wgAllowedIPAIPAddr   = 2
+	wgAllowedIPACidrMask = 3
+
+	WireGuardKeyLen = 32
+)
+
+// WireGuard is a WireGuard interface. The encrypted UDP socket of a WireGuard interface stays
+// in the network namespace where the interface is created, even if the interface is moved to another namespace.
+type WireGuard struct {
+	PrivateKey []byte
+	ListenPort int
+	Peers      []*WireGuardPeer
+}
+
+type WireGuardPeer struct {
+	PublicKey           []byte
+	Endpoint            netip.AddrPort
+	AllowedIPs          []netip.Prefix
+	PersistentKeepalive time.Duration
+}
+
+func (d *WireGuard) getLink() netlink.Link {
+
+	return &netlink.Wireguard{}
+}
+
+// wireguardSet configures keys and peers of a WireGuard interface
+func (ns *namespace) wireguardSet(name string, d *WireGuard) error {
+
+	if len(d.PrivateKey) != WireGuardKeyLen {
+		return fmt.Errorf("invalid WireGuard private key length of %s: %d", name, len(d.PrivateKey))
+	}
+	if d.ListenPort < 0 || d.ListenPort > 0xffff {
+		return fmt.Errorf("invalid WireGuard listen port of %s: %d", name, d.ListenPort)
+	}
+
+	return ns.Run(func() error {
+
+		family, err := netlink.GenlFamilyGet(wgGenlName)
+		if err != nil {
+			return fmt.Errorf("failed to get generic netlink family %q: %w", wgGenlName, err)
+		}
+
+		req := nl.NewNetlinkRequest(int(family.ID), unix.NLM_F_ACK)
+		req.AddData(&nl.Genlmsg{Command: wgCmdSetDevice, Version: wgGenlVersion})
+		req.AddData(nl.NewRtAttr(wgDeviceAIfname, nl.ZeroTerminated(name)))
+		req.AddData(nl.NewRtAttr(wgDeviceAPrivateKey, d.PrivateKey))
+		req.AddData(nl.NewRtAttr(wgDeviceAListenPort, nl.Uint16Attr(uint16(d.ListenPort))))
+		req.AddData(nl.NewRtAttr(wgDeviceAFlags, nl.Uint32Attr(wgDeviceFReplacePeers)))
+
+		peers := nl.NewRtAttr(unix.NLA_F_NESTED|wgDeviceAPeers, nil)
+		for i, peer := range d.Peers {
+			if len(peer.PublicKey) != WireGuardKeyLen {
+				return fmt.Errorf("invalid WireGuard public key length of a peer of %s: %d", name, len(peer.PublicKey))
+			}
+
+			p := peers.AddRtAttr(unix.NLA_F_NESTED|i, nil)
+			p.AddRtAttr(wgPeerAPublicKey, peer.PublicKey)
+			p.AddRtAttr(wgPeerAFlags, nl.Uint32Attr(wgPeerFReplaceAllowedIPs))
+			if peer.Endpoint.IsValid() {
+				p.AddRtAttr(wgPeerAEndpoint, sockaddr(peer.Endpoint))
+			}
+			if peer.PersistentKeepalive > 0 {
+				p.AddRtAttr(wgPeerAPersistentKeepaliveInterval, nl.Uint16Attr(uint16(peer.PersistentKeepalive/time.Second)))
+			}
+
+			allowedIPs := p.AddRtAttr(unix.NLA_F_NESTED|wgPeerAAllowedIPs, nil)
+			for j, prefix := range peer.AllowedIPs {
+				family := unix.AF_INET
+				if prefix.Addr().Is6() {
+					family = unix.AF_INET6
+				}
+				a := allowedIPs.AddRtAttr(unix.NLA_F_NESTED|j, nil)
+				a.AddRtAttr(wgAllowedIPAFamily, nl.Uint16Attr(uint16(family)))
+				a.AddRtAttr(wgAllowedIPAIPAddr, prefix.Masked().Addr().AsSlice())
+				a.AddRtAttr(wgAllowedIPACidrMask, nl.Uint8Attr(uint8(prefix.Bits())))
+			}
+		}
+		req.AddData(peers)
+
+		if _, err := req.Execute(unix.NETLINK_GENERIC, 0); err != nil {
+			return fmt.Errorf("failed to configure WireGuard interface %s (netns: %s): %w", name, ns.path, err)
+		}
+
+		return nil
+	})
+}
+
+// wireguardListenPort returns the listen port of a WireGuard interface
+func (ns *namespace) wireguardListenPort(name string) (int, error) {
+
+	var port int
+
+	err := ns.Run(func() error {
+
+		family, err := netlink.GenlFamilyGet(wgGenlName)
+		if err != nil {
+			return fmt.Errorf("failed to get generic netlink family %q: %w", wgGenlName, err)
+		}
+
+		req := nl.NewNetlinkRequest(int(family.ID), unix.NLM_F_DUMP)
+		req.AddData(&nl.Genlmsg{Command: wgCmdGetDevice, Version: wgGenlVersion})
+		req.AddData(nl.NewRtAttr(wgDeviceAIfname, nl.ZeroTerminated(name)))
+
+		msgs, err := req.Execute(unix.NETLINK_GENERIC, 0)
+		if err != nil {
+			return fmt.Errorf("failed to get WireGuard interface %s (netns: %s): %w", name, ns.path, err)
+		}
+
+		for _, msg := range msgs {
+			attrs, err := nl.ParseRouteAttr(msg[nl.SizeofGenlmsg:])
+			if err != nil {
+				return fmt.Errorf("failed to parse attributes of WireGuard interface %s: %w", name, err)
+			}
+			for _, attr := range attrs {
+				if attr.Attr.Type == wgDeviceAListenPort {
+					port = int(binary.NativeEndian.Uint16(attr.Value))
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return port, err
+}
+
+// sockaddr returns a binary representation of struct sockaddr_in or sockaddr_in6
+func sockaddr(addrPort netip.AddrPort) []byte {
+
+	addr := addrPort.Addr().Unmap()
+
+	if addr.Is4() {
+		b := make([]byte, unix.SizeofSockaddrInet4)
+		binary.NativeEndian.PutUint16(b[0:2], unix.AF_INET)
+		binary.BigEndian.PutUint16(b[2:4], addrPort.Port())
+		a := addr.As4()
+		copy(b[4:8], a[:])
+		return b
+	}
+
+	b := make([]byte, unix.SizeofSockaddrInet6)
+	binary.NativeEndian.PutUint16(b[0:2], unix.AF_INET6)
+	binary.BigEndian.PutUint16(b[2:4], addrPort.Port())
+	a := addr.As16()
+	copy(b[8:24], a[:])
+	return b
+}
@@ -0,0 +1,81 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package redact removes sensitive values, such as keys, policies and initdata, from what is logged
+// or stored for debugging. It extends the redaction of structs in the cloud-providers util package.
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util"
+	putil "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util"
+)
+
+// PolicyAnnotationKey is the pod annotation of the kata agent policy
+const PolicyAnnotationKey = "io.katacontainers.config.agent.policy"
+
+// sensitiveAnnotations are annotations whose values are redacted
+var sensitiveAnnotations = map[string]bool{
+	util.InitdataAnnotationKey: true,
+	PolicyAnnotationKey:        true,
+}
+
+// sensitiveWords are words that mark other annotations as sensitive
+var sensitiveWords = []string{"secret", "password", "token", "credential", "private"}
+
+// SensitiveAnnotation reports whether the value of an annotation is sensitive
+func SensitiveAnnotation(key string) bool {
+	if sensitiveAnnotations[key] {
+		return true
+	}
+	lower := strings.ToLower(key)
+	for _, word := range sensitiveWords {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// Annotations returns a copy of annotations in which sensitive values are redacted. Key IDs of cloud volumes are
+// redacted, and the other attributes of cloud volumes are kept.
+func Annotations(annotations map[string]string) map[string]string {
+	redacted := putil.RedactMap(annotations, SensitiveAnnotation)
+	if value, ok := redacted[util.CloudVolumesAnnotationKey]; ok {
+		redacted[util.CloudVolumesAnnotationKey] = CloudVolumes(value)
+	}
+	return redacted
+}
+
+// CloudVolumes returns the cloud volumes annotation with the key IDs of volumes redacted
+func CloudVolumes(value string) string {
+	var volumes map[string]util.CloudVolumeAnnotation
+	if err := json.Unmarshal([]byte(value), &volumes); err != nil {
+		return putil.RedactString(value)
+	}
+	for name, volume := range volumes {
+		if volume.KeyID != "" {
+			volume.KeyID = putil.Replacement
+			volumes[name] = volume
+		}
+	}
+	data, err := json.Marshal(volumes)
+	if err != nil {
+		return putil.RedactString(value)
+	}
+	return string(data)
+}
+
+// Policy returns a placeholder of a kata agent policy, with its size and digest to tell policies apart
+func Policy(policy string) string {
+	if policy == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(policy))
+	return fmt.Sprintf("%s (%d bytes, sha256:%s)", putil.Replacement, len(policy), hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,44 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-api-adaptor/pkg/util"
+)
+
+func TestAnnotations(t *testing.T) {
+	annotations := map[string]string{
+		"io.kubernetes.cri.sandbox-name":  "web",
+		util.InitdataAnnotationKey:        "algorithm = \"sha256\"",
+		PolicyAnnotationKey:               "package agent_policy",
+		"example.com/db-password":         "hunter2",
+		util.CloudVolumesAnnotationKey:    `{"vol-0":{"mount_point":"/data","fs_type":"ext4","lun":"0","disk_id":"disk-1","key_id":"kbs:///default/key/1"}}`,
+		"io.katacontainers.pkg.oci.state": "",
+	}
+
+	redacted := Annotations(annotations)
+	assert.Equal(t, "web", redacted["io.kubernetes.cri.sandbox-name"])
+	assert.Equal(t, "**********", redacted[util.InitdataAnnotationKey])
+	assert.Equal(t, "**********", redacted[PolicyAnnotationKey])
+	assert.Equal(t, "**********", redacted["example.com/db-password"])
+	assert.Contains(t, redacted[util.CloudVolumesAnnotationKey], `"disk_id":"disk-1"`)
+	assert.NotContains(t, redacted[util.CloudVolumesAnnotationKey], "kbs:///")
+	assert.Equal(t, "", redacted["io.katacontainers.pkg.oci.state"])
+
+	// The original annotations are not modified
+	assert.Equal(t, "hunter2", annotations["example.com/db-password"])
+
+	assert.Equal(t, "**********", CloudVolumes("{invalid"))
+}
+
+func TestPolicy(t *testing.T) {
+	assert.Empty(t, Policy(""))
+	p := Policy("package agent_policy")
+	assert.NotContains(t, p, "agent_policy")
+	assert.Contains(t, p, "20 bytes, sha256:")
+}
@@ -0,0 +1,258 @@
+// Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package tlsutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// A credential store keeps the CA certificate/key pair and the client certificate/key pair
+// generated at the first start up of cloud-api-adaptor. Restarted instances load the same
+// credentials, so they can still talk to pod VMs that were issued certificates before the
+// restart. Multiple cloud-api-adaptor instances that share a store also share one trust root.
+
+// Names of the PEM-encoded credentials in a credential store
+const (
+	CACertName     = "ca.crt"
+	CAKeyName      = "ca.key"
+	ClientCertName = "client.crt"
+	ClientKeyName  = "client.key"
+)
+
+// credentialLockName is the name of the lock file of a file credential store
+const credentialLockName = ".lock"
+
+// ErrCredentialsExist is returned by CredentialStore.Create when credentials are already stored
+var ErrCredentialsExist = errors.New("credentials already exist")
+
+// Credentials holds the PEM-encoded CA and client certificates and their private keys
+type Credentials struct {
+	CACert     []byte
+	CAKey      []byte
+	ClientCert []byte
+	ClientKey  []byte
+}
+
+type CredentialStore interface {
+	// Load returns stored credentials. It returns nil if no credentials are stored yet.
+	Load(ctx context.Context) (*Credentials, error)
+	// Create stores credentials. It returns ErrCredentialsExist if credentials are already stored.
+	Create(ctx context.Context, creds *Credentials) error
+}
+
+// NewCredentials generates a self-signed CA certificate for caOrgName and a self-signed client certificate for clientOrgName
+func NewCredentials(caOrgName, clientOrgName string) (*Credentials, error) {
+
+	caCertPEM, caKeyPEM, err := generateCertificate(caOrgName, "", nil, nil, false, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate a CA certificate for %q: %w", caOrgName, err)
+	}
+
+	clientCertPEM, clientKeyPEM, err := NewClientCertificate(clientOrgName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credentials{
+		CACert:     caCertPEM,
+		CAKey:      caKeyPEM,
+		ClientCert: clientCertPEM,
+		ClientKey:  clientKeyPEM,
+	}, nil
+}
+
+// LoadOrCreateCredentials loads credentials from a store. When the store is empty, it generates
+// new credentials and stores them. If another instance stores credentials concurrently,
+// the credentials stored by that instance are returned.
+func LoadOrCreateCredentials(ctx context.Context, store CredentialStore, caOrgName, clientOrgName string) (*Credentials, error) {
+
+	creds, err := store.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load credentials: %w", err)
+	}
+	if creds != nil {
+		return creds, creds.validate()
+	}
+
+	creds, err = NewCredentials(caOrgName, clientOrgName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = store.Create(ctx, creds)
+	if errors.Is(err, ErrCredentialsExist) {
+		// Lost a race with another instance
+		creds, err = store.Load(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load credentials: %w", err)
+		}
+		if creds == nil {
+			return nil, errors.New("credentials disappeared from the store")
+		}
+		return creds, creds.validate()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store credentials: %w", err)
+	}
+
+	return creds, nil
+}
+
+func (c *Credentials) validate() error {
+
+	for name, data := range map[string][]byte{
+		CACertName:     c.CACert,
+		CAKeyName:      c.CAKey,
+		ClientCertName: c.ClientCert,
+		ClientKeyName:  c.ClientKey,
+	} {
+		if _, err := decodePEM(data); err != nil {
+			return fmt.Errorf("invalid %s in the credential store: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// NewCAServiceFromPEM creates a CA service that issues server certificates using an existing CA certificate and its private key
+func NewCAServiceFromPEM(orgName string, certPEM, keyPEM []byte) (CAService, error) {
+
+	// Issue a throwaway certificate to make sure the certificate and the key are usable
+	if _, _, err := generateCertificate(orgName, orgName, certPEM, keyPEM, false, false); err != nil {
+		return nil, fmt.Errorf("failed to set up a CA service for %q: %w", orgName, err)
+	}
+
+	s := &caService{
+		orgName: orgName,
+		certPEM: certPEM,
+		keyPEM:  keyPEM,
+	}
+
+	return s, nil
+}
+
+type fileCredentialStore struct {
+	dir string
+}
+
+// NewFileCredentialStore returns a credential store that keeps credentials as PEM files in dir
+func NewFileCredentialStore(dir string) CredentialStore {
+	return &fileCredentialStore{dir: dir}
+}
+
+func (s *fileCredentialStore) Load(ctx context.Context) (*Credentials, error) {
+
+	if _, err := os.Stat(filepath.Join(s.dir, CACertName)); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	var creds Credentials
+
+	for name, data := range map[string]*[]byte{
+		CACertName:     &creds.CACert,
+		CAKeyName:      &creds.CAKey,
+		ClientCertName: &creds.ClientCert,
+		ClientKeyName:  &creds.ClientKey,
+	} {
+		path := filepath.Join(s.dir, name)
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		*data = b
+	}
+
+	return &creds, nil
+}
+
+// Create writes credentials in the store directory itself, so that the directory may be a mount point
+// or have other content. Concurrent instances are serialized with a lock file. The CA certificate is
+// written last with O_EXCL, so that Load never sees partial credentials.
+func (s *fileCredentialStore) Create(ctx context.Context, creds *Credentials) error {
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", s.dir, err)
+	}
+
+	lockPath := filepath.Join(s.dir, credentialLockName)
+	lock, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", lockPath, err)
+	}
+	defer lock.Close()
+
+	// The lock is released when the file is closed, or when the process exits
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", lockPath, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(s.dir, CACertName)); err == nil {
+		return ErrCredentialsExist
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to check credentials in %s: %w", s.dir, err)
+	}
+
+	// Files left by an instance that crashed in the middle of Create are overwritten
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{
+		{CAKeyName, creds.CAKey},
+		{ClientCertName, creds.ClientCert},
+		{ClientKeyName, creds.ClientKey},
+	} {
+		if err := writeFileAtomic(filepath.Join(s.dir, file.name), file.data); err != nil {
+			return err
+		}
+	}
+
+	path := filepath.Join(s.dir, CACertName)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return ErrCredentialsExist
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := f.Write(creds.CACert); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path, and renames it to path
+func writeFileAtomic(path string, data []byte) error {
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary file for %s: %w", path, err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", f.Name(), err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.Name(), err)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename %s to %s: %w", f.Name(), path, err)
+	}
+
+	return nil
+}
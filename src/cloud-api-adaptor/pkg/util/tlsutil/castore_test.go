@@ -0,0 +1,123 @@
+// Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package tlsutil
+
+import (
+	"context"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCredentialStore(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "ca")
+
+	store := NewFileCredentialStore(dir)
+
+	creds, err := store.Load(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, creds)
+
+	creds1, err := LoadOrCreateCredentials(ctx, store, "agent-protocol-forwarder", "cloud-api-adaptor")
+	require.NoError(t, err)
+
+	for _, name := range []string{CACertName, CAKeyName, ClientCertName, ClientKeyName} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	// A restarted instance loads the same credentials
+	creds2, err := LoadOrCreateCredentials(ctx, NewFileCredentialStore(dir), "agent-protocol-forwarder", "cloud-api-adaptor")
+	require.NoError(t, err)
+	assert.Equal(t, creds1, creds2)
+
+	// Credentials are never overwritten
+	other, err := NewCredentials("agent-protocol-forwarder", "cloud-api-adaptor")
+	require.NoError(t, err)
+	assert.ErrorIs(t, store.Create(ctx, other), ErrCredentialsExist)
+}
+
+func TestFileCredentialStoreExistingDir(t *testing.T) {
+	ctx := context.Background()
+
+	// The directory may be a mount point that already has other files
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("other content"), 0o644))
+
+	creds1, err := LoadOrCreateCredentials(ctx, NewFileCredentialStore(dir), "agent-protocol-forwarder", "cloud-api-adaptor")
+	require.NoError(t, err)
+
+	creds2, err := NewFileCredentialStore(dir).Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, creds1, creds2)
+
+	_, err = os.Stat(filepath.Join(dir, "README"))
+	assert.NoError(t, err)
+}
+
+func TestFileCredentialStoreConcurrent(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "ca")
+
+	results := make([]*Credentials, 5)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			creds, err := LoadOrCreateCredentials(ctx, NewFileCredentialStore(dir), "agent-protocol-forwarder", "cloud-api-adaptor")
+			assert.NoError(t, err)
+			results[i] = creds
+		}(i)
+	}
+	wg.Wait()
+
+	for _, creds := range results[1:] {
+		assert.Equal(t, results[0], creds)
+	}
+}
+
+func TestFileCredentialStoreIncomplete(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, CACertName), []byte("dummy"), 0o600))
+
+	_, err := NewFileCredentialStore(dir).Load(context.Background())
+	assert.Error(t, err)
+}
+
+func TestNewCAServiceFromPEM(t *testing.T) {
+	creds, err := NewCredentials("agent-protocol-forwarder", "cloud-api-adaptor")
+	require.NoError(t, err)
+
+	caService, err := NewCAServiceFromPEM("agent-protocol-forwarder", creds.CACert, creds.CAKey)
+	require.NoError(t, err)
+	assert.Equal(t, creds.CACert, caService.RootCertificate())
+
+	certPEM, _, err := caService.Issue("podvm-test")
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(creds.CACert))
+
+	certDER, err := decodePEM(certPEM)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(certDER)
+	require.NoError(t, err)
+
+	_, err = cert.Verify(x509.VerifyOptions{Roots: roots, DNSName: "podvm-test"})
+	assert.NoError(t, err)
+
+	// A CA certificate paired with a key of another CA is rejected
+	other, err := NewCredentials("agent-protocol-forwarder", "cloud-api-adaptor")
+	require.NoError(t, err)
+	_, err = NewCAServiceFromPEM("agent-protocol-forwarder", creds.CACert, other.CAKey)
+	assert.Error(t, err)
+}
@@ -0,0 +1,152 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var logger = log.New(log.Writer(), "[util/tracing] ", log.LstdFlags|log.Lmsgprefix)
+
+const (
+	ExporterNone   = ""
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+
+	tracerName      = "github.com/confidential-containers/cloud-api-adaptor"
+	shutdownTimeout = 5 * time.Second
+)
+
+// Config specifies how spans are exported. Tracing is disabled when Exporter is empty.
+// The config of cloud-api-adaptor is passed to agent-protocol-forwarder in apf.json.
+type Config struct {
+	Exporter string `json:"exporter,omitempty"`
+	// Endpoint is the host:port of an OTLP gRPC collector. The OTEL_EXPORTER_OTLP_* environment variables are used when it is empty.
+	Endpoint string `json:"endpoint,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"`
+}
+
+// Enabled returns true if spans are exported
+func (c *Config) Enabled() bool {
+	return c != nil && c.Exporter != ExporterNone
+}
+
+// Validate returns an error if the exporter is unknown
+func (c *Config) Validate() error {
+	switch c.Exporter {
+	case ExporterNone, ExporterOTLP, ExporterStdout:
+		return nil
+	default:
+		return fmt.Errorf("unknown tracing exporter %q (supported: %s, %s)", c.Exporter, ExporterOTLP, ExporterStdout)
+	}
+}
+
+// Tracer returns the tracer used by cloud-api-adaptor components. Its spans are dropped until a Service is created.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Start starts a span as a child of the span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err in span if it is not nil, and ends span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+type Service struct {
+	provider *sdktrace.TracerProvider
+	readyCh  chan struct{}
+}
+
+// NewService installs a global tracer provider that exports spans as specified by cfg.
+// The returned service flushes pending spans when its context is canceled.
+func NewService(cfg *Config, serviceName string) (*Service, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		var opts []otlptracegrpc.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		// The gRPC connection is established lazily, so this does not block
+		exporter, err = otlptracegrpc.New(context.Background(), opts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("tracing is disabled")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	attrs := []attribute.KeyValue{attribute.String("service.name", serviceName)}
+	if hostname, err := os.Hostname(); err == nil {
+		attrs = append(attrs, attribute.String("host.name", hostname))
+	}
+	res := resource.NewSchemaless(attrs...)
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	logger.Printf("exporting traces of %s to %s", serviceName, cfg.Exporter)
+
+	return &Service{
+		provider: provider,
+		readyCh:  make(chan struct{}),
+	}, nil
+}
+
+func (s *Service) Start(ctx context.Context) error {
+	close(s.readyCh)
+
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := s.provider.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutting down tracer provider: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) Ready() chan struct{} {
+	return s.readyCh
+}
@@ -0,0 +1,91 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing
+
+import (
+	"context"
+
+	"github.com/containerd/ttrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The trace context crosses ttrpc connections as W3C trace context headers in ttrpc metadata
+
+type metadataCarrier ttrpc.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values, ok := ttrpc.MD(c).Get(key); ok {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	ttrpc.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+var _ propagation.TextMapCarrier = metadataCarrier{}
+
+// UnaryClientInterceptor injects the span context of ctx into the metadata of outgoing requests.
+// A trace context received from an upstream caller and forwarded in the metadata is replaced.
+func UnaryClientInterceptor() ttrpc.UnaryClientInterceptor {
+	return func(ctx context.Context, req *ttrpc.Request, resp *ttrpc.Response, info *ttrpc.UnaryClientInfo, invoker ttrpc.Invoker) error {
+		if trace.SpanContextFromContext(ctx).IsValid() {
+			propagator := otel.GetTextMapPropagator()
+
+			md := ttrpc.MD{}
+			propagator.Inject(ctx, metadataCarrier(md))
+
+			if len(md) > 0 {
+				var metadata []*ttrpc.KeyValue
+				for _, kv := range req.Metadata {
+					if _, ok := md[kv.Key]; !ok {
+						metadata = append(metadata, kv)
+					}
+				}
+				for key, values := range md {
+					for _, value := range values {
+						metadata = append(metadata, &ttrpc.KeyValue{Key: key, Value: value})
+					}
+				}
+				req.Metadata = metadata
+			}
+		}
+		return invoker(ctx, req, resp)
+	}
+}
+
+// UnaryServerInterceptor starts a server span for each incoming request. The span is a child of
+// the trace context in the request metadata. When the request has no trace context, the span is
+// a child of parent, if it is valid.
+func UnaryServerInterceptor(parent trace.SpanContext) ttrpc.UnaryServerInterceptor {
+	return func(ctx context.Context, unmarshal ttrpc.Unmarshaler, info *ttrpc.UnaryServerInfo, method ttrpc.Method) (interface{}, error) {
+		parentCtx := ctx
+		if md, ok := ttrpc.GetMetadata(ctx); ok {
+			parentCtx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+		}
+		if !trace.SpanContextFromContext(parentCtx).IsValid() && parent.IsValid() {
+			parentCtx = trace.ContextWithRemoteSpanContext(ctx, parent)
+		}
+
+		ctx, span := Tracer().Start(parentCtx, info.FullMethod,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.String("rpc.system", "ttrpc")))
+
+		res, err := method(ctx, unmarshal)
+		End(span, err)
+		return res, err
+	}
+}
@@ -0,0 +1,140 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/ttrpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const testService = "test.Service"
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+
+	return recorder
+}
+
+// serve starts a ttrpc server with a single Call method that runs handler
+func serve(t *testing.T, parent trace.SpanContext, handler func(ctx context.Context) error) *ttrpc.Client {
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+
+	server, err := ttrpc.NewServer(ttrpc.WithUnaryServerInterceptor(UnaryServerInterceptor(parent)))
+	require.NoError(t, err)
+
+	server.Register(testService, map[string]ttrpc.Method{
+		"Call": func(ctx context.Context, unmarshal func(interface{}) error) (interface{}, error) {
+			var req emptypb.Empty
+			if err := unmarshal(&req); err != nil {
+				return nil, err
+			}
+			return &emptypb.Empty{}, handler(ctx)
+		},
+	})
+
+	go func() {
+		_ = server.Serve(context.Background(), listener)
+	}()
+	t.Cleanup(func() { server.Close() })
+
+	conn, err := net.Dial("unix", socketPath)
+	require.NoError(t, err)
+
+	client := ttrpc.NewClient(conn, ttrpc.WithUnaryClientInterceptor(UnaryClientInterceptor()))
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+func call(ctx context.Context, client *ttrpc.Client) error {
+	return client.Call(ctx, testService, "Call", &emptypb.Empty{}, &emptypb.Empty{})
+}
+
+func TestTracePropagation(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	// backend plays the role of agent-protocol-forwarder
+	backend := serve(t, trace.SpanContext{}, func(ctx context.Context) error {
+		_, span := Start(ctx, "backend work")
+		span.End()
+		return nil
+	})
+
+	// frontend plays the role of the agent proxy, forwarding requests to the backend
+	frontend := serve(t, trace.SpanContext{}, func(ctx context.Context) error {
+		return call(ctx, backend)
+	})
+
+	ctx, root := Start(context.Background(), "root")
+	require.NoError(t, call(ctx, frontend))
+	root.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 4)
+
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range spans {
+		assert.Equal(t, root.SpanContext().TraceID(), span.SpanContext().TraceID(), "span %s is not in the trace", span.Name())
+		byName[span.Name()] = span
+	}
+
+	// The frontend and the backend both serve "/test.Service/Call". The frontend span is the child of root.
+	var frontendSpan, backendSpan sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		if span.Name() != "/"+testService+"/Call" {
+			continue
+		}
+		if span.Parent().SpanID() == root.SpanContext().SpanID() {
+			frontendSpan = span
+		} else {
+			backendSpan = span
+		}
+	}
+	require.NotNil(t, frontendSpan)
+	require.NotNil(t, backendSpan)
+	assert.Equal(t, trace.SpanKindServer, frontendSpan.SpanKind())
+	assert.Equal(t, frontendSpan.SpanContext().SpanID(), backendSpan.Parent().SpanID())
+	assert.True(t, backendSpan.Parent().IsRemote())
+	assert.Equal(t, backendSpan.SpanContext().SpanID(), byName["backend work"].Parent().SpanID())
+}
+
+func TestTraceDefaultParent(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	_, pod := Start(context.Background(), "pod")
+	pod.End()
+
+	// Requests without a trace context, such as requests from the kata shim, join the trace of the pod
+	client := serve(t, pod.SpanContext(), func(ctx context.Context) error { return nil })
+	require.NoError(t, call(context.Background(), client))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, pod.SpanContext().TraceID(), spans[1].SpanContext().TraceID())
+	assert.Equal(t, pod.SpanContext().SpanID(), spans[1].Parent().SpanID())
+}
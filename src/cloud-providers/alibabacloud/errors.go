@@ -0,0 +1,54 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package alibabacloud
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/alibabacloud-go/tea/tea"
+
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+)
+
+// sdkErrorCode returns the error code of an ECS API error, or an empty string for other errors
+func sdkErrorCode(err error) string {
+	var sdkErr *tea.SDKError
+	if errors.As(err, &sdkErr) {
+		return tea.StringValue(sdkErr.Code)
+	}
+	return ""
+}
+
+// toProviderError wraps an ECS API error with the matching provider error class
+func toProviderError(err error) error {
+	var sdkErr *tea.SDKError
+	if !errors.As(err, &sdkErr) {
+		return err
+	}
+
+	// Ref: https://api.aliyun.com/document/Ecs/2014-05-26/errorCode
+	switch code := tea.StringValue(sdkErr.Code); {
+	case code == "InvalidInstanceId.NotFound":
+		return provider.WrapError(provider.ErrInstanceNotFound, err)
+	case strings.Contains(code, "NoStock"), strings.Contains(code, "NotOnSale"), strings.Contains(code, "ResourceNotAvailable"):
+		return provider.WrapError(provider.ErrInsufficientCapacity, err)
+	case strings.Contains(code, "QuotaExceed"):
+		return provider.WrapError(provider.ErrQuotaExceeded, err)
+	case strings.HasPrefix(code, "Forbidden"), strings.HasPrefix(code, "InvalidAccessKeyId"), code == "SignatureDoesNotMatch":
+		return provider.WrapError(provider.ErrAuth, err)
+	case strings.HasPrefix(code, "Invalid"), strings.HasPrefix(code, "MissingParameter"):
+		return provider.WrapError(provider.ErrInvalidSpec, err)
+	}
+
+	switch tea.IntValue(sdkErr.StatusCode) {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return provider.WrapError(provider.ErrAuth, err)
+	case http.StatusBadRequest:
+		return provider.WrapError(provider.ErrInvalidSpec, err)
+	}
+
+	return err
+}
@@ -33,12 +33,15 @@ func (*Manager) ParseCmd(flags *flag.FlagSet) {
 	reg.BoolWithEnv(&alibabacloudcfg.UsePublicIP, "use-public-ip", false, "USE_PUBLIC_IP", "Use Public IP for connecting to the kata-agent inside the Pod VM")
 	reg.IntWithEnv(&alibabacloudcfg.SystemDiskSize, "system-disk-size", 40, "SYSTEM_DISK_SIZE", "System Disk size (in GiB) for the Pod VMs")
 	reg.BoolWithEnv(&alibabacloudcfg.DisableCVM, "disable-cvm", false, "DISABLECVM", "Use non-CVMs for peer pods")
+	reg.IntWithEnv(&alibabacloudcfg.FallbackMaxAttempts, "fallback-max-attempts", 0, "FALLBACK_MAX_ATTEMPTS", "Maximum number of instance creation attempts when capacity or quota is exhausted (0 tries every fallback)")
+	reg.BoolWithEnv(&alibabacloudcfg.FallbackLargerInstanceTypes, "fallback-larger-instance-types", false, "FALLBACK_LARGER_INSTANCE_TYPES", "Fall back to larger instance types from PODVM_INSTANCE_TYPES when capacity or quota is exhausted")
 
 	// Flags without environment variable support (pass empty string for envVarName)
 	reg.StringWithEnv(&alibabacloudcfg.VpcID, "vpc-id", "", "", "VPC ID to be used for the Pod VMs")
 
 	// Custom flag types (comma-separated lists)
 	reg.CustomTypeWithEnv(&alibabacloudcfg.SecurityGroupIDs, "security-group-ids", "cn-beijing", "SECURITY_GROUP_IDS", "Security Group Ids to be used for the Pod VM, comma separated")
+	reg.CustomTypeWithEnv(&alibabacloudcfg.FallbackVswitchIDs, "fallback-vswitch-ids", "", "FALLBACK_VSWITCH_IDS", "Alternate vSwitch IDs to fall back to when capacity or quota is exhausted, comma separated")
 	reg.CustomTypeWithEnv(&alibabacloudcfg.Tags, "tags", "", "TAGS", "Custom tags (key=value pairs) to be used for the Pod VMs, comma separated")
 }
 
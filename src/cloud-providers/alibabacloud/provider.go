@@ -6,6 +6,7 @@ package alibabacloud
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -45,6 +46,9 @@ type ecsClient interface {
 	// Describe InstanceTypes
 	DescribeInstanceTypes(
 		params *ecs.DescribeInstanceTypesRequest) (*ecs.DescribeInstanceTypesResponse, error)
+	// Describe Instances
+	DescribeInstances(
+		params *ecs.DescribeInstancesRequest) (*ecs.DescribeInstancesResponse, error)
 	// Describe InstanceAttribute
 	DescribeInstanceAttribute(
 		params *ecs.DescribeInstanceAttributeRequest) (*ecs.DescribeInstanceAttributeResponse, error)
@@ -279,18 +283,35 @@ func (p *alibabaCloudProvider) CreateInstance(ctx context.Context, podName, sand
 
 	logger.Printf("CreateInstance: name: %q", instanceName)
 
-	result, err := p.ecsClient.RunInstances(req)
+	policy := provider.FallbackPolicy{
+		MaxAttempts:         p.serviceConfig.FallbackMaxAttempts,
+		LargerInstanceTypes: p.serviceConfig.FallbackLargerInstanceTypes && spec.InstanceType == "",
+	}
+	attempts := policy.Attempts(instanceType, p.serviceConfig.InstanceTypeSpecList, p.serviceConfig.FallbackVswitchIDs)
+
+	result, attempt, err := provider.CreateWithFallback(ctx, attempts, func(ctx context.Context, attempt provider.CreateAttempt) (*ecs.RunInstancesResponse, error) {
+		req.InstanceType = tea.String(attempt.InstanceType)
+		if attempt.Placement != "" {
+			req.VSwitchId = tea.String(attempt.Placement)
+		}
+		result, err := p.ecsClient.RunInstances(req)
+		if err != nil {
+			return nil, fmt.Errorf("creating instance (%v) returned error: %w", result, toProviderError(err))
+		}
+		return result, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("creating instance (%v) returned error: %s", result, err)
+		return nil, err
 	}
 
 	instanceID := *result.Body.InstanceIdSets.InstanceIdSet[0]
-	logger.Printf("created an instance %s for sandbox %s", instanceID, sandboxID)
+	logger.Printf("created an instance %s for sandbox %s with %s", instanceID, sandboxID, attempt)
 
 	// Create partial instance to return on error (allows caller to cleanup)
 	instance = &provider.Instance{
 		ID:   instanceID,
 		Name: instanceName,
+		Type: attempt.InstanceType,
 	}
 
 	// Wait instance to create
@@ -376,24 +397,25 @@ func (p *alibabaCloudProvider) DeleteInstance(ctx context.Context, instanceID st
 		}
 		resp, err := p.ecsClient.DeleteInstance(&req)
 		if err != nil {
-			if *err.(*tea.SDKError).Code == "IncorrectInstanceStatus" {
+			if sdkErrorCode(err) == "IncorrectInstanceStatus" {
 				logger.Printf("instance %s is not in the correct state to be deleted, retrying", instanceID)
 				return false, nil
 			}
 
-			if *err.(*tea.SDKError).Code == "InvalidInstanceId.NotFound" {
+			err = toProviderError(err)
+			if errors.Is(err, provider.ErrInstanceNotFound) {
 				logger.Printf("instance %s is not found", instanceID)
 				return true, nil
 			}
 
 			logger.Printf("failed to delete an instance: %v and the response is %v", err, resp)
-			return false, fmt.Errorf("failed to delete an instance %s: %+v", instanceID, err)
+			return false, fmt.Errorf("failed to delete an instance %s: %w", instanceID, err)
 		}
 
 		return true, nil
 	})
 	if err != nil {
-		return fmt.Errorf("failed to delete an instance %s", instanceID)
+		return err
 	}
 
 	logger.Printf("Deleted an instance %s", instanceID)
@@ -825,3 +847,129 @@ func (p *alibabaCloudProvider) bindEipToNic(eipID *string, nicID *string) error
 	logger.Printf("Bound EIP %s to NIC %s successfully", *eipID, *nicID)
 	return nil
 }
+
+func toInstanceStatus(status *string) provider.InstanceStatus {
+	switch tea.StringValue(status) {
+	case "Pending", "Starting":
+		return provider.InstanceStatusPending
+	case "Running":
+		return provider.InstanceStatusRunning
+	case "Stopping", "Stopped":
+		return provider.InstanceStatusStopped
+	}
+	return provider.InstanceStatusUnknown
+}
+
+func (p *alibabaCloudProvider) toInstanceInfo(instance *ecs.DescribeInstancesResponseBodyInstancesInstance) *provider.InstanceInfo {
+	info := &provider.InstanceInfo{
+		Instance: provider.Instance{
+			ID:   tea.StringValue(instance.InstanceId),
+			Name: tea.StringValue(instance.InstanceName),
+		},
+		Status:       toInstanceStatus(instance.Status),
+		InstanceType: tea.StringValue(instance.InstanceType),
+		Tags:         map[string]string{},
+	}
+
+	// CreationTime is in the form of 2017-12-10T04:04Z
+	if t, err := time.Parse("2006-01-02T15:04Z", tea.StringValue(instance.CreationTime)); err == nil {
+		info.CreatedAt = t
+	}
+
+	if instance.Tags != nil {
+		for _, tag := range instance.Tags.Tag {
+			info.Tags[tea.StringValue(tag.TagKey)] = tea.StringValue(tag.TagValue)
+		}
+	}
+
+	if instance.VpcAttributes != nil && instance.VpcAttributes.PrivateIpAddress != nil {
+		for _, addr := range instance.VpcAttributes.PrivateIpAddress.IpAddress {
+			if ip, err := netip.ParseAddr(tea.StringValue(addr)); err == nil {
+				info.IPs = append(info.IPs, ip)
+			}
+		}
+	}
+
+	// Like CreateInstance, report the public IP address as the first IP address when it is in use
+	if p.serviceConfig.UsePublicIP && instance.EipAddress != nil && len(info.IPs) > 0 {
+		if ip, err := netip.ParseAddr(tea.StringValue(instance.EipAddress.IpAddress)); err == nil {
+			info.IPs[0] = ip
+		}
+	}
+
+	return info
+}
+
+func (p *alibabaCloudProvider) GetInstance(ctx context.Context, instanceID string) (*provider.InstanceInfo, error) {
+	instanceIDs, err := json.Marshal([]string{instanceID})
+	if err != nil {
+		return nil, err
+	}
+
+	req := &ecs.DescribeInstancesRequest{
+		RegionId:    tea.String(p.serviceConfig.Region),
+		InstanceIds: tea.String(string(instanceIDs)),
+	}
+	resp, err := p.ecsClient.DescribeInstances(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe instance %s: %w", instanceID, toProviderError(err))
+	}
+
+	if resp.Body != nil && resp.Body.Instances != nil {
+		for _, instance := range resp.Body.Instances.Instance {
+			if tea.StringValue(instance.InstanceId) == instanceID {
+				return p.toInstanceInfo(instance), nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("instance %s: %w", instanceID, provider.ErrInstanceNotFound)
+}
+
+func (p *alibabaCloudProvider) ListInstances(ctx context.Context, filter provider.InstanceFilter) ([]*provider.InstanceInfo, error) {
+	req := &ecs.DescribeInstancesRequest{
+		RegionId:   tea.String(p.serviceConfig.Region),
+		MaxResults: tea.Int32(100),
+	}
+	if p.serviceConfig.VpcID != "" {
+		req.VpcId = tea.String(p.serviceConfig.VpcID)
+	}
+	if filter.NamePrefix != "" {
+		// InstanceName supports a trailing wildcard
+		req.InstanceName = tea.String(filter.NamePrefix + "*")
+	}
+	for k, v := range filter.Tags {
+		req.Tag = append(req.Tag, &ecs.DescribeInstancesRequestTag{
+			Key:   tea.String(k),
+			Value: tea.String(v),
+		})
+	}
+
+	var instances []*provider.InstanceInfo
+
+	for {
+		resp, err := p.ecsClient.DescribeInstances(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list instances: %v", err)
+		}
+		if resp.Body == nil {
+			break
+		}
+
+		if resp.Body.Instances != nil {
+			for _, instance := range resp.Body.Instances.Instance {
+				info := p.toInstanceInfo(instance)
+				if filter.Match(info) {
+					instances = append(instances, info)
+				}
+			}
+		}
+
+		if tea.StringValue(resp.Body.NextToken) == "" {
+			break
+		}
+		req.NextToken = resp.Body.NextToken
+	}
+
+	return instances, nil
+}
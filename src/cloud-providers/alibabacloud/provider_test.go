@@ -0,0 +1,116 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package alibabacloud
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	ecs "github.com/alibabacloud-go/ecs-20140526/v4/client"
+	"github.com/alibabacloud-go/tea/tea"
+
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+)
+
+// mockECSClient returns the instances of pages from DescribeInstances, and records the requests
+type mockECSClient struct {
+	ecsClient
+	pages    [][]*ecs.DescribeInstancesResponseBodyInstancesInstance
+	requests []*ecs.DescribeInstancesRequest
+}
+
+func (m *mockECSClient) DescribeInstances(req *ecs.DescribeInstancesRequest) (*ecs.DescribeInstancesResponse, error) {
+	copied := *req
+	m.requests = append(m.requests, &copied)
+
+	page := len(m.requests) - 1
+	body := &ecs.DescribeInstancesResponseBody{
+		Instances: &ecs.DescribeInstancesResponseBodyInstances{Instance: m.pages[page]},
+	}
+	if page+1 < len(m.pages) {
+		body.NextToken = tea.String("next")
+	}
+	return &ecs.DescribeInstancesResponse{Body: body}, nil
+}
+
+func testInstance(name string, tags map[string]string) *ecs.DescribeInstancesResponseBodyInstancesInstance {
+	instance := &ecs.DescribeInstancesResponseBodyInstancesInstance{
+		InstanceId:   tea.String("i-" + name),
+		InstanceName: tea.String(name),
+		Status:       tea.String("Stopped"),
+		Tags:         &ecs.DescribeInstancesResponseBodyInstancesInstanceTags{},
+	}
+	for k, v := range tags {
+		instance.Tags.Tag = append(instance.Tags.Tag, &ecs.DescribeInstancesResponseBodyInstancesInstanceTagsTag{
+			TagKey:   tea.String(k),
+			TagValue: tea.String(v),
+		})
+	}
+	return instance
+}
+
+func TestListInstances(t *testing.T) {
+	pages := [][]*ecs.DescribeInstancesResponseBodyInstancesInstance{
+		{
+			testInstance("podvm-a", map[string]string{"cluster": "c1"}),
+			testInstance("podvm-b", map[string]string{"cluster": "c2"}),
+		},
+		{
+			testInstance("other", map[string]string{"cluster": "c1"}),
+			testInstance("plain", nil),
+		},
+	}
+
+	for _, tc := range []struct {
+		name         string
+		filter       provider.InstanceFilter
+		want         []string
+		instanceName string
+	}{
+		{name: "empty filter", want: []string{"podvm-a", "podvm-b", "other", "plain"}},
+		{name: "name prefix", filter: provider.InstanceFilter{NamePrefix: "podvm-"}, want: []string{"podvm-a", "podvm-b"}, instanceName: "podvm-*"},
+		{name: "tags", filter: provider.InstanceFilter{Tags: map[string]string{"cluster": "c1"}}, want: []string{"podvm-a", "other"}},
+		{name: "name prefix and tags", filter: provider.InstanceFilter{NamePrefix: "podvm-", Tags: map[string]string{"cluster": "c1"}}, want: []string{"podvm-a"}, instanceName: "podvm-*"},
+		{name: "no match", filter: provider.InstanceFilter{Tags: map[string]string{"cluster": "c3"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockECSClient{pages: pages}
+			p := &alibabaCloudProvider{
+				ecsClient:     client,
+				serviceConfig: &Config{Region: "cn-test"},
+			}
+
+			instances, err := p.ListInstances(context.Background(), tc.filter)
+			if err != nil {
+				t.Fatalf("ListInstances() error = %v", err)
+			}
+			var got []string
+			for _, info := range instances {
+				got = append(got, info.Name)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ListInstances() = %v, want %v", got, tc.want)
+			}
+
+			if len(client.requests) != len(pages) {
+				t.Fatalf("DescribeInstances() called %d times, want %d", len(client.requests), len(pages))
+			}
+			req := client.requests[0]
+			if name := tea.StringValue(req.InstanceName); name != tc.instanceName {
+				t.Errorf("InstanceName = %q, want %q", name, tc.instanceName)
+			}
+			var tags map[string]string
+			for _, tag := range req.Tag {
+				if tags == nil {
+					tags = map[string]string{}
+				}
+				tags[tea.StringValue(tag.Key)] = tea.StringValue(tag.Value)
+			}
+			if !reflect.DeepEqual(tags, tc.filter.Tags) {
+				t.Errorf("Tag = %v, want %v", tags, tc.filter.Tags)
+			}
+		})
+	}
+}
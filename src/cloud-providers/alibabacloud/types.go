@@ -35,6 +35,21 @@ func (i *instanceTypes) Set(value string) error {
 	return nil
 }
 
+type vswitchIDs []string
+
+func (i *vswitchIDs) String() string {
+	return strings.Join(*i, ", ")
+}
+
+func (i *vswitchIDs) Set(value string) error {
+	if len(value) == 0 {
+		*i = make(vswitchIDs, 0)
+	} else {
+		*i = append(*i, strings.Split(value, ",")...)
+	}
+	return nil
+}
+
 type Config struct {
 	AccessKeyID          string
 	SecretKey            string
@@ -52,6 +67,10 @@ type Config struct {
 	UsePublicIP          bool
 	SystemDiskSize       int
 	DisableCVM           bool
+	// Instance creation fallback when capacity or quota is exhausted
+	FallbackMaxAttempts         int
+	FallbackLargerInstanceTypes bool
+	FallbackVswitchIDs          vswitchIDs
 }
 
 func (c Config) Redact() Config {
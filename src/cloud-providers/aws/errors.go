@@ -0,0 +1,53 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package aws
+
+import (
+	"errors"
+	"strings"
+
+	smithy "github.com/aws/smithy-go"
+
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+)
+
+// Ref: https://docs.aws.amazon.com/AWSEC2/latest/APIReference/errors-overview.html
+var authErrorCodes = map[string]bool{
+	"AuthFailure":           true,
+	"Blocked":               true,
+	"ExpiredToken":          true,
+	"InvalidClientTokenId":  true,
+	"OptInRequired":         true,
+	"PendingVerification":   true,
+	"SignatureDoesNotMatch": true,
+	"UnauthorizedOperation": true,
+}
+
+// toProviderError wraps an EC2 API error with the matching provider error class
+func toProviderError(err error) error {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+
+	code := apiErr.ErrorCode()
+	switch {
+	case code == "InvalidInstanceID.NotFound":
+		return provider.WrapError(provider.ErrInstanceNotFound, err)
+	case strings.HasPrefix(code, "Insufficient"), code == "Unsupported":
+		// Unsupported is returned when the instance type is not offered in the availability zone
+		return provider.WrapError(provider.ErrInsufficientCapacity, err)
+	case code == "RequestLimitExceeded":
+		// API throttling is not a quota of resources
+		return err
+	case strings.HasSuffix(code, "LimitExceeded"), code == "MaxSpotInstanceCountExceeded":
+		return provider.WrapError(provider.ErrQuotaExceeded, err)
+	case authErrorCodes[code]:
+		return provider.WrapError(provider.ErrAuth, err)
+	case strings.HasPrefix(code, "Invalid"), strings.HasPrefix(code, "MissingParameter"):
+		return provider.WrapError(provider.ErrInvalidSpec, err)
+	}
+
+	return err
+}
@@ -0,0 +1,47 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package aws
+
+import (
+	"errors"
+	"testing"
+
+	smithy "github.com/aws/smithy-go"
+
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+)
+
+func TestToProviderError(t *testing.T) {
+	tests := []struct {
+		code string
+		want error
+	}{
+		{"InsufficientInstanceCapacity", provider.ErrInsufficientCapacity},
+		{"Unsupported", provider.ErrInsufficientCapacity},
+		{"VcpuLimitExceeded", provider.ErrQuotaExceeded},
+		{"InstanceLimitExceeded", provider.ErrQuotaExceeded},
+		{"UnauthorizedOperation", provider.ErrAuth},
+		{"AuthFailure", provider.ErrAuth},
+		{"InvalidAMIID.NotFound", provider.ErrInvalidSpec},
+		{"InvalidParameterValue", provider.ErrInvalidSpec},
+		{"InvalidInstanceID.NotFound", provider.ErrInstanceNotFound},
+		{"RequestLimitExceeded", nil},
+		{"InternalError", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			apiErr := &smithy.GenericAPIError{Code: tt.code, Message: "test"}
+			err := toProviderError(apiErr)
+			if !errors.Is(err, apiErr) {
+				t.Errorf("toProviderError() = %v, does not wrap %v", err, apiErr)
+			}
+			for _, class := range []error{provider.ErrInsufficientCapacity, provider.ErrQuotaExceeded, provider.ErrAuth, provider.ErrInvalidSpec, provider.ErrInstanceNotFound} {
+				if errors.Is(err, class) != (class == tt.want) {
+					t.Errorf("toProviderError() = %v, errors.Is(%v) = %v", err, class, !(class == tt.want))
+				}
+			}
+		})
+	}
+}
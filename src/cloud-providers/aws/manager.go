@@ -34,6 +34,8 @@ func (*Manager) ParseCmd(flags *flag.FlagSet) {
 	reg.BoolWithEnv(&awscfg.UsePublicIP, "use-public-ip", false, "USE_PUBLIC_IP", "Use Public IP for connecting to the kata-agent inside the Pod VM")
 	reg.IntWithEnv(&awscfg.RootVolumeSize, "root-volume-size", 30, "ROOT_VOLUME_SIZE", "Root volume size (in GiB) for the Pod VMs")
 	reg.BoolWithEnv(&awscfg.DisableCVM, "disable-cvm", false, "DISABLECVM", "Use non-CVMs for peer pods")
+	reg.IntWithEnv(&awscfg.FallbackMaxAttempts, "fallback-max-attempts", 0, "FALLBACK_MAX_ATTEMPTS", "Maximum number of instance creation attempts when capacity or quota is exhausted (0 tries every fallback)")
+	reg.BoolWithEnv(&awscfg.FallbackLargerInstanceTypes, "fallback-larger-instance-types", false, "FALLBACK_LARGER_INSTANCE_TYPES", "Fall back to larger instance types from PODVM_INSTANCE_TYPES when capacity or quota is exhausted")
 
 	// Flags without environment variable support (pass empty string for envVarName)
 	reg.StringWithEnv(&awscfg.LoginProfile, "aws-profile", "", "", "AWS Login Profile")
@@ -41,6 +43,7 @@ func (*Manager) ParseCmd(flags *flag.FlagSet) {
 	// Custom flag types (comma-separated lists)
 	reg.CustomTypeWithEnv(&awscfg.SecurityGroupIDs, "securitygroupids", "", "AWS_SG_IDS", "Security Group Ids to be used for the Pod VM, comma separated")
 	reg.CustomTypeWithEnv(&awscfg.InstanceTypes, "instance-types", "", "PODVM_INSTANCE_TYPES", "Instance types to be used for the Pod VMs, comma separated")
+	reg.CustomTypeWithEnv(&awscfg.FallbackSubnetIDs, "fallback-subnet-ids", "", "FALLBACK_SUBNET_IDS", "Alternate subnet IDs to fall back to when capacity or quota is exhausted, comma separated")
 	reg.CustomTypeWithEnv(&awscfg.Tags, "tags", "", "TAGS", "Custom tags (key=value pairs) to be used for the Pod VMs, comma separated")
 }
 
@@ -95,6 +95,12 @@ type ec2Client interface {
 	DescribeVolumes(ctx context.Context,
 		params *ec2.DescribeVolumesInput,
 		optFns ...func(*ec2.Options)) (*ec2.DescribeVolumesOutput, error)
+	AssignPrivateIpAddresses(ctx context.Context,
+		params *ec2.AssignPrivateIpAddressesInput,
+		optFns ...func(*ec2.Options)) (*ec2.AssignPrivateIpAddressesOutput, error)
+	UnassignPrivateIpAddresses(ctx context.Context,
+		params *ec2.UnassignPrivateIpAddressesInput,
+		optFns ...func(*ec2.Options)) (*ec2.UnassignPrivateIpAddressesOutput, error)
 }
 
 // Make instanceRunningWaiter as an interface
@@ -322,19 +328,36 @@ func (p *awsProvider) CreateInstance(ctx context.Context, podName, sandboxID str
 
 	logger.Printf("Creating instance %s for sandbox %s", instanceName, sandboxID)
 
-	result, err := p.ec2Client.RunInstances(ctx, input)
+	result, attempt, err := provider.CreateWithFallback(ctx, p.createAttempts(instanceType, spec), func(ctx context.Context, attempt provider.CreateAttempt) (*ec2.RunInstancesOutput, error) {
+		if !p.serviceConfig.UseLaunchTemplate {
+			input.InstanceType = types.InstanceType(attempt.InstanceType)
+			if attempt.Placement != "" {
+				if input.NetworkInterfaces != nil {
+					input.NetworkInterfaces[0].SubnetId = aws.String(attempt.Placement)
+				} else {
+					input.SubnetId = aws.String(attempt.Placement)
+				}
+			}
+		}
+		result, err := p.ec2Client.RunInstances(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("creating instance %s (%v): %w", instanceName, result, toProviderError(err))
+		}
+		return result, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("creating instance %s (%v): %w", instanceName, result, err)
+		return nil, err
 	}
 
 	instanceID := *result.Instances[0].InstanceId
 
-	logger.Printf("Created instance %s (%s) for sandbox %s", instanceName, instanceID, sandboxID)
+	logger.Printf("Created instance %s (%s) for sandbox %s with %s", instanceName, instanceID, sandboxID, attempt)
 
 	// Create partial instance to return on error (allows caller to cleanup)
 	instance = &provider.Instance{
 		ID:   instanceID,
 		Name: instanceName,
+		Type: attempt.InstanceType,
 	}
 
 	ips, err := getIPs(result.Instances[0])
@@ -534,6 +557,11 @@ func (p *awsProvider) DeleteInstance(ctx context.Context, instanceID string) err
 
 	resp, err := p.ec2Client.TerminateInstances(ctx, terminateInput)
 	if err != nil {
+		err = toProviderError(err)
+		if errors.Is(err, provider.ErrInstanceNotFound) {
+			logger.Printf("Instance %s is already deleted", instanceID)
+			return nil
+		}
 		logger.Printf("failed to delete instance %v: %v and the response is %v", instanceID, err, resp)
 		return err
 	}
@@ -559,6 +587,27 @@ func (p *awsProvider) selectInstanceType(_ context.Context, spec provider.Instan
 	return provider.SelectInstanceTypeToUse(spec, p.serviceConfig.InstanceTypeSpecList, p.serviceConfig.InstanceTypes, p.serviceConfig.InstanceType)
 }
 
+// createAttempts returns the instance type and subnet combinations to try when capacity or quota is exhausted.
+// Instances created from a launch template use the instance type and subnet of the template, and EBS volumes
+// can only be attached to instances in their availability zone, so there is no fallback in these cases.
+func (p *awsProvider) createAttempts(instanceType string, spec provider.InstanceTypeSpec) []provider.CreateAttempt {
+	if p.serviceConfig.UseLaunchTemplate {
+		return []provider.CreateAttempt{{InstanceType: instanceType}}
+	}
+
+	policy := provider.FallbackPolicy{
+		MaxAttempts:         p.serviceConfig.FallbackMaxAttempts,
+		LargerInstanceTypes: p.serviceConfig.FallbackLargerInstanceTypes && spec.InstanceType == "",
+	}
+
+	var subnets []string
+	if len(spec.Volumes) == 0 {
+		subnets = p.serviceConfig.FallbackSubnetIDs
+	}
+
+	return policy.Attempts(instanceType, p.serviceConfig.InstanceTypeSpecList, subnets)
+}
+
 // Add a method to populate InstanceTypeSpecList for all the instanceTypes
 func (p *awsProvider) updateInstanceTypeSpecList() error {
 	// Get the instance types from the service config
@@ -889,3 +938,216 @@ func (p *awsProvider) getDeviceNameAndSize(imageID string) (string, int32, error
 
 	return *deviceName, *deviceSize, nil
 }
+
+func toInstanceStatus(state *types.InstanceState) provider.InstanceStatus {
+	if state == nil {
+		return provider.InstanceStatusUnknown
+	}
+	switch state.Name {
+	case types.InstanceStateNamePending:
+		return provider.InstanceStatusPending
+	case types.InstanceStateNameRunning:
+		return provider.InstanceStatusRunning
+	case types.InstanceStateNameStopping, types.InstanceStateNameStopped:
+		return provider.InstanceStatusStopped
+	case types.InstanceStateNameShuttingDown, types.InstanceStateNameTerminated:
+		return provider.InstanceStatusTerminated
+	}
+	return provider.InstanceStatusUnknown
+}
+
+func (p *awsProvider) toInstanceInfo(instance types.Instance) *provider.InstanceInfo {
+	info := &provider.InstanceInfo{
+		Instance: provider.Instance{
+			ID: aws.ToString(instance.InstanceId),
+		},
+		Status:       toInstanceStatus(instance.State),
+		InstanceType: string(instance.InstanceType),
+		Tags:         map[string]string{},
+		CreatedAt:    aws.ToTime(instance.LaunchTime),
+	}
+
+	for _, tag := range instance.Tags {
+		info.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	info.Name = info.Tags["Name"]
+
+	for _, nic := range instance.NetworkInterfaces {
+		if ip, err := netip.ParseAddr(aws.ToString(nic.PrivateIpAddress)); err == nil {
+			info.IPs = append(info.IPs, ip)
+		}
+	}
+
+	// Like CreateInstance, report the public IP address as the first IP address when it is in use
+	if p.serviceConfig.UsePublicIP && len(instance.NetworkInterfaces) > 0 && instance.NetworkInterfaces[0].Association != nil {
+		if ip, err := netip.ParseAddr(aws.ToString(instance.NetworkInterfaces[0].Association.PublicIp)); err == nil {
+			if len(info.IPs) > 0 {
+				info.IPs[0] = ip
+			} else {
+				info.IPs = []netip.Addr{ip}
+			}
+		}
+	}
+
+	return info
+}
+
+func (p *awsProvider) GetInstance(ctx context.Context, instanceID string) (*provider.InstanceInfo, error) {
+
+	result, err := p.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describing instance %s: %w", instanceID, toProviderError(err))
+	}
+
+	for _, reservation := range result.Reservations {
+		for _, instance := range reservation.Instances {
+			if aws.ToString(instance.InstanceId) == instanceID {
+				return p.toInstanceInfo(instance), nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("instance %s: %w", instanceID, provider.ErrInstanceNotFound)
+}
+
+func (p *awsProvider) ListInstances(ctx context.Context, filter provider.InstanceFilter) ([]*provider.InstanceInfo, error) {
+
+	var filters []types.Filter
+	if filter.NamePrefix != "" {
+		filters = append(filters, types.Filter{
+			Name:   aws.String("tag:Name"),
+			Values: []string{filter.NamePrefix + "*"},
+		})
+	}
+	for k, v := range filter.Tags {
+		filters = append(filters, types.Filter{
+			Name:   aws.String("tag:" + k),
+			Values: []string{v},
+		})
+	}
+
+	var instances []*provider.InstanceInfo
+
+	input := &ec2.DescribeInstancesInput{Filters: filters}
+	for {
+		result, err := p.ec2Client.DescribeInstances(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("listing instances: %w", toProviderError(err))
+		}
+
+		for _, reservation := range result.Reservations {
+			for _, instance := range reservation.Instances {
+				info := p.toInstanceInfo(instance)
+				if filter.Match(info) {
+					instances = append(instances, info)
+				}
+			}
+		}
+
+		if aws.ToString(result.NextToken) == "" {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+
+	return instances, nil
+}
+
+// primaryNetworkInterface returns the network interface at device index 0 of an instance
+func (p *awsProvider) primaryNetworkInterface(ctx context.Context, instanceID string) (*types.InstanceNetworkInterface, error) {
+
+	result, err := p.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describing instance %s: %w", instanceID, toProviderError(err))
+	}
+
+	for _, reservation := range result.Reservations {
+		for _, instance := range reservation.Instances {
+			if aws.ToString(instance.InstanceId) != instanceID {
+				continue
+			}
+			for i, nic := range instance.NetworkInterfaces {
+				if nic.Attachment != nil && aws.ToInt32(nic.Attachment.DeviceIndex) == 0 {
+					return &instance.NetworkInterfaces[i], nil
+				}
+			}
+			return nil, fmt.Errorf("instance %s has no primary network interface", instanceID)
+		}
+	}
+
+	return nil, fmt.Errorf("instance %s: %w", instanceID, provider.ErrInstanceNotFound)
+}
+
+// AssignPodIPs assigns pod IP addresses as secondary private IP addresses of the primary network interface.
+// Reassignment is not allowed, so that an address assigned to a network interface of a worker node, for example
+// by the Amazon VPC CNI plugin, is not taken away from it. Assigning such an address fails.
+func (p *awsProvider) AssignPodIPs(ctx context.Context, instanceID string, ips []netip.Addr) error {
+
+	nic, err := p.primaryNetworkInterface(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	nicID := aws.ToString(nic.NetworkInterfaceId)
+
+	var addrs []string
+	for _, ip := range ips {
+		addrs = append(addrs, ip.String())
+	}
+
+	if _, err := p.ec2Client.AssignPrivateIpAddresses(ctx, &ec2.AssignPrivateIpAddressesInput{
+		NetworkInterfaceId: aws.String(nicID),
+		PrivateIpAddresses: addrs,
+		AllowReassignment:  aws.Bool(false),
+	}); err != nil {
+		return fmt.Errorf("assigning private IP addresses %v to network interface %s of instance %s: %w", addrs, nicID, instanceID, toProviderError(err))
+	}
+
+	logger.Printf("assigned private IP addresses %v to network interface %s of instance %s", addrs, nicID, instanceID)
+
+	return nil
+}
+
+// UnassignPodIPs removes pod IP addresses from the secondary private IP addresses of the primary network interface
+func (p *awsProvider) UnassignPodIPs(ctx context.Context, instanceID string, ips []netip.Addr) error {
+
+	nic, err := p.primaryNetworkInterface(ctx, instanceID)
+	if err != nil {
+		if errors.Is(err, provider.ErrInstanceNotFound) {
+			return nil
+		}
+		return err
+	}
+	nicID := aws.ToString(nic.NetworkInterfaceId)
+
+	assigned := map[netip.Addr]bool{}
+	for _, addr := range nic.PrivateIpAddresses {
+		if ip, err := netip.ParseAddr(aws.ToString(addr.PrivateIpAddress)); err == nil && !aws.ToBool(addr.Primary) {
+			assigned[ip] = true
+		}
+	}
+
+	var addrs []string
+	for _, ip := range ips {
+		if assigned[ip] {
+			addrs = append(addrs, ip.String())
+		}
+	}
+	if len(addrs) == 0 {
+		return nil
+	}
+
+	if _, err := p.ec2Client.UnassignPrivateIpAddresses(ctx, &ec2.UnassignPrivateIpAddressesInput{
+		NetworkInterfaceId: aws.String(nicID),
+		PrivateIpAddresses: addrs,
+	}); err != nil {
+		return fmt.Errorf("unassigning private IP addresses %v from network interface %s of instance %s: %w", addrs, nicID, instanceID, toProviderError(err))
+	}
+
+	logger.Printf("unassigned private IP addresses %v from network interface %s of instance %s", addrs, nicID, instanceID)
+
+	return nil
+}
@@ -5,15 +5,18 @@ package aws
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/netip"
 	"reflect"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	smithy "github.com/aws/smithy-go"
 	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util/cloudinit"
 )
@@ -21,6 +24,9 @@ import (
 // Mock EC2 API
 type mockEC2Client struct{}
 
+// deletedInstanceID is an instance that the mock EC2 API does not know
+const deletedInstanceID = "i-0000000000000000"
+
 // Return a new mock EC2 API
 func newMockEC2Client() *mockEC2Client {
 	return &mockEC2Client{}
@@ -58,6 +64,10 @@ func (m mockEC2Client) TerminateInstances(ctx context.Context,
 	params *ec2.TerminateInstancesInput,
 	optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error) {
 
+	if params.InstanceIds[0] == deletedInstanceID {
+		return nil, &smithy.GenericAPIError{Code: "InvalidInstanceID.NotFound", Message: "The instance ID does not exist"}
+	}
+
 	// Return a mock TerminateInstancesOutput
 	return &ec2.TerminateInstancesOutput{}, nil
 }
@@ -274,6 +284,22 @@ func newMockAWSInstanceWaiter() *MockAWSInstanceWaiter {
 	return &MockAWSInstanceWaiter{}
 }
 
+// Create a mock EC2 AssignPrivateIpAddresses method
+func (m mockEC2Client) AssignPrivateIpAddresses(ctx context.Context,
+	params *ec2.AssignPrivateIpAddressesInput,
+	optFns ...func(*ec2.Options)) (*ec2.AssignPrivateIpAddressesOutput, error) {
+
+	return &ec2.AssignPrivateIpAddressesOutput{}, nil
+}
+
+// Create a mock EC2 UnassignPrivateIpAddresses method
+func (m mockEC2Client) UnassignPrivateIpAddresses(ctx context.Context,
+	params *ec2.UnassignPrivateIpAddressesInput,
+	optFns ...func(*ec2.Options)) (*ec2.UnassignPrivateIpAddressesOutput, error) {
+
+	return &ec2.UnassignPrivateIpAddressesOutput{}, nil
+}
+
 // Create a mock EC2 DescribeImages method
 func (m mockEC2Client) DescribeImages(ctx context.Context,
 	params *ec2.DescribeImagesInput,
@@ -417,6 +443,7 @@ func TestCreateInstance(t *testing.T) {
 				ID:   "i-1234567890abcdef0",
 				Name: "podvm-podtest-123",
 				IPs:  []netip.Addr{netip.MustParseAddr("10.0.0.2")},
+				Type: "t2.small",
 			},
 			// Test should not return an error
 			wantErr: false,
@@ -444,6 +471,7 @@ func TestCreateInstance(t *testing.T) {
 				ID:   "i-1234567890abcdef0",
 				Name: "podvm-podpublicip-123",
 				IPs:  []netip.Addr{netip.MustParseAddr("192.168.100.1")},
+				Type: "t2.small",
 			},
 			// Test should not return an error
 			wantErr: false,
@@ -517,6 +545,7 @@ func TestCreateInstance(t *testing.T) {
 				ID:   "i-1234567890abcdef0",
 				Name: "podvm-podemptyinstance-123",
 				IPs:  []netip.Addr{netip.MustParseAddr("10.0.0.2")},
+				Type: "t2.small",
 			},
 			// Test should not return an error
 			wantErr: false,
@@ -545,6 +574,7 @@ func TestCreateInstance(t *testing.T) {
 				ID:   "i-1234567890abcdef0",
 				Name: "podvm-podemptyinstance-123",
 				IPs:  []netip.Addr{netip.MustParseAddr("10.0.0.2")},
+				Type: "t2.small",
 			},
 			// Test should not return an error
 			wantErr: false,
@@ -601,6 +631,20 @@ func TestDeleteInstance(t *testing.T) {
 			// Test should not return an error
 			wantErr: false,
 		},
+		// Test deleting an instance that does not exist
+		{
+			name: "DeleteInstanceNotFound",
+			fields: fields{
+				ec2Client:     newMockEC2Client(),
+				serviceConfig: serviceConfig,
+			},
+			args: args{
+				ctx:        context.Background(),
+				instanceID: deletedInstanceID,
+			},
+			// Test should not return an error
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -615,6 +659,49 @@ func TestDeleteInstance(t *testing.T) {
 	}
 }
 
+func TestGetInstance(t *testing.T) {
+	p := &awsProvider{
+		ec2Client:     newMockEC2Client(),
+		serviceConfig: serviceConfig,
+	}
+
+	info, err := p.GetInstance(context.Background(), "i-1234567890abcdef0")
+	if err != nil {
+		t.Fatalf("awsProvider.GetInstance() error = %v", err)
+	}
+	if info.ID != "i-1234567890abcdef0" {
+		t.Errorf("awsProvider.GetInstance() ID = %v, want %v", info.ID, "i-1234567890abcdef0")
+	}
+	if want := []netip.Addr{netip.MustParseAddr("10.0.0.2")}; !reflect.DeepEqual(info.IPs, want) {
+		t.Errorf("awsProvider.GetInstance() IPs = %v, want %v", info.IPs, want)
+	}
+
+	p.serviceConfig = serviceConfigPublicIP
+	info, err = p.GetInstance(context.Background(), "i-1234567890abcdef0")
+	if err != nil {
+		t.Fatalf("awsProvider.GetInstance() error = %v", err)
+	}
+	if want := []netip.Addr{netip.MustParseAddr("192.168.100.1")}; !reflect.DeepEqual(info.IPs, want) {
+		t.Errorf("awsProvider.GetInstance() IPs = %v, want %v", info.IPs, want)
+	}
+
+	// An instance whose private IP address is not reported still gets its public IP address
+	info = p.toInstanceInfo(types.Instance{
+		InstanceId: aws.String("i-1234567890abcdef0"),
+		NetworkInterfaces: []types.InstanceNetworkInterface{
+			{Association: &types.InstanceNetworkInterfaceAssociation{PublicIp: aws.String("192.168.100.1")}},
+		},
+	})
+	if want := []netip.Addr{netip.MustParseAddr("192.168.100.1")}; !reflect.DeepEqual(info.IPs, want) {
+		t.Errorf("awsProvider.toInstanceInfo() IPs = %v, want %v", info.IPs, want)
+	}
+
+	// The mock client always describes the same instance, so other instances are never found
+	if _, err := p.GetInstance(context.Background(), "i-0000000000000000"); !errors.Is(err, provider.ErrInstanceNotFound) {
+		t.Errorf("awsProvider.GetInstance() error = %v, want %v", err, provider.ErrInstanceNotFound)
+	}
+}
+
 func TestGetInstanceTypeInformation(t *testing.T) {
 	type fields struct {
 		ec2Client     ec2Client
@@ -803,6 +890,73 @@ func TestCreateInstanceWithVolumes(t *testing.T) {
 	}
 }
 
+// capacityEC2Client fails RunInstances with InsufficientInstanceCapacity for the listed instance types and subnets
+type capacityEC2Client struct {
+	mockEC2Client
+	noCapacity map[string]bool
+	runs       []string
+}
+
+func (m *capacityEC2Client) RunInstances(ctx context.Context,
+	params *ec2.RunInstancesInput,
+	optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+
+	run := string(params.InstanceType) + "/" + aws.ToString(params.SubnetId)
+	m.runs = append(m.runs, run)
+	if m.noCapacity[run] {
+		return nil, &smithy.GenericAPIError{Code: "InsufficientInstanceCapacity", Message: "We currently do not have sufficient capacity"}
+	}
+	return m.mockEC2Client.RunInstances(ctx, params, optFns...)
+}
+
+func TestCreateInstanceFallback(t *testing.T) {
+	cfg := *serviceConfig
+	cfg.SubnetID = "subnet-a"
+	cfg.InstanceType = "t3.small"
+	cfg.InstanceTypes = instanceTypes{"t3.small", "t3.medium"}
+	cfg.FallbackLargerInstanceTypes = true
+	cfg.FallbackSubnetIDs = subnetIDs{"subnet-b"}
+	cfg.InstanceTypeSpecList = []provider.InstanceTypeSpec{
+		{InstanceType: "t3.small", VCPUs: 2, Memory: 2048},
+		{InstanceType: "t3.medium", VCPUs: 2, Memory: 4096},
+	}
+
+	client := &capacityEC2Client{
+		noCapacity: map[string]bool{
+			"t3.small/subnet-a":  true,
+			"t3.medium/subnet-a": true,
+		},
+	}
+	p := &awsProvider{
+		ec2Client:     client,
+		waiter:        newMockAWSInstanceWaiter(),
+		serviceConfig: &cfg,
+	}
+
+	instance, err := p.CreateInstance(context.Background(), "podtest", "123", &mockCloudConfig{}, provider.InstanceTypeSpec{VCPUs: 2, Memory: 2048})
+	if err != nil {
+		t.Fatalf("CreateInstance() error = %v", err)
+	}
+	if instance.Type != "t3.small" {
+		t.Errorf("CreateInstance() instance type = %s, want t3.small", instance.Type)
+	}
+	want := []string{"t3.small/subnet-a", "t3.medium/subnet-a", "t3.small/subnet-b"}
+	if !reflect.DeepEqual(client.runs, want) {
+		t.Errorf("CreateInstance() attempts = %v, want %v", client.runs, want)
+	}
+
+	// The retry budget is exhausted before the alternate subnet is tried
+	cfg.FallbackMaxAttempts = 2
+	client.runs = nil
+	_, err = p.CreateInstance(context.Background(), "podtest", "123", &mockCloudConfig{}, provider.InstanceTypeSpec{VCPUs: 2, Memory: 2048})
+	if !errors.Is(err, provider.ErrInsufficientCapacity) {
+		t.Errorf("CreateInstance() error = %v, want %v", err, provider.ErrInsufficientCapacity)
+	}
+	if len(client.runs) != 2 {
+		t.Errorf("CreateInstance() made %d attempts, want 2", len(client.runs))
+	}
+}
+
 func TestConfigVerifier(t *testing.T) {
 	type fields struct {
 		serviceConfig *Config
@@ -848,3 +1002,120 @@ func TestConfigVerifier(t *testing.T) {
 		})
 	}
 }
+
+// podIPEC2Client describes an instance with a primary network interface, and records assigned private IP addresses
+type podIPEC2Client struct {
+	mockEC2Client
+	assigned   []string
+	unassigned []string
+	reassign   bool
+	// inUse has addresses assigned to other network interfaces
+	inUse []string
+}
+
+func (m *podIPEC2Client) DescribeInstances(ctx context.Context,
+	params *ec2.DescribeInstancesInput,
+	optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+
+	nic := types.InstanceNetworkInterface{
+		NetworkInterfaceId: aws.String("eni-0123456789abcdef0"),
+		Attachment:         &types.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int32(0)},
+		PrivateIpAddresses: []types.InstancePrivateIpAddress{
+			{PrivateIpAddress: aws.String("10.0.0.2"), Primary: aws.Bool(true)},
+		},
+	}
+	for _, ip := range m.assigned {
+		nic.PrivateIpAddresses = append(nic.PrivateIpAddresses, types.InstancePrivateIpAddress{PrivateIpAddress: aws.String(ip), Primary: aws.Bool(false)})
+	}
+
+	return &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId: aws.String("i-1234567890abcdef0"),
+						NetworkInterfaces: []types.InstanceNetworkInterface{
+							{
+								NetworkInterfaceId: aws.String("eni-0fedcba9876543210"),
+								Attachment:         &types.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int32(1)},
+							},
+							nic,
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func (m *podIPEC2Client) AssignPrivateIpAddresses(ctx context.Context,
+	params *ec2.AssignPrivateIpAddressesInput,
+	optFns ...func(*ec2.Options)) (*ec2.AssignPrivateIpAddressesOutput, error) {
+
+	if aws.ToString(params.NetworkInterfaceId) != "eni-0123456789abcdef0" {
+		return nil, fmt.Errorf("unexpected network interface %s", aws.ToString(params.NetworkInterfaceId))
+	}
+	for _, ip := range params.PrivateIpAddresses {
+		if slices.Contains(m.inUse, ip) && !aws.ToBool(params.AllowReassignment) {
+			return nil, &smithy.GenericAPIError{Code: "InvalidIPAddress.InUse", Message: "Address " + ip + " is in use"}
+		}
+	}
+	m.assigned = append(m.assigned, params.PrivateIpAddresses...)
+	m.reassign = aws.ToBool(params.AllowReassignment)
+	return &ec2.AssignPrivateIpAddressesOutput{}, nil
+}
+
+func (m *podIPEC2Client) UnassignPrivateIpAddresses(ctx context.Context,
+	params *ec2.UnassignPrivateIpAddressesInput,
+	optFns ...func(*ec2.Options)) (*ec2.UnassignPrivateIpAddressesOutput, error) {
+
+	if aws.ToString(params.NetworkInterfaceId) != "eni-0123456789abcdef0" {
+		return nil, fmt.Errorf("unexpected network interface %s", aws.ToString(params.NetworkInterfaceId))
+	}
+	m.unassigned = append(m.unassigned, params.PrivateIpAddresses...)
+	return &ec2.UnassignPrivateIpAddressesOutput{}, nil
+}
+
+func TestAssignPodIPs(t *testing.T) {
+	client := &podIPEC2Client{}
+	p := &awsProvider{
+		ec2Client:     client,
+		serviceConfig: serviceConfig,
+	}
+
+	var _ provider.PodIPAssigner = p
+
+	ips := []netip.Addr{netip.MustParseAddr("10.0.0.10"), netip.MustParseAddr("10.0.0.11")}
+
+	if err := p.AssignPodIPs(context.Background(), "i-1234567890abcdef0", ips); err != nil {
+		t.Fatalf("awsProvider.AssignPodIPs() error = %v", err)
+	}
+	if want := []string{"10.0.0.10", "10.0.0.11"}; !reflect.DeepEqual(client.assigned, want) {
+		t.Errorf("awsProvider.AssignPodIPs() assigned = %v, want %v", client.assigned, want)
+	}
+	if client.reassign {
+		t.Error("awsProvider.AssignPodIPs() allows reassignment")
+	}
+
+	// Addresses that are not assigned to the instance are ignored
+	ips = append(ips, netip.MustParseAddr("10.0.0.12"), netip.MustParseAddr("10.0.0.2"))
+	if err := p.UnassignPodIPs(context.Background(), "i-1234567890abcdef0", ips); err != nil {
+		t.Fatalf("awsProvider.UnassignPodIPs() error = %v", err)
+	}
+	if want := []string{"10.0.0.10", "10.0.0.11"}; !reflect.DeepEqual(client.unassigned, want) {
+		t.Errorf("awsProvider.UnassignPodIPs() unassigned = %v, want %v", client.unassigned, want)
+	}
+
+	// Addresses assigned to other network interfaces are not taken
+	client.inUse = []string{"10.0.0.20"}
+	if err := p.AssignPodIPs(context.Background(), "i-1234567890abcdef0", []netip.Addr{netip.MustParseAddr("10.0.0.20")}); err == nil {
+		t.Error("awsProvider.AssignPodIPs() error = nil, want an error for an address in use")
+	}
+
+	if err := p.AssignPodIPs(context.Background(), deletedInstanceID, ips); !errors.Is(err, provider.ErrInstanceNotFound) {
+		t.Errorf("awsProvider.AssignPodIPs() error = %v, want %v", err, provider.ErrInstanceNotFound)
+	}
+	if err := p.UnassignPodIPs(context.Background(), deletedInstanceID, ips); err != nil {
+		t.Errorf("awsProvider.UnassignPodIPs() error = %v, want nil", err)
+	}
+}
@@ -36,6 +36,21 @@ func (i *instanceTypes) Set(value string) error {
 	return nil
 }
 
+type subnetIDs []string
+
+func (i *subnetIDs) String() string {
+	return strings.Join(*i, ", ")
+}
+
+func (i *subnetIDs) Set(value string) error {
+	if len(value) == 0 {
+		*i = make(subnetIDs, 0)
+	} else {
+		*i = append(*i, strings.Split(value, ",")...)
+	}
+	return nil
+}
+
 type Config struct {
 	AccessKeyID          string
 	SecretKey            string
@@ -56,6 +71,10 @@ type Config struct {
 	RootVolumeSize       int
 	RootDeviceName       string
 	DisableCVM           bool
+	// Instance creation fallback when capacity or quota is exhausted
+	FallbackMaxAttempts         int
+	FallbackLargerInstanceTypes bool
+	FallbackSubnetIDs           subnetIDs
 }
 
 func (c Config) Redact() Config {
@@ -0,0 +1,55 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package azure
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+)
+
+// Ref: https://learn.microsoft.com/en-us/troubleshoot/azure/virtual-machines/windows/error-messages
+var capacityErrorCodes = map[string]bool{
+	"AllocationFailed":                      true,
+	"OverconstrainedAllocationRequest":      true,
+	"OverconstrainedZonalAllocationRequest": true,
+	"SkuNotAvailable":                       true,
+	"ZonalAllocationFailed":                 true,
+}
+
+// toProviderError wraps an Azure API error with the matching provider error class.
+// notFound is the class of a 404 response. It is provider.ErrInstanceNotFound when the
+// request is about an existing VM, and provider.ErrInvalidSpec when a VM is created,
+// since a missing resource is then one referenced by the VM, such as an image or a subnet.
+func toProviderError(err error, notFound error) error {
+	var authErr *azidentity.AuthenticationFailedError
+	if errors.As(err, &authErr) {
+		return provider.WrapError(provider.ErrAuth, err)
+	}
+
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) {
+		return err
+	}
+
+	switch code := respErr.ErrorCode; {
+	case capacityErrorCodes[code]:
+		return provider.WrapError(provider.ErrInsufficientCapacity, err)
+	case strings.Contains(code, "QuotaExceeded"), code == "OperationNotAllowed" && strings.Contains(err.Error(), "quota"):
+		return provider.WrapError(provider.ErrQuotaExceeded, err)
+	case respErr.StatusCode == http.StatusUnauthorized, respErr.StatusCode == http.StatusForbidden:
+		return provider.WrapError(provider.ErrAuth, err)
+	case respErr.StatusCode == http.StatusNotFound:
+		return provider.WrapError(notFound, err)
+	case respErr.StatusCode == http.StatusBadRequest, respErr.StatusCode == http.StatusConflict:
+		return provider.WrapError(provider.ErrInvalidSpec, err)
+	}
+
+	return err
+}
@@ -0,0 +1,48 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package azure
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+)
+
+func TestToProviderError(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		status   int
+		notFound error
+		want     error
+	}{
+		{"capacity", "ZonalAllocationFailed", http.StatusConflict, provider.ErrInstanceNotFound, provider.ErrInsufficientCapacity},
+		{"sku", "SkuNotAvailable", http.StatusConflict, provider.ErrInstanceNotFound, provider.ErrInsufficientCapacity},
+		{"quota", "QuotaExceeded", http.StatusConflict, provider.ErrInstanceNotFound, provider.ErrQuotaExceeded},
+		{"auth", "AuthorizationFailed", http.StatusForbidden, provider.ErrInstanceNotFound, provider.ErrAuth},
+		{"vm not found", "ResourceNotFound", http.StatusNotFound, provider.ErrInstanceNotFound, provider.ErrInstanceNotFound},
+		{"image not found", "PlatformImageNotFound", http.StatusNotFound, provider.ErrInvalidSpec, provider.ErrInvalidSpec},
+		{"invalid parameter", "InvalidParameter", http.StatusBadRequest, provider.ErrInstanceNotFound, provider.ErrInvalidSpec},
+		{"internal", "InternalServerError", http.StatusInternalServerError, provider.ErrInstanceNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			respErr := &azcore.ResponseError{ErrorCode: tt.code, StatusCode: tt.status}
+			err := toProviderError(respErr, tt.notFound)
+			if !errors.Is(err, respErr) {
+				t.Errorf("toProviderError() = %v, does not wrap %v", err, respErr)
+			}
+			for _, class := range []error{provider.ErrInsufficientCapacity, provider.ErrQuotaExceeded, provider.ErrAuth, provider.ErrInvalidSpec, provider.ErrInstanceNotFound} {
+				if errors.Is(err, class) != (class == tt.want) {
+					t.Errorf("toProviderError() = %v, errors.Is(%v) = %v", err, class, class != tt.want)
+				}
+			}
+		})
+	}
+}
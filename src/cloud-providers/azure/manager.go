@@ -40,9 +40,12 @@ func (*Manager) ParseCmd(flags *flag.FlagSet) {
 	reg.BoolWithEnv(&azurecfg.EnableSecureBoot, "enable-secure-boot", false, "ENABLE_SECURE_BOOT", "Enable secure boot for the VMs")
 	reg.BoolWithEnv(&azurecfg.UsePublicIP, "use-public-ip", false, "USE_PUBLIC_IP", "Assign public IP to the PoD VM and use to connect to kata-agent")
 	reg.IntWithEnv(&azurecfg.RootVolumeSize, "root-volume-size", 0, "ROOT_VOLUME_SIZE", "Root volume size in GB. Default is 0, which implies the default image disk size")
+	reg.IntWithEnv(&azurecfg.FallbackMaxAttempts, "fallback-max-attempts", 0, "FALLBACK_MAX_ATTEMPTS", "Maximum number of instance creation attempts when capacity or quota is exhausted (0 tries every fallback)")
+	reg.BoolWithEnv(&azurecfg.FallbackLargerInstanceTypes, "fallback-larger-instance-types", false, "FALLBACK_LARGER_INSTANCE_TYPES", "Fall back to larger instance sizes from AZURE_INSTANCE_SIZES when capacity or quota is exhausted")
 
 	// Custom flag types (comma-separated lists)
 	reg.CustomTypeWithEnv(&azurecfg.InstanceSizes, "instance-sizes", "", "AZURE_INSTANCE_SIZES", "Instance sizes to be used for the Pod VMs, comma separated")
+	reg.CustomTypeWithEnv(&azurecfg.FallbackZones, "fallback-zones", "", "FALLBACK_ZONES", "Availability zones to fall back to when capacity or quota is exhausted, comma separated")
 	reg.CustomTypeWithEnv(&azurecfg.Tags, "tags", "", "TAGS", "Custom tags (key=value pairs) to be used for the Pod VMs, comma separated")
 }
 
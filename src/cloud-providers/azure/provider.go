@@ -189,12 +189,12 @@ func (p *azureProvider) create(ctx context.Context, parameters *armcompute.Virtu
 
 	pollerResponse, err := vmClient.BeginCreateOrUpdate(ctx, p.serviceConfig.ResourceGroupName, vmName, *parameters, nil)
 	if err != nil {
-		return nil, fmt.Errorf("beginning VM creation or update: %w", err)
+		return nil, fmt.Errorf("beginning VM creation or update: %w", toProviderError(err, provider.ErrInvalidSpec))
 	}
 
 	resp, err := pollerResponse.PollUntilDone(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("waiting for the VM creation: %w", err)
+		return nil, fmt.Errorf("waiting for the VM creation: %w", toProviderError(err, provider.ErrInvalidSpec))
 	}
 
 	logger.Printf("created VM successfully: %s", *resp.ID)
@@ -301,9 +301,30 @@ func (p *azureProvider) CreateInstance(ctx context.Context, podName, sandboxID s
 
 	logger.Printf("CreateInstance: name: %q", instanceName)
 
-	vm, err := p.create(ctx, vmParameters)
+	var retry bool
+	vm, attempt, err := provider.CreateWithFallback(ctx, p.createAttempts(instanceSize, spec), func(ctx context.Context, attempt provider.CreateAttempt) (*armcompute.VirtualMachine, error) {
+		// A VM that failed to be allocated is left in the failed state, and its zone cannot be changed
+		if retry {
+			if err := p.deleteVM(ctx, instanceName); err != nil {
+				return nil, err
+			}
+		}
+		retry = true
+
+		vmParameters.Properties.HardwareProfile.VMSize = to.Ptr(armcompute.VirtualMachineSizeTypes(attempt.InstanceType))
+		vmParameters.Zones = nil
+		if attempt.Placement != "" {
+			vmParameters.Zones = []*string{to.Ptr(attempt.Placement)}
+		}
+
+		vm, err := p.create(ctx, vmParameters)
+		if err != nil {
+			return nil, fmt.Errorf("Creating instance (%v): %w", vm, err)
+		}
+		return vm, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("Creating instance (%v): %s", vm, err)
+		return nil, err
 	}
 
 	vmID := *vm.ID
@@ -312,6 +333,7 @@ func (p *azureProvider) CreateInstance(ctx context.Context, podName, sandboxID s
 	instance = &provider.Instance{
 		ID:   vmID,
 		Name: instanceName,
+		Type: attempt.InstanceType,
 	}
 
 	ips, err := p.getIPs(ctx, vm)
@@ -326,28 +348,31 @@ func (p *azureProvider) CreateInstance(ctx context.Context, podName, sandboxID s
 }
 
 func (p *azureProvider) DeleteInstance(ctx context.Context, instanceID string) error {
-	vmClient, err := armcompute.NewVirtualMachinesClient(p.serviceConfig.SubscriptionID, p.azureClient, nil)
+	vmName, err := vmNameFromID(instanceID)
 	if err != nil {
-		return fmt.Errorf("creating VM client: %w", err)
+		logger.Printf("finding VM name of %s: %v", instanceID, err)
+		return err
 	}
 
-	// instanceID in the form of /subscriptions/<subID>/resourceGroups/<resource_name>/providers/Microsoft.Compute/virtualMachines/<VM_Name>.
-	re := regexp.MustCompile(`^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Compute/virtualMachines/(.*)$`)
-	match := re.FindStringSubmatch(instanceID)
-	if len(match) < 1 {
-		logger.Print("finding VM name using regexp:", match)
-		return errNotFound
-	}
-
-	vmName := match[1]
+	return p.deleteVM(ctx, vmName)
+}
 
-	pollerResponse, err := vmClient.BeginDelete(ctx, p.serviceConfig.ResourceGroupName, vmName, nil)
+func (p *azureProvider) deleteVM(ctx context.Context, vmName string) error {
+	vmClient, err := armcompute.NewVirtualMachinesClient(p.serviceConfig.SubscriptionID, p.azureClient, nil)
 	if err != nil {
-		return fmt.Errorf("beginning VM deletion: %w", err)
+		return fmt.Errorf("creating VM client: %w", err)
 	}
 
-	if _, err = pollerResponse.PollUntilDone(ctx, nil); err != nil {
-		return fmt.Errorf("waiting for the VM deletion: %w", err)
+	pollerResponse, err := vmClient.BeginDelete(ctx, p.serviceConfig.ResourceGroupName, vmName, nil)
+	if err == nil {
+		_, err = pollerResponse.PollUntilDone(ctx, nil)
+	}
+	if err != nil {
+		if err = toProviderError(err, provider.ErrInstanceNotFound); errors.Is(err, provider.ErrInstanceNotFound) {
+			logger.Printf("VM %s is already deleted", vmName)
+			return nil
+		}
+		return fmt.Errorf("deleting VM %s: %w", vmName, err)
 	}
 
 	logger.Printf("deleted VM successfully: %s", vmName)
@@ -374,6 +399,22 @@ func (p *azureProvider) ConfigVerifier() error {
 	return nil
 }
 
+// createAttempts returns the instance size and zone combinations to try when capacity or quota is exhausted.
+// Managed disks can only be attached to VMs in their zone, so there is no zone fallback for pods with volumes.
+func (p *azureProvider) createAttempts(instanceSize string, spec provider.InstanceTypeSpec) []provider.CreateAttempt {
+	policy := provider.FallbackPolicy{
+		MaxAttempts:         p.serviceConfig.FallbackMaxAttempts,
+		LargerInstanceTypes: p.serviceConfig.FallbackLargerInstanceTypes && spec.InstanceType == "",
+	}
+
+	var zones []string
+	if len(spec.Volumes) == 0 {
+		zones = p.serviceConfig.FallbackZones
+	}
+
+	return policy.Attempts(instanceSize, p.serviceConfig.InstanceSizeSpecList, zones)
+}
+
 // Add SelectInstanceType method to select an instance type based on the memory and vcpu requirements
 func (p *azureProvider) selectInstanceType(ctx context.Context, spec provider.InstanceTypeSpec) (string, error) {
 
@@ -651,3 +692,147 @@ func (p *azureProvider) getVMParameters(instanceSize, diskName, cloudConfig stri
 
 	return &vmParameters, nil
 }
+
+// vmNameFromID returns the name of a VM from its resource ID
+func vmNameFromID(instanceID string) (string, error) {
+	// instanceID in the form of /subscriptions/<subID>/resourceGroups/<resource_name>/providers/Microsoft.Compute/virtualMachines/<VM_Name>.
+	re := regexp.MustCompile(`^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Compute/virtualMachines/(.*)$`)
+	match := re.FindStringSubmatch(instanceID)
+	if len(match) < 2 {
+		return "", errNotFound
+	}
+	return match[1], nil
+}
+
+// toInstanceStatus maps the power state of a VM to an instance status. When the instance view
+// is not available, the status is derived from the provisioning state.
+func toInstanceStatus(vm *armcompute.VirtualMachine) provider.InstanceStatus {
+	if vm.Properties == nil {
+		return provider.InstanceStatusUnknown
+	}
+
+	if vm.Properties.InstanceView != nil {
+		for _, s := range vm.Properties.InstanceView.Statuses {
+			code, found := strings.CutPrefix(stringValue(s.Code), "PowerState/")
+			if !found {
+				continue
+			}
+			switch code {
+			case "starting":
+				return provider.InstanceStatusPending
+			case "running":
+				return provider.InstanceStatusRunning
+			case "stopping", "stopped", "deallocating", "deallocated":
+				return provider.InstanceStatusStopped
+			}
+		}
+	}
+
+	switch stringValue(vm.Properties.ProvisioningState) {
+	case "Creating", "Updating":
+		return provider.InstanceStatusPending
+	case "Succeeded":
+		return provider.InstanceStatusRunning
+	case "Deleting":
+		return provider.InstanceStatusTerminated
+	}
+	return provider.InstanceStatusUnknown
+}
+
+func toInstanceInfo(vm *armcompute.VirtualMachine) *provider.InstanceInfo {
+	info := &provider.InstanceInfo{
+		Instance: provider.Instance{
+			ID:   stringValue(vm.ID),
+			Name: stringValue(vm.Name),
+		},
+		Status: toInstanceStatus(vm),
+		Tags:   map[string]string{},
+	}
+
+	for k, v := range vm.Tags {
+		info.Tags[k] = stringValue(v)
+	}
+
+	if vm.Properties != nil {
+		if vm.Properties.HardwareProfile != nil && vm.Properties.HardwareProfile.VMSize != nil {
+			info.InstanceType = string(*vm.Properties.HardwareProfile.VMSize)
+		}
+		if vm.Properties.TimeCreated != nil {
+			info.CreatedAt = *vm.Properties.TimeCreated
+		}
+	}
+
+	return info
+}
+
+func (p *azureProvider) GetInstance(ctx context.Context, instanceID string) (*provider.InstanceInfo, error) {
+	vmClient, err := armcompute.NewVirtualMachinesClient(p.serviceConfig.SubscriptionID, p.azureClient, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating VM client: %w", err)
+	}
+
+	vmName, err := vmNameFromID(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("instance %s: %w", instanceID, err)
+	}
+
+	resp, err := vmClient.Get(ctx, p.serviceConfig.ResourceGroupName, vmName, &armcompute.VirtualMachinesClientGetOptions{
+		Expand: to.Ptr(armcompute.InstanceViewTypesInstanceView),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting VM %s: %w", vmName, toProviderError(err, provider.ErrInstanceNotFound))
+	}
+
+	info := toInstanceInfo(&resp.VirtualMachine)
+
+	if info.Status == provider.InstanceStatusRunning {
+		ips, err := p.getIPs(ctx, &resp.VirtualMachine)
+		if err != nil {
+			return nil, fmt.Errorf("getting IPs of VM %s: %w", vmName, err)
+		}
+		info.IPs = ips
+	}
+
+	return info, nil
+}
+
+// ListInstances lists VMs in the configured resource group. IP addresses are not
+// resolved, since that requires additional API calls for each VM.
+func (p *azureProvider) ListInstances(ctx context.Context, filter provider.InstanceFilter) ([]*provider.InstanceInfo, error) {
+	vmClient, err := armcompute.NewVirtualMachinesClient(p.serviceConfig.SubscriptionID, p.azureClient, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating VM client: %w", err)
+	}
+
+	var instances []*provider.InstanceInfo
+
+	pager := vmClient.NewListPager(p.serviceConfig.ResourceGroupName, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing VMs: %w", toProviderError(err, provider.ErrInstanceNotFound))
+		}
+		instances = append(instances, matchInstances(page.Value, filter)...)
+	}
+
+	return instances, nil
+}
+
+// matchInstances returns the instance info of VMs that match the filter
+func matchInstances(vms []*armcompute.VirtualMachine, filter provider.InstanceFilter) []*provider.InstanceInfo {
+	var instances []*provider.InstanceInfo
+	for _, vm := range vms {
+		info := toInstanceInfo(vm)
+		if filter.Match(info) {
+			instances = append(instances, info)
+		}
+	}
+	return instances
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -5,6 +5,7 @@ package azure
 
 import (
 	"math"
+	"reflect"
 	"testing"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
@@ -89,3 +90,41 @@ func TestBuildDataDisks(t *testing.T) {
 		}
 	})
 }
+
+func TestMatchInstances(t *testing.T) {
+	vm := func(name string, tags map[string]*string) *armcompute.VirtualMachine {
+		return &armcompute.VirtualMachine{
+			ID:   to.Ptr("/subscriptions/sub-1/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/" + name),
+			Name: to.Ptr(name),
+			Tags: tags,
+		}
+	}
+	vms := []*armcompute.VirtualMachine{
+		vm("podvm-a", map[string]*string{"cluster": to.Ptr("c1")}),
+		vm("podvm-b", map[string]*string{"cluster": to.Ptr("c2")}),
+		vm("other", map[string]*string{"cluster": to.Ptr("c1")}),
+		vm("plain", nil),
+	}
+
+	for _, tc := range []struct {
+		name   string
+		filter provider.InstanceFilter
+		want   []string
+	}{
+		{name: "empty filter", want: []string{"podvm-a", "podvm-b", "other", "plain"}},
+		{name: "name prefix", filter: provider.InstanceFilter{NamePrefix: "podvm-"}, want: []string{"podvm-a", "podvm-b"}},
+		{name: "tags", filter: provider.InstanceFilter{Tags: map[string]string{"cluster": "c1"}}, want: []string{"podvm-a", "other"}},
+		{name: "name prefix and tags", filter: provider.InstanceFilter{NamePrefix: "podvm-", Tags: map[string]string{"cluster": "c1"}}, want: []string{"podvm-a"}},
+		{name: "no match", filter: provider.InstanceFilter{Tags: map[string]string{"cluster": "c3"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, info := range matchInstances(vms, tc.filter) {
+				got = append(got, info.Name)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -25,6 +25,21 @@ func (i *instanceSizes) Set(value string) error {
 	return nil
 }
 
+type zones []string
+
+func (i *zones) String() string {
+	return strings.Join(*i, ", ")
+}
+
+func (i *zones) Set(value string) error {
+	if len(value) == 0 {
+		*i = make(zones, 0)
+	} else {
+		*i = append(*i, strings.Split(value, ",")...)
+	}
+	return nil
+}
+
 type Config struct {
 	SubscriptionID       string
 	ClientID             string
@@ -50,6 +65,10 @@ type Config struct {
 	EnableSecureBoot bool
 	UsePublicIP      bool
 	RootVolumeSize   int
+	// Instance creation fallback when capacity or quota is exhausted
+	FallbackMaxAttempts         int
+	FallbackLargerInstanceTypes bool
+	FallbackZones               zones
 }
 
 func (c Config) Redact() Config {
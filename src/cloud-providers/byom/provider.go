@@ -5,6 +5,7 @@ package byom
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -128,6 +129,10 @@ func (p *byomProvider) CreateInstance(ctx context.Context, podName, sandboxID st
 	// Allocate IP from global pool
 	ip, err := p.globalPoolMgr.AllocateIP(ctx, allocationID, podName)
 	if err != nil {
+		if errors.Is(err, ErrNoAvailableIPs) {
+			// All the VMs in the pool are in use
+			err = provider.WrapError(provider.ErrInsufficientCapacity, err)
+		}
 		return nil, fmt.Errorf("failed to allocate IP from pool: %w", err)
 	}
 
@@ -203,6 +208,69 @@ func (p *byomProvider) DeleteInstance(ctx context.Context, instanceID string) er
 	return nil
 }
 
+// GetInstance returns an allocated VM of the pool
+func (p *byomProvider) GetInstance(ctx context.Context, instanceID string) (*provider.InstanceInfo, error) {
+	ip, err := netip.ParseAddr(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid instance ID %s: %w", instanceID, err)
+	}
+
+	allocations, err := p.globalPoolMgr.ListAllocatedIPs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, allocation := range allocations {
+		if allocation.IP == ip.String() {
+			return toInstanceInfo(allocation)
+		}
+	}
+
+	return nil, fmt.Errorf("instance %s: %w", instanceID, provider.ErrInstanceNotFound)
+}
+
+// ListInstances returns allocated VMs of the pool. VMs available in the pool are not instances
+// of any pod, so they are not listed.
+func (p *byomProvider) ListInstances(ctx context.Context, filter provider.InstanceFilter) ([]*provider.InstanceInfo, error) {
+	allocations, err := p.globalPoolMgr.ListAllocatedIPs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []*provider.InstanceInfo
+
+	for _, allocation := range allocations {
+		info, err := toInstanceInfo(allocation)
+		if err != nil {
+			logger.Printf("Warning: skipping allocation %s: %v", allocation.AllocationID, err)
+			continue
+		}
+		if filter.Match(info) {
+			instances = append(instances, info)
+		}
+	}
+
+	return instances, nil
+}
+
+func toInstanceInfo(allocation IPAllocation) (*provider.InstanceInfo, error) {
+	ip, err := netip.ParseAddr(allocation.IP)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidAllocatedIP, allocation.IP, err)
+	}
+
+	// Use the same ID and name as CreateInstance
+	return &provider.InstanceInfo{
+		Instance: provider.Instance{
+			ID:   ip.String(),
+			Name: fmt.Sprintf("byom-%s", ip.String()),
+			IPs:  []netip.Addr{ip},
+		},
+		Status:    provider.InstanceStatusRunning,
+		CreatedAt: allocation.AllocatedAt.Time,
+	}, nil
+}
+
 // Teardown cleans up resources
 func (p *byomProvider) Teardown() error {
 	logger.Printf("BYOM provider teardown completed")
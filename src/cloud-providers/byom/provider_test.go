@@ -0,0 +1,63 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package byom
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+)
+
+// mockPoolManager returns allocations from ListAllocatedIPs
+type mockPoolManager struct {
+	GlobalVMPoolManager
+	allocations map[string]IPAllocation
+}
+
+func (m *mockPoolManager) ListAllocatedIPs(ctx context.Context) (map[string]IPAllocation, error) {
+	return m.allocations, nil
+}
+
+func TestListInstances(t *testing.T) {
+	p := &byomProvider{
+		globalPoolMgr: &mockPoolManager{
+			allocations: map[string]IPAllocation{
+				"a1": {AllocationID: "a1", IP: "192.168.1.10"},
+				"a2": {AllocationID: "a2", IP: "192.168.1.11"},
+				"a3": {AllocationID: "a3", IP: "invalid"},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		filter provider.InstanceFilter
+		want   []string
+	}{
+		{name: "empty filter", want: []string{"byom-192.168.1.10", "byom-192.168.1.11"}},
+		{name: "name prefix", filter: provider.InstanceFilter{NamePrefix: "byom-192.168.1.1"}, want: []string{"byom-192.168.1.10", "byom-192.168.1.11"}},
+		{name: "other name prefix", filter: provider.InstanceFilter{NamePrefix: "podvm-"}},
+		// BYOM VMs have no tags, so a tag filter matches none of them
+		{name: "tags", filter: provider.InstanceFilter{Tags: map[string]string{"cluster": "c1"}}},
+		{name: "name prefix and tags", filter: provider.InstanceFilter{NamePrefix: "byom-", Tags: map[string]string{"cluster": "c1"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			instances, err := p.ListInstances(context.Background(), tc.filter)
+			if err != nil {
+				t.Fatalf("ListInstances() error = %v", err)
+			}
+			var got []string
+			for _, info := range instances {
+				got = append(got, info.Name)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ListInstances() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
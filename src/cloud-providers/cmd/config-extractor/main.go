@@ -23,6 +23,7 @@ type FlagInfo struct {
 	Description string `json:"description"`
 	Required    bool   `json:"required"`
 	Secret      bool   `json:"secret"`
+	Shared      bool   `json:"shared"`
 }
 
 type ProviderConfig struct {
@@ -50,23 +51,27 @@ func main() {
 		os.Exit(1)
 	}
 	execDir := filepath.Dir(execPath)
+	srcDir := filepath.Join(execDir, "..", "..")
 
 	config := &ProviderConfig{Provider: provider, Flags: []FlagInfo{}}
 
 	// Parse common flags if -include-shared is set
 	if *includeAll {
 		commonPath := filepath.Join(execDir, "..", "..", "cloud-api-adaptor", "cmd", "cloud-api-adaptor", "main.go")
-		commonFlags, err := parseFile(commonPath)
+		commonFlags, err := parseFile(commonPath, srcDir)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: could not parse common flags: %v\n", err)
 		} else {
+			for i := range commonFlags {
+				commonFlags[i].Shared = true
+			}
 			config.Flags = append(config.Flags, commonFlags...)
 		}
 	}
 
 	// Parse provider-specific flags from manager.go
 	managerPath := filepath.Join(execDir, "..", provider, "manager.go")
-	providerFlags, err := parseFile(managerPath)
+	providerFlags, err := parseFile(managerPath, srcDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -97,7 +102,7 @@ func main() {
 	}
 }
 
-func parseFile(path string) ([]FlagInfo, error) {
+func parseFile(path, srcDir string) ([]FlagInfo, error) {
 	fset := token.NewFileSet()
 	node, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
 	if err != nil {
@@ -108,6 +113,26 @@ func parseFile(path string) ([]FlagInfo, error) {
 	dir := filepath.Dir(path)
 	constants := parsePackageConstants(dir, fset)
 
+	// Constants of imported packages of this repository are referred to as <package>.<name>
+	for _, imp := range node.Imports {
+		importPath := strings.Trim(imp.Path.Value, `"`)
+		rel, ok := strings.CutPrefix(importPath, repoImportPrefix)
+		if !ok {
+			continue
+		}
+		importDir := filepath.Join(srcDir, filepath.FromSlash(rel))
+		name := packageName(importDir, fset)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if name == "" || name == "_" || name == "." {
+			continue
+		}
+		for k, v := range parsePackageConstants(importDir, fset) {
+			constants[name+"."+k] = v
+		}
+	}
+
 	var flags []FlagInfo
 
 	// Find all reg.XxxWithEnv calls anywhere in the file
@@ -123,6 +148,23 @@ func parseFile(path string) ([]FlagInfo, error) {
 	return flags, nil
 }
 
+// repoImportPrefix is the import path prefix of the modules under the src directory of this repository
+const repoImportPrefix = "github.com/confidential-containers/cloud-api-adaptor/src/"
+
+// packageName returns the name of the non-test package in the directory
+func packageName(dir string, fset *token.FileSet) string {
+	pkgs, err := parser.ParseDir(fset, dir, nil, parser.PackageClauseOnly)
+	if err != nil {
+		return ""
+	}
+	for name := range pkgs {
+		if !strings.HasSuffix(name, "_test") {
+			return name
+		}
+	}
+	return ""
+}
+
 // parsePackageConstants extracts all const declarations from all .go files in the directory
 func parsePackageConstants(dir string, fset *token.FileSet) map[string]string {
 	constants := make(map[string]string)
@@ -303,6 +345,13 @@ func exprToString(expr ast.Expr, constants map[string]string) string {
 			return val
 		}
 		return e.Name
+	case *ast.SelectorExpr:
+		// Constant of an imported package
+		if x, ok := e.X.(*ast.Ident); ok {
+			if val, ok := constants[x.Name+"."+e.Sel.Name]; ok {
+				return val
+			}
+		}
 	case *ast.UnaryExpr:
 		// Handle negative numbers
 		if e.Op == token.SUB {
@@ -0,0 +1,259 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// ConfigVersion is the version of the format of configuration files
+	ConfigVersion = "v1"
+
+	// ProviderSection is the section of the configuration file of the flags of cloud providers
+	ProviderSection = "provider"
+)
+
+// LoadConfigFile sets flags from a YAML configuration file. See LoadConfig.
+func (r *FlagRegistrar) LoadConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading configuration file %s: %w", path, err)
+	}
+	if err := r.LoadConfig(data); err != nil {
+		return fmt.Errorf("loading configuration file %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadConfig sets flags from a YAML configuration, whose sections map flag names to values. For example:
+//
+//	version: v1
+//	server:
+//	  pods-dir: /run/peerpod/pods
+//	provider:
+//	  instance-types: [t3.small, t3.medium]
+//
+// Flags that are set on the command line or by their environment variables override the configuration.
+// Lists are joined with commas, and maps are joined as comma separated key=value pairs. Unknown sections
+// and keys are rejected. It must be called after the FlagSet is parsed.
+func (r *FlagRegistrar) LoadConfig(data []byte) error {
+	var config map[string]any
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return err
+	}
+
+	if version, ok := config["version"]; !ok || version != ConfigVersion {
+		return fmt.Errorf("unsupported version %v, expected %q", version, ConfigVersion)
+	}
+	delete(config, "version")
+
+	set := r.setFlags()
+	metadata := r.metadata()
+
+	r.registry.mutex.Lock()
+	defer r.registry.mutex.Unlock()
+
+	sections := map[string]bool{}
+	for _, m := range r.registry.flags {
+		sections[m.section] = true
+	}
+
+	type setting struct {
+		key   string
+		name  string
+		value string
+	}
+	var settings []setting
+	var unknown []string
+
+	for _, section := range sortedKeys(config) {
+		if !sections[section] {
+			unknown = append(unknown, section)
+			continue
+		}
+		values, ok := stringMap(config[section])
+		if !ok {
+			if config[section] != nil {
+				return fmt.Errorf("section %s is not a mapping", section)
+			}
+			continue
+		}
+		for _, name := range sortedKeys(values) {
+			key := section + "." + name
+			m := metadata[name]
+			if m == nil || m.section != section || m.commandLineOnly {
+				unknown = append(unknown, key)
+				continue
+			}
+			value, err := configValue(values[name])
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			settings = append(settings, setting{key: key, name: name, value: value})
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown keys: %s", strings.Join(unknown, ", "))
+	}
+
+	for _, s := range settings {
+		m := metadata[s.name]
+		if set[s.name] || (m.envVarName != "" && os.Getenv(m.envVarName) != "") {
+			continue
+		}
+		if err := r.flags.Set(s.name, s.value); err != nil {
+			return fmt.Errorf("%s: %w", s.key, err)
+		}
+		if r.registry.fromConfig == nil {
+			r.registry.fromConfig = map[string]bool{}
+		}
+		r.registry.fromConfig[s.name] = true
+	}
+	return nil
+}
+
+// stringMap converts a YAML mapping to a map with string keys
+func stringMap(value any) (map[string]any, bool) {
+	mapping, ok := value.(map[any]any)
+	if !ok {
+		return nil, false
+	}
+	m := make(map[string]any, len(mapping))
+	for key, item := range mapping {
+		m[fmt.Sprint(key)] = item
+	}
+	return m, true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// configValue converts a value of the configuration file to the string representation of a flag value
+func configValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := configValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case map[any]any:
+		pairs := make([]string, 0, len(v))
+		for key, item := range v {
+			s, err := configValue(item)
+			if err != nil {
+				return "", err
+			}
+			pairs = append(pairs, fmt.Sprintf("%v=%s", key, s))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ","), nil
+	case string, bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
+
+// Schema returns a JSON Schema of the configuration file, generated from the metadata of the registered flags
+func (r *FlagRegistrar) Schema() ([]byte, error) {
+	r.registry.mutex.Lock()
+	defer r.registry.mutex.Unlock()
+
+	sections := map[string]map[string]any{}
+	var order []string
+	for _, m := range r.registry.flags {
+		if m.commandLineOnly {
+			continue
+		}
+		properties, ok := sections[m.section]
+		if !ok {
+			properties = map[string]any{}
+			sections[m.section] = properties
+			order = append(order, m.section)
+		}
+		properties[m.name] = m.schema()
+	}
+
+	properties := map[string]any{
+		"version": map[string]any{"const": ConfigVersion},
+	}
+	for _, section := range order {
+		properties[section] = map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties":           sections[section],
+		}
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "cloud-api-adaptor configuration",
+		"type":                 "object",
+		"required":             []string{"version"},
+		"additionalProperties": false,
+		"properties":           properties,
+	}, "", "  ")
+}
+
+func (m *flagMetadata) schema() map[string]any {
+	description := m.usage
+	if m.envVarName != "" {
+		description += fmt.Sprintf(" (overridden by %s)", m.envVarName)
+	}
+	schema := map[string]any{"description": description}
+
+	switch m.flagType {
+	case "string":
+		schema["type"] = "string"
+	case "int":
+		schema["type"] = "integer"
+	case "uint", "uint64":
+		schema["type"] = "integer"
+		schema["minimum"] = 0
+	case "float64":
+		schema["type"] = "number"
+	case "bool":
+		schema["type"] = "boolean"
+	case "duration":
+		schema["type"] = "string"
+		schema["pattern"] = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	default:
+		// Custom types are comma separated lists or key=value pairs
+		schema["type"] = []string{"string", "array", "object"}
+		schema["items"] = map[string]any{"type": []string{"string", "number", "boolean"}}
+	}
+
+	if m.hasDefault {
+		if d, ok := m.hardcodedDefault.(time.Duration); ok {
+			schema["default"] = d.String()
+		} else {
+			schema["default"] = m.hardcodedDefault
+		}
+	}
+	if m.secret {
+		schema["writeOnly"] = true
+	}
+	return schema
+}
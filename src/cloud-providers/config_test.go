@@ -0,0 +1,174 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testList []string
+
+func (l *testList) String() string { return strings.Join(*l, ",") }
+
+func (l *testList) Set(value string) error {
+	*l = append(*l, strings.Split(value, ",")...)
+	return nil
+}
+
+type testConfig struct {
+	configFile   string
+	podsDir      string
+	proxyTimeout time.Duration
+	tunnelType   string
+	vxlanPort    int
+	secretKey    string
+	region       string
+	types        testList
+}
+
+func newTestConfig(t *testing.T) (*testConfig, *flag.FlagSet, *FlagRegistrar) {
+	var c testConfig
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+
+	reg := NewFlagRegistrar(flags)
+	reg.StringWithEnv(&c.configFile, "config", "", "TEST_CONFIG_FILE", "Configuration file", CommandLineOnly())
+	reg.SetSection("server")
+	reg.StringWithEnv(&c.podsDir, "pods-dir", "/run/peerpod/pods", "TEST_PODS_DIR", "Pods directory")
+	reg.DurationWithEnv(&c.proxyTimeout, "proxy-timeout", 5*time.Minute, "TEST_PROXY_TIMEOUT", "Proxy timeout")
+	reg.SetSection("network")
+	reg.StringWithEnv(&c.tunnelType, "tunnel-type", "vxlan", "TEST_TUNNEL_TYPE", "Tunnel type")
+	reg.IntWithEnv(&c.vxlanPort, "vxlan-port", 0, "", "VXLAN port")
+
+	// Providers register their flags with their own registrars
+	preg := NewFlagRegistrar(flags)
+	preg.StringWithEnv(&c.secretKey, "secret-key", "", "TEST_SECRET_KEY", "Secret key", Secret())
+	preg.StringWithEnv(&c.region, "region", "", "TEST_REGION", "Region", Required())
+	preg.CustomTypeWithEnv(&c.types, "instance-types", "", "TEST_INSTANCE_TYPES", "Instance types")
+
+	return &c, flags, reg
+}
+
+const testConfigYAML = `
+version: v1
+server:
+  pods-dir: /var/lib/peerpods
+  proxy-timeout: 10m
+network:
+  tunnel-type: wireguard
+  vxlan-port: 4790
+provider:
+  secret-key: abcdefg
+  region: eu-gb
+  instance-types: [t3.small, t3.medium]
+`
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("TEST_TUNNEL_TYPE", "vxlan")
+
+	c, flags, reg := newTestConfig(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(testConfigYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := flags.Parse([]string{"-config", path, "-pods-dir", "/tmp/pods"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reg.LoadConfigFile(c.configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flags and environment variables override the configuration file
+	if c.podsDir != "/tmp/pods" || c.tunnelType != "vxlan" {
+		t.Errorf("overridden values are changed: %s %s", c.podsDir, c.tunnelType)
+	}
+	if c.proxyTimeout != 10*time.Minute || c.vxlanPort != 4790 || c.region != "eu-gb" || c.secretKey != "abcdefg" {
+		t.Errorf("unexpected values: %+v", c)
+	}
+	if !reflect.DeepEqual(c.types, testList{"t3.small", "t3.medium"}) {
+		t.Errorf("unexpected instance types: %v", c.types)
+	}
+
+	if err := reg.Validate(); err != nil {
+		t.Errorf("required flag set by the configuration file is missing: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := reg.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, `config  "eu-gb"`) || strings.Contains(out, "abcdefg") {
+		t.Errorf("unexpected dump:\n%s", out)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		config   string
+		expected string
+	}{
+		"missing version": {"server: {}\n", "unsupported version"},
+		"wrong version":   {"version: v2\n", "unsupported version"},
+		"unknown section": {"version: v1\nstorage:\n  size: 1\n", "unknown keys: storage"},
+		"unknown key":     {"version: v1\nserver:\n  pod-dir: /tmp\n  tunnel-type: vxlan\n", "unknown keys: server.pod-dir, server.tunnel-type"},
+		"command line":    {"version: v1\nserver:\n  config: /tmp/c.yaml\n", "unknown keys"},
+		"invalid value":   {"version: v1\nserver:\n  proxy-timeout: often\n", "server.proxy-timeout"},
+		"not a mapping":   {"version: v1\nserver: [a]\n", "not a mapping"},
+	} {
+		_, flags, reg := newTestConfig(t)
+		if err := flags.Parse(nil); err != nil {
+			t.Fatal(err)
+		}
+		err := reg.LoadConfig([]byte(tc.config))
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("%s: expected error containing %q, got %v", name, tc.expected, err)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	_, _, reg := newTestConfig(t)
+
+	data, err := reg.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		AdditionalProperties bool `json:"additionalProperties"`
+		Properties           map[string]struct {
+			AdditionalProperties bool                      `json:"additionalProperties"`
+			Properties           map[string]map[string]any `json:"properties"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	if schema.AdditionalProperties || schema.Properties["server"].AdditionalProperties {
+		t.Errorf("unknown keys are allowed by the schema")
+	}
+	if _, ok := schema.Properties["server"].Properties["config"]; ok {
+		t.Errorf("command line only flag is in the schema")
+	}
+
+	timeout := schema.Properties["server"].Properties["proxy-timeout"]
+	if timeout["type"] != "string" || timeout["default"] != "5m0s" {
+		t.Errorf("unexpected schema of proxy-timeout: %v", timeout)
+	}
+	if port := schema.Properties["network"].Properties["vxlan-port"]; port["type"] != "integer" || port["default"] != nil {
+		t.Errorf("unexpected schema of vxlan-port: %v", port)
+	}
+	if key := schema.Properties[ProviderSection].Properties["secret-key"]; key["writeOnly"] != true {
+		t.Errorf("unexpected schema of secret-key: %v", key)
+	}
+}
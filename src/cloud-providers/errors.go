@@ -0,0 +1,60 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error classes returned by providers. A provider wraps the error from its cloud SDK with
+// one of these errors, so that callers can check the class with errors.Is regardless of the
+// cloud provider, and still see the original error message.
+var (
+	// ErrInsufficientCapacity is returned when the cloud cannot provide an instance of the
+	// requested type at the moment, for example because the zone is out of capacity
+	ErrInsufficientCapacity = errors.New("insufficient capacity")
+
+	// ErrQuotaExceeded is returned when an account or project quota or limit is reached
+	ErrQuotaExceeded = errors.New("quota exceeded")
+
+	// ErrInstanceNotFound is returned when an instance does not exist
+	ErrInstanceNotFound = errors.New("instance not found")
+
+	// ErrAuth is returned when the credentials of a provider are invalid or lack a permission
+	ErrAuth = errors.New("authentication or authorization failure")
+
+	// ErrInvalidSpec is returned when the requested instance cannot be created as specified,
+	// for example because of an unknown image or an unsupported instance type
+	ErrInvalidSpec = errors.New("invalid instance spec")
+)
+
+// WrapError returns an error that matches both class and err with errors.Is.
+// It returns err unchanged if err is nil or already matches class.
+func WrapError(class, err error) error {
+	if err == nil || errors.Is(err, class) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", class, err)
+}
+
+// IsRetryable returns whether an operation that failed with err may succeed when it is
+// retried later with the same arguments. Capacity shortages are usually temporary, but
+// an instance creation that already fell back to other instance types or placements is
+// not retried, since the retries would multiply the attempts.
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrInsufficientCapacity) && !errors.Is(err, ErrFallbackExhausted)
+}
+
+// ShouldFallback returns whether an instance creation that failed with err may succeed
+// with another instance type or in another zone
+func ShouldFallback(err error) bool {
+	return errors.Is(err, ErrInsufficientCapacity) || errors.Is(err, ErrQuotaExceeded)
+}
+
+// IsPermanent returns whether an operation that failed with err fails again when it is
+// retried with the same configuration, so that callers should fail fast
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrAuth) || errors.Is(err, ErrInvalidSpec)
+}
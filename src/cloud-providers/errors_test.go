@@ -0,0 +1,49 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestWrapError(t *testing.T) {
+	sdkErr := errors.New("ZONE_RESOURCE_POOL_EXHAUSTED")
+
+	err := fmt.Errorf("creating instance: %w", WrapError(ErrInsufficientCapacity, sdkErr))
+	if !errors.Is(err, ErrInsufficientCapacity) || !errors.Is(err, sdkErr) {
+		t.Errorf("WrapError() = %v, want an error matching %v and %v", err, ErrInsufficientCapacity, sdkErr)
+	}
+	if err := WrapError(ErrInsufficientCapacity, err); err.Error() != "creating instance: insufficient capacity: ZONE_RESOURCE_POOL_EXHAUSTED" {
+		t.Errorf("WrapError() wraps an error of the same class again: %v", err)
+	}
+	if err := WrapError(ErrAuth, nil); err != nil {
+		t.Errorf("WrapError() = %v, want nil", err)
+	}
+
+	tests := []struct {
+		err                                  error
+		retryable, shouldFallback, permanent bool
+	}{
+		{WrapError(ErrInsufficientCapacity, sdkErr), true, true, false},
+		{WrapError(ErrQuotaExceeded, sdkErr), false, true, false},
+		{WrapError(ErrFallbackExhausted, WrapError(ErrInsufficientCapacity, sdkErr)), false, true, false},
+		{WrapError(ErrAuth, sdkErr), false, false, true},
+		{WrapError(ErrInvalidSpec, sdkErr), false, false, true},
+		{WrapError(ErrInstanceNotFound, sdkErr), false, false, false},
+		{sdkErr, false, false, false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.retryable {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.retryable)
+		}
+		if got := ShouldFallback(tt.err); got != tt.shouldFallback {
+			t.Errorf("ShouldFallback(%v) = %v, want %v", tt.err, got, tt.shouldFallback)
+		}
+		if got := IsPermanent(tt.err); got != tt.permanent {
+			t.Errorf("IsPermanent(%v) = %v, want %v", tt.err, got, tt.permanent)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrFallbackExhausted is returned along with the errors of all the attempts when instance creation failed
+// with every instance type and placement that a provider falls back to
+var ErrFallbackExhausted = errors.New("instance creation fallback exhausted")
+
+// FallbackPolicy controls how a provider retries instance creation when the cloud has no capacity
+// or quota left for the selected instance type or placement. The zero value makes a single attempt.
+//
+// The libvirt and byom providers have no fallback. libvirt creates VMs on a single local hypervisor with
+// no instance types or placements to choose from, and byom hands out pre-existing VMs from an IP pool.
+type FallbackPolicy struct {
+	// MaxAttempts limits the number of creation attempts. Zero means one attempt for each combination
+	// of instance type and placement.
+	MaxAttempts int
+	// LargerInstanceTypes enables falling back to the next larger instance types of the sorted instance type list
+	LargerInstanceTypes bool
+}
+
+// CreateAttempt is a combination of instance type and placement to create an instance with
+type CreateAttempt struct {
+	InstanceType string
+	// Placement is a provider specific location such as a subnet, a zone or a dedicated host group.
+	// An empty placement means the location configured for the provider.
+	Placement string
+}
+
+func (a CreateAttempt) String() string {
+	if a.Placement == "" {
+		return fmt.Sprintf("instance type %q", a.InstanceType)
+	}
+	return fmt.Sprintf("instance type %q in %q", a.InstanceType, a.Placement)
+}
+
+// Attempts returns the attempts in the order they are tried. The selected instance type comes first,
+// followed by the larger instance types of sortedSpecList with the same architecture. Instance types
+// with GPUs are only used when the selected instance type has GPUs. The default placement is tried
+// with all the instance types before the alternate placements.
+func (p FallbackPolicy) Attempts(instanceType string, sortedSpecList []InstanceTypeSpec, placements []string) []CreateAttempt {
+	instanceTypes := []string{instanceType}
+	if p.LargerInstanceTypes {
+		instanceTypes = append(instanceTypes, largerInstanceTypes(instanceType, sortedSpecList)...)
+	}
+
+	var attempts []CreateAttempt
+	for _, placement := range append([]string{""}, placements...) {
+		for _, t := range instanceTypes {
+			attempts = append(attempts, CreateAttempt{InstanceType: t, Placement: placement})
+		}
+	}
+
+	if p.MaxAttempts > 0 && len(attempts) > p.MaxAttempts {
+		attempts = attempts[:p.MaxAttempts]
+	}
+
+	return attempts
+}
+
+func largerInstanceTypes(instanceType string, sortedSpecList []InstanceTypeSpec) []string {
+	index := -1
+	for i, spec := range sortedSpecList {
+		if spec.InstanceType == instanceType {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil
+	}
+
+	selected := sortedSpecList[index]
+	var larger []string
+	for _, spec := range sortedSpecList[index+1:] {
+		if spec.Arch != selected.Arch || spec.GPUs < selected.GPUs || (selected.GPUs == 0 && spec.GPUs > 0) {
+			continue
+		}
+		if spec.VCPUs < selected.VCPUs || spec.Memory < selected.Memory {
+			continue
+		}
+		larger = append(larger, spec.InstanceType)
+	}
+	return larger
+}
+
+// CreateWithFallback calls create for each attempt until an instance is created. It moves on to the next
+// attempt only when the error indicates that capacity or quota is exhausted (see ShouldFallback). The attempt
+// that succeeded is returned along with the result. If all the attempts fail, the errors of all the
+// attempts are returned.
+func CreateWithFallback[T any](ctx context.Context, attempts []CreateAttempt, create func(context.Context, CreateAttempt) (T, error)) (T, CreateAttempt, error) {
+	var zero T
+
+	if len(attempts) == 0 {
+		return zero, CreateAttempt{}, fmt.Errorf("no instance creation attempts: %w", ErrInvalidSpec)
+	}
+
+	var errs []error
+	for i, attempt := range attempts {
+		if i > 0 {
+			logger.Printf("Retrying instance creation with %s (attempt %d of %d)", attempt, i+1, len(attempts))
+		}
+
+		result, err := create(ctx, attempt)
+		if err == nil {
+			return result, attempt, nil
+		}
+
+		if len(attempts) == 1 {
+			return zero, attempt, err
+		}
+
+		errs = append(errs, fmt.Errorf("attempt %d with %s: %w", i+1, attempt, err))
+
+		if !ShouldFallback(err) || ctx.Err() != nil {
+			break
+		}
+		if i+1 == len(attempts) {
+			return zero, CreateAttempt{}, WrapError(ErrFallbackExhausted, errors.Join(errs...))
+		}
+		logger.Printf("Instance creation with %s failed: %v", attempt, err)
+	}
+
+	return zero, CreateAttempt{}, errors.Join(errs...)
+}
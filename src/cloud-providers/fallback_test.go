@@ -0,0 +1,150 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFallbackPolicyAttempts(t *testing.T) {
+	specList := SortInstanceTypesOnResources([]InstanceTypeSpec{
+		{InstanceType: "small", VCPUs: 2, Memory: 4096, Arch: "amd64"},
+		{InstanceType: "medium", VCPUs: 4, Memory: 8192, Arch: "amd64"},
+		{InstanceType: "medium-arm", VCPUs: 4, Memory: 8192, Arch: "arm64"},
+		{InstanceType: "medium-gpu", VCPUs: 4, Memory: 16384, GPUs: 1, Arch: "amd64"},
+		{InstanceType: "large", VCPUs: 8, Memory: 32768, Arch: "amd64"},
+	})
+
+	tests := []struct {
+		name         string
+		policy       FallbackPolicy
+		instanceType string
+		placements   []string
+		want         []CreateAttempt
+	}{
+		{
+			name:         "zero policy",
+			instanceType: "small",
+			want:         []CreateAttempt{{InstanceType: "small"}},
+		},
+		{
+			name:         "larger instance types",
+			policy:       FallbackPolicy{LargerInstanceTypes: true},
+			instanceType: "small",
+			want:         []CreateAttempt{{InstanceType: "small"}, {InstanceType: "medium"}, {InstanceType: "large"}},
+		},
+		{
+			name:         "instance type not in the list",
+			policy:       FallbackPolicy{LargerInstanceTypes: true},
+			instanceType: "other",
+			want:         []CreateAttempt{{InstanceType: "other"}},
+		},
+		{
+			name:         "placements",
+			policy:       FallbackPolicy{LargerInstanceTypes: true},
+			instanceType: "medium",
+			placements:   []string{"subnet-b"},
+			want: []CreateAttempt{
+				{InstanceType: "medium"},
+				{InstanceType: "large"},
+				{InstanceType: "medium", Placement: "subnet-b"},
+				{InstanceType: "large", Placement: "subnet-b"},
+			},
+		},
+		{
+			name:         "retry budget",
+			policy:       FallbackPolicy{MaxAttempts: 3, LargerInstanceTypes: true},
+			instanceType: "small",
+			placements:   []string{"subnet-b"},
+			want:         []CreateAttempt{{InstanceType: "small"}, {InstanceType: "medium"}, {InstanceType: "large"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.Attempts(tt.instanceType, specList, tt.placements)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Attempts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateWithFallback(t *testing.T) {
+	attempts := []CreateAttempt{{InstanceType: "small"}, {InstanceType: "large"}, {InstanceType: "small", Placement: "zone-b"}}
+
+	tests := []struct {
+		name      string
+		errs      map[CreateAttempt]error
+		want      CreateAttempt
+		wantCalls int
+		wantErr   error
+	}{
+		{
+			name:      "first attempt succeeds",
+			want:      attempts[0],
+			wantCalls: 1,
+		},
+		{
+			name: "fallback on insufficient capacity and quota",
+			errs: map[CreateAttempt]error{
+				attempts[0]: ErrInsufficientCapacity,
+				attempts[1]: ErrQuotaExceeded,
+			},
+			want:      attempts[2],
+			wantCalls: 3,
+		},
+		{
+			name: "no fallback on other errors",
+			errs: map[CreateAttempt]error{
+				attempts[0]: ErrInsufficientCapacity,
+				attempts[1]: ErrAuth,
+			},
+			wantCalls: 2,
+			wantErr:   ErrAuth,
+		},
+		{
+			name: "all attempts fail",
+			errs: map[CreateAttempt]error{
+				attempts[0]: ErrInsufficientCapacity,
+				attempts[1]: ErrInsufficientCapacity,
+				attempts[2]: ErrInsufficientCapacity,
+			},
+			wantCalls: 3,
+			wantErr:   ErrFallbackExhausted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			result, got, err := CreateWithFallback(context.Background(), attempts, func(ctx context.Context, attempt CreateAttempt) (string, error) {
+				calls++
+				if err := tt.errs[attempt]; err != nil {
+					return "", err
+				}
+				return attempt.InstanceType, nil
+			})
+
+			if calls != tt.wantCalls {
+				t.Errorf("CreateWithFallback() made %d attempts, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("CreateWithFallback() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateWithFallback() error = %v", err)
+			}
+			if got != tt.want || result != tt.want.InstanceType {
+				t.Errorf("CreateWithFallback() = %q, %v, want %q, %v", result, got, tt.want.InstanceType, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util"
+)
+
+// flagMetadata is the metadata of a flag registered by a FlagRegistrar
+type flagMetadata struct {
+	flagType         string
+	name             string
+	envVarName       string
+	usage            string
+	section          string
+	hardcodedDefault any
+	hasDefault       bool
+	flagOptions
+}
+
+// flagRegistry holds the metadata of the flags of a FlagSet in the order of registration
+type flagRegistry struct {
+	mutex sync.Mutex
+	flags []*flagMetadata
+	// fromConfig are the flags that are set by the configuration file
+	fromConfig map[string]bool
+}
+
+var (
+	registriesMutex sync.Mutex
+	registries      = map[*flag.FlagSet]*flagRegistry{}
+)
+
+func registryOf(flags *flag.FlagSet) *flagRegistry {
+	registriesMutex.Lock()
+	defer registriesMutex.Unlock()
+
+	registry, ok := registries[flags]
+	if !ok {
+		registry = &flagRegistry{}
+		registries[flags] = registry
+	}
+	return registry
+}
+
+func (r *FlagRegistrar) record(flagType, flagName, envVarName, usage string, hardcodedDefault any, opts []FlagOption) {
+	r.registry.mutex.Lock()
+	defer r.registry.mutex.Unlock()
+
+	r.registry.flags = append(r.registry.flags, &flagMetadata{
+		flagType:         flagType,
+		name:             flagName,
+		envVarName:       envVarName,
+		usage:            usage,
+		section:          r.section,
+		hardcodedDefault: hardcodedDefault,
+		hasDefault:       !reflect.ValueOf(hardcodedDefault).IsZero(),
+		flagOptions:      *applyOptions(opts),
+	})
+}
+
+func (r *FlagRegistrar) metadata() map[string]*flagMetadata {
+	r.registry.mutex.Lock()
+	defer r.registry.mutex.Unlock()
+
+	metadata := make(map[string]*flagMetadata, len(r.registry.flags))
+	for _, m := range r.registry.flags {
+		metadata[m.name] = m
+	}
+	return metadata
+}
+
+// source returns where the value of a flag comes from: "flag", "env", "config" or "default"
+func (r *FlagRegistrar) source(name string, m *flagMetadata, set map[string]bool) string {
+	if r.registry.fromConfig[name] {
+		return "config"
+	}
+	if set[name] {
+		return "flag"
+	}
+	if m != nil && m.envVarName != "" && os.Getenv(m.envVarName) != "" {
+		return "env"
+	}
+	return "default"
+}
+
+// setFlags returns the flags that are set on the command line or by the configuration file
+func (r *FlagRegistrar) setFlags() map[string]bool {
+	set := map[string]bool{}
+	r.flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
+
+// Validate returns an error that lists the required flags that are set neither on the command line
+// nor by their environment variables, and have no default. It must be called after the FlagSet is parsed.
+func (r *FlagRegistrar) Validate() error {
+	set := r.setFlags()
+
+	r.registry.mutex.Lock()
+	defer r.registry.mutex.Unlock()
+
+	var missing []string
+	for _, m := range r.registry.flags {
+		if !m.required || m.hasDefault || r.source(m.name, m, set) != "default" {
+			continue
+		}
+		if m.envVarName != "" {
+			missing = append(missing, fmt.Sprintf("--%s (%s)", m.name, m.envVarName))
+		} else {
+			missing = append(missing, "--"+m.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required configuration: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Dump prints the effective value of each flag of the FlagSet, with its environment variable and the source
+// of the value. Values of secret flags are masked.
+func (r *FlagRegistrar) Dump(w io.Writer) error {
+	set := r.setFlags()
+	metadata := r.metadata()
+
+	r.registry.mutex.Lock()
+	defer r.registry.mutex.Unlock()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "FLAG\tENV VAR\tSOURCE\tVALUE\n")
+	r.flags.VisitAll(func(f *flag.Flag) {
+		m := metadata[f.Name]
+		envVarName := "-"
+		value := f.Value.String()
+		if m != nil {
+			if m.envVarName != "" {
+				envVarName = m.envVarName
+			}
+			if m.secret {
+				value = util.RedactString(value)
+			}
+		}
+		fmt.Fprintf(tw, "--%s\t%s\t%s\t%q\n", f.Name, envVarName, r.source(f.Name, m, set), value)
+	})
+	return tw.Flush()
+}
@@ -0,0 +1,84 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestFlagRegistrarValidate(t *testing.T) {
+	t.Setenv("TEST_ZONE", "")
+	t.Setenv("TEST_NETWORK", "default")
+
+	var project, zone, network, key string
+	var size int
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+
+	// Registrars of the same FlagSet share metadata, like the common and provider flags of cloud-api-adaptor
+	NewFlagRegistrar(flags).StringWithEnv(&project, "project", "", "TEST_PROJECT", "Project", Required())
+	reg := NewFlagRegistrar(flags)
+	reg.StringWithEnv(&zone, "zone", "", "TEST_ZONE", "Zone", Required())
+	reg.StringWithEnv(&network, "network", "", "TEST_NETWORK", "Network", Required())
+	reg.StringWithEnv(&key, "key", "", "TEST_KEY", "Key", Secret())
+	reg.IntWithEnv(&size, "size", 10, "TEST_SIZE", "Size", Required())
+
+	if err := flags.Parse([]string{}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := reg.Validate()
+	if err == nil {
+		t.Fatal("expected an error for missing flags")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "--project (TEST_PROJECT), --zone (TEST_ZONE)") || strings.Contains(msg, "network") || strings.Contains(msg, "size") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := flags.Parse([]string{"-project", "p1", "-zone", "z1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFlagRegistrarDump(t *testing.T) {
+	t.Setenv("TEST_REGION", "eu-gb")
+
+	var region, secretKey, instanceType string
+	var debug bool
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	reg := NewFlagRegistrar(flags)
+	reg.StringWithEnv(&region, "region", "", "TEST_REGION", "Region")
+	reg.StringWithEnv(&secretKey, "secret-key", "", "TEST_SECRET_KEY", "Secret key", Secret())
+	reg.StringWithEnv(&instanceType, "instance-type", "t2.small", "TEST_INSTANCE_TYPE", "Instance type")
+	flags.BoolVar(&debug, "debug", false, "Debug")
+
+	if err := flags.Parse([]string{"-secret-key", "abcdefg"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := reg.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	if strings.Contains(out, "abcdefg") {
+		t.Errorf("dump contains the secret key:\n%s", out)
+	}
+	for _, expected := range []string{
+		`--region         TEST_REGION         env      "eu-gb"`,
+		`--secret-key     TEST_SECRET_KEY     flag     "**********"`,
+		`--instance-type  TEST_INSTANCE_TYPE  default  "t2.small"`,
+		`--debug          -                   default  "false"`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("dump does not contain %q:\n%s", expected, out)
+		}
+	}
+}
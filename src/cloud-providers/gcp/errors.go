@@ -0,0 +1,61 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package gcp
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+)
+
+// Errors of long-running operations carry the error codes of the operation only in the message
+// Ref: https://cloud.google.com/compute/docs/troubleshooting/troubleshooting-resource-availability
+var (
+	capacityErrorCodes = []string{"ZONE_RESOURCE_POOL_EXHAUSTED", "RESOURCE_POOL_EXHAUSTED", "resourcePoolExhausted"}
+	quotaErrorCodes    = []string{"QUOTA_EXCEEDED", "quotaExceeded"}
+)
+
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// toProviderError wraps a Compute Engine API error with the matching provider error class.
+// notFound is the class of a 404 response. It is provider.ErrInstanceNotFound when the
+// request is about an existing instance, and provider.ErrInvalidSpec when an instance is
+// created, since a missing resource is then one referenced by the instance, such as an image.
+func toProviderError(err error, notFound error) error {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+
+	details := apiErr.Message
+	for _, item := range apiErr.Errors {
+		details += " " + item.Reason
+	}
+
+	switch {
+	case containsAny(details, capacityErrorCodes):
+		return provider.WrapError(provider.ErrInsufficientCapacity, err)
+	case containsAny(details, quotaErrorCodes):
+		return provider.WrapError(provider.ErrQuotaExceeded, err)
+	case apiErr.Code == http.StatusUnauthorized, apiErr.Code == http.StatusForbidden:
+		return provider.WrapError(provider.ErrAuth, err)
+	case apiErr.Code == http.StatusNotFound:
+		return provider.WrapError(notFound, err)
+	case apiErr.Code == http.StatusBadRequest:
+		return provider.WrapError(provider.ErrInvalidSpec, err)
+	}
+
+	return err
+}
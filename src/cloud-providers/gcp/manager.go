@@ -33,6 +33,9 @@ func (*Manager) ParseCmd(flags *flag.FlagSet) {
 	reg.StringWithEnv(&gcpcfg.ConfidentialType, "confidential-type", "", "GCP_CONFIDENTIAL_TYPE", "Used when DisableCVM=false. i.e: TDX, SEV or SEV_SNP. Check if the machine type is compatible.")
 	reg.IntWithEnv(&gcpcfg.RootVolumeSize, "root-volume-size", 10, "ROOT_VOLUME_SIZE", "Root volume size (in GiB) for the Pod VMs")
 	reg.BoolWithEnv(&gcpcfg.UsePublicIP, "use-public-ip", false, "USE_PUBLIC_IP", "Use Public IP for connecting to the kata-agent inside the Pod VM")
+	reg.IntWithEnv(&gcpcfg.FallbackMaxAttempts, "fallback-max-attempts", 0, "FALLBACK_MAX_ATTEMPTS", "Maximum number of instance creation attempts when capacity or quota is exhausted (0 tries every fallback)")
+	reg.BoolWithEnv(&gcpcfg.FallbackLargerMachineTypes, "fallback-larger-instance-types", false, "FALLBACK_LARGER_INSTANCE_TYPES", "Fall back to larger machine types from GCP_INSTANCE_TYPES when capacity or quota is exhausted")
+	reg.StringWithEnv(&gcpcfg.PodIPRangeName, "pod-ip-range-name", "", "GCP_POD_IP_RANGE_NAME", "Secondary range of the subnetwork that pod IP addresses belong to (routed tunnel type only, empty for the primary range)")
 
 	// Custom flag types (comma-separated lists)
 	reg.CustomTypeWithEnv(&gcpcfg.Tags, "tags", "", "TAGS", "List of tags to be added to the Pod VMs. Tags must already exist in the GCP project. Format: key1=value1,key2=value2")
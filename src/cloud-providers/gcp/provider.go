@@ -6,10 +6,12 @@ package gcp
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"net/netip"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/auth/credentials"
 	compute "cloud.google.com/go/compute/apiv1"
@@ -19,6 +21,7 @@ import (
 	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util/cloudinit"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	proto "google.golang.org/protobuf/proto"
 )
@@ -421,20 +424,36 @@ func (p *gcpProvider) CreateInstance(ctx context.Context, podName, sandboxID str
 		InstanceResource: instanceResource,
 	}
 
-	op, err := p.instancesClient.Insert(ctx, insertReq)
-	if err != nil {
-		return nil, fmt.Errorf("Instances.Insert error: %s. req: %v", err, insertReq)
+	// Instances are looked up in the configured zone, so only the machine type falls back
+	policy := provider.FallbackPolicy{
+		MaxAttempts:         p.serviceConfig.FallbackMaxAttempts,
+		LargerInstanceTypes: p.serviceConfig.FallbackLargerMachineTypes && spec.InstanceType == "",
 	}
-	err = op.Wait(ctx)
+	attempts := policy.Attempts(machineType, p.serviceConfig.MachineTypeSpecList, nil)
+
+	_, attempt, err := provider.CreateWithFallback(ctx, attempts, func(ctx context.Context, attempt provider.CreateAttempt) (struct{}, error) {
+		instanceResource.MachineType = proto.String(fmt.Sprintf("zones/%s/machineTypes/%s", p.serviceConfig.Zone, attempt.InstanceType))
+
+		op, err := p.instancesClient.Insert(ctx, insertReq)
+		if err != nil {
+			return struct{}{}, fmt.Errorf("Instances.Insert error: %w. req: %v", toProviderError(err, provider.ErrInvalidSpec), insertReq)
+		}
+		err = op.Wait(ctx)
+		if err != nil {
+			return struct{}{}, fmt.Errorf("waiting for Instances.Insert error: %w. req: %v", toProviderError(err, provider.ErrInvalidSpec), insertReq)
+		}
+		return struct{}{}, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("waiting for Instances.Insert error: %s. req: %v", err, insertReq)
+		return nil, err
 	}
-	logger.Printf("created an instance %s for sandbox %s", instanceName, sandboxID)
+	logger.Printf("created an instance %s for sandbox %s with %s", instanceName, sandboxID, attempt)
 
 	// Create partial instance to return on error (allows caller to cleanup)
 	instance = &provider.Instance{
 		ID:   instanceName,
 		Name: instanceName,
+		Type: attempt.InstanceType,
 	}
 
 	getReq := &computepb.GetInstanceRequest{
@@ -507,12 +526,15 @@ func (p *gcpProvider) DeleteInstance(ctx context.Context, instanceID string) err
 		Instance: instanceID,
 	}
 	op, err := p.instancesClient.Delete(ctx, req)
-	if err != nil {
-		return fmt.Errorf("Instances.Delete error: %w, req: %v", err, req)
+	if err == nil {
+		err = op.Wait(ctx)
 	}
-	err = op.Wait(ctx)
 	if err != nil {
-		return fmt.Errorf("waiting for Instances.Delete error: %s. req: %v", err, req)
+		if err = toProviderError(err, provider.ErrInstanceNotFound); errors.Is(err, provider.ErrInstanceNotFound) {
+			logger.Printf("instance %s is already deleted", instanceID)
+			return nil
+		}
+		return fmt.Errorf("Instances.Delete error: %w, req: %v", err, req)
 	}
 	logger.Printf("deleted an instance %s", instanceID)
 	return nil
@@ -530,3 +552,218 @@ func hasAnyPrefix(s string, prefixes ...string) bool {
 	}
 	return false
 }
+
+func toInstanceStatus(status string) provider.InstanceStatus {
+	switch status {
+	case "PROVISIONING", "STAGING", "REPAIRING":
+		return provider.InstanceStatusPending
+	case "RUNNING":
+		return provider.InstanceStatusRunning
+	case "STOPPING", "STOPPED", "SUSPENDING", "SUSPENDED", "TERMINATED":
+		// TERMINATED is reported for stopped instances, deleted instances disappear
+		return provider.InstanceStatusStopped
+	}
+	return provider.InstanceStatusUnknown
+}
+
+func (p *gcpProvider) toInstanceInfo(gcpInstance *computepb.Instance) *provider.InstanceInfo {
+	info := &provider.InstanceInfo{
+		Instance: provider.Instance{
+			ID:   gcpInstance.GetName(),
+			Name: gcpInstance.GetName(),
+		},
+		Status: toInstanceStatus(gcpInstance.GetStatus()),
+		// Tags of GCP instances are resource manager tag bindings, which are not part of the instance resource.
+		// Labels are reported instead.
+		Tags: map[string]string{},
+	}
+
+	// machine type is a URL like https://www.googleapis.com/compute/v1/projects/<project>/zones/<zone>/machineTypes/<type>
+	machineType := gcpInstance.GetMachineType()
+	info.InstanceType = machineType[strings.LastIndex(machineType, "/")+1:]
+
+	for k, v := range gcpInstance.GetLabels() {
+		info.Tags[k] = v
+	}
+
+	if t, err := time.Parse(time.RFC3339, gcpInstance.GetCreationTimestamp()); err == nil {
+		info.CreatedAt = t
+	}
+
+	if info.Status == provider.InstanceStatusRunning {
+		ips, err := getIPs(gcpInstance.GetNetworkInterfaces(), p.serviceConfig.UsePublicIP)
+		if err != nil {
+			logger.Printf("failed to get IPs for the instance %s: %v", info.Name, err)
+		}
+		info.IPs = ips
+	}
+
+	return info
+}
+
+func (p *gcpProvider) GetInstance(ctx context.Context, instanceID string) (*provider.InstanceInfo, error) {
+	req := &computepb.GetInstanceRequest{
+		Project:  p.serviceConfig.ProjectID,
+		Zone:     p.serviceConfig.Zone,
+		Instance: instanceID,
+	}
+	gcpInstance, err := p.instancesClient.Get(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("Instances.Get error: %w, req: %v", toProviderError(err, provider.ErrInstanceNotFound), req)
+	}
+
+	return p.toInstanceInfo(gcpInstance), nil
+}
+
+func (p *gcpProvider) ListInstances(ctx context.Context, filter provider.InstanceFilter) ([]*provider.InstanceInfo, error) {
+	req := &computepb.ListInstancesRequest{
+		Project: p.serviceConfig.ProjectID,
+		Zone:    p.serviceConfig.Zone,
+	}
+
+	var instances []*provider.InstanceInfo
+
+	it := p.instancesClient.List(ctx, req)
+	for {
+		gcpInstance, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Instances.List error: %w, req: %v", toProviderError(err, provider.ErrInstanceNotFound), req)
+		}
+
+		info := p.toInstanceInfo(gcpInstance)
+		if filter.Match(info) {
+			instances = append(instances, info)
+		}
+	}
+
+	return instances, nil
+}
+
+// aliasIPRangeAddr returns the address of an alias IP range of a single address
+func aliasIPRangeAddr(r *computepb.AliasIpRange) (netip.Addr, bool) {
+	prefix, err := netip.ParsePrefix(r.GetIpCidrRange())
+	if err != nil {
+		addr, err := netip.ParseAddr(r.GetIpCidrRange())
+		return addr, err == nil
+	}
+	return prefix.Addr(), prefix.IsSingleIP()
+}
+
+// addAliasIPRanges returns alias IP ranges with an alias IP range of a single address added for each IP address
+// that is not in ranges yet. It also returns whether any range is added.
+func addAliasIPRanges(ranges []*computepb.AliasIpRange, ips []netip.Addr, rangeName string) ([]*computepb.AliasIpRange, bool) {
+
+	assigned := map[netip.Addr]bool{}
+	for _, r := range ranges {
+		if addr, ok := aliasIPRangeAddr(r); ok {
+			assigned[addr] = true
+		}
+	}
+
+	updated := append([]*computepb.AliasIpRange{}, ranges...)
+	for _, ip := range ips {
+		if assigned[ip] {
+			continue
+		}
+		r := &computepb.AliasIpRange{
+			IpCidrRange: proto.String(netip.PrefixFrom(ip, ip.BitLen()).String()),
+		}
+		if rangeName != "" {
+			r.SubnetworkRangeName = proto.String(rangeName)
+		}
+		updated = append(updated, r)
+		assigned[ip] = true
+	}
+
+	return updated, len(updated) != len(ranges)
+}
+
+// removeAliasIPRanges returns alias IP ranges without the alias IP ranges of a single address of the IP addresses.
+// It also returns whether any range is removed.
+func removeAliasIPRanges(ranges []*computepb.AliasIpRange, ips []netip.Addr) ([]*computepb.AliasIpRange, bool) {
+
+	remove := map[netip.Addr]bool{}
+	for _, ip := range ips {
+		remove[ip] = true
+	}
+
+	updated := []*computepb.AliasIpRange{}
+	for _, r := range ranges {
+		if addr, ok := aliasIPRangeAddr(r); ok && remove[addr] {
+			continue
+		}
+		updated = append(updated, r)
+	}
+
+	return updated, len(updated) != len(ranges)
+}
+
+// updateAliasIPRanges updates the alias IP ranges of the first network interface of an instance
+func (p *gcpProvider) updateAliasIPRanges(ctx context.Context, instanceID string, update func([]*computepb.AliasIpRange) ([]*computepb.AliasIpRange, bool)) error {
+
+	getReq := &computepb.GetInstanceRequest{
+		Project:  p.serviceConfig.ProjectID,
+		Zone:     p.serviceConfig.Zone,
+		Instance: instanceID,
+	}
+	gcpInstance, err := p.instancesClient.Get(ctx, getReq)
+	if err != nil {
+		return fmt.Errorf("Instances.Get error: %w, req: %v", toProviderError(err, provider.ErrInstanceNotFound), getReq)
+	}
+
+	nics := gcpInstance.GetNetworkInterfaces()
+	if len(nics) == 0 {
+		return fmt.Errorf("instance %s has no network interface", instanceID)
+	}
+	nic := nics[0]
+
+	ranges, changed := update(nic.GetAliasIpRanges())
+	if !changed {
+		return nil
+	}
+
+	req := &computepb.UpdateNetworkInterfaceInstanceRequest{
+		Project:          p.serviceConfig.ProjectID,
+		Zone:             p.serviceConfig.Zone,
+		Instance:         instanceID,
+		NetworkInterface: nic.GetName(),
+		NetworkInterfaceResource: &computepb.NetworkInterface{
+			AliasIpRanges: ranges,
+			// The fingerprint makes the update fail if the network interface is changed concurrently
+			Fingerprint: nic.Fingerprint,
+		},
+	}
+	op, err := p.instancesClient.UpdateNetworkInterface(ctx, req)
+	if err == nil {
+		err = op.Wait(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("Instances.UpdateNetworkInterface error: %w, req: %v", toProviderError(err, provider.ErrInstanceNotFound), req)
+	}
+
+	logger.Printf("updated alias IP ranges of %s of instance %s: %v", nic.GetName(), instanceID, ranges)
+
+	return nil
+}
+
+// AssignPodIPs adds pod IP addresses as alias IP ranges of the first network interface.
+// GCP does not move alias IP ranges between instances, so the addresses must not be in use by other instances.
+func (p *gcpProvider) AssignPodIPs(ctx context.Context, instanceID string, ips []netip.Addr) error {
+	return p.updateAliasIPRanges(ctx, instanceID, func(ranges []*computepb.AliasIpRange) ([]*computepb.AliasIpRange, bool) {
+		return addAliasIPRanges(ranges, ips, p.serviceConfig.PodIPRangeName)
+	})
+}
+
+// UnassignPodIPs removes pod IP addresses from the alias IP ranges of the first network interface
+func (p *gcpProvider) UnassignPodIPs(ctx context.Context, instanceID string, ips []netip.Addr) error {
+	err := p.updateAliasIPRanges(ctx, instanceID, func(ranges []*computepb.AliasIpRange) ([]*computepb.AliasIpRange, bool) {
+		return removeAliasIPRanges(ranges, ips)
+	})
+	if errors.Is(err, provider.ErrInstanceNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package gcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+	"google.golang.org/api/option"
+	"google.golang.org/protobuf/encoding/protojson"
+	proto "google.golang.org/protobuf/proto"
+)
+
+var _ provider.PodIPAssigner = &gcpProvider{}
+
+func aliasIPRangeStrings(ranges []*computepb.AliasIpRange) []string {
+	var s []string
+	for _, r := range ranges {
+		s = append(s, r.GetSubnetworkRangeName()+":"+r.GetIpCidrRange())
+	}
+	return s
+}
+
+func TestAliasIPRanges(t *testing.T) {
+	ranges := []*computepb.AliasIpRange{
+		{IpCidrRange: proto.String("10.4.0.0/24"), SubnetworkRangeName: proto.String("pods")},
+		{IpCidrRange: proto.String("10.2.0.5/32")},
+	}
+	ips := []netip.Addr{netip.MustParseAddr("10.2.0.5"), netip.MustParseAddr("10.2.0.6"), netip.MustParseAddr("10.2.0.6")}
+
+	added, changed := addAliasIPRanges(ranges, ips, "peerpods")
+	if !changed {
+		t.Error("addAliasIPRanges() changed = false, want true")
+	}
+	want := []string{"pods:10.4.0.0/24", ":10.2.0.5/32", "peerpods:10.2.0.6/32"}
+	if got := aliasIPRangeStrings(added); !reflect.DeepEqual(got, want) {
+		t.Errorf("addAliasIPRanges() = %v, want %v", got, want)
+	}
+	if len(ranges) != 2 {
+		t.Errorf("addAliasIPRanges() modified the original ranges: %v", aliasIPRangeStrings(ranges))
+	}
+
+	if _, changed := addAliasIPRanges(added, ips, "peerpods"); changed {
+		t.Error("addAliasIPRanges() changed = true for assigned addresses, want false")
+	}
+
+	// A range of more than one address is not removed, even if it contains a pod IP address
+	removed, changed := removeAliasIPRanges(added, append(ips, netip.MustParseAddr("10.4.0.1")))
+	if !changed {
+		t.Error("removeAliasIPRanges() changed = false, want true")
+	}
+	want = []string{"pods:10.4.0.0/24"}
+	if got := aliasIPRangeStrings(removed); !reflect.DeepEqual(got, want) {
+		t.Errorf("removeAliasIPRanges() = %v, want %v", got, want)
+	}
+
+	if _, changed := removeAliasIPRanges(removed, ips); changed {
+		t.Error("removeAliasIPRanges() changed = true for unassigned addresses, want false")
+	}
+}
+
+func TestListInstances(t *testing.T) {
+	instance := func(name string, labels map[string]string) *computepb.Instance {
+		return &computepb.Instance{
+			Name:   proto.String(name),
+			Status: proto.String("TERMINATED"),
+			Labels: labels,
+		}
+	}
+	list := &computepb.InstanceList{
+		Items: []*computepb.Instance{
+			instance("podvm-a", map[string]string{"cluster": "c1"}),
+			instance("podvm-b", map[string]string{"cluster": "c2"}),
+			instance("other", map[string]string{"cluster": "c1"}),
+			instance("plain", nil),
+		},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/compute/v1/projects/test-project/zones/test-zone/instances" {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := protojson.Marshal(list)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	client, err := compute.NewInstancesRESTClient(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("NewInstancesRESTClient() error = %v", err)
+	}
+	defer client.Close()
+
+	p := &gcpProvider{
+		serviceConfig:   &Config{ProjectID: "test-project", Zone: "test-zone"},
+		instancesClient: client,
+	}
+
+	for _, tc := range []struct {
+		name   string
+		filter provider.InstanceFilter
+		want   []string
+	}{
+		{name: "empty filter", want: []string{"podvm-a", "podvm-b", "other", "plain"}},
+		{name: "name prefix", filter: provider.InstanceFilter{NamePrefix: "podvm-"}, want: []string{"podvm-a", "podvm-b"}},
+		{name: "labels", filter: provider.InstanceFilter{Tags: map[string]string{"cluster": "c1"}}, want: []string{"podvm-a", "other"}},
+		{name: "name prefix and labels", filter: provider.InstanceFilter{NamePrefix: "podvm-", Tags: map[string]string{"cluster": "c1"}}, want: []string{"podvm-a"}},
+		{name: "no match", filter: provider.InstanceFilter{Tags: map[string]string{"cluster": "c3"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			instances, err := p.ListInstances(context.Background(), tc.filter)
+			if err != nil {
+				t.Fatalf("ListInstances() error = %v", err)
+			}
+			var got []string
+			for _, info := range instances {
+				got = append(got, info.Name)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ListInstances() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
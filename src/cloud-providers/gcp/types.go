@@ -41,6 +41,11 @@ type Config struct {
 	UsePublicIP         bool
 	MachineTypes        machineTypes
 	MachineTypeSpecList []provider.InstanceTypeSpec
+	// Instance creation fallback when capacity or quota is exhausted
+	FallbackMaxAttempts        int
+	FallbackLargerMachineTypes bool
+	// PodIPRangeName is the secondary range of the subnetwork that pod IP addresses of the routed tunnel type belong to
+	PodIPRangeName string
 }
 
 func (c Config) Redact() Config {
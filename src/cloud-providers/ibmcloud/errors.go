@@ -0,0 +1,78 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package ibmcloud
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+)
+
+// errorCodes returns the error codes in the body of a failed VPC API response
+func errorCodes(resp *core.DetailedResponse) []string {
+	if resp == nil {
+		return nil
+	}
+	result, ok := resp.Result.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	items, ok := result["errors"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var codes []string
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			if code, ok := m["code"].(string); ok {
+				codes = append(codes, code)
+			}
+		}
+	}
+	return codes
+}
+
+// toProviderError wraps a VPC API error with the matching provider error class.
+// notFound is the class of a 404 response. It is provider.ErrInstanceNotFound when the
+// request is about an existing instance, and provider.ErrInvalidSpec when an instance is
+// created, since a missing resource is then one referenced by the instance, such as an image.
+func toProviderError(err error, resp *core.DetailedResponse, notFound error) error {
+	if err == nil {
+		return nil
+	}
+
+	var authErr *core.AuthenticationError
+	if errors.As(err, &authErr) {
+		return provider.WrapError(provider.ErrAuth, err)
+	}
+
+	for _, code := range errorCodes(resp) {
+		switch {
+		case strings.Contains(code, "capacity"), strings.Contains(code, "insufficient"):
+			return provider.WrapError(provider.ErrInsufficientCapacity, err)
+		case strings.Contains(code, "quota"):
+			return provider.WrapError(provider.ErrQuotaExceeded, err)
+		}
+	}
+
+	if resp == nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return provider.WrapError(provider.ErrAuth, err)
+	case http.StatusNotFound:
+		return provider.WrapError(notFound, err)
+	case http.StatusBadRequest:
+		return provider.WrapError(provider.ErrInvalidSpec, err)
+	}
+
+	return err
+}
@@ -0,0 +1,56 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package ibmcloud
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+)
+
+func TestToProviderError(t *testing.T) {
+	response := func(status int, code string) *core.DetailedResponse {
+		return &core.DetailedResponse{
+			StatusCode: status,
+			Result: map[string]interface{}{
+				"errors": []interface{}{map[string]interface{}{"code": code}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		resp     *core.DetailedResponse
+		notFound error
+		want     error
+	}{
+		{"quota", response(http.StatusBadRequest, "over_quota"), provider.ErrInstanceNotFound, provider.ErrQuotaExceeded},
+		{"capacity", response(http.StatusServiceUnavailable, "insufficient_capacity"), provider.ErrInstanceNotFound, provider.ErrInsufficientCapacity},
+		{"auth", response(http.StatusUnauthorized, "not_authorized"), provider.ErrInstanceNotFound, provider.ErrAuth},
+		{"instance not found", response(http.StatusNotFound, "not_found"), provider.ErrInstanceNotFound, provider.ErrInstanceNotFound},
+		{"image not found", response(http.StatusNotFound, "not_found"), provider.ErrInvalidSpec, provider.ErrInvalidSpec},
+		{"bad request", response(http.StatusBadRequest, "validation_invalid_argument"), provider.ErrInstanceNotFound, provider.ErrInvalidSpec},
+		{"internal", response(http.StatusInternalServerError, "internal_error"), provider.ErrInstanceNotFound, nil},
+		{"no response", nil, provider.ErrInstanceNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sdkErr := errors.New("test")
+			err := toProviderError(sdkErr, tt.resp, tt.notFound)
+			if !errors.Is(err, sdkErr) {
+				t.Errorf("toProviderError() = %v, does not wrap %v", err, sdkErr)
+			}
+			for _, class := range []error{provider.ErrInsufficientCapacity, provider.ErrQuotaExceeded, provider.ErrAuth, provider.ErrInvalidSpec, provider.ErrInstanceNotFound} {
+				if errors.Is(err, class) != (class == tt.want) {
+					t.Errorf("toProviderError() = %v, errors.Is(%v) = %v", err, class, class != tt.want)
+				}
+			}
+		})
+	}
+}
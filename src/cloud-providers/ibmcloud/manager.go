@@ -34,6 +34,8 @@ func (*Manager) ParseCmd(flags *flag.FlagSet) {
 	reg.StringWithEnv(&ibmcloudVPCConfig.ClusterID, "cluster-id", "", "IBMCLOUD_CLUSTER_ID", "Cluster ID")
 
 	reg.BoolWithEnv(&ibmcloudVPCConfig.DisableCVM, "disable-cvm", true, "DISABLECVM", "Use non-CVMs for peer pods")
+	reg.IntWithEnv(&ibmcloudVPCConfig.FallbackMaxAttempts, "fallback-max-attempts", 0, "FALLBACK_MAX_ATTEMPTS", "Maximum number of instance creation attempts when capacity or quota is exhausted (0 tries every fallback)")
+	reg.BoolWithEnv(&ibmcloudVPCConfig.FallbackLargerInstanceProfiles, "fallback-larger-instance-types", false, "FALLBACK_LARGER_INSTANCE_TYPES", "Fall back to larger instance profiles from IBMCLOUD_PODVM_INSTANCE_PROFILE_LIST when capacity or quota is exhausted")
 
 	// Flags without environment variable support (pass empty string for envVarName)
 	reg.StringWithEnv(&ibmcloudVPCConfig.CRTokenFileName, "cr-token-filename", "/var/run/secrets/tokens/vault-token", "", "Projected service account token")
@@ -41,6 +41,7 @@ const maxInstanceNameLen = 63
 type vpcV1 interface {
 	CreateInstanceWithContext(context.Context, *vpcv1.CreateInstanceOptions) (*vpcv1.Instance, *core.DetailedResponse, error)
 	GetInstanceWithContext(context.Context, *vpcv1.GetInstanceOptions) (*vpcv1.Instance, *core.DetailedResponse, error)
+	ListInstancesWithContext(context.Context, *vpcv1.ListInstancesOptions) (*vpcv1.InstanceCollection, *core.DetailedResponse, error)
 	DeleteInstanceWithContext(context.Context, *vpcv1.DeleteInstanceOptions) (*core.DetailedResponse, error)
 	GetInstanceProfileWithContext(context.Context, *vpcv1.GetInstanceProfileOptions) (*vpcv1.InstanceProfile, *core.DetailedResponse, error)
 	GetImageWithContext(ctx context.Context, getImageOptions *vpcv1.GetImageOptions) (*vpcv1.Image, *core.DetailedResponse, error)
@@ -483,7 +484,7 @@ func (p *ibmcloudVPCProvider) CreateInstance(ctx context.Context, podName, sandb
 
 	logger.Printf("CreateInstance: name: %q", instanceName)
 
-	vpcInstance, err := p.createInstanceWithFallback(ctx, prototype)
+	vpcInstance, attempt, err := p.createInstanceWithFallback(ctx, prototype, instanceProfile, spec)
 	if err != nil {
 		return nil, err
 	}
@@ -495,6 +496,12 @@ func (p *ibmcloudVPCProvider) CreateInstance(ctx context.Context, podName, sandb
 	instance = &provider.Instance{
 		ID:   instanceID,
 		Name: instanceName,
+		Type: attempt.InstanceType,
+	}
+
+	// The fallback may have created the instance with another profile
+	if vpcInstance.Profile != nil && vpcInstance.Profile.Name != nil {
+		instance.Type = *vpcInstance.Profile.Name
 	}
 
 	var ips []netip.Addr
@@ -536,42 +543,41 @@ func (p *ibmcloudVPCProvider) CreateInstance(ctx context.Context, podName, sandb
 	return instance, nil
 }
 
-func (p *ibmcloudVPCProvider) createInstanceWithFallback(ctx context.Context, prototype *vpcv1.InstancePrototype) (*vpcv1.Instance, error) {
+// createInstanceWithFallback creates an instance with the selected profile. When capacity or quota is exhausted,
+// it falls back to larger profiles, and then from the selected dedicated host to the dedicated host group.
+func (p *ibmcloudVPCProvider) createInstanceWithFallback(ctx context.Context, prototype *vpcv1.InstancePrototype, instanceProfile string, spec provider.InstanceTypeSpec) (*vpcv1.Instance, provider.CreateAttempt, error) {
 
 	dedicatedHostID := p.serviceConfig.selectedDedicatedHostID
 	dedicatedHostGroupID := p.serviceConfig.selectedDedicatedHostGroupID
 
-	inst, resp, err := p.vpc.CreateInstanceWithContext(ctx, &vpcv1.CreateInstanceOptions{
-		InstancePrototype: prototype,
-	})
-	if err == nil {
-		return inst, nil
+	// Fallback to the dedicated host group if both IDs exist
+	var placements []string
+	if dedicatedHostID != "" && dedicatedHostGroupID != "" {
+		placements = append(placements, dedicatedHostGroupID)
 	}
 
-	// Fallback if both IDs exist
-	if dedicatedHostID != "" && dedicatedHostGroupID != "" {
-		logger.Printf("warning, creation failed on dedicated host %q: %v; retrying on dedicated host group %q", dedicatedHostID, err, dedicatedHostGroupID)
+	policy := provider.FallbackPolicy{
+		MaxAttempts:         p.serviceConfig.FallbackMaxAttempts,
+		LargerInstanceTypes: p.serviceConfig.FallbackLargerInstanceProfiles && spec.InstanceType == "",
+	}
+	attempts := policy.Attempts(instanceProfile, p.serviceConfig.InstanceProfileSpecList, placements)
 
-		prototype.PlacementTarget =
-			&vpcv1.InstancePlacementTargetPrototypeDedicatedHostGroupIdentityDedicatedHostGroupIdentityByID{
-				ID: &dedicatedHostGroupID,
+	return provider.CreateWithFallback(ctx, attempts, func(ctx context.Context, attempt provider.CreateAttempt) (*vpcv1.Instance, error) {
+		prototype.Profile = &vpcv1.InstanceProfileIdentity{Name: &attempt.InstanceType}
+		if attempt.Placement != "" {
+			prototype.PlacementTarget = &vpcv1.InstancePlacementTargetPrototypeDedicatedHostGroupIdentityDedicatedHostGroupIdentityByID{
+				ID: &attempt.Placement,
 			}
+		}
 
-		inst2, resp2, err2 := p.vpc.CreateInstanceWithContext(ctx, &vpcv1.CreateInstanceOptions{
+		inst, resp, err := p.vpc.CreateInstanceWithContext(ctx, &vpcv1.CreateInstanceOptions{
 			InstancePrototype: prototype,
 		})
-		if err2 == nil {
-			return inst2, nil
+		if err != nil {
+			return nil, fmt.Errorf("failed to create an instance: %w and the response is %s", toProviderError(err, resp, provider.ErrInvalidSpec), resp)
 		}
-
-		// Return both errors for context.
-		return nil, errors.Join(
-			fmt.Errorf("instance creation on dedicated host %q failed: %w and the response is %s", dedicatedHostID, err, resp),
-			fmt.Errorf("fallback instance creation on dedicated host group %q failed: %w and the response is %s", dedicatedHostGroupID, err2, resp2),
-		)
-	}
-
-	return nil, fmt.Errorf("failed to create an instance: %w and the response is %s", err, resp)
+		return inst, nil
+	})
 }
 
 // Select an instance profile based on the memory and vcpu requirements
@@ -690,6 +696,10 @@ func (p *ibmcloudVPCProvider) DeleteInstance(ctx context.Context, instanceID str
 	options.SetID(instanceID)
 	resp, err := p.vpc.DeleteInstanceWithContext(ctx, options)
 	if err != nil {
+		if err = toProviderError(err, resp, provider.ErrInstanceNotFound); errors.Is(err, provider.ErrInstanceNotFound) {
+			logger.Printf("instance %s is already deleted", instanceID)
+			return nil
+		}
 		logger.Printf("failed to delete an instance: %v and the response is %v", err, resp)
 		return err
 	}
@@ -709,3 +719,99 @@ func (p *ibmcloudVPCProvider) ConfigVerifier() error {
 	}
 	return nil
 }
+
+func toInstanceStatus(status *string) provider.InstanceStatus {
+	if status == nil {
+		return provider.InstanceStatusUnknown
+	}
+	switch *status {
+	case vpcv1.InstanceStatusPendingConst, vpcv1.InstanceStatusStartingConst, vpcv1.InstanceStatusRestartingConst:
+		return provider.InstanceStatusPending
+	case vpcv1.InstanceStatusRunningConst:
+		return provider.InstanceStatusRunning
+	case vpcv1.InstanceStatusStoppingConst, vpcv1.InstanceStatusStoppedConst:
+		return provider.InstanceStatusStopped
+	case vpcv1.InstanceStatusDeletingConst:
+		return provider.InstanceStatusTerminated
+	}
+	return provider.InstanceStatusUnknown
+}
+
+// toInstanceInfo converts a VPC instance. User tags of VPC instances are managed by the global
+// tagging service and are not part of the instance resource, so no tags are reported.
+func toInstanceInfo(vpcInstance *vpcv1.Instance) *provider.InstanceInfo {
+	info := &provider.InstanceInfo{
+		Instance: provider.Instance{
+			ID:   core.StringNilMapper(vpcInstance.ID),
+			Name: core.StringNilMapper(vpcInstance.Name),
+		},
+		Status: toInstanceStatus(vpcInstance.Status),
+	}
+
+	if vpcInstance.Profile != nil {
+		info.InstanceType = core.StringNilMapper(vpcInstance.Profile.Name)
+	}
+	if vpcInstance.CreatedAt != nil {
+		info.CreatedAt = time.Time(*vpcInstance.CreatedAt)
+	}
+
+	return info
+}
+
+func (p *ibmcloudVPCProvider) GetInstance(ctx context.Context, instanceID string) (*provider.InstanceInfo, error) {
+
+	vpcInstance, resp, err := p.vpc.GetInstanceWithContext(ctx, &vpcv1.GetInstanceOptions{ID: &instanceID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get an instance %s: %w", instanceID, toProviderError(err, resp, provider.ErrInstanceNotFound))
+	}
+
+	info := toInstanceInfo(vpcInstance)
+	if vpcInstance.PrimaryNetworkInterface != nil {
+		if ips, err := getIPs(vpcInstance, instanceID, 0); err == nil {
+			info.IPs = ips
+		}
+	}
+
+	return info, nil
+}
+
+// ListInstances lists instances in the VPC of pod VMs. IP addresses are not reported.
+// Filtering by tags is not supported, since tags are not reported for VPC instances.
+func (p *ibmcloudVPCProvider) ListInstances(ctx context.Context, filter provider.InstanceFilter) ([]*provider.InstanceInfo, error) {
+
+	options := &vpcv1.ListInstancesOptions{}
+	if p.serviceConfig.VpcID != "" {
+		options.SetVPCID(p.serviceConfig.VpcID)
+	}
+	if p.serviceConfig.ResourceGroupID != "" {
+		options.SetResourceGroupID(p.serviceConfig.ResourceGroupID)
+	}
+
+	var instances []*provider.InstanceInfo
+
+	for {
+		result, resp, err := p.vpc.ListInstancesWithContext(ctx, options)
+		if err != nil {
+			logger.Printf("failed to list instances: %v and the response is %v", err, resp)
+			return nil, toProviderError(err, resp, provider.ErrInstanceNotFound)
+		}
+
+		for i := range result.Instances {
+			info := toInstanceInfo(&result.Instances[i])
+			if filter.Match(info) {
+				instances = append(instances, info)
+			}
+		}
+
+		start, err := result.GetNextStart()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the next page of instances: %w", err)
+		}
+		if start == nil {
+			break
+		}
+		options.SetStart(*start)
+	}
+
+	return instances, nil
+}
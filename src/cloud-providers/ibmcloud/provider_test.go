@@ -87,6 +87,26 @@ func (v *mockVPC) GetInstanceWithContext(ctx context.Context, opt *vpcv1.GetInst
 	return instance, nil, nil
 }
 
+func (v *mockVPC) ListInstancesWithContext(ctx context.Context, opt *vpcv1.ListInstancesOptions) (*vpcv1.InstanceCollection, *core.DetailedResponse, error) {
+
+	collection := &vpcv1.InstanceCollection{
+		Instances: []vpcv1.Instance{
+			{
+				ID:      ptr("123"),
+				Name:    ptr("podvm-pod1-999"),
+				Status:  ptr(vpcv1.InstanceStatusRunningConst),
+				Profile: &vpcv1.InstanceProfileReference{Name: ptr("bx2-2x8")},
+			},
+			{
+				ID:     ptr("456"),
+				Name:   ptr("worker-1"),
+				Status: ptr(vpcv1.InstanceStatusRunningConst),
+			},
+		},
+	}
+	return collection, nil, nil
+}
+
 func (v *mockVPC) GetInstanceProfileWithContext(context context.Context, options *vpcv1.GetInstanceProfileOptions) (*vpcv1.InstanceProfile, *core.DetailedResponse, error) {
 	profileType := options.Name
 
@@ -196,6 +216,23 @@ func TestDeleteInstance(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestListInstances(t *testing.T) {
+
+	p := &ibmcloudVPCProvider{
+		vpc:           &mockVPC{},
+		serviceConfig: &Config{},
+		globalTagging: &mockTagging{},
+	}
+
+	instances, err := p.ListInstances(context.Background(), provider.InstanceFilter{NamePrefix: "podvm-"})
+	assert.NoError(t, err)
+	assert.Len(t, instances, 1)
+	assert.Equal(t, "123", instances[0].ID)
+	assert.Equal(t, "podvm-pod1-999", instances[0].Name)
+	assert.Equal(t, provider.InstanceStatusRunning, instances[0].Status)
+	assert.Equal(t, "bx2-2x8", instances[0].InstanceType)
+}
+
 func TestGetInstanceTypeInformation(t *testing.T) {
 	type args struct {
 		instanceType string
@@ -137,6 +137,9 @@ type Config struct {
 	Tags                     tags
 	DedicatedHostIDs         dedicatedHostIDs
 	DedicatedHostGroupIDs    dedicatedHostGroupIDs
+	// Instance creation fallback when capacity or quota is exhausted
+	FallbackMaxAttempts            int
+	FallbackLargerInstanceProfiles bool
 
 	selectedDedicatedHostID      string
 	selectedDedicatedHostGroupID string
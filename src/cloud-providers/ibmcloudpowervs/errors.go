@@ -0,0 +1,46 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package ibmcloudpowervs
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+)
+
+// apiResponseError is implemented by the error responses of the Power VS API client
+type apiResponseError interface {
+	error
+	Code() int
+}
+
+// toProviderError wraps a Power VS API error with the matching provider error class.
+// notFound is the class of a 404 response. It is provider.ErrInstanceNotFound when the
+// request is about an existing instance, and provider.ErrInvalidSpec when an instance is
+// created, since a missing resource is then one referenced by the instance, such as an image.
+func toProviderError(err error, notFound error) error {
+	var respErr apiResponseError
+	if !errors.As(err, &respErr) {
+		return err
+	}
+
+	message := strings.ToLower(respErr.Error())
+
+	switch code := respErr.Code(); {
+	case strings.Contains(message, "insufficient") || strings.Contains(message, "capacity"):
+		return provider.WrapError(provider.ErrInsufficientCapacity, err)
+	case strings.Contains(message, "quota"):
+		return provider.WrapError(provider.ErrQuotaExceeded, err)
+	case code == http.StatusUnauthorized, code == http.StatusForbidden:
+		return provider.WrapError(provider.ErrAuth, err)
+	case code == http.StatusNotFound, code == http.StatusGone:
+		return provider.WrapError(notFound, err)
+	case code == http.StatusBadRequest, code == http.StatusUnprocessableEntity:
+		return provider.WrapError(provider.ErrInvalidSpec, err)
+	}
+
+	return err
+}
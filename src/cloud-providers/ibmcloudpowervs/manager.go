@@ -33,6 +33,8 @@ func (*Manager) ParseCmd(flags *flag.FlagSet) {
 	reg.Float64WithEnv(&ibmcloudPowerVSConfig.Memory, "memory", 2, "POWERVS_MEMORY", "Amount of memory in GB")
 	reg.Float64WithEnv(&ibmcloudPowerVSConfig.Processors, "cpu", 0.5, "POWERVS_PROCESSORS", "Number of processors allocated")
 	reg.BoolWithEnv(&ibmcloudPowerVSConfig.UsePublicIP, "use-public-ip", false, "USE_PUBLIC_IP", "Use Public IP for connecting to the agent-protocol-forwarder inside the Pod VM")
+	reg.IntWithEnv(&ibmcloudPowerVSConfig.FallbackMaxAttempts, "fallback-max-attempts", 0, "FALLBACK_MAX_ATTEMPTS", "Maximum number of instance creation attempts when capacity or quota is exhausted (0 tries every fallback)")
+	reg.CustomTypeWithEnv(&ibmcloudPowerVSConfig.FallbackSystemTypes, "fallback-sys-types", "", "FALLBACK_SYSTEM_TYPES", "Alternate system types to fall back to when capacity or quota is exhausted, comma separated")
 	reg.DurationWithEnv(&ibmcloudPowerVSConfig.BuildTimeout, "build-timeout", 150*time.Second, "POWERVS_BUILD_TIMEOUT", "Maximum timeout to build the VM")
 }
 
@@ -6,6 +6,7 @@ package ibmcloudpowervs
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"net/netip"
@@ -102,15 +103,21 @@ func (p *ibmcloudPowerVSProvider) CreateInstance(ctx context.Context, podName, s
 		Memory:     core.Float64Ptr(memory),
 		Processors: core.Float64Ptr(processors),
 		ProcType:   core.StringPtr(p.serviceConfig.ProcessorType),
-		SysType:    systemType,
 		UserData:   base64.StdEncoding.EncodeToString([]byte(userData)),
 	}
 
 	logger.Printf("CreateInstance: name: %q", instanceName)
 
-	pvsInstances, err := p.powervsService.instanceClient(ctx).Create(body)
+	pvsInstances, attempt, err := provider.CreateWithFallback(ctx, p.createAttempts(systemType, spec), func(ctx context.Context, attempt provider.CreateAttempt) (*models.PVMInstanceList, error) {
+		body.SysType = attempt.InstanceType
+		pvsInstances, err := p.powervsService.instanceClient(ctx).Create(body)
+		if err != nil {
+			logger.Printf("failed to create an instance: %s error: %v", instanceName, err)
+			return nil, toProviderError(err, provider.ErrInvalidSpec)
+		}
+		return pvsInstances, nil
+	})
 	if err != nil {
-		logger.Printf("failed to create an instance: %s error: %v", instanceName, err)
 		return nil, err
 	}
 
@@ -118,6 +125,8 @@ func (p *ibmcloudPowerVSProvider) CreateInstance(ctx context.Context, podName, s
 		return nil, fmt.Errorf("instance is not created: %s", instanceName)
 	}
 
+	instanceType := fmt.Sprintf("%s-%gx%g", attempt.InstanceType, processors, memory)
+
 	ins := (*pvsInstances)[0]
 	instanceID := *ins.PvmInstanceID
 
@@ -162,6 +171,7 @@ func (p *ibmcloudPowerVSProvider) CreateInstance(ctx context.Context, podName, s
 			ID:   instanceID,
 			Name: instanceName,
 			IPs:  make([]netip.Addr, 0),
+			Type: instanceType,
 		}, nil
 
 	}
@@ -175,13 +185,39 @@ func (p *ibmcloudPowerVSProvider) CreateInstance(ctx context.Context, podName, s
 		ID:   instanceID,
 		Name: instanceName,
 		IPs:  ips,
+		Type: instanceType,
 	}, nil
 }
 
+// createAttempts returns the system types to try when capacity or quota is exhausted. The system type
+// is the instance type of the attempt. There is no fallback when the system type is set with an annotation.
+func (p *ibmcloudPowerVSProvider) createAttempts(systemType string, spec provider.InstanceTypeSpec) []provider.CreateAttempt {
+	attempts := []provider.CreateAttempt{{InstanceType: systemType}}
+	if spec.InstanceType != "" && (spec.VCPUs == 0 || spec.Memory == 0) {
+		return attempts
+	}
+
+	for _, t := range p.serviceConfig.FallbackSystemTypes {
+		if t != systemType {
+			attempts = append(attempts, provider.CreateAttempt{InstanceType: t})
+		}
+	}
+
+	if n := p.serviceConfig.FallbackMaxAttempts; n > 0 && len(attempts) > n {
+		attempts = attempts[:n]
+	}
+
+	return attempts
+}
+
 func (p *ibmcloudPowerVSProvider) DeleteInstance(ctx context.Context, instanceID string) error {
 
 	err := p.powervsService.instanceClient(ctx).Delete(instanceID)
 	if err != nil {
+		if err = toProviderError(err, provider.ErrInstanceNotFound); errors.Is(err, provider.ErrInstanceNotFound) {
+			logger.Printf("instance %s is already deleted", instanceID)
+			return nil
+		}
 		logger.Printf("failed to delete an instance: %s error: %v", instanceID, err)
 		return err
 	}
@@ -310,3 +346,75 @@ func (p *ibmcloudPowerVSProvider) getIPFromDHCPServer(ctx context.Context, insta
 
 	return ip, nil
 }
+
+func toInstanceStatus(status *string) provider.InstanceStatus {
+	if status == nil {
+		return provider.InstanceStatusUnknown
+	}
+	switch *status {
+	case "BUILD", "REBOOT":
+		return provider.InstanceStatusPending
+	case "ACTIVE":
+		return provider.InstanceStatusRunning
+	case "SHUTOFF":
+		return provider.InstanceStatusStopped
+	}
+	return provider.InstanceStatusUnknown
+}
+
+func (p *ibmcloudPowerVSProvider) GetInstance(ctx context.Context, instanceID string) (*provider.InstanceInfo, error) {
+
+	ins, err := p.powervsService.instanceClient(ctx).Get(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the instance: %s error: %w", instanceID, toProviderError(err, provider.ErrInstanceNotFound))
+	}
+
+	return p.toInstanceInfo(ins.PvmInstanceID, ins.ServerName, ins.Status, ins.SysType, time.Time(ins.CreationDate), ins.Networks), nil
+}
+
+// ListInstances lists instances in the Power VS service instance. Tags are not reported.
+func (p *ibmcloudPowerVSProvider) ListInstances(ctx context.Context, filter provider.InstanceFilter) ([]*provider.InstanceInfo, error) {
+
+	pvsInstances, err := p.powervsService.instanceClient(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %v", err)
+	}
+
+	var instances []*provider.InstanceInfo
+
+	for _, ins := range pvsInstances.PvmInstances {
+		info := p.toInstanceInfo(ins.PvmInstanceID, ins.ServerName, ins.Status, ins.SysType, time.Time(ins.CreationDate), ins.Networks)
+		if filter.Match(info) {
+			instances = append(instances, info)
+		}
+	}
+
+	return instances, nil
+}
+
+func (p *ibmcloudPowerVSProvider) toInstanceInfo(id, name, status *string, sysType string, createdAt time.Time, networks []*models.PVMInstanceNetwork) *provider.InstanceInfo {
+	info := &provider.InstanceInfo{
+		Instance: provider.Instance{
+			ID:   core.StringNilMapper(id),
+			Name: core.StringNilMapper(name),
+		},
+		Status:       toInstanceStatus(status),
+		InstanceType: sysType,
+		CreatedAt:    createdAt,
+	}
+
+	for _, network := range networks {
+		if network.Type != "fixed" {
+			continue
+		}
+		ipAddress := network.IPAddress
+		if p.serviceConfig.UsePublicIP {
+			ipAddress = network.ExternalIP
+		}
+		if ip, err := netip.ParseAddr(ipAddress); err == nil {
+			info.IPs = append(info.IPs, ip)
+		}
+	}
+
+	return info
+}
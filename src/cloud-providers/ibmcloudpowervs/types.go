@@ -4,11 +4,27 @@
 package ibmcloudpowervs
 
 import (
+	"strings"
 	"time"
 
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util"
 )
 
+type systemTypes []string
+
+func (i *systemTypes) String() string {
+	return strings.Join(*i, ", ")
+}
+
+func (i *systemTypes) Set(value string) error {
+	if len(value) == 0 {
+		*i = make(systemTypes, 0)
+	} else {
+		*i = append(*i, strings.Split(value, ",")...)
+	}
+	return nil
+}
+
 type Config struct {
 	APIKey            string
 	Zone              string
@@ -22,6 +38,9 @@ type Config struct {
 	SystemType        string
 	UsePublicIP       bool
 	BuildTimeout      time.Duration
+
+	FallbackMaxAttempts int
+	FallbackSystemTypes systemTypes
 }
 
 func (c Config) Redact() Config {
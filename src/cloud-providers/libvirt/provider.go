@@ -7,6 +7,7 @@ package libvirt
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/netip"
@@ -14,6 +15,7 @@ import (
 	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util"
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util/cloudinit"
+	"libvirt.org/go/libvirt"
 )
 
 var logger = log.New(log.Writer(), "[adaptor/cloud/libvirt] ", log.LstdFlags|log.Lmsgprefix)
@@ -150,6 +152,11 @@ func (p *libvirtProvider) DeleteInstance(ctx context.Context, instanceID string)
 
 	err := DeleteDomain(ctx, p.libvirtClient, instanceID)
 	if err != nil {
+		var libvirtErr libvirt.Error
+		if errors.As(err, &libvirtErr) && libvirtErr.Code == libvirt.ERR_NO_DOMAIN {
+			logger.Printf("instance %s is already deleted", instanceID)
+			return nil
+		}
 		return fmt.Errorf("failed to delete instance %s: %w", instanceID, err)
 	}
 	logger.Printf("deleted an instance %s", instanceID)
@@ -203,3 +210,85 @@ func (p *libvirtProvider) ConfigVerifier() error {
 
 	return nil
 }
+
+func toInstanceStatus(state libvirt.DomainState) provider.InstanceStatus {
+	switch state {
+	case libvirt.DOMAIN_RUNNING, libvirt.DOMAIN_BLOCKED:
+		return provider.InstanceStatusRunning
+	case libvirt.DOMAIN_PAUSED, libvirt.DOMAIN_SHUTDOWN, libvirt.DOMAIN_SHUTOFF, libvirt.DOMAIN_CRASHED, libvirt.DOMAIN_PMSUSPENDED:
+		return provider.InstanceStatusStopped
+	}
+	return provider.InstanceStatusUnknown
+}
+
+// getInstanceInfo returns information on a domain. Instance IDs of libvirt instances are domain UUIDs.
+func getInstanceInfo(dom *libvirt.Domain) (*provider.InstanceInfo, error) {
+	uuid, err := dom.GetUUIDString()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get domain UUID: %w", err)
+	}
+	name, err := dom.GetName()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get name of domain %s: %w", uuid, err)
+	}
+	state, _, err := dom.GetState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state of domain %s: %w", uuid, err)
+	}
+
+	info := &provider.InstanceInfo{
+		Instance: provider.Instance{
+			ID:   uuid,
+			Name: name,
+		},
+		Status: toInstanceStatus(state),
+	}
+
+	if state == libvirt.DOMAIN_RUNNING {
+		ips, err := getDomainIPs(dom)
+		if err != nil {
+			logger.Printf("failed to get IPs of domain %s: %v", name, err)
+		}
+		info.IPs = ips
+	}
+
+	return info, nil
+}
+
+func (p *libvirtProvider) GetInstance(ctx context.Context, instanceID string) (info *provider.InstanceInfo, err error) {
+	domain, err := p.libvirtClient.connection.LookupDomainByUUIDString(instanceID)
+	if err != nil {
+		var libvirtErr libvirt.Error
+		if errors.As(err, &libvirtErr) && libvirtErr.Code == libvirt.ERR_NO_DOMAIN {
+			return nil, fmt.Errorf("instance %s: %w", instanceID, provider.ErrInstanceNotFound)
+		}
+		return nil, fmt.Errorf("failed to lookup domain by UUID: %w", err)
+	}
+	defer freeDomain(domain, &err)
+
+	return getInstanceInfo(domain)
+}
+
+// ListInstances lists domains of the libvirt connection. Tags are not reported.
+func (p *libvirtProvider) ListInstances(ctx context.Context, filter provider.InstanceFilter) (instances []*provider.InstanceInfo, err error) {
+	domains, err := p.libvirtClient.connection.ListAllDomains(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", err)
+	}
+
+	for i := range domains {
+		defer freeDomain(&domains[i], &err)
+	}
+
+	for i := range domains {
+		info, err := getInstanceInfo(&domains[i])
+		if err != nil {
+			return nil, err
+		}
+		if filter.Match(info) {
+			instances = append(instances, info)
+		}
+	}
+
+	return instances, nil
+}
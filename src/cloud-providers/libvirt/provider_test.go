@@ -184,6 +184,39 @@ func TestTeardown(t *testing.T) {
 	assert.Error(t, err, "connection should be closed after Teardown")
 }
 
+// TestListInstances lists the predefined "test" domain of the libvirt test driver
+func TestListInstances(t *testing.T) {
+	conn, err := libvirt.NewConnect("test:///default")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	p := &libvirtProvider{libvirtClient: &libvirtClient{connection: conn}}
+
+	for _, tc := range []struct {
+		name   string
+		filter provider.InstanceFilter
+		want   []string
+	}{
+		{name: "empty filter", want: []string{"test"}},
+		{name: "name prefix", filter: provider.InstanceFilter{NamePrefix: "te"}, want: []string{"test"}},
+		{name: "other name prefix", filter: provider.InstanceFilter{NamePrefix: "podvm-"}},
+		// Tags are not reported for domains, so a tag filter matches none of them
+		{name: "tags", filter: provider.InstanceFilter{Tags: map[string]string{"cluster": "c1"}}},
+		{name: "name prefix and tags", filter: provider.InstanceFilter{NamePrefix: "te", Tags: map[string]string{"cluster": "c1"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			instances, err := p.ListInstances(context.Background(), tc.filter)
+			require.NoError(t, err)
+
+			var got []string
+			for _, info := range instances {
+				got = append(got, info.Name)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
 func TestDeleteInstanceEmptyID(t *testing.T) {
 	p := &libvirtProvider{
 		serviceConfig: newTestConfig(),
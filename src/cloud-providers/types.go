@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net/netip"
 	"strings"
+	"time"
 
 	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util/cloudinit"
 )
@@ -20,6 +21,71 @@ type Provider interface {
 	ConfigVerifier() error
 }
 
+// InstanceLister is an optional interface implemented by providers that can look up
+// existing pod VM instances. It is meant for reconciliation, garbage collection and debugging.
+type InstanceLister interface {
+	// GetInstance returns an instance by ID. It returns an error wrapping ErrInstanceNotFound if the instance does not exist.
+	GetInstance(ctx context.Context, instanceID string) (*InstanceInfo, error)
+	// ListInstances returns all instances that match the filter. Providers may omit IP addresses
+	// and tags when they cannot be obtained without additional API calls for each instance.
+	ListInstances(ctx context.Context, filter InstanceFilter) ([]*InstanceInfo, error)
+}
+
+// PodIPAssigner is an optional interface implemented by providers that can make the cloud network route
+// pod IP addresses to a pod VM instance, for example as secondary private IP addresses or alias IP ranges.
+// It is required by the routed tunnel type.
+type PodIPAssigner interface {
+	// AssignPodIPs assigns the IP addresses to the primary network interface of an instance.
+	// Assignment fails if an IP address is in use elsewhere, such as on another network interface. Addresses are
+	// never taken away from another instance, which may be a worker node that got them from a CNI plugin.
+	AssignPodIPs(ctx context.Context, instanceID string, ips []netip.Addr) error
+	// UnassignPodIPs removes the IP addresses from the primary network interface of an instance.
+	// IP addresses that are not assigned to the instance are ignored.
+	UnassignPodIPs(ctx context.Context, instanceID string, ips []netip.Addr) error
+}
+
+// InstanceStatus is the lifecycle state of an instance normalized across cloud providers
+type InstanceStatus string
+
+const (
+	InstanceStatusUnknown    InstanceStatus = "unknown"
+	InstanceStatusPending    InstanceStatus = "pending"
+	InstanceStatusRunning    InstanceStatus = "running"
+	InstanceStatusStopped    InstanceStatus = "stopped"
+	InstanceStatusTerminated InstanceStatus = "terminated"
+)
+
+// InstanceInfo describes an existing instance
+type InstanceInfo struct {
+	Instance
+	Status       InstanceStatus
+	InstanceType string
+	Tags         map[string]string
+	// CreatedAt is the creation time of the instance, or zero if the provider does not report it
+	CreatedAt time.Time
+}
+
+// InstanceFilter selects instances to be listed. An empty filter matches all instances visible to the provider.
+type InstanceFilter struct {
+	// NamePrefix selects instances whose name starts with the prefix
+	NamePrefix string
+	// Tags selects instances that have all the tags
+	Tags map[string]string
+}
+
+// Match returns whether an instance matches the filter
+func (f InstanceFilter) Match(info *InstanceInfo) bool {
+	if !strings.HasPrefix(info.Name, f.NamePrefix) {
+		return false
+	}
+	for k, v := range f.Tags {
+		if tv, ok := info.Tags[k]; !ok || tv != v {
+			return false
+		}
+	}
+	return true
+}
+
 // keyValueFlag represents a flag of key-value pairs
 type KeyValueFlag map[string]string
 
@@ -58,6 +124,8 @@ type Instance struct {
 	ID   string
 	Name string
 	IPs  []netip.Addr
+	// Type is the instance type selected by the provider, if known
+	Type string
 }
 
 type InstanceTypeSpec struct {
@@ -114,3 +114,32 @@ func isEqual(a, b KeyValueFlag) bool {
 
 	return true
 }
+
+func TestInstanceFilter_Match(t *testing.T) {
+	info := &InstanceInfo{
+		Instance: Instance{Name: "podvm-nginx-12345678"},
+		Tags:     map[string]string{"cluster": "c1", "env": "test"},
+	}
+
+	tests := []struct {
+		name   string
+		filter InstanceFilter
+		want   bool
+	}{
+		{name: "empty filter", filter: InstanceFilter{}, want: true},
+		{name: "matching prefix", filter: InstanceFilter{NamePrefix: "podvm-"}, want: true},
+		{name: "non-matching prefix", filter: InstanceFilter{NamePrefix: "other-"}, want: false},
+		{name: "matching tags", filter: InstanceFilter{Tags: map[string]string{"cluster": "c1"}}, want: true},
+		{name: "non-matching tag value", filter: InstanceFilter{Tags: map[string]string{"cluster": "c2"}}, want: false},
+		{name: "missing tag", filter: InstanceFilter{Tags: map[string]string{"owner": "me"}}, want: false},
+		{name: "prefix and tags", filter: InstanceFilter{NamePrefix: "podvm-", Tags: map[string]string{"env": "test"}}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(info); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -32,14 +32,14 @@ func VerifyCloudInstanceType(instanceType string, validInstanceTypes []string, d
 	// If instanceTypes is empty and instanceType is not default, return error
 	if len(validInstanceTypes) == 0 && instanceType != defaultInstanceType {
 		// Return error if instanceTypes is empty and instanceType is not default
-		return "", fmt.Errorf("requested instance type (%q) is not default (%q) and supported instance types list is empty",
-			instanceType, defaultInstanceType)
+		return "", fmt.Errorf("requested instance type (%q) is not default (%q) and supported instance types list is empty: %w",
+			instanceType, defaultInstanceType, ErrInvalidSpec)
 
 	}
 
 	// If instanceTypes is not empty and instanceType is not among the supported instance types, return error
 	if len(validInstanceTypes) > 0 && !util.Contains(validInstanceTypes, instanceType) {
-		return "", fmt.Errorf("requested instance type (%q) is not part of supported instance types list", instanceType)
+		return "", fmt.Errorf("requested instance type (%q) is not part of supported instance types list: %w", instanceType, ErrInvalidSpec)
 	}
 
 	return instanceType, nil
@@ -114,7 +114,7 @@ func GetBestFitInstanceType(sortedInstanceTypeSpecList []InstanceTypeSpec, vcpus
 
 	// If binary search fails to find a match, return error
 	if index == len(sortedInstanceTypeSpecList) {
-		return "", fmt.Errorf("no instance type found for the given vcpus (%d) and memory (%d)", vcpus, memory)
+		return "", fmt.Errorf("no instance type found for the given vcpus (%d) and memory (%d): %w", vcpus, memory, ErrInvalidSpec)
 	}
 
 	// If binary search finds a match, return the instance type
@@ -143,7 +143,7 @@ func GetBestFitInstanceTypeWithGPU(sortedInstanceTypeSpecList []InstanceTypeSpec
 	})
 
 	if index == len(sortedInstanceTypeSpecList) {
-		return "", fmt.Errorf("no instance type found for the given GPUs (%d), vCPUs (%d), and memory (%d)", gpus, vcpus, memory)
+		return "", fmt.Errorf("no instance type found for the given GPUs (%d), vCPUs (%d), and memory (%d): %w", gpus, vcpus, memory, ErrInvalidSpec)
 	}
 
 	return sortedInstanceTypeSpecList[index].InstanceType, nil
@@ -168,8 +168,9 @@ type FlagOption func(*flagOptions)
 
 // flagOptions holds optional metadata for a flag.
 type flagOptions struct {
-	required bool
-	secret   bool
+	required        bool
+	secret          bool
+	commandLineOnly bool
 }
 
 // Required marks a flag as required.
@@ -182,6 +183,11 @@ func Secret() FlagOption {
 	return func(o *flagOptions) { o.secret = true }
 }
 
+// CommandLineOnly marks a flag that cannot be set in a configuration file, such as the path of the file itself.
+func CommandLineOnly() FlagOption {
+	return func(o *flagOptions) { o.commandLineOnly = true }
+}
+
 // applyOptions applies functional options and returns the resulting flagOptions.
 func applyOptions(opts []FlagOption) *flagOptions {
 	options := &flagOptions{}
@@ -192,20 +198,29 @@ func applyOptions(opts []FlagOption) *flagOptions {
 }
 
 // FlagRegistrar wraps a FlagSet to provide registration methods with environment variable support.
+// The metadata of registered flags is shared by all registrars of the same FlagSet.
 type FlagRegistrar struct {
-	flags *flag.FlagSet
+	flags    *flag.FlagSet
+	registry *flagRegistry
+	section  string
 }
 
 // NewFlagRegistrar creates a new FlagRegistrar for the given FlagSet.
+// Flags are registered in the provider section of the configuration file.
 func NewFlagRegistrar(flags *flag.FlagSet) *FlagRegistrar {
-	return &FlagRegistrar{flags: flags}
+	return &FlagRegistrar{flags: flags, registry: registryOf(flags), section: ProviderSection}
+}
+
+// SetSection sets the section of the configuration file of the flags that are registered next
+func (r *FlagRegistrar) SetSection(section string) {
+	r.section = section
 }
 
 // StringWithEnv registers a string flag with environment variable support.
-// Optional FlagOption parameters (Required(), Secret()) are metadata-only (used by config-extractor).
+// Optional FlagOption parameters (Required(), Secret()) are recorded for Validate() and Dump().
 // Usage: reg.StringWithEnv(&field, "flag", "", "ENV", "desc", Required(), Secret())
 func (r *FlagRegistrar) StringWithEnv(field *string, flagName, hardcodedDefault, envVarName, usage string, opts ...FlagOption) {
-	_ = applyOptions(opts) // metadata-only, used by config-extractor
+	r.record("string", flagName, envVarName, usage, hardcodedDefault, opts)
 
 	*field = hardcodedDefault
 
@@ -219,9 +234,9 @@ func (r *FlagRegistrar) StringWithEnv(field *string, flagName, hardcodedDefault,
 }
 
 // IntWithEnv registers an int flag with environment variable support.
-// Optional FlagOption parameters (Required(), Secret()) are metadata-only (used by config-extractor).
+// Optional FlagOption parameters (Required(), Secret()) are recorded for Validate() and Dump().
 func (r *FlagRegistrar) IntWithEnv(field *int, flagName string, hardcodedDefault int, envVarName, usage string, opts ...FlagOption) {
-	_ = applyOptions(opts) // metadata-only, used by config-extractor
+	r.record("int", flagName, envVarName, usage, hardcodedDefault, opts)
 
 	*field = hardcodedDefault
 
@@ -238,9 +253,9 @@ func (r *FlagRegistrar) IntWithEnv(field *int, flagName string, hardcodedDefault
 }
 
 // UintWithEnv registers a uint flag with environment variable support.
-// Optional FlagOption parameters (Required(), Secret()) are metadata-only (used by config-extractor).
+// Optional FlagOption parameters (Required(), Secret()) are recorded for Validate() and Dump().
 func (r *FlagRegistrar) UintWithEnv(field *uint, flagName string, hardcodedDefault uint, envVarName, usage string, opts ...FlagOption) {
-	_ = applyOptions(opts) // metadata-only, used by config-extractor
+	r.record("uint", flagName, envVarName, usage, hardcodedDefault, opts)
 
 	*field = hardcodedDefault
 
@@ -256,9 +271,9 @@ func (r *FlagRegistrar) UintWithEnv(field *uint, flagName string, hardcodedDefau
 }
 
 // Uint64WithEnv registers a uint64 flag with environment variable support.
-// Optional FlagOption parameters (Required(), Secret()) are metadata-only (used by config-extractor).
+// Optional FlagOption parameters (Required(), Secret()) are recorded for Validate() and Dump().
 func (r *FlagRegistrar) Uint64WithEnv(field *uint64, flagName string, hardcodedDefault uint64, envVarName, usage string, opts ...FlagOption) {
-	_ = applyOptions(opts) // metadata-only, used by config-extractor
+	r.record("uint64", flagName, envVarName, usage, hardcodedDefault, opts)
 
 	*field = hardcodedDefault
 
@@ -274,9 +289,9 @@ func (r *FlagRegistrar) Uint64WithEnv(field *uint64, flagName string, hardcodedD
 }
 
 // Float64WithEnv registers a float64 flag with environment variable support.
-// Optional FlagOption parameters (Required(), Secret()) are metadata-only (used by config-extractor).
+// Optional FlagOption parameters (Required(), Secret()) are recorded for Validate() and Dump().
 func (r *FlagRegistrar) Float64WithEnv(field *float64, flagName string, hardcodedDefault float64, envVarName, usage string, opts ...FlagOption) {
-	_ = applyOptions(opts) // metadata-only, used by config-extractor
+	r.record("float64", flagName, envVarName, usage, hardcodedDefault, opts)
 
 	*field = hardcodedDefault
 
@@ -293,9 +308,9 @@ func (r *FlagRegistrar) Float64WithEnv(field *float64, flagName string, hardcode
 
 // BoolWithEnv registers a bool flag with environment variable support.
 // Accepts: "1" or "true" (case-insensitive) for true, anything else for false.
-// Optional FlagOption parameters (Required(), Secret()) are metadata-only (used by config-extractor).
+// Optional FlagOption parameters (Required(), Secret()) are recorded for Validate() and Dump().
 func (r *FlagRegistrar) BoolWithEnv(field *bool, flagName string, hardcodedDefault bool, envVarName, usage string, opts ...FlagOption) {
-	_ = applyOptions(opts) // metadata-only, used by config-extractor
+	r.record("bool", flagName, envVarName, usage, hardcodedDefault, opts)
 
 	*field = hardcodedDefault
 
@@ -310,9 +325,9 @@ func (r *FlagRegistrar) BoolWithEnv(field *bool, flagName string, hardcodedDefau
 }
 
 // DurationWithEnv registers a duration flag with environment variable support.
-// Optional FlagOption parameters (Required(), Secret()) are metadata-only (used by config-extractor).
+// Optional FlagOption parameters (Required(), Secret()) are recorded for Validate() and Dump().
 func (r *FlagRegistrar) DurationWithEnv(field *time.Duration, flagName string, hardcodedDefault time.Duration, envVarName, usage string, opts ...FlagOption) {
-	_ = applyOptions(opts) // metadata-only, used by config-extractor
+	r.record("duration", flagName, envVarName, usage, hardcodedDefault, opts)
 
 	*field = hardcodedDefault
 
@@ -329,9 +344,9 @@ func (r *FlagRegistrar) DurationWithEnv(field *time.Duration, flagName string, h
 
 // CustomTypeWithEnv registers a custom flag type (like comma-separated lists or key-value maps) with environment variable support.
 // The field must implement flag.Value interface.
-// Optional FlagOption parameters (Required(), Secret()) are metadata-only (used by config-extractor).
+// Optional FlagOption parameters (Required(), Secret()) are recorded for Validate() and Dump().
 func (r *FlagRegistrar) CustomTypeWithEnv(field flag.Value, flagName, hardcodedDefault, envVarName, usage string, opts ...FlagOption) {
-	_ = applyOptions(opts) // metadata-only, used by config-extractor
+	r.record("custom", flagName, envVarName, usage, hardcodedDefault, opts)
 
 	// Check environment variable first
 	if envVarName != "" {
@@ -0,0 +1,243 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package util
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/proxy"
+)
+
+// ContextDialer dials network connections. *net.Dialer implements it.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// DialerConfig configures a chain of proxies to dial pod VMs through, when the worker node
+// cannot reach the pod VM network directly
+type DialerConfig struct {
+	// Chain is a comma separated list of proxy URLs, in the order they are traversed.
+	// The supported schemes are socks5:// (SOCKS5), http:// (HTTP CONNECT) and ssh:// (SSH jump host).
+	// User information in the URL is used to authenticate with the proxy. Connections are dialed
+	// directly when it is empty.
+	Chain string
+
+	// SSH private key file and host key allowlist directory for ssh:// hops. The allowlist is
+	// required, since the stateless trust on first use of pod VMs cannot authenticate a jump host.
+	SSHPrivateKeyPath      string
+	SSHHostKeyAllowlistDir string
+
+	// Timeout for establishing the connection to the first hop
+	Timeout time.Duration
+}
+
+// NewChainDialer creates a dialer that establishes connections through the proxies of the chain
+func NewChainDialer(config *DialerConfig) (ContextDialer, error) {
+	var dialer ContextDialer = &net.Dialer{Timeout: config.Timeout}
+
+	for _, hop := range strings.Split(config.Chain, ",") {
+		hop = strings.TrimSpace(hop)
+		if hop == "" {
+			continue
+		}
+
+		u, err := url.Parse(hop)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q: %w", hop, err)
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("proxy URL %q has no host", u.Redacted())
+		}
+
+		switch u.Scheme {
+		case "socks5":
+			dialer, err = newSOCKS5Dialer(u, dialer)
+		case "http":
+			dialer = &httpConnectDialer{proxyURL: u, forward: dialer}
+		case "ssh":
+			dialer, err = newSSHJumpDialer(u, config, dialer)
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q in %q", u.Scheme, u.Redacted())
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dialer, nil
+}
+
+func newSOCKS5Dialer(u *url.URL, forward ContextDialer) (ContextDialer, error) {
+	var auth *proxy.Auth
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth = &proxy.Auth{User: u.User.Username(), Password: password}
+	}
+
+	d, err := proxy.SOCKS5("tcp", u.Host, auth, forwardDialer{forward})
+	if err != nil {
+		return nil, fmt.Errorf("creating SOCKS5 dialer for %s: %w", u.Host, err)
+	}
+	return d.(ContextDialer), nil
+}
+
+// forwardDialer adapts a ContextDialer to proxy.Dialer
+type forwardDialer struct {
+	ContextDialer
+}
+
+func (d forwardDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// httpConnectDialer establishes connections with the CONNECT method of an HTTP proxy
+type httpConnectDialer struct {
+	proxyURL *url.URL
+	forward  ContextDialer
+}
+
+func (d *httpConnectDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.forward.DialContext(ctx, "tcp", d.proxyURL.Host)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to HTTP proxy %s: %w", d.proxyURL.Host, err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+	if u := d.proxyURL.User; u != nil {
+		password, _ := u.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("sending CONNECT request to HTTP proxy %s: %w", d.proxyURL.Host, err)
+	}
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("reading CONNECT response of HTTP proxy %s: %w", d.proxyURL.Host, err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("HTTP proxy %s failed to connect to %s: %s", d.proxyURL.Host, address, res.Status)
+	}
+
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn returns data that was read ahead with the proxy response before reading from the connection
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// sshJumpDialer establishes connections through an SSH jump host. Each connection uses its own
+// SSH connection, which is closed along with it.
+type sshJumpDialer struct {
+	host         string
+	clientConfig *ssh.ClientConfig
+	forward      ContextDialer
+}
+
+func newSSHJumpDialer(u *url.URL, config *DialerConfig, forward ContextDialer) (ContextDialer, error) {
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("SSH jump host URL %q has no user name", u.Redacted())
+	}
+	if config.SSHPrivateKeyPath == "" {
+		return nil, fmt.Errorf("SSH jump host %s requires a private key", u.Host)
+	}
+	if config.SSHHostKeyAllowlistDir == "" {
+		return nil, fmt.Errorf("SSH jump host %s requires a host key allowlist directory", u.Host)
+	}
+
+	privateKey, err := ReadAndValidatePrivateKey(config.SSHPrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load private key from %s: %w", config.SSHPrivateKeyPath, err)
+	}
+
+	clientConfig, err := CreateSSHClient(&SSHConfig{
+		PrivateKey:          privateKey,
+		Username:            u.User.Username(),
+		Timeout:             config.Timeout,
+		HostKeyAllowlistDir: config.SSHHostKeyAllowlistDir,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSH client configuration for %s: %w", u.Host, err)
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "22")
+	}
+
+	return &sshJumpDialer{host: host, clientConfig: clientConfig, forward: forward}, nil
+}
+
+func (d *sshJumpDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.forward.DialContext(ctx, "tcp", d.host)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to SSH jump host %s: %w", d.host, err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, d.host, d.clientConfig)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create SSH connection to %s: %w", d.host, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	client := ssh.NewClient(sshConn, chans, reqs)
+
+	tunnel, err := client.DialContext(ctx, network, address)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("SSH jump host %s failed to connect to %s: %w", d.host, address, err)
+	}
+
+	return &sshTunnelConn{Conn: tunnel, client: client}, nil
+}
+
+type sshTunnelConn struct {
+	net.Conn
+	client *ssh.Client
+}
+
+func (c *sshTunnelConn) Close() error {
+	err := c.Conn.Close()
+	c.client.Close()
+	return err
+}
@@ -0,0 +1,381 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package util
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// listen starts a TCP listener that serves each connection with handle
+func listen(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// startEchoServer starts a server that stands in for a pod VM
+func startEchoServer(t *testing.T) string {
+	return listen(t, func(conn net.Conn) {
+		defer conn.Close()
+		_, _ = io.Copy(conn, conn)
+	})
+}
+
+func pipe(a, b net.Conn) {
+	go func() {
+		_, _ = io.Copy(a, b)
+		a.Close()
+	}()
+	_, _ = io.Copy(b, a)
+	b.Close()
+}
+
+// startSOCKS5Proxy starts a SOCKS5 proxy that supports CONNECT without authentication
+func startSOCKS5Proxy(t *testing.T, connects *atomic.Int32) string {
+	return listen(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		// Greeting: version, number of methods, methods
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+			return
+		}
+		if _, err := conn.Write([]byte{5, 0}); err != nil {
+			return
+		}
+
+		// Request: version, command, reserved, address type, address, port
+		req := make([]byte, 4)
+		if _, err := io.ReadFull(conn, req); err != nil || req[1] != 1 {
+			return
+		}
+		var host string
+		switch req[3] {
+		case 1:
+			ip := make([]byte, 4)
+			if _, err := io.ReadFull(conn, ip); err != nil {
+				return
+			}
+			host = net.IP(ip).String()
+		case 3:
+			n := make([]byte, 1)
+			if _, err := io.ReadFull(conn, n); err != nil {
+				return
+			}
+			name := make([]byte, n[0])
+			if _, err := io.ReadFull(conn, name); err != nil {
+				return
+			}
+			host = string(name)
+		default:
+			return
+		}
+		port := make([]byte, 2)
+		if _, err := io.ReadFull(conn, port); err != nil {
+			return
+		}
+
+		target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+		if err != nil {
+			_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+			target.Close()
+			return
+		}
+		connects.Add(1)
+		pipe(conn, target)
+	})
+}
+
+// startHTTPConnectProxy starts an HTTP proxy that supports the CONNECT method
+func startHTTPConnectProxy(t *testing.T, connects *atomic.Int32, wantAuth string) string {
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodConnect {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if r.Header.Get("Proxy-Authorization") != wantAuth {
+				http.Error(w, "proxy authentication required", http.StatusProxyAuthRequired)
+				return
+			}
+			target, err := net.Dial("tcp", r.Host)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				target.Close()
+				return
+			}
+			if _, err := conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+				conn.Close()
+				target.Close()
+				return
+			}
+			connects.Add(1)
+			pipe(conn, target)
+		}),
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { server.Close() })
+
+	return listener.Addr().String()
+}
+
+// startSSHJumpHost starts an SSH server that accepts the public key and forwards direct-tcpip channels.
+// It writes its host key to allowlistDir.
+func startSSHJumpHost(t *testing.T, authorizedKey ssh.PublicKey, allowlistDir string, connects *atomic.Int32) string {
+	hostKey, err := generateTestSSHKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate host key: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(allowlistDir, "jump.pub"), ssh.MarshalAuthorizedKey(hostKey.PublicKey), 0644); err != nil {
+		t.Fatalf("failed to write host key: %v", err)
+	}
+	hostSigner, err := ssh.ParsePrivateKey([]byte(hostKey.PrivateKey))
+	if err != nil {
+		t.Fatalf("failed to parse host key: %v", err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "jump" && ssh.FingerprintSHA256(key) == ssh.FingerprintSHA256(authorizedKey) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unauthorized key for %s", conn.User())
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	return listen(t, func(conn net.Conn) {
+		_, chans, reqs, err := ssh.NewServerConn(conn, config)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+
+		for newChannel := range chans {
+			if newChannel.ChannelType() != "direct-tcpip" {
+				_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+				continue
+			}
+			var payload struct {
+				Host       string
+				Port       uint32
+				OriginHost string
+				OriginPort uint32
+			}
+			if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+				_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+			if err != nil {
+				_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				target.Close()
+				continue
+			}
+			go ssh.DiscardRequests(requests)
+			connects.Add(1)
+			go func() {
+				go func() {
+					_, _ = io.Copy(channel, target)
+					channel.Close()
+				}()
+				_, _ = io.Copy(target, channel)
+				target.Close()
+			}()
+		}
+	})
+}
+
+func assertEcho(t *testing.T, dialer ContextDialer, address string) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		t.Fatalf("DialContext() error = %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if string(buf) != "ping" {
+		t.Errorf("Read() = %q, want %q", buf, "ping")
+	}
+}
+
+func TestNewChainDialer(t *testing.T) {
+	echoAddr := startEchoServer(t)
+
+	keyPair, err := generateTestSSHKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_rsa")
+	if err := os.WriteFile(keyPath, []byte(keyPair.PrivateKey), 0600); err != nil {
+		t.Fatalf("failed to write private key: %v", err)
+	}
+
+	var socksConnects, httpConnects, authHTTPConnects, sshConnects atomic.Int32
+	socksAddr := startSOCKS5Proxy(t, &socksConnects)
+	httpAddr := startHTTPConnectProxy(t, &httpConnects, "")
+	authHTTPAddr := startHTTPConnectProxy(t, &authHTTPConnects, "Basic dXNlcjpwYXNz")
+	allowlistDir := t.TempDir()
+	sshAddr := startSSHJumpHost(t, keyPair.PublicKey, allowlistDir, &sshConnects)
+
+	tests := []struct {
+		name  string
+		chain string
+		want  map[*atomic.Int32]int32
+	}{
+		{
+			name: "direct",
+		},
+		{
+			name:  "SOCKS5",
+			chain: "socks5://" + socksAddr,
+			want:  map[*atomic.Int32]int32{&socksConnects: 1},
+		},
+		{
+			name:  "HTTP CONNECT",
+			chain: "http://" + httpAddr,
+			want:  map[*atomic.Int32]int32{&httpConnects: 1},
+		},
+		{
+			name:  "HTTP CONNECT with authentication",
+			chain: "http://user:pass@" + authHTTPAddr,
+			want:  map[*atomic.Int32]int32{&authHTTPConnects: 1},
+		},
+		{
+			name:  "SSH jump host",
+			chain: "ssh://jump@" + sshAddr,
+			want:  map[*atomic.Int32]int32{&sshConnects: 1},
+		},
+		{
+			name:  "chain of proxies",
+			chain: "ssh://jump@" + sshAddr + ",http://" + httpAddr + ",socks5://" + socksAddr,
+			want:  map[*atomic.Int32]int32{&sshConnects: 1, &httpConnects: 1, &socksConnects: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, c := range []*atomic.Int32{&socksConnects, &httpConnects, &authHTTPConnects, &sshConnects} {
+				c.Store(0)
+			}
+
+			dialer, err := NewChainDialer(&DialerConfig{Chain: tt.chain, SSHPrivateKeyPath: keyPath, SSHHostKeyAllowlistDir: allowlistDir, Timeout: 5 * time.Second})
+			if err != nil {
+				t.Fatalf("NewChainDialer() error = %v", err)
+			}
+
+			assertEcho(t, dialer, echoAddr)
+
+			for c, want := range tt.want {
+				if got := c.Load(); got != want {
+					t.Errorf("proxy connections = %d, want %d", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewChainDialerErrors(t *testing.T) {
+	var connects atomic.Int32
+	authHTTPAddr := startHTTPConnectProxy(t, &connects, "Basic dXNlcjpwYXNz")
+
+	keyPair, err := generateTestSSHKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_rsa")
+	if err := os.WriteFile(keyPath, []byte(keyPair.PrivateKey), 0600); err != nil {
+		t.Fatalf("failed to write private key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		chain   string
+		keyPath string
+	}{
+		{name: "unsupported scheme", chain: "ftp://proxy:21"},
+		{name: "missing host", chain: "socks5://"},
+		{name: "SSH without user", chain: "ssh://bastion"},
+		{name: "SSH without private key", chain: "ssh://jump@bastion"},
+		{name: "SSH without host key allowlist", chain: "ssh://jump@bastion", keyPath: keyPath},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewChainDialer(&DialerConfig{Chain: tt.chain, SSHPrivateKeyPath: tt.keyPath}); err == nil {
+				t.Errorf("NewChainDialer(%q) error = nil, want error", tt.chain)
+			}
+		})
+	}
+
+	t.Run("proxy authentication failure", func(t *testing.T) {
+		dialer, err := NewChainDialer(&DialerConfig{Chain: "http://user:wrong@" + authHTTPAddr})
+		if err != nil {
+			t.Fatalf("NewChainDialer() error = %v", err)
+		}
+		if _, err := dialer.DialContext(context.Background(), "tcp", "127.0.0.1:1"); err == nil {
+			t.Error("DialContext() error = nil, want error")
+		}
+	})
+}
@@ -8,6 +8,12 @@ import (
 const (
 	// PodVMNamePrefix is the prefix of the name of every pod VM instance.
 	PodVMNamePrefix = "podvm"
+	// WarmPoolPodName is used in place of a pod name to generate the names of warm pool instances,
+	// which are created by cloud-api-adaptor before a pod is assigned to them.
+	WarmPoolPodName = "warm-pool"
+	// WarmPoolAnnotation is the annotation of a node with the comma separated IDs of the warm pool instances
+	// of cloud-api-adaptor on the node that are not claimed by a pod. Claimed instances are tracked by PeerPods.
+	WarmPoolAnnotation = "kata.peerpods.io/warm-pool-instances"
 )
 
 func sanitize(input string) string {
@@ -43,3 +49,21 @@ func GenerateInstanceName(podName, sandboxID string, podvmNameMax int) string {
 
 	return instanceName
 }
+
+// IsInstanceNameOfPod returns whether an instance name generated by GenerateInstanceName
+// may belong to a pod. Since pod names may be truncated in instance names, an instance
+// name may match multiple pods whose names share a prefix.
+func IsInstanceNameOfPod(instanceName, podName string) bool {
+
+	rest, found := strings.CutPrefix(instanceName, PodVMNamePrefix+"-")
+	if !found {
+		return false
+	}
+
+	i := strings.LastIndex(rest, "-")
+	if i <= 0 {
+		return false
+	}
+
+	return strings.HasPrefix(sanitize(podName), rest[:i])
+}
@@ -0,0 +1,66 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package util
+
+import "testing"
+
+func TestIsInstanceNameOfPod(t *testing.T) {
+	sandboxID := "4ef7f83bbef5404d0db7915191b739ef535d97a17659a87851f3d33a4da3a936"
+
+	for _, tc := range []struct {
+		name         string
+		instanceName string
+		podName      string
+		want         bool
+	}{
+		{
+			name:         "match",
+			instanceName: GenerateInstanceName("nginx", sandboxID, 63),
+			podName:      "nginx",
+			want:         true,
+		},
+		{
+			name:         "sanitized pod name",
+			instanceName: GenerateInstanceName("Nginx.Web", sandboxID, 63),
+			podName:      "Nginx.Web",
+			want:         true,
+		},
+		{
+			name:         "truncated pod name",
+			instanceName: GenerateInstanceName("nginx-caa-5bddddbf56-7kfvf", sandboxID, 30),
+			podName:      "nginx-caa-5bddddbf56-7kfvf",
+			want:         true,
+		},
+		{
+			name:         "different pod",
+			instanceName: GenerateInstanceName("nginx", sandboxID, 63),
+			podName:      "redis",
+			want:         false,
+		},
+		{
+			name:         "pod name longer than the one in the instance name",
+			instanceName: GenerateInstanceName("nginx", sandboxID, 63),
+			podName:      "nginx-2",
+			want:         true,
+		},
+		{
+			name:         "pod name shorter than the one in the instance name",
+			instanceName: GenerateInstanceName("nginx-2", sandboxID, 63),
+			podName:      "nginx",
+			want:         false,
+		},
+		{
+			name:         "not a pod VM",
+			instanceName: "worker-nginx-4ef7f83b",
+			podName:      "nginx",
+			want:         false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsInstanceNameOfPod(tc.instanceName, tc.podName); got != tc.want {
+				t.Errorf("IsInstanceNameOfPod(%q, %q) = %v, want %v", tc.instanceName, tc.podName, got, tc.want)
+			}
+		})
+	}
+}
@@ -3,22 +3,78 @@ package util
 import (
 	"fmt"
 	"reflect"
+	"strings"
 )
 
 const replacement = "**********"
 
+// Replacement is the value that replaces redacted strings
+const Replacement = replacement
+
+// RedactStruct redacts fields of the struct that struc points to. String fields are replaced by a placeholder
+// and other fields are zeroed. Fields of nested structs are specified as dotted paths, e.g. "TLSConfig.KeyData".
+// Nested structs that are referred to by pointers are copied before they are redacted, so that values shared
+// with the original struct are not modified.
 func RedactStruct(struc interface{}, fields ...string) interface{} {
 	v := reflect.ValueOf(struc).Elem()
 	if v.Type().Kind() != reflect.Struct {
 		panic(fmt.Sprintf("Unsupported type, %v", v.Type().String()))
 	}
 	for _, field := range fields {
-		f := v.FieldByName(field)
-		if f.Kind() == reflect.String {
-			f.SetString(replacement)
-		} else {
-			f.Set(reflect.Zero(v.Type()))
-		}
+		redactField(v, strings.Split(field, "."))
 	}
 	return struc
 }
+
+func redactField(v reflect.Value, path []string) {
+	f := v.FieldByName(path[0])
+	if !f.IsValid() {
+		panic(fmt.Sprintf("Unknown field %s of %v", path[0], v.Type().String()))
+	}
+
+	if len(path) > 1 {
+		if f.Kind() == reflect.Pointer {
+			if f.IsNil() {
+				return
+			}
+			c := reflect.New(f.Type().Elem())
+			c.Elem().Set(f.Elem())
+			f.Set(c)
+			f = c.Elem()
+		}
+		if f.Kind() != reflect.Struct {
+			panic(fmt.Sprintf("Unsupported type of %s, %v", path[0], f.Type().String()))
+		}
+		redactField(f, path[1:])
+		return
+	}
+
+	if f.Kind() == reflect.String {
+		f.SetString(replacement)
+	} else {
+		f.Set(reflect.Zero(f.Type()))
+	}
+}
+
+// RedactString returns a placeholder of a sensitive string, which tells whether the string is empty
+func RedactString(s string) string {
+	if s == "" {
+		return ""
+	}
+	return replacement
+}
+
+// RedactMap returns a copy of a map, in which the values of sensitive keys are replaced by a placeholder
+func RedactMap(m map[string]string, sensitive func(key string) bool) map[string]string {
+	if m == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(m))
+	for key, value := range m {
+		if sensitive(key) {
+			value = RedactString(value)
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
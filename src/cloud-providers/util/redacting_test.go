@@ -0,0 +1,70 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testTLS struct {
+	CertFile string
+	KeyData  []byte
+}
+
+type testConfig struct {
+	Name     string
+	Password string
+	Port     int
+	TLS      *testTLS
+}
+
+func TestRedactStruct(t *testing.T) {
+	tls := &testTLS{CertFile: "/etc/cert.pem", KeyData: []byte("private key")}
+	cfg := testConfig{Name: "test", Password: "secret", Port: 15150, TLS: tls}
+
+	c := cfg
+	redacted := RedactStruct(&c, "Password", "Port", "TLS.KeyData").(*testConfig)
+
+	if redacted.Name != "test" || redacted.Password != replacement || redacted.Port != 0 {
+		t.Errorf("unexpected redacted config: %#v", redacted)
+	}
+	if redacted.TLS.CertFile != "/etc/cert.pem" || redacted.TLS.KeyData != nil {
+		t.Errorf("unexpected redacted TLS config: %#v", redacted.TLS)
+	}
+
+	// The nested struct that the original config refers to is not modified
+	if string(tls.KeyData) != "private key" || cfg.Password != "secret" {
+		t.Errorf("original config has been modified: %#v %#v", cfg, tls)
+	}
+
+	// Nil nested structs are skipped
+	c = testConfig{}
+	RedactStruct(&c, "TLS.KeyData")
+	if c.TLS != nil {
+		t.Errorf("nil TLS config has been set: %#v", c.TLS)
+	}
+}
+
+func TestRedactMap(t *testing.T) {
+	m := map[string]string{"user": "admin", "password": "secret", "token": ""}
+	redacted := RedactMap(m, func(key string) bool {
+		return key == "password" || key == "token"
+	})
+
+	expected := map[string]string{"user": "admin", "password": replacement, "token": ""}
+	if !reflect.DeepEqual(redacted, expected) {
+		t.Errorf("expected %v, got %v", expected, redacted)
+	}
+	if m["password"] != "secret" {
+		t.Errorf("original map has been modified")
+	}
+	if RedactMap(nil, func(string) bool { return true }) != nil {
+		t.Errorf("expected nil")
+	}
+	if s := RedactString("secret"); strings.Contains(s, "secret") {
+		t.Errorf("RedactString returned %s", s)
+	}
+}
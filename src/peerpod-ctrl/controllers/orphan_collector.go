@@ -0,0 +1,273 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util"
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/cloud-api-adaptor/src/peerpod-ctrl/api/v1alpha1"
+)
+
+// Event reasons reported by OrphanCollector
+const (
+	ReasonOrphanedInstance             = "OrphanedInstance"
+	ReasonOrphanedInstanceDeleted      = "OrphanedInstanceDeleted"
+	ReasonOrphanedInstanceDeleteFailed = "OrphanedInstanceDeleteFailed"
+)
+
+// OrphanCollector periodically deletes pod VM instances that are not tracked by any PeerPod object
+// and do not belong to any live pod. Such instances are left behind when cloud-api-adaptor crashes
+// between creating an instance and creating the PeerPod object, or when a PeerPod object is lost.
+//
+// Instances are selected by the pod VM name prefix, and also by cluster tags if ClusterTags is set.
+// When multiple clusters share a cloud account, cluster tags must be set to avoid deleting pod VMs of
+// other clusters. An instance is deleted once it has been observed as orphaned for longer than GracePeriod.
+type OrphanCollector struct {
+	client.Client
+	// APIReader reads pods directly from the API server, so that pods don't need to be cached
+	APIReader client.Reader
+	Recorder  events.EventRecorder
+
+	// CloudProvider is the name of the cloud provider. If empty, CLOUD_PROVIDER from the peer-pods-cm ConfigMap is used.
+	CloudProvider string
+	Interval      time.Duration
+	GracePeriod   time.Duration
+	ClusterTags   map[string]string
+	// DryRun reports orphaned instances without deleting them
+	DryRun bool
+
+	provider      provider.Provider
+	orphanedSince map[string]time.Time
+	now           func() time.Time
+}
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=list
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=list
+//+kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
+
+// SetupWithManager adds the collector to the Manager. The collector runs only on the leader.
+func (c *OrphanCollector) SetupWithManager(mgr ctrl.Manager) error {
+	if c.Interval <= 0 {
+		return fmt.Errorf("invalid orphan collection interval: %v", c.Interval)
+	}
+	return mgr.Add(c)
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable
+func (c *OrphanCollector) NeedLeaderElection() bool {
+	return true
+}
+
+// Start implements manager.Runnable
+func (c *OrphanCollector) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("orphan-collector")
+	ctx = log.IntoContext(ctx, logger)
+
+	logger.Info("starting orphaned instance collector", "interval", c.Interval, "gracePeriod", c.GracePeriod, "clusterTags", c.ClusterTags, "dryRun", c.DryRun)
+
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.sweep(ctx); err != nil {
+			logger.Error(err, "failed to collect orphaned instances")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *OrphanCollector) getLister() (provider.InstanceLister, error) {
+	if c.provider == nil {
+		if err := loadCloudConfigs(c.Client); err != nil {
+			// Cloud configs may also be set as environment variables of the controller
+			log.Log.Info("cannot fetch cloud configs at the moment", "error", err)
+		}
+
+		cloudName := c.CloudProvider
+		if cloudName == "" {
+			cloudName = os.Getenv("CLOUD_PROVIDER")
+		}
+		if cloudName == "" {
+			return nil, fmt.Errorf("cloud provider is not set")
+		}
+
+		p, err := GetProvider(cloudName)
+		if err != nil {
+			return nil, err
+		}
+		c.CloudProvider = cloudName
+		c.provider = p
+	}
+
+	lister, ok := c.provider.(provider.InstanceLister)
+	if !ok {
+		return nil, fmt.Errorf("%s cloud provider does not support listing instances", c.CloudProvider)
+	}
+	return lister, nil
+}
+
+func (c *OrphanCollector) sweep(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+
+	lister, err := c.getLister()
+	if err != nil {
+		return err
+	}
+
+	// The name prefix is always required, so that other instances with the cluster tags are never deleted
+	filter := provider.InstanceFilter{
+		NamePrefix: util.PodVMNamePrefix + "-",
+		Tags:       c.ClusterTags,
+	}
+
+	// List instances first, so that instances created during the sweep are always newer than the PeerPod and pod lists
+	instances, err := lister.ListInstances(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("listing instances: %w", err)
+	}
+
+	ppList := confidentialcontainersorgv1alpha1.PeerPodList{}
+	if err := c.List(ctx, &ppList); err != nil {
+		return fmt.Errorf("listing PeerPods: %w", err)
+	}
+
+	tracked := make(map[string]bool, len(ppList.Items))
+	for _, pp := range ppList.Items {
+		tracked[pp.Spec.InstanceID] = true
+	}
+
+	podList := corev1.PodList{}
+	if err := c.APIReader.List(ctx, &podList); err != nil {
+		return fmt.Errorf("listing pods: %w", err)
+	}
+
+	// Unclaimed warm pool instances are not tracked by PeerPods. cloud-api-adaptor lists them in an annotation
+	// of its node, and deletes them on shutdown and restart. Claimed instances remain listed until their PeerPods
+	// are created, since their names do not match their pods.
+	nodeList := corev1.NodeList{}
+	if err := c.APIReader.List(ctx, &nodeList); err != nil {
+		return fmt.Errorf("listing nodes: %w", err)
+	}
+	warmPool := make(map[string]bool)
+	for _, node := range nodeList.Items {
+		for _, id := range strings.Split(node.Annotations[util.WarmPoolAnnotation], ",") {
+			if id != "" {
+				warmPool[id] = true
+			}
+		}
+	}
+
+	if c.orphanedSince == nil {
+		c.orphanedSince = make(map[string]time.Time)
+	}
+	if c.now == nil {
+		c.now = time.Now
+	}
+	now := c.now()
+
+	orphans := make(map[string]time.Time)
+
+	// authErr is set when a deletion fails because of the credentials, so that further deletions are skipped
+	var authErr error
+
+	for _, instance := range instances {
+		if tracked[instance.ID] || warmPool[instance.ID] || instance.Status == provider.InstanceStatusTerminated || hasLivePod(instance, podList.Items) {
+			continue
+		}
+
+		since, ok := c.orphanedSince[instance.ID]
+		if !ok {
+			since = now
+			logger.Info("found orphaned instance", "InstanceID", instance.ID, "InstanceName", instance.Name)
+		}
+		orphans[instance.ID] = since
+
+		if now.Sub(since) < c.GracePeriod || (!instance.CreatedAt.IsZero() && now.Sub(instance.CreatedAt) < c.GracePeriod) {
+			continue
+		}
+
+		if c.DryRun {
+			logger.Info("orphaned instance would be deleted (dry run)", "InstanceID", instance.ID, "InstanceName", instance.Name)
+			c.event(corev1.EventTypeNormal, ReasonOrphanedInstance, "Orphaned instance %s (%s) would be deleted (dry run)", instance.Name, instance.ID)
+			continue
+		}
+
+		if authErr != nil {
+			continue
+		}
+
+		logger.Info("deleting orphaned instance", "InstanceID", instance.ID, "InstanceName", instance.Name, "CloudProvider", c.CloudProvider)
+		if err := c.provider.DeleteInstance(ctx, instance.ID); err != nil && !errors.Is(err, provider.ErrInstanceNotFound) {
+			logger.Error(err, "failed to delete orphaned instance", "InstanceID", instance.ID)
+			c.event(corev1.EventTypeWarning, ReasonOrphanedInstanceDeleteFailed, "Failed to delete orphaned instance %s (%s): %v", instance.Name, instance.ID, err)
+			if errors.Is(err, provider.ErrAuth) {
+				authErr = err
+			}
+			continue
+		}
+		c.event(corev1.EventTypeNormal, ReasonOrphanedInstanceDeleted, "Deleted orphaned instance %s (%s)", instance.Name, instance.ID)
+		delete(orphans, instance.ID)
+	}
+
+	// Forget instances that are gone or no longer orphaned
+	c.orphanedSince = orphans
+
+	if authErr != nil {
+		return fmt.Errorf("deleting orphaned instances: %w", authErr)
+	}
+
+	return nil
+}
+
+// hasLivePod returns whether an instance may belong to a pod that is not terminated
+func hasLivePod(instance *provider.InstanceInfo, pods []corev1.Pod) bool {
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if util.IsInstanceNameOfPod(instance.Name, pod.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// event records an event on the peer-pods-cm ConfigMap, since orphaned instances have no Kubernetes object
+func (c *OrphanCollector) event(eventtype, reason, messageFmt string, args ...interface{}) {
+	if c.Recorder == nil {
+		return
+	}
+
+	cm := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ppConfigMap,
+			Namespace: os.Getenv("PEERPODS_NAMESPACE"),
+		},
+	}
+
+	c.Recorder.Eventf(cm, nil, eventtype, reason, "DeleteInstance", messageFmt, args...)
+}
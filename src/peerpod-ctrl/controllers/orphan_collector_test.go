@@ -0,0 +1,363 @@
+// (C) Copyright Confidential Containers Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	provider "github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util"
+	"github.com/confidential-containers/cloud-api-adaptor/src/cloud-providers/util/cloudinit"
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/cloud-api-adaptor/src/peerpod-ctrl/api/v1alpha1"
+)
+
+type mockProvider struct {
+	instances []*provider.InstanceInfo
+	filter    provider.InstanceFilter
+	deleted   []string
+	deleteErr error
+}
+
+func (p *mockProvider) CreateInstance(ctx context.Context, podName, sandboxID string, cloudConfig cloudinit.CloudConfigGenerator, spec provider.InstanceTypeSpec) (*provider.Instance, error) {
+	return nil, nil
+}
+
+func (p *mockProvider) DeleteInstance(ctx context.Context, instanceID string) error {
+	p.deleted = append(p.deleted, instanceID)
+	return p.deleteErr
+}
+
+func (p *mockProvider) Teardown() error {
+	return nil
+}
+
+func (p *mockProvider) ConfigVerifier() error {
+	return nil
+}
+
+func (p *mockProvider) GetInstance(ctx context.Context, instanceID string) (*provider.InstanceInfo, error) {
+	return nil, provider.ErrInstanceNotFound
+}
+
+func (p *mockProvider) ListInstances(ctx context.Context, filter provider.InstanceFilter) ([]*provider.InstanceInfo, error) {
+	p.filter = filter
+	var instances []*provider.InstanceInfo
+	for _, instance := range p.instances {
+		if filter.Match(instance) {
+			instances = append(instances, instance)
+		}
+	}
+	return instances, nil
+}
+
+func TestOrphanCollectorSweep(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+
+	instance := func(id, name string) *provider.InstanceInfo {
+		return &provider.InstanceInfo{
+			Instance:  provider.Instance{ID: id, Name: name},
+			Status:    provider.InstanceStatusRunning,
+			CreatedAt: start.Add(-time.Hour),
+		}
+	}
+
+	p := &mockProvider{
+		instances: []*provider.InstanceInfo{
+			instance("i-tracked", "podvm-tracked-12345678"),
+			instance("i-live", "podvm-live-12345678"),
+			instance("i-orphan", "podvm-orphan-12345678"),
+			instance("i-completed", "podvm-completed-12345678"),
+			instance("i-worker", "worker-1"),
+			instance("i-warm", "podvm-warm-pool-12345678"),
+			instance("i-claimed", "podvm-warm-pool-87654321"),
+		},
+	}
+	p.instances = append(p.instances, &provider.InstanceInfo{
+		Instance:  provider.Instance{ID: "i-new", Name: "podvm-new-12345678"},
+		Status:    provider.InstanceStatusPending,
+		CreatedAt: start.Add(10 * time.Minute),
+	})
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := confidentialcontainersorgv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&confidentialcontainersorgv1alpha1.PeerPod{
+			ObjectMeta: metav1.ObjectMeta{Name: "tracked", Namespace: "default"},
+			Spec:       confidentialcontainersorgv1alpha1.PeerPodSpec{CloudProvider: "mock", InstanceID: "i-tracked"},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker-1", Annotations: map[string]string{util.WarmPoolAnnotation: "i-warm,i-other"}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "live", Namespace: "default"},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "completed", Namespace: "default"},
+			Status:     corev1.PodStatus{Phase: corev1.PodSucceeded},
+		},
+	).Build()
+
+	recorder := events.NewFakeRecorder(10)
+
+	c := &OrphanCollector{
+		Client:        cli,
+		APIReader:     cli,
+		Recorder:      recorder,
+		CloudProvider: "mock",
+		Interval:      time.Minute,
+		GracePeriod:   30 * time.Minute,
+		provider:      p,
+		now:           func() time.Time { return now },
+	}
+
+	sweep := func() {
+		t.Helper()
+		if err := c.sweep(context.Background()); err != nil {
+			t.Fatalf("sweep() error = %v", err)
+		}
+	}
+
+	sweep()
+
+	if want := (provider.InstanceFilter{NamePrefix: "podvm-"}); !reflect.DeepEqual(p.filter, want) {
+		t.Errorf("ListInstances() filter = %v, want %v", p.filter, want)
+	}
+	if len(p.deleted) != 0 {
+		t.Errorf("instances deleted before the grace period = %v", p.deleted)
+	}
+
+	now = start.Add(20 * time.Minute)
+	sweep()
+
+	if len(p.deleted) != 0 {
+		t.Errorf("instances deleted before the grace period = %v", p.deleted)
+	}
+
+	now = start.Add(31 * time.Minute)
+	sweep()
+
+	// i-claimed is a warm pool instance that was claimed by a pod whose PeerPod is lost
+	sort.Strings(p.deleted)
+	if want := []string{"i-claimed", "i-completed", "i-orphan"}; !reflect.DeepEqual(p.deleted, want) {
+		t.Errorf("deleted instances = %v, want %v", p.deleted, want)
+	}
+	if len(recorder.Events) != 3 {
+		t.Errorf("number of events = %d, want 3", len(recorder.Events))
+	}
+
+	// i-new was not deleted in the previous sweep, since it was created only 21 minutes before
+	p.deleted = nil
+	now = start.Add(45 * time.Minute)
+	sweep()
+
+	if want := []string{"i-new"}; !reflect.DeepEqual(p.deleted, want) {
+		t.Errorf("deleted instances = %v, want %v", p.deleted, want)
+	}
+}
+
+func TestOrphanCollectorDryRun(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+
+	p := &mockProvider{
+		instances: []*provider.InstanceInfo{
+			{
+				Instance: provider.Instance{ID: "i-orphan", Name: "podvm-orphan-12345678"},
+				Status:   provider.InstanceStatusRunning,
+				Tags:     map[string]string{"cluster": "c1"},
+			},
+			{
+				Instance: provider.Instance{ID: "i-other", Name: "podvm-other-12345678"},
+				Status:   provider.InstanceStatusRunning,
+				Tags:     map[string]string{"cluster": "c2"},
+			},
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := confidentialcontainersorgv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	recorder := events.NewFakeRecorder(10)
+
+	c := &OrphanCollector{
+		Client:        cli,
+		APIReader:     cli,
+		Recorder:      recorder,
+		CloudProvider: "mock",
+		Interval:      time.Minute,
+		GracePeriod:   time.Minute,
+		ClusterTags:   map[string]string{"cluster": "c1"},
+		DryRun:        true,
+		provider:      p,
+		now:           func() time.Time { return now },
+	}
+
+	for _, d := range []time.Duration{0, 2 * time.Minute} {
+		now = start.Add(d)
+		if err := c.sweep(context.Background()); err != nil {
+			t.Fatalf("sweep() error = %v", err)
+		}
+	}
+
+	if want := (provider.InstanceFilter{NamePrefix: "podvm-", Tags: map[string]string{"cluster": "c1"}}); !reflect.DeepEqual(p.filter, want) {
+		t.Errorf("ListInstances() filter = %v, want %v", p.filter, want)
+	}
+	if len(p.deleted) != 0 {
+		t.Errorf("instances deleted in dry run = %v", p.deleted)
+	}
+	if len(recorder.Events) != 1 {
+		t.Fatalf("number of events = %d, want 1", len(recorder.Events))
+	}
+	if e := <-recorder.Events; !strings.Contains(e, ReasonOrphanedInstance) || !strings.Contains(e, "i-orphan") {
+		t.Errorf("event = %q", e)
+	}
+}
+
+func TestOrphanCollectorClusterTags(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+
+	instance := func(id, name, cluster string) *provider.InstanceInfo {
+		return &provider.InstanceInfo{
+			Instance: provider.Instance{ID: id, Name: name},
+			Status:   provider.InstanceStatusRunning,
+			Tags:     map[string]string{"cluster": cluster},
+		}
+	}
+
+	p := &mockProvider{
+		instances: []*provider.InstanceInfo{
+			instance("i-orphan", "podvm-orphan-12345678", "c1"),
+			instance("i-other", "podvm-other-12345678", "c2"),
+			// A worker node or other VM of the cluster may have the cluster tags, but it is not a pod VM
+			instance("i-worker", "worker-1", "c1"),
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := confidentialcontainersorgv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	c := &OrphanCollector{
+		Client:        cli,
+		APIReader:     cli,
+		Recorder:      events.NewFakeRecorder(10),
+		CloudProvider: "mock",
+		Interval:      time.Minute,
+		GracePeriod:   time.Minute,
+		ClusterTags:   map[string]string{"cluster": "c1"},
+		provider:      p,
+		now:           func() time.Time { return now },
+	}
+
+	for _, d := range []time.Duration{0, 2 * time.Minute} {
+		now = start.Add(d)
+		if err := c.sweep(context.Background()); err != nil {
+			t.Fatalf("sweep() error = %v", err)
+		}
+	}
+
+	if want := []string{"i-orphan"}; !reflect.DeepEqual(p.deleted, want) {
+		t.Errorf("deleted instances = %v, want %v", p.deleted, want)
+	}
+}
+
+func TestOrphanCollectorAuthFailure(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+
+	p := &mockProvider{
+		instances: []*provider.InstanceInfo{
+			{
+				Instance: provider.Instance{ID: "i-orphan1", Name: "podvm-orphan1-12345678"},
+				Status:   provider.InstanceStatusRunning,
+			},
+			{
+				Instance: provider.Instance{ID: "i-orphan2", Name: "podvm-orphan2-12345678"},
+				Status:   provider.InstanceStatusRunning,
+			},
+		},
+		deleteErr: fmt.Errorf("terminating instance: %w", provider.ErrAuth),
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := confidentialcontainersorgv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	c := &OrphanCollector{
+		Client:        cli,
+		APIReader:     cli,
+		Recorder:      events.NewFakeRecorder(10),
+		CloudProvider: "mock",
+		Interval:      time.Minute,
+		GracePeriod:   time.Minute,
+		provider:      p,
+		now:           func() time.Time { return now },
+	}
+
+	if err := c.sweep(context.Background()); err != nil {
+		t.Fatalf("sweep() error = %v", err)
+	}
+
+	now = start.Add(2 * time.Minute)
+	if err := c.sweep(context.Background()); !errors.Is(err, provider.ErrAuth) {
+		t.Fatalf("sweep() error = %v, want %v", err, provider.ErrAuth)
+	}
+
+	// Deletion stops at the first authentication failure, but both instances remain orphans
+	if len(p.deleted) != 1 {
+		t.Errorf("deleted instances = %v, want one deletion attempt", p.deleted)
+	}
+	if len(c.orphanedSince) != 2 {
+		t.Errorf("orphaned instances = %v, want 2", c.orphanedSince)
+	}
+
+	// An instance that is already gone counts as deleted
+	p.deleted = nil
+	p.deleteErr = provider.ErrInstanceNotFound
+	if err := c.sweep(context.Background()); err != nil {
+		t.Fatalf("sweep() error = %v", err)
+	}
+	if len(p.deleted) != 2 || len(c.orphanedSince) != 0 {
+		t.Errorf("deleted instances = %v, orphaned instances = %v", p.deleted, c.orphanedSince)
+	}
+}
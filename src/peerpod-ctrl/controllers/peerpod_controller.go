@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -102,16 +103,20 @@ func (r *PeerPodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 
 	if controllerutil.ContainsFinalizer(&pp, ppFinalizer) {
 		logger.Info("deleting instance", "InstanceID", pp.Spec.InstanceID, "CloudProvider", pp.Spec.CloudProvider)
-		provider := r.Providers[pp.Spec.CloudProvider]
-		if provider == nil {
+		cloudProvider := r.Providers[pp.Spec.CloudProvider]
+		if cloudProvider == nil {
 			p, err := GetProvider(pp.Spec.CloudProvider)
 			if err != nil {
 				return ctrl.Result{}, err
 			}
 			r.Providers[pp.Spec.CloudProvider] = p
-			provider = p
+			cloudProvider = p
 		}
-		if err := provider.DeleteInstance(ctx, pp.Spec.InstanceID); err != nil {
+		if err := cloudProvider.DeleteInstance(ctx, pp.Spec.InstanceID); err != nil && !errors.Is(err, provider.ErrInstanceNotFound) {
+			if errors.Is(err, provider.ErrAuth) {
+				// Drop the provider, so that it is created again with the cloud configs reloaded
+				delete(r.Providers, pp.Spec.CloudProvider)
+			}
 			return ctrl.Result{}, err
 		}
 
@@ -136,6 +141,11 @@ func (r *PeerPodReconciler) SetupWithManager(mgr ctrl.Manager) error {
 }
 
 func (r *PeerPodReconciler) cloudConfigsGetter() error {
+	return loadCloudConfigs(r.Client)
+}
+
+// loadCloudConfigs sets values of the peer-pods-cm ConfigMap and the peer-pods-secret Secret as environment variables
+func loadCloudConfigs(c client.Reader) error {
 	peerpodscm := corev1.ConfigMap{}
 	peerpodssecret := corev1.Secret{}
 	ns := os.Getenv("PEERPODS_NAMESPACE")
@@ -144,7 +154,7 @@ func (r *PeerPodReconciler) cloudConfigsGetter() error {
 	}
 
 	var cmErr error
-	if cmErr = r.Get(context.TODO(), types.NamespacedName{Name: ppConfigMap, Namespace: ns}, &peerpodscm); cmErr == nil {
+	if cmErr = c.Get(context.TODO(), types.NamespacedName{Name: ppConfigMap, Namespace: ns}, &peerpodscm); cmErr == nil {
 		// set all configs as env vars to make sure all the required vars for auth are set
 		for k, v := range peerpodscm.Data {
 			os.Setenv(k, v)
@@ -152,7 +162,7 @@ func (r *PeerPodReconciler) cloudConfigsGetter() error {
 	}
 
 	var secretErr error
-	if secretErr = r.Get(context.TODO(), types.NamespacedName{Name: ppSecret, Namespace: ns}, &peerpodssecret); secretErr == nil {
+	if secretErr = c.Get(context.TODO(), types.NamespacedName{Name: ppSecret, Namespace: ns}, &peerpodssecret); secretErr == nil {
 		for k, v := range peerpodssecret.Data {
 			os.Setenv(k, string(v))
 		}
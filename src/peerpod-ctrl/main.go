@@ -19,6 +19,7 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -56,11 +57,24 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var orphanGCInterval time.Duration
+	var orphanGCGracePeriod time.Duration
+	var orphanGCDryRun bool
+	var orphanGCClusterTags provider.KeyValueFlag
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&orphanGCInterval, "orphan-gc-interval", 0,
+		"Interval of sweeps that delete orphaned pod VM instances. Set to 0 to disable the sweeps.")
+	flag.DurationVar(&orphanGCGracePeriod, "orphan-gc-grace-period", 30*time.Minute,
+		"Time an instance must be orphaned before it is deleted.")
+	flag.BoolVar(&orphanGCDryRun, "orphan-gc-dry-run", false,
+		"Report orphaned pod VM instances without deleting them.")
+	flag.Var(&orphanGCClusterTags, "orphan-gc-cluster-tags",
+		"Tags (key1=value1,key2=value2) that identify pod VM instances of this cluster, "+
+			"in addition to the pod VM name prefix.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -111,6 +125,20 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "PeerPod")
 		os.Exit(1)
 	}
+	if orphanGCInterval > 0 {
+		if err = (&controllers.OrphanCollector{
+			Client:      mgr.GetClient(),
+			APIReader:   mgr.GetAPIReader(),
+			Recorder:    mgr.GetEventRecorder("peerpod-ctrl"),
+			Interval:    orphanGCInterval,
+			GracePeriod: orphanGCGracePeriod,
+			ClusterTags: orphanGCClusterTags,
+			DryRun:      orphanGCDryRun,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create orphaned instance collector")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {